@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"net_visualizer/netflow"
+)
+
+// maxReportedAsymmetric caps the number of connections listed by reportAsymmetric
+const maxReportedAsymmetric = 10
+
+// reportAsymmetric lists the first connections observed far more often from one side
+// than from the other, see netflow.EdgeInfo.Asymmetric
+func reportAsymmetric(w io.Writer, model *netflow.Model) {
+	edges := model.AsymmetricEdges()
+	if len(edges) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "INFO: %d connections observed at least %d times more often from one side than from the other (incomplete capture, or unidirectional flows):\n", len(edges), netflow.AsymmetryRatio)
+	for i, e := range edges {
+		if i == maxReportedAsymmetric {
+			fmt.Fprintf(w, "  ... and %d more\n", len(edges)-maxReportedAsymmetric)
+			break
+		}
+		src, dst := model.Nodes[e.Source.PID], model.Nodes[e.Dest.PID]
+		info := model.Edges[e]
+		fmt.Fprintf(w, "  %s:%d (%s) -> %s:%d (%s) fwd=%d rev=%d\n", src.LocalIP, e.Source.Port, src.ProcessName, dst.LocalIP, e.Dest.Port, dst.ProcessName, info.CountBySource, info.CountByDest)
+	}
+}
+
+// directionCountsLabel returns the annotation of the edge labels with DirectionCounts:
+// the observations from each side, flagging the asymmetric ones
+func directionCountsLabel(info *netflow.EdgeInfo) string {
+	ret := fmt.Sprintf(" fwd=%d rev=%d", info.CountBySource, info.CountByDest)
+	if info.Asymmetric() {
+		ret += " (incomplete capture?)"
+	}
+	return ret
+}
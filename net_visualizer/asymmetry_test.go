@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestDirectionCounts(t *testing.T) {
+	// after sampleTrace, the client of tcp-echo on 10.42.0.55 observes its connection 20
+	// more times, the server never again
+	trace := sampleTrace + strings.Repeat("10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat\n", 20)
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+	out := renderDOT(model, RenderOptions{DirectionCounts: true}).String()
+	for _, want := range []string{
+		`label="10.42.0.55:5672->10.42.0.54:5671 fwd=20 rev=1 (incomplete capture?)"`,
+		`label="10.42.0.53:5672->10.42.0.54:5671 fwd=1 rev=1"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+
+	var buf bytes.Buffer
+	reportAsymmetric(&buf, model)
+	want := `INFO: 1 connections observed at least 10 times more often from one side than from the other (incomplete capture, or unidirectional flows):
+  10.42.0.55:5672 (ncat) -> 10.42.0.54:5671 (tcp-echo) fwd=20 rev=1
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got report:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"net_visualizer/netflow"
+)
+
+// defaultClusterLabelTemplate is the -cluster-label-template equivalent of the builtin
+// pod cluster labels
+const defaultClusterLabelTemplate = `{{if .PodName}}{{.Namespace}}/{{.PodName}}{{else if .ContainerID}}{{.ContainerID}}{{else}}{{.IP}}{{end}}`
+
+// clusterLabelData is what a -cluster-label-template is executed with, for every pod
+// cluster
+type clusterLabelData struct {
+	IP           string // IP of the first process of the cluster
+	PodName      string // with -kube; "" for the IPs not resolved to a pod
+	Namespace    string
+	ContainerID  string // as reported by the tracer, if any
+	ProcessCount int    // number of processes drawn inside the cluster
+}
+
+// sampleClusterLabelData is used to check the templates before any input gets consumed
+var sampleClusterLabelData = clusterLabelData{
+	IP: "10.42.0.9", PodName: "web-0", Namespace: "default", ContainerID: "3f2a9c", ProcessCount: 2,
+}
+
+// parseClusterLabelTemplate parses a -cluster-label-template, checking that it only
+// refers to the fields of clusterLabelData
+func parseClusterLabelTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("cluster-label").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cluster label template: %w", err)
+	}
+	if err := tmpl.Execute(new(strings.Builder), sampleClusterLabelData); err != nil {
+		return nil, fmt.Errorf("invalid cluster label template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// templateClusterLabel returns the label of the pod cluster of n, holding count
+// processes, produced by the ClusterLabelTemplate
+func templateClusterLabel(n *netflow.ProcessEndpoints, count int, opts RenderOptions) string {
+	data := clusterLabelData{
+		IP:           n.LocalIP,
+		ContainerID:  n.ContainerID,
+		ProcessCount: count,
+	}
+	if n.Pod != nil {
+		data.PodName, data.Namespace = n.Pod.Name, n.Pod.Namespace
+	}
+	var b strings.Builder
+	if err := opts.ClusterLabelTemplate.Execute(&b, data); err != nil {
+		warnf("cluster label template: %v", err)
+	}
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestParseClusterLabelTemplate(t *testing.T) {
+	for _, text := range []string{"{{.IP", "{{.Bogus}}", "{{.ProcessCount.X}}"} {
+		if _, err := parseClusterLabelTemplate(text); err == nil || !strings.Contains(err.Error(), "invalid cluster label template") {
+			t.Errorf("parseClusterLabelTemplate(%q) = %v, want an invalid template error", text, err)
+		}
+	}
+}
+
+func TestRenderDOTClusterLabelTemplate(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	model.ResolvePods(netflow.StaticPodResolver{
+		"10.42.0.54": {Name: "tcp-echo-5d8f", Namespace: "default"},
+	})
+	tmpl, err := parseClusterLabelTemplate(`{{with .PodName}}{{.}}{{else}}pod {{.IP}}{{end}} ({{.ProcessCount}})`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := renderDOT(model, RenderOptions{GroupByPod: true, ClusterLabelTemplate: tmpl}).String()
+	for _, want := range []string{`label="tcp-echo-5d8f (1)"`, `label="pod 10.42.0.53 (1)"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, out)
+		}
+	}
+	if got := strings.Count(out, "subgraph cluster_"); got != 6 {
+		t.Errorf("got %d pod clusters, want 6:\n%s", got, out)
+	}
+
+	// the processes sharing the IP are counted in the same cluster
+	trace := sampleTrace + `10.42.0.54:8080<-10.42.0.54:40000|PID=100 CMD=sidecar
+10.42.0.54:40000->10.42.0.54:8080|PID=101 CMD=app
+`
+	out = renderDOT(mustBuildModel(t, trace, netflow.ModelOptions{}), RenderOptions{GroupByPod: true, ClusterLabelTemplate: tmpl}).String()
+	if !strings.Contains(out, `label="pod 10.42.0.54 (3)"`) {
+		t.Errorf("the cluster of 10.42.0.54 should hold 3 processes:\n%s", out)
+	}
+}
+
+// TestDefaultClusterLabelTemplate checks that the documented default template produces
+// the builtin labels
+func TestDefaultClusterLabelTemplate(t *testing.T) {
+	tmpl, err := parseClusterLabelTemplate(defaultClusterLabelTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := sampleTrace + `10.42.0.1:80<-10.42.0.2:40000|PID=200 CONTAINER=ctr-client CMD=client
+10.42.0.2:40000->10.42.0.1:80|PID=100 CONTAINER=ctr-app CMD=app
+`
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+	model.ResolvePods(netflow.StaticPodResolver{
+		"10.42.0.54": {Name: "tcp-echo-5d8f", Namespace: "default"},
+	})
+	builtin := renderDOT(model, RenderOptions{GroupByPod: true}).String()
+	if got := renderDOT(model, RenderOptions{GroupByPod: true, ClusterLabelTemplate: tmpl}).String(); got != builtin {
+		t.Errorf("default template output:\n%s\ndiffers from the builtin labels:\n%s", got, builtin)
+	}
+}
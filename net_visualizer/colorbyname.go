@@ -0,0 +1,32 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// namePalette holds the fill colors used by ColorByName: the light qualitative palettes
+// of ColorBrewer ("Set3", "Pastel1" and part of "Pastel2"), without duplicates, all
+// light enough for the black labels to stay readable
+var namePalette = []string{
+	"#8dd3c7", "#ffffb3", "#bebada", "#fb8072", "#80b1d3", "#fdb462",
+	"#b3de69", "#fccde5", "#d9d9d9", "#bc80bd", "#ccebc5", "#ffed6f",
+	"#fbb4ae", "#b3cde3", "#decbe4", "#fed9a6", "#ffffcc", "#e5d8bd",
+	"#fddaec", "#f2f2f2", "#b3e2cd", "#fdcdac", "#cbd5e8", "#f4cae4",
+}
+
+// nameColor returns the fill color of the processes with the given name: a function of
+// the name alone, so that a service gets the same color in every graph. Distinct names
+// may share a color, the palette being finite: the labels still tell them apart
+func nameColor(name string) string {
+	// unlike FNV, whose low bits barely change between similar names, SHA-256 spreads
+	// e.g. api-1 and api-2 over the whole palette
+	sum := sha256.Sum256([]byte(name))
+	return namePalette[binary.BigEndian.Uint32(sum[:4])%uint32(len(namePalette))]
+}
+
+// colorByName fills the node with the color of its process name
+func colorByName(node *dotNode, name string) {
+	node.Attr("style", "filled")
+	node.Attr("fillcolor", nameColor(name))
+}
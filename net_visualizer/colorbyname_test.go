@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestNameColor(t *testing.T) {
+	for _, name := range []string{"ncat", "tcp-echo"} {
+		want := nameColor(name)
+		for range 3 {
+			if got := nameColor(name); got != want {
+				t.Errorf("nameColor(%q) = %s, then %s", name, want, got)
+			}
+		}
+	}
+	// pinned: a change of the hash or of the palette changes the colors of all the graphs
+	if got := nameColor("postgres"); got != "#80b1d3" {
+		t.Errorf("nameColor(postgres) = %s, want #80b1d3", got)
+	}
+	// the palette spreads the names: 100 names must get most of the colors
+	colors := make(map[string]struct{})
+	for i := range 100 {
+		colors[nameColor("svc-"+strings.Repeat("x", i))] = struct{}{}
+	}
+	if len(colors) < len(namePalette)*3/4 {
+		t.Errorf("100 names only got %d colors out of %d", len(colors), len(namePalette))
+	}
+}
+
+func TestRenderDOTColorByName(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	out := renderDOT(model, RenderOptions{ColorByName: true}).String()
+	// the five ncat processes share their color
+	ncat := `fillcolor="` + nameColor("ncat") + `"`
+	if n := strings.Count(out, ncat); n != 5 {
+		t.Errorf("got %d nodes with %s, want the 5 ncat processes:\n%s", n, ncat, out)
+	}
+	if !strings.Contains(out, `fillcolor="`+nameColor("tcp-echo")+`"`) {
+		t.Errorf("tcp-echo is not filled with its color:\n%s", out)
+	}
+	if again := renderDOT(mustBuildModel(t, sampleTrace, netflow.ModelOptions{}), RenderOptions{ColorByName: true}).String(); again != out {
+		t.Errorf("the colors must not change across runs")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// loadConfig reads the -config file: a YAML (or JSON) object mapping the flag names,
+// without the leading dash, to their values, e.g. {"format": "svg", "group-by-pod": true}
+func loadConfig(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err = yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // keep the integers exact
+	var config map[string]any
+	if err := dec.Decode(&config); err != nil {
+		return nil, fmt.Errorf("%s: not a mapping of flag names to values", path)
+	}
+	return config, nil
+}
+
+// applyConfig sets the flags of fs after the config, except the ones given on the
+// command line, which take precedence. The values of the repeatable flags can be
+// lists, setting the flag once per element. All the keys that are not flag names are
+// reported at once.
+func applyConfig(fs *flag.FlagSet, config map[string]any, path string) error {
+	var unknown []string
+	for name := range config {
+		if fs.Lookup(name) == nil || name == "config" {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return fmt.Errorf("%s: unknown flags: %s", path, strings.Join(unknown, ", "))
+	}
+
+	onCommandLine := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		onCommandLine[f.Name] = true
+	})
+	for _, name := range slices.Sorted(maps.Keys(config)) {
+		if onCommandLine[name] {
+			continue
+		}
+		values, ok := config[name].([]any)
+		if !ok {
+			values = []any{config[name]}
+		}
+		for _, v := range values {
+			s, err := configValue(v)
+			if err == nil {
+				err = fs.Set(name, s)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: invalid value for %s: %w", path, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// configValue returns the command-line form of a scalar config value
+func configValue(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		return "", errors.New("want a string, a number, a boolean or a list of them")
+	}
+}
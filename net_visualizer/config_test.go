@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"net_visualizer/netflow"
+)
+
+func TestApplyConfig(t *testing.T) {
+	// a subset of the flags of main
+	newFlags := func(modelOpts *netflow.ModelOptions, renderOpts *RenderOptions, format *string, watch *time.Duration) *flag.FlagSet {
+		fs := flag.NewFlagSet("net_visualizer", flag.ContinueOnError)
+		fs.StringVar(format, "format", "dot", "")
+		fs.DurationVar(watch, "watch", 0, "")
+		fs.BoolVar(&renderOpts.GroupByPod, "group-by-pod", false, "")
+		fs.IntVar(&renderOpts.MaxNameLen, "max-name-len", 120, "")
+		fs.Var(&renderOpts.Styles, "style", "")
+		fs.Var(&modelOpts.Filter.ExcludeProcesses, "exclude-process", "")
+		return fs
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := `# the team defaults
+format: svg
+watch: 5s
+group-by-pod: true
+max-name-len: 40
+style: 'nginx.*=box:blue'
+exclude-process:
+  - k3s-server
+  - coredns
+`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var modelOpts netflow.ModelOptions
+	var renderOpts RenderOptions
+	var format string
+	var watch time.Duration
+	fs := newFlags(&modelOpts, &renderOpts, &format, &watch)
+	// the command line takes precedence over the config
+	if err := fs.Parse([]string{"-format", "png"}); err != nil {
+		t.Fatal(err)
+	}
+	values, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned unexpected error: %v", err)
+	}
+	if err := applyConfig(fs, values, path); err != nil {
+		t.Fatalf("applyConfig returned unexpected error: %v", err)
+	}
+	if format != "png" || watch != 5*time.Second || !renderOpts.GroupByPod || renderOpts.MaxNameLen != 40 {
+		t.Errorf("got -format %s -watch %s -group-by-pod %v -max-name-len %d, want png, 5s, true and 40",
+			format, watch, renderOpts.GroupByPod, renderOpts.MaxNameLen)
+	}
+	if len(renderOpts.Styles) != 1 || renderOpts.Styles[0].Shape != "box" {
+		t.Errorf("Styles = %+v, want the nginx rule", renderOpts.Styles)
+	}
+	if got := modelOpts.Filter.ExcludeProcesses.String(); got != "coredns,k3s-server" {
+		t.Errorf("ExcludeProcesses = %s, want both processes of the list", got)
+	}
+
+	for _, tc := range []struct {
+		config, wantErr string
+	}{
+		{`{"format": "svg", "colour": "red", "grouping": true}`, "unknown flags: colour, grouping"},
+		{`{"config": "other.yaml"}`, "unknown flags: config"},
+		{`{"max-name-len": "forty"}`, "invalid value for max-name-len"},
+		{`{"style": {"nginx": "box"}}`, "invalid value for style"},
+		{`- format`, "not a mapping"},
+	} {
+		if err := os.WriteFile(path, []byte(tc.config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		fs := newFlags(&modelOpts, &renderOpts, &format, &watch)
+		values, err := loadConfig(path)
+		if err == nil {
+			err = applyConfig(fs, values, path)
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("config %s: got error %v, want %q", tc.config, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"net_visualizer/netflow"
+)
+
+// csvHeader lists the columns emitted by -format csv, one row per edge
+var csvHeader = []string{
+	"src_pid", "src_name", "src_ip", "src_port",
+	"dst_pid", "dst_name", "dst_ip", "dst_port",
+	"direction", "count", "first_seen", "last_seen",
+}
+
+// writeCSV serializes the edges of the model as an adjacency list, for spreadsheet analysis.
+// The direction column holds the client/server roles of the endpoints, see netflow.Model.Role;
+// the first_seen and last_seen columns are empty when the input has no timestamps.
+func writeCSV(w io.Writer, model *netflow.Model) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, e := range model.SortedEdges() {
+		src := model.Nodes[e.Source.PID]
+		dst := model.Nodes[e.Dest.PID]
+		err := cw.Write([]string{
+			strconv.FormatInt(src.PID(), 10), src.ProcessName, src.LocalIP, strconv.Itoa(e.Source.Port),
+			strconv.FormatInt(dst.PID(), 10), dst.ProcessName, dst.LocalIP, strconv.Itoa(e.Dest.Port),
+			model.Role(e).String(), strconv.Itoa(model.Edges[e].Count),
+			netflow.FormatTimestamp(model.Edges[e].FirstSeen), netflow.FormatTimestamp(model.Edges[e].LastSeen),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"slices"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestWriteCSV(t *testing.T) {
+	trace := sampleTrace + `10.42.0.54:5671<-10.42.0.60:5690|PID=723999 CMD=nc, the "other" one
+10.42.0.60:5690->10.42.0.54:5671|PID=722977 CMD=tcp-echo
+`
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, model); err != nil {
+		t.Fatalf("writeCSV returned unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"nc, the ""other"" one"`) {
+		t.Errorf("process names with commas and quotes must be quoted:\n%s", buf.String())
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV output does not parse: %v", err)
+	}
+	if len(rows) != 1+len(model.Edges) {
+		t.Fatalf("got %d rows, want a header plus %d edges", len(rows), len(model.Edges))
+	}
+	if !slices.Equal(rows[0], csvHeader) {
+		t.Errorf("header = %v, want %v", rows[0], csvHeader)
+	}
+	want := []string{"723715", "ncat", "10.42.0.56", "5674", "723429", "ncat", "10.42.0.58", "5673", "client->server", "1", "", ""}
+	if !slices.Equal(rows[1], want) {
+		t.Errorf("first row = %v, want %v", rows[1], want)
+	}
+	if got := rows[len(rows)-1][1]; got != `nc, the "other" one` {
+		t.Errorf("last row src_name = %q", got)
+	}
+}
+
+func TestWriteCSVTimestamps(t *testing.T) {
+	trace := `10.42.0.54:5671<-10.42.0.55:5672|PID=723736 TS=2024-05-01T10:00:00Z CMD=ncat
+10.42.0.55:5672->10.42.0.54:5671|PID=722977 TS=2024-05-01T10:00:01.5Z CMD=tcp-echo
+10.42.0.54:5671<-10.42.0.55:5672|PID=723736 TS=2024-05-01T10:02:00Z CMD=ncat
+`
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, mustBuildModel(t, trace, netflow.ModelOptions{})); err != nil {
+		t.Fatalf("writeCSV returned unexpected error: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV output does not parse: %v", err)
+	}
+	if got := rows[1][len(rows[1])-2:]; !slices.Equal(got, []string{"2024-05-01T10:00:01.5Z", "2024-05-01T10:02:00Z"}) {
+		t.Errorf("first_seen, last_seen = %v", got)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// inputCodec describes a compression format supported for the inputs
+type inputCodec struct {
+	name   string
+	suffix string
+	magic  []byte
+	open   func(io.Reader) (io.Reader, error)
+}
+
+var inputCodecs = []inputCodec{
+	{"gzip", ".gz", []byte{0x1f, 0x8b}, func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+	{"bzip2", ".bz2", []byte("BZh"), func(r io.Reader) (io.Reader, error) { return bzip2.NewReader(r), nil }},
+	{"zstd", ".zst", []byte{0x28, 0xb5, 0x2f, 0xfd}, func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) }},
+}
+
+// decompress wraps the input with the decompressor matching its magic bytes, returning
+// the name of the detected codec, or "" for plain inputs. Inputs whose suffix announces
+// a compression that their content does not match are read as plain text, with a warning.
+func decompress(r io.Reader, name string) (io.Reader, string, error) {
+	br := bufio.NewReader(r)
+	for _, c := range inputCodecs {
+		if magic, _ := br.Peek(len(c.magic)); bytes.Equal(magic, c.magic) {
+			dr, err := c.open(br)
+			return dr, c.name, err
+		}
+	}
+	for _, c := range inputCodecs {
+		if strings.HasSuffix(name, c.suffix) {
+			warnf("%s does not look %s compressed, reading it as plain text", name, c.name)
+		}
+	}
+	return br, "", nil
+}
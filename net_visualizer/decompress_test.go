@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	for _, tc := range []struct {
+		path  string
+		codec string
+	}{
+		{"testdata/sample.trace.gz", "gzip"},
+		{"testdata/sample.trace.bz2", "bzip2"},
+		{"testdata/sample.trace.zst", "zstd"},
+	} {
+		f, err := os.Open(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		r, codec, err := decompress(f, tc.path)
+		if err != nil {
+			t.Fatalf("decompress(%s) returned unexpected error: %v", tc.path, err)
+		}
+		if codec != tc.codec {
+			t.Errorf("decompress(%s) detected %q, want %q", tc.path, codec, tc.codec)
+		}
+		if got, err := io.ReadAll(r); err != nil || string(got) != sampleTrace {
+			t.Errorf("decompress(%s) = %q, %v; want the sample trace", tc.path, got, err)
+		}
+	}
+}
+
+func TestDecompressPlain(t *testing.T) {
+	// plain inputs, and inputs with a misleading suffix, are read as-is
+	for _, name := range []string{"stdin", "capture.trace.zst"} {
+		r, codec, err := decompress(strings.NewReader(sampleTrace), name)
+		if err != nil || codec != "" {
+			t.Errorf("decompress(%s) = %q, %v; want a plain input", name, codec, err)
+		}
+		if got, _ := io.ReadAll(r); string(got) != sampleTrace {
+			t.Errorf("decompress(%s) altered the input: %q", name, got)
+		}
+	}
+
+	// a truncated header is an error
+	if _, _, err := decompress(strings.NewReader("\x1f\x8b"), "stdin"); err == nil {
+		t.Errorf("decompress of a truncated gzip header expected an error")
+	}
+}
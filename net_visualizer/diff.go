@@ -0,0 +1,216 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// diffNode identifies a process across two -format json snapshots: PIDs change when the
+// processes restart, so the identity is the process name and IP
+type diffNode struct {
+	Name, IP string
+}
+
+func (n diffNode) String() string {
+	return fmt.Sprintf("%s (%s)", n.Name, n.IP)
+}
+
+// diffEdge identifies a connection across two snapshots: the client port is ephemeral,
+// so the identity is made of the two processes and of the destination port
+type diffEdge struct {
+	Source, Dest diffNode
+	DstPort      int
+}
+
+func (e diffEdge) String() string {
+	return fmt.Sprintf("%s -> %s:%d", e.Source, e.Dest, e.DstPort)
+}
+
+// topologyDiff is what changed between two snapshots; the slices are sorted
+type topologyDiff struct {
+	AddedNodes, RemovedNodes, CommonNodes []diffNode
+	AddedEdges, RemovedEdges, CommonEdges []diffEdge
+}
+
+// Empty tells whether the snapshots have the same processes and connections
+func (d topologyDiff) Empty() bool {
+	return len(d.AddedNodes)+len(d.RemovedNodes)+len(d.AddedEdges)+len(d.RemovedEdges) == 0
+}
+
+// readJSONDoc loads a snapshot written by -format json
+func readJSONDoc(path string) (jsonDoc, error) {
+	var doc jsonDoc
+	f, err := os.Open(path)
+	if err != nil {
+		return doc, err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(&doc); err != nil {
+		return doc, fmt.Errorf("%s: not a -format json snapshot: %w", path, err)
+	}
+	return doc, nil
+}
+
+// snapshotSets returns the identities of the processes and connections of a snapshot;
+// the processes sharing a name and an IP, e.g. forks, become a single one
+func snapshotSets(doc jsonDoc) (map[diffNode]struct{}, map[diffEdge]struct{}, error) {
+	nodes := make(map[diffNode]struct{}, len(doc.Nodes))
+	byID := make(map[string]diffNode, len(doc.Nodes))
+	for _, n := range doc.Nodes {
+		node := diffNode{Name: n.Name, IP: n.IP}
+		nodes[node] = struct{}{}
+		byID[n.ID] = node
+	}
+	edges := make(map[diffEdge]struct{}, len(doc.Edges))
+	for _, e := range doc.Edges {
+		src, ok1 := byID[e.Source]
+		dst, ok2 := byID[e.Target]
+		if !ok1 || !ok2 {
+			return nil, nil, fmt.Errorf("edge %s->%s refers to an unknown node", e.Source, e.Target)
+		}
+		edges[diffEdge{Source: src, Dest: dst, DstPort: e.DstPort}] = struct{}{}
+	}
+	return nodes, edges, nil
+}
+
+// diffSets splits the union of before and after into the keys only in after, only in
+// before, and in both, each sorted with compare
+func diffSets[K comparable](before, after map[K]struct{}, compare func(a, b K) int) (added, removed, common []K) {
+	for k := range after {
+		if _, ok := before[k]; ok {
+			common = append(common, k)
+		} else {
+			added = append(added, k)
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+	slices.SortFunc(added, compare)
+	slices.SortFunc(removed, compare)
+	slices.SortFunc(common, compare)
+	return added, removed, common
+}
+
+func compareDiffNodes(a, b diffNode) int {
+	return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.IP, b.IP))
+}
+
+func compareDiffEdges(a, b diffEdge) int {
+	return cmp.Or(compareDiffNodes(a.Source, b.Source), compareDiffNodes(a.Dest, b.Dest), cmp.Compare(a.DstPort, b.DstPort))
+}
+
+// diffSnapshots compares two -format json snapshots
+func diffSnapshots(before, after jsonDoc) (topologyDiff, error) {
+	var d topologyDiff
+	oldNodes, oldEdges, err := snapshotSets(before)
+	if err != nil {
+		return d, fmt.Errorf("old snapshot: %w", err)
+	}
+	newNodes, newEdges, err := snapshotSets(after)
+	if err != nil {
+		return d, fmt.Errorf("new snapshot: %w", err)
+	}
+	d.AddedNodes, d.RemovedNodes, d.CommonNodes = diffSets(oldNodes, newNodes, compareDiffNodes)
+	d.AddedEdges, d.RemovedEdges, d.CommonEdges = diffSets(oldEdges, newEdges, compareDiffEdges)
+	return d, nil
+}
+
+// Print writes the summary of the changes, as printed by -diff
+func (d topologyDiff) Print(w io.Writer) {
+	if d.Empty() {
+		fmt.Fprintln(w, "No changes")
+		return
+	}
+	printSection := func(title, sign string, items []fmt.Stringer) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s (%d):\n", title, len(items))
+		for _, item := range items {
+			fmt.Fprintf(w, "  %s %s\n", sign, item)
+		}
+	}
+	printSection("Added processes", "+", stringers(d.AddedNodes))
+	printSection("Removed processes", "-", stringers(d.RemovedNodes))
+	printSection("Added connections", "+", stringers(d.AddedEdges))
+	printSection("Removed connections", "-", stringers(d.RemovedEdges))
+}
+
+func stringers[T fmt.Stringer](items []T) []fmt.Stringer {
+	ret := make([]fmt.Stringer, len(items))
+	for i, item := range items {
+		ret[i] = item
+	}
+	return ret
+}
+
+// the colors of the -diff graph
+const (
+	diffAddedColor   = "green"
+	diffRemovedColor = "red"
+)
+
+// renderDiffDOT draws the union of the two snapshots, the additions in green and the
+// removals in red
+func renderDiffDOT(d topologyDiff) *dotGraph {
+	graph := newDOTGraph()
+	dotNodes := make(map[diffNode]*dotNode)
+	addNodes := func(nodes []diffNode, color string) {
+		for _, n := range nodes {
+			node := graph.Node("n" + strconv.Itoa(len(dotNodes)+1)).Label(dotString(n.Name + "\nIP=" + n.IP))
+			if color != "" {
+				node.Attr("color", color).Attr("fontcolor", color)
+			}
+			dotNodes[n] = node
+		}
+	}
+	addNodes(d.CommonNodes, "")
+	addNodes(d.AddedNodes, diffAddedColor)
+	addNodes(d.RemovedNodes, diffRemovedColor)
+
+	addEdges := func(edges []diffEdge, color string) {
+		for _, e := range edges {
+			edge := dotNodes[e.Source].Edge(dotNodes[e.Dest], strconv.Itoa(e.DstPort))
+			if color != "" {
+				edge.Attr("color", color).Attr("fontcolor", color)
+			}
+		}
+	}
+	addEdges(d.CommonEdges, "")
+	addEdges(d.AddedEdges, diffAddedColor)
+	addEdges(d.RemovedEdges, diffRemovedColor)
+	return graph
+}
+
+// runDiff implements -diff: the summary of the changes goes to stdout, and the graph of
+// the changes to output, if not empty
+func runDiff(oldPath, newPath, output string) error {
+	before, err := readJSONDoc(oldPath)
+	if err != nil {
+		return err
+	}
+	after, err := readJSONDoc(newPath)
+	if err != nil {
+		return err
+	}
+	d, err := diffSnapshots(before, after)
+	if err != nil {
+		return err
+	}
+	d.Print(os.Stdout)
+	if output == "" {
+		return nil
+	}
+	return writeFileAtomic(output, func(w io.Writer) error {
+		_, err := io.WriteString(w, renderDiffDOT(d).String())
+		return err
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+// snapshot returns the -format json snapshot of a trace, as read back by -diff
+func snapshot(t *testing.T, trace string) jsonDoc {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, mustBuildModel(t, trace, netflow.ModelOptions{})); err != nil {
+		t.Fatal(err)
+	}
+	var doc jsonDoc
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	before := snapshot(t, sampleTrace)
+
+	// the same topology, with restarted processes
+	restarted := strings.NewReplacer("PID=722977", "PID=1722977", "PID=723429", "PID=1723429").Replace(sampleTrace)
+	d, err := diffSnapshots(before, snapshot(t, restarted))
+	if err != nil {
+		t.Fatalf("diffSnapshots returned unexpected error: %v", err)
+	}
+	if !d.Empty() {
+		t.Errorf("got %+v, want no changes", d)
+	}
+	var buf bytes.Buffer
+	d.Print(&buf)
+	if buf.String() != "No changes\n" {
+		t.Errorf("Print = %q", buf.String())
+	}
+
+	// a new client of tcp-echo
+	added := sampleTrace + `10.42.0.54:5671<-10.42.0.60:5690|PID=723999 CMD=curl
+10.42.0.60:5690->10.42.0.54:5671|PID=722977 CMD=tcp-echo
+`
+	d, err = diffSnapshots(before, snapshot(t, added))
+	if err != nil {
+		t.Fatalf("diffSnapshots returned unexpected error: %v", err)
+	}
+	curl := diffNode{Name: "curl", IP: "10.42.0.60"}
+	echo := diffNode{Name: "tcp-echo", IP: "10.42.0.54"}
+	if !slices.Equal(d.AddedNodes, []diffNode{curl}) || len(d.RemovedNodes) != 0 {
+		t.Errorf("got added %v and removed %v processes, want curl added", d.AddedNodes, d.RemovedNodes)
+	}
+	if want := []diffEdge{{Source: curl, Dest: echo, DstPort: 5671}}; !slices.Equal(d.AddedEdges, want) || len(d.RemovedEdges) != 0 {
+		t.Errorf("got added %v and removed %v connections, want %v added", d.AddedEdges, d.RemovedEdges, want)
+	}
+
+	// the other way around, the connection and its client are removed
+	d, err = diffSnapshots(snapshot(t, added), before)
+	if err != nil {
+		t.Fatalf("diffSnapshots returned unexpected error: %v", err)
+	}
+	if len(d.AddedEdges) != 0 || len(d.RemovedEdges) != 1 || d.RemovedEdges[0].Source != curl {
+		t.Errorf("got added %v and removed %v connections, want the curl one removed", d.AddedEdges, d.RemovedEdges)
+	}
+	buf.Reset()
+	d.Print(&buf)
+	want := `Removed processes (1):
+  - curl (10.42.0.60)
+Removed connections (1):
+  - curl (10.42.0.60) -> tcp-echo (10.42.0.54):5671
+`
+	if buf.String() != want {
+		t.Errorf("Print = %q, want %q", buf.String(), want)
+	}
+
+	out := renderDiffDOT(d).String()
+	for _, want := range []string{
+		`[color="red",fontcolor="red",label="curl\nIP=10.42.0.60"]`,
+		`[color="red",fontcolor="red",label="5671"]`,
+		`[label="tcp-echo\nIP=10.42.0.54"]`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunDiff(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.json")
+	if err := os.WriteFile(oldPath, []byte(`{"nodes": [], "edges": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runDiff(oldPath, filepath.Join(dir, "missing.json"), ""); err == nil {
+		t.Error("runDiff accepted a missing snapshot")
+	}
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"nodes": [], "edges": [{"source": "n1", "target": "n2"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runDiff(oldPath, bad, ""); err == nil || !strings.Contains(err.Error(), "unknown node") {
+		t.Errorf("runDiff returned %v, want an error about the unknown nodes", err)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// dotString makes s safe to be quoted by dotGraph, that relies on the Go %q verb.
+// Graphviz only understands the \" \\ and \n escapes produced by %q: the non-printable
+// runes, that %q escapes as \t, \u00a0, ..., are replaced by '?' instead of being drawn
+// as such. A trailing backslash, that Graphviz would take as escaping the closing quote,
+// gets followed by a space.
+func dotString(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r != '\n' && !unicode.IsPrint(r) {
+			return '?'
+		}
+		return r
+	}, strings.ToValidUTF8(s, "?"))
+	if strings.HasSuffix(s, `\`) {
+		s += " "
+	}
+	return s
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+// graphvizStrings lexes the quoted strings of the DOT output the way Graphviz does: only
+// \" is an escape, every other backslash is kept as-is. It fails the test if a string
+// is not terminated or if the braces do not balance.
+func graphvizStrings(t *testing.T, out string) []string {
+	t.Helper()
+	var ret []string
+	depth := 0
+	for i := 0; i < len(out); i++ {
+		switch out[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '"':
+			var s strings.Builder
+			for i++; ; i++ {
+				if i >= len(out) {
+					t.Fatalf("unterminated string in DOT output:\n%s", out)
+				}
+				if out[i] == '\\' && i+1 < len(out) && out[i+1] == '"' {
+					s.WriteByte('"')
+					i++
+					continue
+				}
+				if out[i] == '"' {
+					break
+				}
+				s.WriteByte(out[i])
+			}
+			ret = append(ret, s.String())
+		}
+	}
+	if depth != 0 {
+		t.Fatalf("unbalanced braces in DOT output:\n%s", out)
+	}
+	return ret
+}
+
+// graphvizLabel applies the escapes that Graphviz interprets in labels and tooltips
+func graphvizLabel(s string) string {
+	var ret strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			ret.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'l', 'r':
+			ret.WriteByte('\n')
+		default:
+			ret.WriteByte(s[i])
+		}
+	}
+	return ret.String()
+}
+
+func TestDotString(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{`say "hi" <b>`, `say "hi" <b>`},
+		{"two\nlines", "two\nlines"},
+		{"tab\there\u00a0\u200b", "tab?here??"},
+		{"bad \xff utf8", "bad ? utf8"},
+		{`C:\dir\`, `C:\dir\ `},
+	} {
+		if got := dotString(tc.in); got != tc.want {
+			t.Errorf("dotString(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRenderDOTRoundTripsSpecialCharacters(t *testing.T) {
+	model := mustBuildModel(t, "10.0.0.2:40000->10.0.0.1:8080|PID=100 CMD=server\n", netflow.ModelOptions{})
+	// the parser sanitizes the names, but other labels, e.g. pods, do not go through it
+	name := `say "hi" <b>` + "\n\t\u00a0" + `C:\dir\`
+	model.Nodes[100].ProcessName = name
+
+	out := renderDOT(model, RenderOptions{NoDegree: true, ShowExternal: true}).String()
+	want := dotString("PID=100\nName=" + name + "\nIP=10.0.0.1\nListen=8080")
+	if labels := graphvizStrings(t, out); !slices.ContainsFunc(labels, func(s string) bool { return graphvizLabel(s) == want }) {
+		t.Errorf("no label reads %q in DOT output:\n%s", want, out)
+	}
+
+	// the tooltip ends with the name of the input, here with a backslash
+	out = renderDOT(model, RenderOptions{ColorBySource: true, SourceNames: []string{name}}).String()
+	if labels := graphvizStrings(t, out); !slices.ContainsFunc(labels, func(s string) bool { return strings.HasSuffix(graphvizLabel(s), dotString(name)) }) {
+		t.Errorf("no tooltip ends with %q in DOT output:\n%s", dotString(name), out)
+	}
+}
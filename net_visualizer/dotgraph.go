@@ -0,0 +1,225 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// dotGraph is a directed DOT graph, or one of its clusters, whose nodes are written with
+// the ID they are created with: the nodes of the processes are n<PID>, see processNodeID,
+// so that the ID of a process stays the same when the rest of the input changes, e.g. to
+// compare two graphs or to refer to a node from a script. The statements are sorted by
+// ID, so that the output is byte-identical across runs.
+type dotGraph struct {
+	id        string // empty for the root graph
+	attrs     dotAttrs
+	parent    *dotGraph
+	clusters  map[string]*dotGraph  // by label
+	nodes     map[string]*dotNode   // by ID
+	edgesFrom map[string][]*dotEdge // by ID of their source node
+	seq       int                   // of the clusters, kept by the root graph
+}
+
+// dotNode is a node of a dotGraph
+type dotNode struct {
+	id    string
+	attrs dotAttrs
+	graph *dotGraph
+}
+
+// dotEdge is an edge of a dotGraph
+type dotEdge struct {
+	from, to *dotNode
+	attrs    dotAttrs
+}
+
+// dotAttrs maps the names of the attributes to their values, as written in the DOT source
+type dotAttrs map[string]string
+
+func newDOTGraph() *dotGraph {
+	return &dotGraph{
+		attrs:     make(dotAttrs),
+		clusters:  make(map[string]*dotGraph),
+		nodes:     make(map[string]*dotNode),
+		edgesFrom: make(map[string][]*dotEdge),
+	}
+}
+
+// processNodeID returns the stable ID of the node of a process: n<PID>, also for the
+// negative keys of the aliased PIDs and of the CIDR groups
+func processNodeID(pid int64) string {
+	return "n" + strconv.FormatInt(pid, 10)
+}
+
+// Attr sets an attribute of the graph
+func (g *dotGraph) Attr(key, value string) *dotGraph {
+	g.attrs[key] = strconv.Quote(value)
+	return g
+}
+
+// Cluster returns the cluster of the graph with this label, creating it if absent
+func (g *dotGraph) Cluster(label string) *dotGraph {
+	if sub, ok := g.clusters[label]; ok {
+		return sub
+	}
+	root := g.root()
+	root.seq++
+	sub := newDOTGraph()
+	sub.id = "cluster_s" + strconv.Itoa(root.seq)
+	sub.parent = g
+	sub.Attr("label", label)
+	g.clusters[label] = sub
+	return sub
+}
+
+func (g *dotGraph) root() *dotGraph {
+	for g.parent != nil {
+		g = g.parent
+	}
+	return g
+}
+
+// Node returns the node with this ID in the graph or in its parents, creating it in the
+// graph if absent, labeled with its ID
+func (g *dotGraph) Node(id string) *dotNode {
+	for p := g; p != nil; p = p.parent {
+		if n, ok := p.nodes[id]; ok {
+			return n
+		}
+	}
+	n := &dotNode{id: id, attrs: make(dotAttrs), graph: g}
+	n.Label(id)
+	g.nodes[id] = n
+	return n
+}
+
+// Attr sets an attribute of the node
+func (n *dotNode) Attr(key, value string) *dotNode {
+	n.attrs[key] = strconv.Quote(value)
+	return n
+}
+
+// Label sets the label of the node
+func (n *dotNode) Label(label string) *dotNode {
+	return n.Attr("label", label)
+}
+
+// HTMLLabel sets the label of the node to an HTML-like label, written as-is
+func (n *dotNode) HTMLLabel(html string) *dotNode {
+	n.attrs["label"] = "<" + html + ">"
+	return n
+}
+
+// Edge adds a labeled edge towards another node, to the graph of both nodes or, when
+// they are in different clusters, to the root graph
+func (n *dotNode) Edge(to *dotNode, label string) *dotEdge {
+	owner := n.graph
+	if to.graph != owner {
+		owner = owner.root()
+	}
+	e := &dotEdge{from: n, to: to, attrs: make(dotAttrs)}
+	e.Attr("label", label)
+	owner.edgesFrom[n.id] = append(owner.edgesFrom[n.id], e)
+	return e
+}
+
+// Attr sets an attribute of the edge
+func (e *dotEdge) Attr(key, value string) *dotEdge {
+	e.attrs[key] = strconv.Quote(value)
+	return e
+}
+
+// Bold draws the edge with a bold line
+func (e *dotEdge) Bold() *dotEdge {
+	return e.Attr("style", "bold")
+}
+
+// Dashed draws the edge with a dashed line
+func (e *dotEdge) Dashed() *dotEdge {
+	return e.Attr("style", "dashed")
+}
+
+// String returns the DOT source of the graph
+func (g *dotGraph) String() string {
+	var w dotWriter
+	g.write(&w)
+	return w.String()
+}
+
+// write writes the statements of the graph: its clusters, its attributes, its nodes and
+// its edges, each group sorted by ID
+func (g *dotGraph) write(w *dotWriter) {
+	if g.parent == nil {
+		w.WriteString("digraph  {")
+	} else {
+		w.WriteString("subgraph " + g.id + " {")
+	}
+	w.level++
+	w.newLine()
+	for _, label := range slices.Sorted(maps.Keys(g.clusters)) {
+		g.clusters[label].write(w)
+	}
+	for _, key := range slices.Sorted(maps.Keys(g.attrs)) {
+		w.WriteString(key + "=" + g.attrs[key] + ";")
+	}
+	w.newLine()
+	for _, id := range slices.Sorted(maps.Keys(g.nodes)) {
+		w.WriteString(dotID(id))
+		g.nodes[id].attrs.write(w)
+		w.WriteString(";")
+		w.newLine()
+	}
+	for _, id := range slices.Sorted(maps.Keys(g.edgesFrom)) {
+		for _, e := range g.edgesFrom[id] {
+			w.WriteString(dotID(e.from.id) + "->" + dotID(e.to.id))
+			e.attrs.write(w)
+			w.WriteString(";")
+			w.newLine()
+		}
+	}
+	w.level--
+	w.newLine()
+	w.WriteString("}")
+	w.newLine()
+}
+
+// write writes the attribute list of a node or an edge, sorted by name
+func (a dotAttrs) write(w *dotWriter) {
+	if len(a) == 0 {
+		return
+	}
+	w.WriteString("[")
+	for i, key := range slices.Sorted(maps.Keys(a)) {
+		if i > 0 {
+			w.WriteString(",")
+		}
+		w.WriteString(key + "=" + a[key])
+	}
+	w.WriteString("]")
+}
+
+// dotID returns the ID as written in the DOT source: as-is when made of letters, digits
+// and underscores only, not starting with a digit, quoted otherwise
+func dotID(id string) string {
+	for i, c := range id {
+		if c != '_' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && (i == 0 || !('0' <= c && c <= '9')) {
+			return strconv.Quote(id)
+		}
+	}
+	if id == "" {
+		return `""`
+	}
+	return id
+}
+
+// dotWriter indents the lines of the DOT source with a tab per level of nesting
+type dotWriter struct {
+	strings.Builder
+	level int
+}
+
+func (w *dotWriter) newLine() {
+	w.WriteString("\n" + strings.Repeat("\t", w.level))
+}
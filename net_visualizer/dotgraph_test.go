@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDOTGraph(t *testing.T) {
+	graph := newDOTGraph().Attr("rankdir", "LR")
+	pod := graph.Cluster("ns/pod")
+	if graph.Cluster("ns/pod") != pod {
+		t.Error("Cluster must return the existing cluster with the same label")
+	}
+	a := pod.Node("n1").Label("a")
+	b := pod.Node("n2").Label("b")
+	if pod.Node("n1") != a {
+		t.Error("Node must return the existing node with the same ID")
+	}
+	ext := graph.Node("10.0.0.9\n(external)")
+	a.Edge(b, "inside").Bold()
+	a.Edge(ext, "outside").Dashed()
+	pod.Node("n3").HTMLLabel(`<table><tr><td>c</td></tr></table>`)
+
+	// the edges between different clusters belong to the root graph
+	want := strings.Join([]string{
+		"digraph  {",
+		"\tsubgraph cluster_s1 {",
+		"\t\tlabel=\"ns/pod\";",
+		"\t\tn1[label=\"a\"];",
+		"\t\tn2[label=\"b\"];",
+		"\t\tn3[label=<<table><tr><td>c</td></tr></table>>];",
+		"\t\tn1->n2[label=\"inside\",style=\"bold\"];",
+		"\t\t",
+		"\t}",
+		"\trankdir=\"LR\";",
+		"\t\"10.0.0.9\\n(external)\"[label=\"10.0.0.9\\n(external)\"];",
+		"\tn1->\"10.0.0.9\\n(external)\"[label=\"outside\",style=\"dashed\"];",
+		"\t",
+		"}",
+		"",
+	}, "\n")
+	if got := graph.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDOTID(t *testing.T) {
+	for _, tc := range []struct{ id, want string }{
+		{"n42", "n42"},
+		{"legend_1", "legend_1"},
+		{"n-1", `"n-1"`},
+		{"42", `"42"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{"", `""`},
+	} {
+		if got := dotID(tc.id); got != tc.want {
+			t.Errorf("dotID(%q) = %s, want %s", tc.id, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"net_visualizer/netflow"
+)
+
+// defaultEdgeLabelTemplate is the -edge-label-template equivalent of the builtin edge
+// labels
+const defaultEdgeLabelTemplate = `{{if .Compact}}{{.SrcPortLabel}}->{{.DstPortLabel}}{{else}}{{.SrcIP}}:{{.SrcPortLabel}}->{{.DstIP}}:{{.DstPortLabel}}{{end}}`
+
+// edgeLabelData is what an -edge-label-template is executed with, for every edge
+type edgeLabelData struct {
+	SrcIP, DstIP               string
+	SrcPort, DstPort           int
+	SrcPortLabel, DstPortLabel string // the port followed by its service name, if known to -resolve-services
+	SrcName, DstName           string // process names
+	SrcPID, DstPID             int64
+	Protocol                   string
+	Count                      int    // number of observations of the connection
+	Bytes, Packets             int64  // traffic volume of the connection; 0 if the tracer does not report it
+	Role                       string // client/server roles, e.g. "client->server"
+	Compact                    bool   // intra-pod edge, whose IPs are omitted from the builtin labels
+}
+
+// sampleEdgeLabelData is used to check the templates before any input gets consumed
+var sampleEdgeLabelData = edgeLabelData{
+	SrcIP: "10.0.0.1", DstIP: "10.0.0.2", SrcPort: 40000, DstPort: 80,
+	SrcPortLabel: "40000", DstPortLabel: "80 (http)", SrcName: "client", DstName: "server",
+	SrcPID: 1, DstPID: 2, Protocol: "tcp", Count: 1, Role: "client->server",
+}
+
+// parseEdgeLabelTemplate parses an -edge-label-template, checking that it only refers
+// to the fields of edgeLabelData
+func parseEdgeLabelTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("edge-label").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid edge label template: %w", err)
+	}
+	if err := tmpl.Execute(new(strings.Builder), sampleEdgeLabelData); err != nil {
+		return nil, fmt.Errorf("invalid edge label template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// templateEdgeLabel returns the label of an edge produced by the EdgeLabelTemplate
+func templateEdgeLabel(model *netflow.Model, sourceNode, destNode *netflow.ProcessEndpoints, edge netflow.Edge, opts RenderOptions) string {
+	data := edgeLabelData{
+		SrcIP:        sourceNode.LocalIP,
+		DstIP:        destNode.LocalIP,
+		SrcPort:      edge.Source.Port,
+		DstPort:      edge.Dest.Port,
+		SrcPortLabel: opts.Services.PortLabel(edge.Source.Port),
+		DstPortLabel: opts.dstPortLabel(edge.Dest.Port),
+		SrcName:      sourceNode.ProcessName,
+		DstName:      destNode.ProcessName,
+		SrcPID:       sourceNode.PID(),
+		DstPID:       destNode.PID(),
+		Protocol:     "tcp",
+		Count:        model.Edges[edge].Count,
+		Bytes:        model.Edges[edge].Bytes,
+		Packets:      model.Edges[edge].Packets,
+		Role:         model.Role(edge).String(),
+		Compact:      sourceNode.LocalIP == destNode.LocalIP && !opts.FullLabels,
+	}
+	var b strings.Builder
+	if err := opts.EdgeLabelTemplate.Execute(&b, data); err != nil {
+		// the template was checked against the same fields: only a runtime failure of
+		// a function, e.g. index out of range, can get here
+		warnf("edge label template: %v", err)
+	}
+	return b.String()
+}
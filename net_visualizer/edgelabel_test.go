@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestParseEdgeLabelTemplate(t *testing.T) {
+	for _, text := range []string{"{{.Count", "{{.Bogus}}", "{{.SrcPort.X}}"} {
+		if _, err := parseEdgeLabelTemplate(text); err == nil || !strings.Contains(err.Error(), "invalid edge label template") {
+			t.Errorf("parseEdgeLabelTemplate(%q) = %v, want an invalid template error", text, err)
+		}
+	}
+}
+
+func TestRenderDOTEdgeLabelTemplate(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	for _, tc := range []struct {
+		template string
+		want     []string
+	}{
+		{"{{.DstPort}}", []string{`n723736->n722977[label="5671"`, `n723715->n723429[label="5673"`}},
+		{"{{.SrcName}} -> {{.DstName}} ({{.Protocol}}, x{{.Count}})", []string{`[label="ncat -> tcp-echo (tcp, x1)"`, `[label="ncat -> tcp-echo (tcp, x2)"`}},
+	} {
+		tmpl, err := parseEdgeLabelTemplate(tc.template)
+		if err != nil {
+			t.Fatalf("parseEdgeLabelTemplate(%q) returned unexpected error: %v", tc.template, err)
+		}
+		out := renderDOT(model, RenderOptions{EdgeLabelTemplate: tmpl}).String()
+		for _, want := range tc.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("DOT output with %q does not contain %s:\n%s", tc.template, want, out)
+			}
+		}
+	}
+}
+
+// TestDefaultEdgeLabelTemplate checks that the documented default template produces the
+// builtin labels
+func TestDefaultEdgeLabelTemplate(t *testing.T) {
+	tmpl, err := parseEdgeLabelTemplate(defaultEdgeLabelTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := sampleTrace + `10.42.0.54:8080<-10.42.0.54:40000|PID=100 CMD=sidecar
+10.42.0.54:40000->10.42.0.54:8080|PID=101 CMD=app
+`
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+	for _, opts := range []RenderOptions{{}, {FullLabels: true}, {Services: ServiceNames{5671: "amqps", 8080: "http-alt"}}} {
+		want := renderDOT(model, opts).String()
+		opts.EdgeLabelTemplate = tmpl
+		if got := renderDOT(model, opts).String(); got != want {
+			t.Errorf("the default template differs from the builtin labels with %+v:\n%s\nwant:\n%s", opts, got, want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"net_visualizer/netflow"
+)
+
+// noEdgesCauses returns the likely reasons why no edge could be drawn out of the input,
+// after the processing stats; nil if the model has edges or if the input was empty
+func noEdgesCauses(model *netflow.Model) []string {
+	stats := model.Stats
+	if len(model.Edges) > 0 || stats.LinesRead == 0 {
+		return nil
+	}
+	var ret []string
+	if stats.LinesInvalid > 0 {
+		ret = append(ret, fmt.Sprintf("%d lines could not be parsed: check the input format with -validate, or describe it with -regex", stats.LinesInvalid))
+	}
+	if dropped := stats.LinesFiltered + stats.LinesExcluded + stats.LinesSampledOut; dropped > 0 {
+		ret = append(ret, fmt.Sprintf("%d lines were dropped by the filters (loopback, ports, -exclude-*, -sample): relax them", dropped))
+	}
+	if n := model.NumDangling(); n > 0 {
+		ret = append(ret, fmt.Sprintf("%d connections have a peer never observed locally: either the capture only covers one end of them, "+
+			"e.g. a single host was traced, or the peers are outside of the traced hosts; -show-external draws them", n))
+	}
+	if stats.SelfEdgesSuppressed > 0 {
+		ret = append(ret, fmt.Sprintf("%d connections are between endpoints of the same process, dropped unless -allow-self-edges", stats.SelfEdgesSuppressed))
+	}
+	if stats.EvictedEdges > 0 {
+		ret = append(ret, fmt.Sprintf("%d edges were forgotten because of -max-tracked-edges", stats.EvictedEdges))
+	}
+	if len(ret) == 0 {
+		ret = append(ret, "the processes only reported listening sockets, without any connection")
+	}
+	return ret
+}
+
+// reportNoEdges warns when no edge could be drawn although the input was not empty,
+// listing the likely causes: a graph with isolated nodes, or none at all, is a common
+// source of confusion
+func reportNoEdges(w io.Writer, model *netflow.Model) {
+	causes := noEdgesCauses(model)
+	if causes == nil {
+		return
+	}
+	fmt.Fprintf(w, "WARNING: no connection could be drawn out of the %d lines read; likely causes:\n", model.Stats.LinesRead)
+	for _, c := range causes {
+		fmt.Fprintf(w, "  - %s\n", c)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestReportNoEdges(t *testing.T) {
+	// only the client host was traced, plus some noise
+	trace := `10.0.0.2:8080<-10.0.0.1:40000|PID=1 CMD=cli
+10.0.0.2:8080<-10.0.0.1:40001|PID=1 CMD=cli
+127.0.0.1:9090<-127.0.0.1:45000|PID=7 CMD=prober
+garbage
+`
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+	var out bytes.Buffer
+	reportNoEdges(&out, model)
+	for _, want := range []string{
+		"WARNING: no connection could be drawn out of the 4 lines read; likely causes:\n",
+		"  - 1 lines could not be parsed",
+		"  - 1 lines were dropped by the filters",
+		"  - 2 connections have a peer never observed locally: either the capture only covers one end of them",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("the warning does not contain %q:\n%s", want, out.String())
+		}
+	}
+	out.Reset()
+	model.PrintStats(&out)
+	if want := "Edges:                  0 (no connection could be drawn)\n"; !strings.Contains(out.String(), want) {
+		t.Errorf("the stats do not contain %q:\n%s", want, out.String())
+	}
+
+	// once the server is traced too, the edges are drawn
+	trace += "10.0.0.1:40000->10.0.0.2:8080|PID=2 CMD=api\n"
+	model = mustBuildModel(t, trace, netflow.ModelOptions{})
+	out.Reset()
+	reportNoEdges(&out, model)
+	if out.Len() != 0 {
+		t.Errorf("unexpected warning for a graph with edges:\n%s", out.String())
+	}
+	// an empty input is not worth a warning either
+	if causes := noEdgesCauses(&netflow.Model{}); causes != nil {
+		t.Errorf("noEdgesCauses = %v for an empty input, want none", causes)
+	}
+}
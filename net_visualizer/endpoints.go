@@ -0,0 +1,32 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"text/tabwriter"
+
+	"net_visualizer/netflow"
+)
+
+// writeEndpoints prints the -dump-endpoints table: the local endpoints observed in the
+// input, each with the process owning it, sorted by IP and then by port. The remote
+// endpoints of the input are correlated to a process through this table
+func writeEndpoints(w io.Writer, endpoints map[netflow.NetworkEndpoint]int64, model *netflow.Model) error {
+	sorted := slices.SortedFunc(maps.Keys(endpoints), func(a, b netflow.NetworkEndpoint) int {
+		return cmp.Or(compareIPs(a.IP, b.IP), cmp.Compare(a.Port, b.Port))
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "IP\tPORT\tPID\tNAME")
+	for _, ep := range sorted {
+		pid, name := endpoints[ep], ""
+		if n, ok := model.Nodes[pid]; ok {
+			pid, name = n.PID(), n.ProcessName
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", ep.IP, ep.Port, pid, name)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestWriteEndpoints(t *testing.T) {
+	b := netflow.NewBuilder(netflow.ModelOptions{})
+	if err := b.Consume(strings.NewReader(sampleTrace), 0); err != nil {
+		t.Fatalf("Consume returned unexpected error: %v", err)
+	}
+	endpoints := b.KnownEndpoints()
+	var buf bytes.Buffer
+	err := b.WithModel(func(model *netflow.Model) error {
+		return writeEndpoints(&buf, endpoints, model)
+	})
+	if err != nil {
+		t.Fatalf("writeEndpoints returned unexpected error: %v", err)
+	}
+	want := `IP          PORT  PID     NAME
+10.42.0.53  5672  723753  ncat
+10.42.0.54  5671  722977  tcp-echo
+10.42.0.55  5672  723736  ncat
+10.42.0.56  5674  723715  ncat
+10.42.0.57  5674  723801  ncat
+10.42.0.58  5673  723429  ncat
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got table:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"net_visualizer/netflow"
+)
+
+// errorReport collects the input lines that cannot be parsed into the -errors-file, one
+// "<input>:<line>: <reason>: <quoted line>" per line, as listed by -validate
+type errorReport struct {
+	w           io.Writer
+	sourceNames []string
+	offsets     lineOffsets // of the lines of the inputs, with -tail
+	name        string      // of the file, for the warnings
+	failed      bool
+}
+
+// add reports a line that cannot be parsed; it is a netflow.ModelOptions.OnParseError
+func (r *errorReport) add(e *netflow.StrictError) {
+	if r.failed {
+		return
+	}
+	reason := e.Err.Error()
+	var parseErr *netflow.ParseError
+	if errors.As(e.Err, &parseErr) {
+		reason = parseErr.Reason.String()
+	}
+	if _, err := fmt.Fprintf(r.w, "%s:%d: %s: %q\n", r.sourceNames[e.Source], r.offsets.lineNum(e.Source, e.LineNum), reason, e.Line); err != nil {
+		// the graph matters more than the report: keep going without it
+		warnf("writing to %s: %v; the next invalid lines are not reported", r.name, err)
+		r.failed = true
+	}
+}
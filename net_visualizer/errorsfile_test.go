@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestErrorReport(t *testing.T) {
+	var out bytes.Buffer
+	report := &errorReport{w: &out, sourceNames: []string{"node1.trace", "node2.trace"}, name: "errors.txt"}
+	first := "garbage\n" + sampleTrace
+	second := sampleTrace + "10.42.0.54:99999<-10.42.0.55:5672|PID=1 CMD=ncat\n"
+	model, err := netflow.BuildModelFromSources([]io.Reader{strings.NewReader(first), strings.NewReader(second)},
+		netflow.ModelOptions{OnParseError: report.add})
+	if err != nil {
+		t.Fatalf("BuildModelFromSources returned unexpected error: %v", err)
+	}
+
+	want := `node1.trace:1: does not match the expected format: "garbage"
+node2.trace:12: port or PID out of range: "10.42.0.54:99999<-10.42.0.55:5672|PID=1 CMD=ncat"
+`
+	if out.String() != want {
+		t.Errorf("report =\n%s\nwant\n%s", out.String(), want)
+	}
+	// the graph is built as usual
+	if want := mustBuildModel(t, sampleTrace, netflow.ModelOptions{}); len(model.Edges) != len(want.Edges) {
+		t.Errorf("got %d edges, want %d", len(model.Edges), len(want.Edges))
+	}
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestErrorReportWriteFailure(t *testing.T) {
+	var warnings bytes.Buffer
+	logOutput = &warnings
+	defer func() { logOutput = os.Stderr }()
+
+	report := &errorReport{w: failingWriter{}, sourceNames: []string{"stdin"}, name: "errors.txt"}
+	for i := 1; i <= 3; i++ {
+		report.add(&netflow.StrictError{LineNum: i, Line: "garbage", Err: errors.New("skipping invalid line")})
+	}
+	if got := strings.Count(warnings.String(), "writing to errors.txt: disk full"); got != 1 {
+		t.Errorf("got %d warnings, want 1: %q", got, warnings.String())
+	}
+}
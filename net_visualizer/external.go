@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"net_visualizer/netflow"
+)
+
+// maxReportedDangling caps the number of dangling connections listed by reportDangling
+const maxReportedDangling = 10
+
+// danglingArrow returns the arrow pointing from the initiator of the connection to the acceptor
+func danglingArrow(dir netflow.Direction) string {
+	if dir == netflow.Remote2Local {
+		return "<-"
+	}
+	return "->"
+}
+
+// reportDangling warns about the connections whose remote endpoint was never observed
+// locally, listing the first ones
+func reportDangling(w io.Writer, model *netflow.Model) {
+	dangling := model.SortedDangling()
+	if len(dangling) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "WARNING: %d connections towards endpoints never observed locally (incomplete capture, or peers outside of the traced hosts):\n", len(dangling))
+	for i, d := range dangling {
+		if i == maxReportedDangling {
+			fmt.Fprintf(w, "  ... and %d more\n", len(dangling)-maxReportedDangling)
+			break
+		}
+		n := model.Nodes[d.Local.PID]
+		fmt.Fprintf(w, "  PID=%d (%s) %s:%d %s %s:%d\n", n.PID(), n.ProcessName, n.LocalIP, d.Local.Port, danglingArrow(d.Dir), d.Remote.IP, d.Remote.Port)
+	}
+}
+
+// renderExternal draws a placeholder node for each dangling remote endpoint, connected
+// to the local processes that reported it. Remote clients are drawn once per IP, since
+// their ports are ephemeral.
+func renderExternal(graph *dotGraph, model *netflow.Model, dotNodes map[int64]*dotNode) {
+	for _, d := range model.SortedDangling() {
+		n := model.Nodes[d.Local.PID]
+		local := fmt.Sprintf("%s:%d", n.LocalIP, d.Local.Port)
+		remote := fmt.Sprintf("%s:%d", d.Remote.IP, d.Remote.Port)
+		if d.Dir == netflow.Remote2Local {
+			ext := externalNode(graph, d.Remote.IP)
+			ext.Edge(dotNodes[d.Local.PID], dotString(remote+"->"+local)).Dashed()
+		} else {
+			ext := externalNode(graph, remote)
+			dotNodes[d.Local.PID].Edge(ext, dotString(local+"->"+remote)).Dashed()
+		}
+	}
+}
+
+func externalNode(graph *dotGraph, name string) *dotNode {
+	return graph.Node(dotString(name+"\n(external)")).Attr("shape", "box").Attr("style", "dashed")
+}
+
+// internetNode names the placeholder node collecting the external peers outside of any
+// -external-cidr
+const internetNode = "INTERNET"
+
+// renderCollapsedExternal is the -collapse-external variant of renderExternal: all the
+// dangling remote endpoints are merged into a single INTERNET node, or into one node per
+// matching CIDR, with one edge per local process and direction counting the connections
+func renderCollapsedExternal(graph *dotGraph, model *netflow.Model, dotNodes map[int64]*dotNode, opts RenderOptions) {
+	type bucketEdge struct {
+		pid    int64
+		bucket string
+		dir    netflow.Direction
+	}
+	counts := make(map[bucketEdge]int)
+	var order []bucketEdge // first-seen order, stable since the dangling connections are sorted
+	for _, d := range model.SortedDangling() {
+		bucket, ok := opts.ExternalCIDRs.NodeOf(d.Remote.IP)
+		if !ok {
+			bucket = internetNode
+		}
+		key := bucketEdge{pid: d.Local.PID, bucket: bucket, dir: d.Dir}
+		if counts[key] == 0 {
+			order = append(order, key)
+		}
+		counts[key]++
+	}
+
+	for _, key := range order {
+		ext := externalNode(graph, key.bucket)
+		label := fmt.Sprintf("%d connections", counts[key])
+		if counts[key] == 1 {
+			label = "1 connection"
+		}
+		var edge *dotEdge
+		if key.dir == netflow.Remote2Local {
+			edge = ext.Edge(dotNodes[key.pid], label)
+		} else {
+			edge = dotNodes[key.pid].Edge(ext, label)
+		}
+		edge.Dashed()
+		if width := edgeWidth(counts[key]); width != "" && !opts.NoWeight {
+			edge.Attr("penwidth", width)
+		}
+	}
+}
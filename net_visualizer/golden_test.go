@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of TestGolden with the current output")
+
+// TestGolden runs the sample traces of testdata/golden through the whole pipeline and
+// compares the DOT output with the checked-in <name>.expected.dot files. After an
+// intended rendering change, regenerate them with: go test -run TestGolden -update
+func TestGolden(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts RenderOptions
+	}{
+		{"loopback-heavy", RenderOptions{}},
+		{"external-peer", RenderOptions{ShowExternal: true}},
+		{"multi-port", RenderOptions{}},
+		{"bidirectional", RenderOptions{ShowRoles: true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", "golden", tc.name+".trace"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			model, err := netflow.BuildModel(f, netflow.ModelOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got bytes.Buffer
+			if err := writeOutput(&got, "dot", model, tc.opts); err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", "golden", tc.name+".expected.dot")
+			if *update {
+				if err := os.WriteFile(golden, got.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v; create it with -update", err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("DOT output differs from %s; if the change is intended, run with -update\ngot:\n%s\nwant:\n%s", golden, got.Bytes(), want)
+			}
+		})
+	}
+}
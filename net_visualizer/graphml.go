@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+
+	"net_visualizer/netflow"
+)
+
+// GraphML document structure, see http://graphml.graphdrawing.org/specification.html
+type graphmlDoc struct {
+	XMLName        xml.Name     `xml:"graphml"`
+	Xmlns          string       `xml:"xmlns,attr"`
+	XmlnsXsi       string       `xml:"xmlns:xsi,attr"`
+	SchemaLocation string       `xml:"xsi:schemaLocation,attr"`
+	Keys           []graphmlKey `xml:"key"`
+	Graph          graphmlGraph `xml:"graph"`
+}
+
+type graphmlKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphmlGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphmlNode `xml:"node"`
+	Edges       []graphmlEdge `xml:"edge"`
+}
+
+type graphmlNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphmlData `xml:"data"`
+}
+
+type graphmlEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphmlData `xml:"data"`
+}
+
+type graphmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+var graphmlKeys = []graphmlKey{
+	{ID: "pid", For: "node", AttrName: "pid", AttrType: "long"},
+	{ID: "name", For: "node", AttrName: "name", AttrType: "string"},
+	{ID: "ip", For: "node", AttrName: "ip", AttrType: "string"},
+	{ID: "src_port", For: "edge", AttrName: "src_port", AttrType: "int"},
+	{ID: "dst_port", For: "edge", AttrName: "dst_port", AttrType: "int"},
+	{ID: "protocol", For: "edge", AttrName: "protocol", AttrType: "string"},
+	{ID: "count", For: "edge", AttrName: "count", AttrType: "int"},
+}
+
+// writeGraphML serializes the model as a GraphML document, for Gephi, yEd and the like
+func writeGraphML(w io.Writer, model *netflow.Model) error {
+	nodes, edges := model.Export()
+
+	doc := graphmlDoc{
+		Xmlns:          "http://graphml.graphdrawing.org/xmlns",
+		XmlnsXsi:       "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: "http://graphml.graphdrawing.org/xmlns http://graphml.graphdrawing.org/xmlns/1.0/graphml.xsd",
+		Keys:           graphmlKeys,
+		Graph: graphmlGraph{
+			ID:          "G",
+			EdgeDefault: "directed",
+		},
+	}
+	for _, n := range nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphmlNode{
+			ID: n.ID,
+			Data: []graphmlData{
+				{Key: "pid", Value: strconv.FormatInt(n.PID, 10)},
+				{Key: "name", Value: n.Name},
+				{Key: "ip", Value: n.IP},
+			},
+		})
+	}
+	for i, e := range edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphmlEdge{
+			ID:     "e" + strconv.Itoa(i),
+			Source: e.Source,
+			Target: e.Target,
+			Data: []graphmlData{
+				{Key: "src_port", Value: strconv.Itoa(e.SrcPort)},
+				{Key: "dst_port", Value: strconv.Itoa(e.DstPort)},
+				{Key: "protocol", Value: e.Protocol},
+				{Key: "count", Value: strconv.Itoa(e.Count)},
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestWriteGraphML(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+
+	var buf bytes.Buffer
+	if err := writeGraphML(&buf, model); err != nil {
+		t.Fatalf("writeGraphML returned unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Errorf("GraphML output lacks the XML header")
+	}
+
+	var doc graphmlDoc
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("GraphML output is not well-formed XML: %v\n%s", err, buf.String())
+	}
+	if doc.Xmlns != "http://graphml.graphdrawing.org/xmlns" {
+		t.Errorf("unexpected namespace %q", doc.Xmlns)
+	}
+	if doc.Graph.EdgeDefault != "directed" {
+		t.Errorf("edgedefault = %q, want directed", doc.Graph.EdgeDefault)
+	}
+
+	// every data element must reference a declared key of the right domain
+	keyDomain := make(map[string]string)
+	for _, k := range doc.Keys {
+		keyDomain[k.ID] = k.For
+	}
+	nodeIDs := make(map[string]bool)
+	for _, n := range doc.Graph.Nodes {
+		nodeIDs[n.ID] = true
+		for _, d := range n.Data {
+			if keyDomain[d.Key] != "node" {
+				t.Errorf("node %s: data key %q is not a declared node key", n.ID, d.Key)
+			}
+		}
+	}
+	for _, e := range doc.Graph.Edges {
+		if !nodeIDs[e.Source] || !nodeIDs[e.Target] {
+			t.Errorf("edge %s references unknown nodes %s -> %s", e.ID, e.Source, e.Target)
+		}
+		for _, d := range e.Data {
+			if keyDomain[d.Key] != "edge" {
+				t.Errorf("edge %s: data key %q is not a declared edge key", e.ID, d.Key)
+			}
+		}
+	}
+
+	if len(doc.Graph.Nodes) != 6 || len(doc.Graph.Edges) != 4 {
+		t.Fatalf("got %d nodes and %d edges, want 6 and 4", len(doc.Graph.Nodes), len(doc.Graph.Edges))
+	}
+	wantNode := graphmlNode{ID: "n722977", Data: []graphmlData{
+		{Key: "pid", Value: "722977"}, {Key: "name", Value: "tcp-echo"}, {Key: "ip", Value: "10.42.0.54"},
+	}}
+	if got := doc.Graph.Nodes[0]; got.ID != wantNode.ID || len(got.Data) != 3 || got.Data[1] != wantNode.Data[1] || got.Data[2] != wantNode.Data[2] {
+		t.Errorf("first node = %+v, want %+v", got, wantNode)
+	}
+	wantEdge := graphmlEdge{ID: "e1", Source: "n723736", Target: "n722977", Data: []graphmlData{
+		{Key: "src_port", Value: "5672"}, {Key: "dst_port", Value: "5671"}, {Key: "protocol", Value: "tcp"}, {Key: "count", Value: "1"},
+	}}
+	got := doc.Graph.Edges[1]
+	if got.ID != wantEdge.ID || got.Source != wantEdge.Source || got.Target != wantEdge.Target || len(got.Data) != len(wantEdge.Data) {
+		t.Fatalf("second edge = %+v, want %+v", got, wantEdge)
+	}
+	for i := range wantEdge.Data {
+		if got.Data[i] != wantEdge.Data[i] {
+			t.Errorf("second edge data[%d] = %+v, want %+v", i, got.Data[i], wantEdge.Data[i])
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"net_visualizer/netflow"
+)
+
+// graphvizCommand is the Graphviz executable rendering the image formats
+const graphvizCommand = "dot"
+
+// writeImage renders the DOT graph to an image in the given format ("svg", "png"),
+// through the Graphviz executable
+func writeImage(w io.Writer, format string, model *netflow.Model, opts RenderOptions) error {
+	path, err := exec.LookPath(graphvizCommand)
+	if err != nil {
+		return fmt.Errorf("the %s format requires the Graphviz %q executable on the PATH: install Graphviz, or use -format dot and render the graph elsewhere", format, graphvizCommand)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(path, "-T"+format)
+	cmd.Stdin = strings.NewReader(renderDOT(model, opts).String())
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return fmt.Errorf("%s failed: %s", graphvizCommand, strings.TrimSpace(stderr.String()))
+		}
+		return fmt.Errorf("running %s: %w", graphvizCommand, err)
+	}
+	return nil
+}
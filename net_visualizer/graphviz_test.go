@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestWriteImage(t *testing.T) {
+	if _, err := exec.LookPath(graphvizCommand); err != nil {
+		t.Skip("Graphviz is not installed")
+	}
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	var buf bytes.Buffer
+	if err := writeOutput(&buf, "svg", model, RenderOptions{}); err != nil {
+		t.Fatalf("writeOutput returned unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "<svg") || !strings.Contains(buf.String(), "tcp-echo") {
+		t.Errorf("unexpected SVG output:\n%s", buf.String())
+	}
+}
+
+func TestWriteImageCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake Graphviz is a shell script")
+	}
+	// a fake Graphviz reporting its arguments and echoing the DOT graph
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"$@\"\ncat\n"
+	if err := os.WriteFile(filepath.Join(dir, graphvizCommand), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	var buf bytes.Buffer
+	if err := writeOutput(&buf, "png", model, RenderOptions{}); err != nil {
+		t.Fatalf("writeOutput returned unexpected error: %v", err)
+	}
+	if want := "-Tpng\n" + renderDOT(model, RenderOptions{}).String(); buf.String() != want {
+		t.Errorf("Graphviz got %q, want %q", buf.String(), want)
+	}
+
+	// Graphviz failures are reported with its error message
+	script = "#!/bin/sh\necho 'syntax error in line 1' >&2\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(dir, graphvizCommand), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	err := writeOutput(&buf, "svg", model, RenderOptions{})
+	if err == nil || !strings.Contains(err.Error(), "syntax error in line 1") {
+		t.Errorf("writeOutput returned %v, want the Graphviz error", err)
+	}
+}
+
+func TestWriteImageWithoutGraphviz(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	err := writeOutput(&bytes.Buffer{}, "svg", model, RenderOptions{})
+	if err == nil || !strings.Contains(err.Error(), "install Graphviz") {
+		t.Errorf("writeOutput returned %v, want an error suggesting to install Graphviz", err)
+	}
+}
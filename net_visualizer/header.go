@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+)
+
+// GraphHeader tells how a graph was generated, for the traceability of the diagrams
+// shared around; RenderOptions.Header draws it as the label of the graph
+type GraphHeader struct {
+	Generated time.Time // zero to leave it out, for reproducible outputs
+	Version   string    // version of net_visualizer, see toolVersion
+	Sources   []string  // names of the inputs, "stdin" for the standard input
+	Filters   []string  // the active filters, as command-line flags
+}
+
+// Label returns the multi-line label of the graph
+func (h *GraphHeader) Label() string {
+	lines := []string{"net_visualizer " + h.Version}
+	if !h.Generated.IsZero() {
+		lines = append(lines, "Generated: "+h.Generated.UTC().Format(time.RFC3339))
+	}
+	lines = append(lines, "Input: "+strings.Join(h.Sources, ", "))
+	if len(h.Filters) > 0 {
+		lines = append(lines, "Filters: "+strings.Join(h.Filters, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// toolVersion returns the version of the module, with the VCS revision it was built from
+// when known, e.g. "(devel) rev 3f4b55d"
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown version"
+	}
+	version := info.Main.Version
+	if version == "" {
+		version = "(devel)"
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" && s.Value != "" {
+			version += " rev " + s.Value[:min(len(s.Value), 7)]
+		}
+	}
+	return version
+}
+
+// filterFlags lists the flags selecting which part of the input is drawn, including the
+// ones cutting the input or truncating the graph, summarized in the GraphHeader
+var filterFlags = []string{
+	"boundary-only", "clients-only", "drop-link-local", "drop-multicast", "drop-ula", "dst-ports",
+	"exclude-pid", "exclude-process", "focus", "focus-depth", "include-process", "max-duration",
+	"max-edges", "max-nodes", "max-tracked-edges", "max-tracked-nodes", "min-connections-per-node",
+	"min-peers", "namespace-boundary", "ports-allowlist", "ports-denylist", "sample",
+	"servers-only", "stale-after", "suppress-chatty", "tail",
+}
+
+// filterSummary returns the filter flags set in fs, sorted by name, in their
+// command-line form, e.g. "-exclude-process=k3s-server"
+func filterSummary(fs *flag.FlagSet) []string {
+	var ret []string
+	fs.Visit(func(f *flag.Flag) {
+		if !slices.Contains(filterFlags, f.Name) {
+			return
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() && f.Value.String() == "true" {
+			ret = append(ret, "-"+f.Name)
+		} else {
+			ret = append(ret, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	return ret
+}
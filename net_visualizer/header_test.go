@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"net_visualizer/netflow"
+)
+
+func TestRenderDOTHeader(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	header := &GraphHeader{
+		Generated: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		Version:   "v1.2.3",
+		Sources:   []string{"a.trace", "stdin"},
+		Filters:   []string{"-drop-multicast", "-exclude-process=k3s"},
+	}
+	out := renderDOT(model, RenderOptions{Header: header}).String()
+	want := `label="net_visualizer v1.2.3\nGenerated: 2024-05-01T12:30:00Z\nInput: a.trace, stdin\nFilters: -drop-multicast -exclude-process=k3s"`
+	if !strings.Contains(out, want) || !strings.Contains(out, `labelloc="t"`) {
+		t.Errorf("DOT output does not contain the header %s:\n%s", want, out)
+	}
+
+	// without a timestamp, the output is reproducible
+	header.Generated = time.Time{}
+	header.Filters = nil
+	out = renderDOT(model, RenderOptions{Header: header}).String()
+	if want := `label="net_visualizer v1.2.3\nInput: a.trace, stdin"`; !strings.Contains(out, want) {
+		t.Errorf("DOT output does not contain the header %s:\n%s", want, out)
+	}
+
+	if out := renderDOT(model, RenderOptions{}).String(); strings.Contains(out, "net_visualizer") || strings.Contains(out, "labelloc") {
+		t.Errorf("the graph must have no header by default:\n%s", out)
+	}
+}
+
+func TestFilterSummary(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Bool("drop-multicast", false, "")
+	fs.Bool("servers-only", false, "")
+	fs.String("exclude-process", "", "")
+	fs.String("rankdir", "", "")
+	fs.Int("max-nodes", 0, "")
+	if err := fs.Parse([]string{"-rankdir=LR", "-exclude-process=k3s", "-drop-multicast", "-servers-only=false", "-max-nodes=100"}); err != nil {
+		t.Fatal(err)
+	}
+	// rendering flags are not filters, the limits truncating the graph are; a bool filter
+	// set to false is shown with its value
+	want := []string{"-drop-multicast", "-exclude-process=k3s", "-max-nodes=100", "-servers-only=false"}
+	if got := filterSummary(fs); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// nonFilterFlags lists the flags of main.go that do not select which part of the input
+// is drawn, left out of the GraphHeader; the ones cutting the input or truncating the
+// graph are filters
+var nonFilterFlags = []string{
+	"allow-self-edges", "anonymize", "anonymize-map", "bidirectional", "client-counts",
+	"cluster-label-template", "collapse-external", "color-by-name", "color-by-source",
+	"concurrent-inputs", "config", "dedup-window", "diff", "direction-counts", "dump-endpoints",
+	"edge-attrs", "edge-label-template", "errors-file", "explain", "external-cidr", "force",
+	"format", "full-labels", "group-by-pod", "group-cidr", "input-format", "kube", "kubeconfig",
+	"label-services", "layout", "legend", "list-listeners", "listen", "max-name-len", "merge-forks",
+	"mesh", "no-degree", "no-header", "no-header-timestamp", "no-ports-in-label", "no-weight",
+	"node-cidr", "node-map", "o", "parallel", "per-namespace", "port-records", "quiet", "rankdir",
+	"regex", "resolve-hosts", "resolve-services", "seed", "services-file", "show-external",
+	"show-roles", "sidecar-ports", "size-by-degree", "split-components", "stats", "strict", "style",
+	"validate", "verbose", "warn-edges", "warn-nodes", "watch", "weight-by",
+}
+
+// TestFilterFlagsComplete fails when a flag is added to main.go without telling whether
+// it is a filter, to be listed in filterFlags, or not
+func TestFilterFlagsComplete(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "main.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defined := map[string]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != "flag" {
+			return true
+		}
+		// the name is the first string argument, after the variable of the *Var forms
+		for _, arg := range call.Args {
+			if lit, ok := arg.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				name, _ := strconv.Unquote(lit.Value)
+				defined[name] = true
+				break
+			}
+		}
+		return true
+	})
+	if len(defined) == 0 {
+		t.Fatal("no flag found in main.go")
+	}
+	for name := range defined {
+		if slices.Contains(filterFlags, name) == slices.Contains(nonFilterFlags, name) {
+			t.Errorf("-%s must be listed in exactly one of filterFlags and nonFilterFlags", name)
+		}
+	}
+	for _, name := range slices.Concat(filterFlags, nonFilterFlags) {
+		if !defined[name] {
+			t.Errorf("-%s is not defined in main.go", name)
+		}
+	}
+}
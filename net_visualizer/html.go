@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"net_visualizer/netflow"
+)
+
+// htmlAssets holds the page of the -format html output, with its stylesheet and script,
+// so that the generated file is self-contained
+//
+//go:embed html
+var htmlAssets embed.FS
+
+var htmlPage = template.Must(template.ParseFS(htmlAssets, "html/page.html"))
+
+// htmlFallbackWarning is only printed once, even when -watch rewrites the page
+var htmlFallbackWarning sync.Once
+
+// htmlPageData is what html/page.html is executed with: either the SVG rendering of the
+// graph, or, without Graphviz, its DOT source
+type htmlPageData struct {
+	Title string
+	SVG   template.HTML
+	DOT   string
+	CSS   template.CSS
+	JS    template.JS
+}
+
+// writeHTML writes a self-contained HTML page showing the graph as an SVG, rendered by
+// Graphviz, that can be panned and zoomed, and whose pod clusters can be collapsed.
+// Without Graphviz, the page falls back to the DOT source of the graph.
+func writeHTML(w io.Writer, model *netflow.Model, opts RenderOptions) error {
+	// the clusters are what the page collapses
+	opts.GroupByPod = true
+	opts.ClusterClasses = true
+
+	data := htmlPageData{Title: "Network topology"}
+	if _, err := exec.LookPath(graphvizCommand); err != nil {
+		htmlFallbackWarning.Do(func() {
+			warnf("the Graphviz %q executable is not on the PATH: the HTML page only shows the DOT source of the graph", graphvizCommand)
+		})
+		data.DOT = renderDOT(model, opts).String()
+	} else {
+		var svg bytes.Buffer
+		if err := writeImage(&svg, "svg", model, opts); err != nil {
+			return err
+		}
+		// drop the XML prolog and doctype, meaningless inline
+		out := svg.String()
+		if i := strings.Index(out, "<svg"); i > 0 {
+			out = out[i:]
+		}
+		data.SVG = template.HTML(out)
+	}
+
+	css, err := htmlAssets.ReadFile("html/viewer.css")
+	if err != nil {
+		return fmt.Errorf("reading the embedded stylesheet: %w", err)
+	}
+	js, err := htmlAssets.ReadFile("html/viewer.js")
+	if err != nil {
+		return fmt.Errorf("reading the embedded script: %w", err)
+	}
+	data.CSS = template.CSS(css)
+	data.JS = template.JS(js)
+	return htmlPage.Execute(w, data)
+}
@@ -0,0 +1,119 @@
+// Interactive layer of the -format html output: the SVG rendered by Graphviz stays
+// readable without it. The clusters, nodes and edges carry the pod-<i>, in-pod-<i>,
+// from-pod-<i> and to-pod-<j> classes set by renderDOT.
+(function () {
+  "use strict";
+  var main = document.getElementById("graph");
+  var svg = main.querySelector("svg");
+  if (!svg) {
+    return;
+  }
+
+  // pan and zoom, by rewriting the viewBox
+  svg.removeAttribute("width");
+  svg.removeAttribute("height");
+  var initial = svg.viewBox.baseVal;
+  var view = { x: initial.x, y: initial.y, w: initial.width, h: initial.height };
+  var home = { x: view.x, y: view.y, w: view.w, h: view.h };
+  function apply() {
+    svg.setAttribute("viewBox", [view.x, view.y, view.w, view.h].join(" "));
+  }
+  function toGraph(clientX, clientY) {
+    var r = svg.getBoundingClientRect();
+    var scale = Math.max(view.w / r.width, view.h / r.height);
+    return {
+      x: view.x + (clientX - r.left - (r.width - view.w / scale) / 2) * scale,
+      y: view.y + (clientY - r.top - (r.height - view.h / scale) / 2) * scale,
+      scale: scale
+    };
+  }
+  svg.addEventListener("wheel", function (ev) {
+    ev.preventDefault();
+    var p = toGraph(ev.clientX, ev.clientY);
+    var factor = ev.deltaY < 0 ? 0.8 : 1.25;
+    view.x = p.x - (p.x - view.x) * factor;
+    view.y = p.y - (p.y - view.y) * factor;
+    view.w *= factor;
+    view.h *= factor;
+    apply();
+  }, { passive: false });
+
+  var drag = null;
+  main.classList.add("pannable");
+  svg.addEventListener("mousedown", function (ev) {
+    drag = { x: ev.clientX, y: ev.clientY, moved: false, scale: toGraph(ev.clientX, ev.clientY).scale };
+    main.classList.add("panning");
+  });
+  window.addEventListener("mousemove", function (ev) {
+    if (!drag) {
+      return;
+    }
+    var dx = ev.clientX - drag.x, dy = ev.clientY - drag.y;
+    if (Math.abs(dx) + Math.abs(dy) > 3) {
+      drag.moved = true;
+    }
+    view.x -= dx * drag.scale;
+    view.y -= dy * drag.scale;
+    drag.x = ev.clientX;
+    drag.y = ev.clientY;
+    apply();
+  });
+  window.addEventListener("mouseup", function () {
+    main.classList.remove("panning");
+    setTimeout(function () { drag = null; }, 0);
+  });
+
+  // collapsible clusters
+  function podClass(el, prefix) {
+    for (var i = 0; i < el.classList.length; i++) {
+      var c = el.classList[i];
+      if (c.indexOf(prefix) === 0 && /^\d+$/.test(c.slice(prefix.length))) {
+        return c.slice(prefix.length);
+      }
+    }
+    return null;
+  }
+  function setCollapsed(cluster, collapsed) {
+    var pod = podClass(cluster, "pod-");
+    cluster.classList.toggle("collapsed", collapsed);
+    svg.querySelectorAll("g.node.in-pod-" + pod + ", g.edge.from-pod-" + pod + ".to-pod-" + pod).forEach(function (el) {
+      el.classList.toggle("hidden", collapsed);
+    });
+    var label = cluster.querySelector("text");
+    if (label) {
+      if (!label.dataset.name) {
+        label.dataset.name = label.textContent;
+      }
+      var members = svg.querySelectorAll("g.node.in-pod-" + pod).length;
+      label.textContent = collapsed ? label.dataset.name + " (" + members + " collapsed)" : label.dataset.name;
+    }
+  }
+  var clusters = Array.prototype.filter.call(svg.querySelectorAll("g.cluster"), function (c) {
+    return podClass(c, "pod-") !== null;
+  });
+  clusters.forEach(function (cluster) {
+    cluster.addEventListener("click", function () {
+      if (drag && drag.moved) {
+        return;
+      }
+      setCollapsed(cluster, !cluster.classList.contains("collapsed"));
+    });
+  });
+
+  document.querySelectorAll("header button").forEach(function (button) {
+    button.addEventListener("click", function () {
+      switch (button.dataset.action) {
+      case "collapse-all":
+        clusters.forEach(function (c) { setCollapsed(c, true); });
+        break;
+      case "expand-all":
+        clusters.forEach(function (c) { setCollapsed(c, false); });
+        break;
+      case "reset":
+        view = { x: home.x, y: home.y, w: home.w, h: home.h };
+        apply();
+        break;
+      }
+    });
+  });
+})();
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestWriteHTML(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake Graphviz is a shell script")
+	}
+	// a fake Graphviz producing an SVG document out of the DOT graph
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho '<?xml version=\"1.0\"?>'\necho '<svg viewBox=\"0 0 10 10\"><g class=\"cluster pod-1\"></g>'\ncat >/dev/null\necho '</svg>'\n"
+	if err := os.WriteFile(filepath.Join(dir, graphvizCommand), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	var buf bytes.Buffer
+	if err := writeOutput(&buf, "html", model, RenderOptions{}); err != nil {
+		t.Fatalf("writeOutput returned unexpected error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		`<main id="graph">` + "\n" + `<svg viewBox="0 0 10 10"><g class="cluster pod-1"></g>`,
+		"<script>// Interactive layer",
+		"g.cluster.collapsed",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("HTML output does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "<?xml") {
+		t.Errorf("the XML prolog of the SVG must be dropped:\n%s", out)
+	}
+}
+
+func TestWriteHTMLWithoutGraphviz(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	var buf bytes.Buffer
+	if err := writeOutput(&buf, "html", model, RenderOptions{}); err != nil {
+		t.Fatalf("writeOutput returned unexpected error: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "digraph") || !strings.Contains(out, "n723736-&gt;n722977") {
+		t.Errorf("the page should fall back to the escaped DOT source:\n%s", out)
+	}
+	if strings.Contains(out, "<script>") {
+		t.Errorf("the fallback page has no graph to make interactive:\n%s", out)
+	}
+}
+
+func TestRenderDOTClusterClasses(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	out := renderDOT(model, RenderOptions{GroupByPod: true, ClusterClasses: true}).String()
+	for _, want := range []string{`class="pod-1"`, `class="in-pod-1"`, `class="from-pod-4 to-pod-1"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, out)
+		}
+	}
+	if out := renderDOT(model, RenderOptions{GroupByPod: true}).String(); strings.Contains(out, "class=") {
+		t.Errorf("the classes should only be set with ClusterClasses:\n%s", out)
+	}
+}
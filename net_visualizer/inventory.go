@@ -0,0 +1,84 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"io"
+	"slices"
+
+	"net_visualizer/netflow"
+)
+
+// inventoryProcess is the entry of a process in the -format inventory document: the
+// ports it accepts connections on, and the remote endpoints it connects to
+type inventoryProcess struct {
+	PID            int64           `json:"pid"`
+	Name           string          `json:"name"`
+	IP             string          `json:"ip"`
+	ListeningPorts []int           `json:"listening_ports"`
+	Egress         []inventoryPeer `json:"egress"`
+}
+
+// inventoryPeer is a remote endpoint a process connects to; Count sums the observations
+// of the connections towards it, and is omitted for the External endpoints, never
+// observed locally, whose connections are not counted
+type inventoryPeer struct {
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Count    int    `json:"count,omitempty"`
+	External bool   `json:"external,omitempty"`
+}
+
+// buildInventory aggregates the model from the perspective of each process, sorted like
+// Model.SortedNodes, their egress endpoints sorted by IP and then by port
+func buildInventory(model *netflow.Model) []inventoryProcess {
+	egress := make(map[int64]map[netflow.NetworkEndpoint]*inventoryPeer)
+	addPeer := func(pid int64, ep netflow.NetworkEndpoint, count int, external bool) {
+		if egress[pid] == nil {
+			egress[pid] = make(map[netflow.NetworkEndpoint]*inventoryPeer)
+		}
+		peer, ok := egress[pid][ep]
+		if !ok {
+			peer = &inventoryPeer{IP: ep.IP, Port: ep.Port, External: external}
+			egress[pid][ep] = peer
+		}
+		peer.Count += count
+	}
+	for e, info := range model.Edges {
+		addPeer(e.Source.PID, netflow.NetworkEndpoint{IP: model.Nodes[e.Dest.PID].LocalIP, Port: e.Dest.Port}, info.Count, false)
+	}
+	for _, d := range model.SortedDangling() {
+		if d.Dir == netflow.Local2Remote {
+			addPeer(d.Local.PID, d.Remote, 0, true)
+		}
+	}
+
+	ret := make([]inventoryProcess, 0, len(model.Nodes))
+	for _, n := range model.SortedNodes() {
+		p := inventoryProcess{
+			PID:            n.PID(),
+			Name:           n.ProcessName,
+			IP:             n.LocalIP,
+			ListeningPorts: model.ListeningPorts(n),
+			Egress:         []inventoryPeer{},
+		}
+		if p.ListeningPorts == nil {
+			p.ListeningPorts = []int{}
+		}
+		for _, peer := range egress[n.ProcessID] {
+			p.Egress = append(p.Egress, *peer)
+		}
+		slices.SortFunc(p.Egress, func(a, b inventoryPeer) int {
+			return cmp.Or(compareIPs(a.IP, b.IP), cmp.Compare(a.Port, b.Port))
+		})
+		ret = append(ret, p)
+	}
+	return ret
+}
+
+// writeInventory serializes the per-process inventory of the model as a JSON document
+func writeInventory(w io.Writer, model *netflow.Model) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(buildInventory(model))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestWriteInventory(t *testing.T) {
+	// the API server accepts connections on 8080 and connects twice to the database, to
+	// the cache and to an external endpoint
+	trace := `10.0.0.2:8080<-10.0.0.1:40000|PID=1 CMD=cli
+10.0.0.1:40000->10.0.0.2:8080|PID=2 CMD=api
+10.0.0.3:5432<-10.0.0.2:41000|PID=2 CMD=api
+10.0.0.2:41000->10.0.0.3:5432|PID=3 CMD=db
+10.0.0.2:41000->10.0.0.3:5432|PID=3 CMD=db
+10.0.0.3:5432<-10.0.0.2:41001|PID=2 CMD=api
+10.0.0.2:41001->10.0.0.3:5432|PID=3 CMD=db
+10.0.0.10:6379<-10.0.0.2:41002|PID=2 CMD=api
+10.0.0.2:41002->10.0.0.10:6379|PID=4 CMD=cache
+10.0.9.9:443<-10.0.0.2:41003|PID=2 CMD=api
+`
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+	var buf bytes.Buffer
+	if err := writeInventory(&buf, model); err != nil {
+		t.Fatalf("writeInventory returned unexpected error: %v", err)
+	}
+	var doc []inventoryProcess
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("inventory output does not parse: %v\n%s", err, buf.String())
+	}
+	if len(doc) != 4 {
+		t.Fatalf("got %d processes, want 4:\n%s", len(doc), buf.String())
+	}
+	api := doc[1]
+	want := inventoryProcess{
+		PID:            2,
+		Name:           "api",
+		IP:             "10.0.0.2",
+		ListeningPorts: []int{8080},
+		Egress: []inventoryPeer{
+			{IP: "10.0.0.3", Port: 5432, Count: 4}, // summed over both connections
+			{IP: "10.0.0.10", Port: 6379, Count: 1},
+			{IP: "10.0.9.9", Port: 443, External: true},
+		},
+	}
+	if !reflect.DeepEqual(api, want) {
+		t.Errorf("API server = %+v, want %+v", api, want)
+	}
+	// the database only accepts connections
+	if db := doc[2]; db.Name != "db" || !reflect.DeepEqual(db.ListeningPorts, []int{5432}) || len(db.Egress) != 0 {
+		t.Errorf("database = %+v, want only the listening port 5432", db)
+	}
+}
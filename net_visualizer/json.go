@@ -0,0 +1,24 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"net_visualizer/netflow"
+)
+
+// jsonDoc is the document emitted by -format json
+type jsonDoc struct {
+	Nodes []netflow.ExportNode `json:"nodes"`
+	Edges []netflow.ExportEdge `json:"edges"`
+}
+
+// writeJSON serializes the model as a single JSON document
+func writeJSON(w io.Writer, model *netflow.Model) error {
+	var doc jsonDoc
+	doc.Nodes, doc.Edges = model.Export()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestWriteJSON(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, model); err != nil {
+		t.Fatalf("writeJSON returned unexpected error: %v", err)
+	}
+	var doc jsonDoc
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("JSON output does not parse: %v\n%s", err, buf.String())
+	}
+
+	if len(doc.Nodes) != 6 || len(doc.Edges) != 4 {
+		t.Fatalf("got %d nodes and %d edges, want 6 and 4", len(doc.Nodes), len(doc.Edges))
+	}
+	server := doc.Nodes[0]
+	if server.PID != 722977 || server.Name != "tcp-echo" || server.InDegree != 2 || server.OutDegree != 0 {
+		t.Errorf("first node = %+v, want tcp-echo with in_degree=2 out_degree=0", server)
+	}
+	want := netflow.ExportEdge{Source: "n723715", Target: "n723429", SrcPort: 5674, DstPort: 5673, Protocol: "tcp", Count: 1}
+	if doc.Edges[0] != want {
+		t.Errorf("first edge = %+v, want %+v", doc.Edges[0], want)
+	}
+	if strings.Contains(buf.String(), "first_seen") {
+		t.Errorf("the active window must be omitted without timestamps:\n%s", buf.String())
+	}
+}
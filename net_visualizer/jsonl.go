@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"net_visualizer/netflow"
+)
+
+// jsonlEdge is a line of -format jsonl: an edge together with the metadata of its
+// endpoints, so that every line stands on its own
+type jsonlEdge struct {
+	netflow.ExportEdge
+	SourceNode netflow.ExportNode `json:"source_node"`
+	TargetNode netflow.ExportNode `json:"target_node"`
+}
+
+// jsonlKey identifies an edge across the successive models of -watch; the count is left
+// out, as it grows over time
+type jsonlKey struct {
+	Source, Target   string
+	SrcPort, DstPort int
+}
+
+// jsonlStream writes the edges of the successive models of -watch as JSON Lines, each
+// edge once: when it is first drawn
+type jsonlStream struct {
+	w    io.Writer
+	seen map[jsonlKey]struct{}
+}
+
+func newJSONLStream(w io.Writer) *jsonlStream {
+	return &jsonlStream{w: w, seen: make(map[jsonlKey]struct{})}
+}
+
+// emit writes the edges of the model not written yet
+func (s *jsonlStream) emit(model *netflow.Model) error {
+	nodes, edges := model.Export()
+	byID := make(map[string]netflow.ExportNode, len(nodes))
+	for _, n := range nodes {
+		byID[n.ID] = n
+	}
+	enc := json.NewEncoder(s.w)
+	for _, e := range edges {
+		key := jsonlKey{Source: e.Source, Target: e.Target, SrcPort: e.SrcPort, DstPort: e.DstPort}
+		if _, ok := s.seen[key]; ok {
+			continue
+		}
+		s.seen[key] = struct{}{}
+		if err := enc.Encode(jsonlEdge{ExportEdge: e, SourceNode: byID[e.Source], TargetNode: byID[e.Target]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeJSONL serializes all the edges of the model as JSON Lines, one object per edge
+func writeJSONL(w io.Writer, model *netflow.Model) error {
+	return newJSONLStream(w).emit(model)
+}
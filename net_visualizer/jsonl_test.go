@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func decodeJSONL(t *testing.T, out string) []jsonlEdge {
+	t.Helper()
+	var ret []jsonlEdge
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		var e jsonlEdge
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("line %q does not parse: %v", line, err)
+		}
+		ret = append(ret, e)
+	}
+	return ret
+}
+
+func TestWriteJSONL(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	var buf bytes.Buffer
+	if err := writeJSONL(&buf, model); err != nil {
+		t.Fatalf("writeJSONL returned unexpected error: %v", err)
+	}
+	edges := decodeJSONL(t, buf.String())
+	if len(edges) != 4 {
+		t.Fatalf("got %d lines, want one per edge:\n%s", len(edges), buf.String())
+	}
+	first := edges[0]
+	want := netflow.ExportEdge{Source: "n723715", Target: "n723429", SrcPort: 5674, DstPort: 5673, Protocol: "tcp", Count: 1}
+	if first.ExportEdge != want {
+		t.Errorf("first edge = %+v, want %+v", first.ExportEdge, want)
+	}
+	if first.SourceNode.PID != 723715 || first.TargetNode.PID != 723429 || first.TargetNode.IP != "10.42.0.58" {
+		t.Errorf("first edge endpoints = %+v and %+v, want the nodes of 723715 and 723429", first.SourceNode, first.TargetNode)
+	}
+}
+
+func TestJSONLStream(t *testing.T) {
+	lines := strings.SplitAfter(sampleTrace, "\n")
+	var buf bytes.Buffer
+	stream := newJSONLStream(&buf)
+
+	// the first half of the trace draws an edge towards each server, the second half the
+	// other two edges, and a further observation of an edge already drawn is not written
+	for _, tc := range []struct {
+		trace string
+		want  int
+	}{
+		{strings.Join(lines[:6], ""), 2},
+		{strings.Join(lines, ""), 2},
+		{strings.Join(lines, "") + lines[3], 0},
+	} {
+		buf.Reset()
+		if err := stream.emit(mustBuildModel(t, tc.trace, netflow.ModelOptions{})); err != nil {
+			t.Fatalf("emit returned unexpected error: %v", err)
+		}
+		if got := decodeJSONL(t, buf.String()); len(got) != tc.want {
+			t.Errorf("got %d new edges, want %d:\n%s", len(got), tc.want, buf.String())
+		}
+	}
+}
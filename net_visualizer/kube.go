@@ -0,0 +1,56 @@
+//go:build kube
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"net_visualizer/netflow"
+)
+
+// newKubePodResolver lists all the pods of the cluster once and returns a resolver for
+// their IPs. When kubeconfig is empty, the in-cluster config is tried first and then the
+// default kubeconfig loading rules ($KUBECONFIG, ~/.kube/config).
+func newKubePodResolver(kubeconfig string) (netflow.PodResolver, error) {
+	var config *rest.Config
+	var err error
+	if kubeconfig == "" {
+		config, err = rest.InClusterConfig()
+	}
+	if kubeconfig != "" || err != nil {
+		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+		loadingRules.ExplicitPath = kubeconfig
+		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, nil).ClientConfig()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Kubernetes config: %w", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	resolver := make(netflow.StaticPodResolver)
+	for _, pod := range pods.Items {
+		if pod.Spec.HostNetwork {
+			// these pods share the IP of the node: the IP does not identify them
+			continue
+		}
+		for _, podIP := range pod.Status.PodIPs {
+			resolver[podIP.IP] = netflow.PodInfo{Name: pod.Name, Namespace: pod.Namespace}
+		}
+	}
+	return resolver, nil
+}
@@ -0,0 +1,16 @@
+//go:build !kube
+
+package main
+
+import (
+	"errors"
+
+	"net_visualizer/netflow"
+)
+
+// errNoKubeSupport is returned by newKubePodResolver in builds without the "kube" tag
+var errNoKubeSupport = errors.New("this binary was built without Kubernetes support; rebuild with 'go build -tags kube'")
+
+func newKubePodResolver(kubeconfig string) (netflow.PodResolver, error) {
+	return nil, errNoKubeSupport
+}
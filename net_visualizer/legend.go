@@ -0,0 +1,48 @@
+package main
+
+import "fmt"
+
+// legendUsage records which of the optional styles are used by a rendered graph, so that
+// the legend only explains those
+type legendUsage struct {
+	styles    map[string]struct{} // patterns of the -style rules matched by at least one node
+	sources   map[int]struct{}    // inputs whose color was used by ColorBySource
+	crossNode bool
+	external  bool
+}
+
+func newLegendUsage() *legendUsage {
+	return &legendUsage{styles: make(map[string]struct{}), sources: make(map[int]struct{})}
+}
+
+// renderLegend adds to the graph a disconnected cluster explaining the colors and styles
+// recorded in the usage; nothing is added when no optional style is in use
+func renderLegend(graph *dotGraph, used *legendUsage, opts RenderOptions) {
+	if len(used.styles) == 0 && len(used.sources) == 0 && !used.crossNode && !used.external {
+		return
+	}
+	legend := graph.Cluster("Legend")
+
+	// rules and sources are listed in command-line order
+	for _, style := range opts.Styles {
+		if _, ok := used.styles[style.Pattern.String()]; ok {
+			style.Apply(legend.Node(dotString(fmt.Sprintf("name matches %s", style.Pattern))))
+		}
+	}
+	for src, name := range opts.SourceNames {
+		if _, ok := used.sources[src]; ok {
+			legend.Node(dotString("observed in "+name)).
+				Attr("style", "filled").
+				Attr("fillcolor", sourcePalette[src%len(sourcePalette)])
+		}
+	}
+	if used.external {
+		externalNode(legend, "endpoint never observed locally").Label("endpoint never observed locally")
+	}
+	if used.crossNode {
+		// edges need two endpoints: blank points, with unique IDs
+		from := legend.Node("legend-cross-node-from").Attr("shape", "point")
+		to := legend.Node("legend-cross-node-to").Attr("shape", "point")
+		from.Edge(to, "cross-node traffic").Bold().Attr("color", "red")
+	}
+}
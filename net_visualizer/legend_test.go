@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestRenderDOTLegend(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace+"10.42.0.60:443<-10.42.0.55:5680|PID=723736 CMD=ncat\n", netflow.ModelOptions{})
+
+	// no optional style in use: no legend
+	if out := renderDOT(model, RenderOptions{Legend: true}).String(); strings.Contains(out, "Legend") {
+		t.Errorf("unexpected legend without styles in use:\n%s", out)
+	}
+
+	var styles NodeStyles
+	styles.Set("^tcp-echo$=box:blue")
+	styles.Set("^nginx=diamond:green")
+	var cidrs NodeCIDRs
+	cidrs.Set("node-a=10.42.0.0/24")
+	out := renderDOT(model, RenderOptions{Legend: true, Styles: styles, NodeCIDRs: cidrs, ShowExternal: true}).String()
+	for _, want := range []string{
+		`label="Legend"`,
+		`[color="blue",label="name matches ^tcp-echo$",shape="box"]`,
+		`[label="endpoint never observed locally",shape="box",style="dashed"]`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, out)
+		}
+	}
+	// unused rules, and cross-node edges when all the pods live on the same node, are not listed
+	for _, unwanted := range []string{"nginx", "cross-node", "observed in"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("DOT output should not contain %s:\n%s", unwanted, out)
+		}
+	}
+
+	out = renderDOT(model, RenderOptions{Legend: true, ColorBySource: true, SourceNames: []string{"node1.trace"}}).String()
+	if want := `[fillcolor="#8dd3c7",label="observed in node1.trace",style="filled"]`; !strings.Contains(out, want) {
+		t.Errorf("DOT output does not contain %s:\n%s", want, out)
+	}
+}
+
+func TestRenderDOTLegendCrossNode(t *testing.T) {
+	trace := `10.42.1.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat
+10.42.0.55:5672->10.42.1.54:5671|PID=722977 CMD=tcp-echo
+`
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+	var cidrs NodeCIDRs
+	cidrs.Set("node-a=10.42.0.0/24")
+	cidrs.Set("node-b=10.42.1.0/24")
+	out := renderDOT(model, RenderOptions{Legend: true, NodeCIDRs: cidrs}).String()
+	if want := `[color="red",label="cross-node traffic",style="bold"]`; !strings.Contains(out, want) {
+		t.Errorf("DOT output does not contain %s:\n%s", want, out)
+	}
+}
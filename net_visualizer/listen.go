@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+)
+
+// listenUnix listens on the unix domain socket at path, replacing the stale socket file
+// left behind by a previous run, if any
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// listenSource merges the lines sent by all the feeders connecting to the listener into
+// a single input, that ends once ctx is done. Feeders can connect and disconnect at any
+// time: their lines are interleaved whole, and a feeder that goes away, cleanly or not,
+// does not affect the others. The listener is closed once ctx is done.
+func listenSource(ctx context.Context, l net.Listener) io.Reader {
+	pr, pw := io.Pipe()
+	var mu sync.Mutex // serializes the writes of whole lines
+
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+					pw.Close()
+				} else {
+					pw.CloseWithError(err)
+				}
+				return
+			}
+			infof("feeder connected to %s", l.Addr())
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					mu.Lock()
+					_, err := io.WriteString(pw, scanner.Text()+"\n")
+					mu.Unlock()
+					if err != nil {
+						return // the input was closed
+					}
+				}
+				if err := scanner.Err(); err != nil {
+					warnf("feeder of %s: %v", l.Addr(), err)
+					return
+				}
+				infof("feeder of %s disconnected", l.Addr())
+			}()
+		}
+	}()
+	return pr
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"net_visualizer/netflow"
+)
+
+func TestListenSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.sock")
+	// a stale socket file left behind by a previous run is replaced
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err := listenUnix(path)
+	if err != nil {
+		t.Fatalf("listenUnix returned unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := netflow.NewBuilder(netflow.ModelOptions{})
+	done := consumeAsync(b, []io.Reader{listenSource(ctx, l)})
+
+	// two concurrent feeders, each sending one side of the connections; the first one
+	// goes away before the second one connects
+	lines := strings.SplitAfter(strings.TrimSpace(sampleTrace), "\n")
+	var first, second strings.Builder
+	for i, line := range lines {
+		if i%2 == 0 {
+			first.WriteString(line)
+		} else {
+			second.WriteString(line)
+		}
+	}
+	for _, feed := range []string{first.String(), second.String() + "\n"} {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(conn, feed); err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+
+	// the model stays alive after the feeders are gone
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var edges int
+		b.WithModel(func(model *netflow.Model) error {
+			edges = len(model.Edges)
+			return nil
+		})
+		if edges == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d edges from the feeders, want the 4 edges of the sample trace", edges)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("the input ended with the feeders, with error %v", err)
+	default:
+	}
+
+	// the input ends once interrupted, and the socket file is gone
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("consumption ended with unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the input did not end once interrupted")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the socket file is still there: %v", err)
+	}
+}
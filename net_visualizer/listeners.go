@@ -0,0 +1,64 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"net_visualizer/netflow"
+)
+
+// compareIPs orders IPs numerically, the unparsable ones (e.g. hostnames) last, by name
+func compareIPs(a, b string) int {
+	ipA, errA := netip.ParseAddr(a)
+	ipB, errB := netip.ParseAddr(b)
+	switch {
+	case errA == nil && errB == nil:
+		return ipA.Compare(ipB)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	default:
+		return cmp.Compare(a, b)
+	}
+}
+
+// writeListeners prints the -list-listeners table: the processes with at least one
+// listening port, sorted by IP and then by port
+func writeListeners(w io.Writer, model *netflow.Model) error {
+	type listener struct {
+		n     *netflow.ProcessEndpoints
+		ports []int
+	}
+	var listeners []listener
+	for _, n := range model.SortedNodes() {
+		if ports := model.ListeningPorts(n); len(ports) > 0 {
+			listeners = append(listeners, listener{n, ports})
+		}
+	}
+	slices.SortStableFunc(listeners, func(a, b listener) int {
+		return cmp.Or(compareIPs(a.n.LocalIP, b.n.LocalIP), cmp.Compare(a.ports[0], b.ports[0]))
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PID\tNAME\tIP\tPORTS")
+	for _, l := range listeners {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", l.n.PID(), l.n.ProcessName, l.n.LocalIP, joinPorts(l.ports))
+	}
+	return tw.Flush()
+}
+
+// joinPorts formats the ports as a comma-separated list, without collapsing ranges
+func joinPorts(ports []int) string {
+	ret := make([]string, len(ports))
+	for i, port := range ports {
+		ret[i] = strconv.Itoa(port)
+	}
+	return strings.Join(ret, ",")
+}
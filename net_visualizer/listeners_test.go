@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestWriteListeners(t *testing.T) {
+	// 10.42.0.9 sorts before 10.42.0.54 numerically; the clients are left out
+	trace := sampleTrace + `10.42.0.9:9090<-10.42.0.55:5680|PID=723736 CMD=ncat
+10.42.0.55:5680->10.42.0.9:9090|PID=500 CMD=metrics
+10.42.0.9:80<-10.42.0.55:5681|PID=723736 CMD=ncat
+10.42.0.55:5681->10.42.0.9:80|PID=501 CMD=web
+`
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+	var buf bytes.Buffer
+	if err := writeListeners(&buf, model); err != nil {
+		t.Fatalf("writeListeners returned unexpected error: %v", err)
+	}
+	want := `PID     NAME      IP          PORTS
+501     web       10.42.0.9   80
+500     metrics   10.42.0.9   9090
+722977  tcp-echo  10.42.0.54  5671
+723429  ncat      10.42.0.58  5673
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got table:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCompareIPs(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"10.0.0.2", "10.0.0.10", -1},
+		{"10.0.0.10", "10.0.0.10", 0},
+		{"db.internal", "10.0.0.1", 1},
+		{"a.internal", "b.internal", -1},
+	} {
+		if got := compareIPs(tc.a, tc.b); got != tc.want {
+			t.Errorf("compareIPs(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
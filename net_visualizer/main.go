@@ -1,275 +1,717 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"net"
+	"io"
+	"maps"
 	"os"
-	"regexp"
 	"slices"
-	"strconv"
+	"strings"
+	"time"
 
-	"github.com/emicklei/dot"
+	"net_visualizer/netflow"
 )
 
-type Direction int
-
+// Exit codes of the program, besides 0 on success
 const (
-	Remote2Local Direction = iota
-	Local2Remote
+	exitError = 1 // unreadable input, failed validation, output write failure, ...
+	exitUsage = 2 // invalid flags; also used by the flag package on parse errors
 )
 
-// InputLine represents 1 line in the input of tcp_correlator, which is the output of tcp_tracer
-type InputLine struct {
-	Dir         Direction
-	RemoteIP    string // TODO: use net.IP instead
-	RemotePort  int
-	LocalIP     string // TODO: use net.IP instead
-	LocalPort   int
-	ProcessID   int64
-	ProcessName string
-}
+// usageError marks the errors due to invalid command-line flags
+type usageError struct{ error }
 
-// NetworkEndpoint represents a generic IP:port pair, which is locally-relevant, i.e. is unique only within
-// a particular container/POD assuming that IPs do not change over the container/POD lifetime
-type NetworkEndpoint struct {
-	IP   string
-	Port int
-}
+func (e usageError) Unwrap() error { return e.error }
 
-// ProcessEndpoints collects all the endpoints (intended as IP:port pairs) that are exposed
-// by the same process. This model assumes that:
-//   - each Kube container exposes 1 network interface (no multi-networks via non-standard CNIs e.g. SRIOV)
-//   - the network interface of each container has a single IP address assigned
-//   - the IP address of the network interface of each container does not change during the lifetime of the PIDs
-//     living inside the PODs and using the network
-type ProcessEndpoints struct {
-	ProcessID   int64
-	ProcessName string
-	LocalIP     string
-	LocalPorts  []int
-	DotNode     dot.Node
+// fatal reports the error to the user and terminates the program with the exit code
+// matching its kind
+func fatal(err error) {
+	fmt.Fprintf(logOutput, "ERROR: %v\n", err)
+	if errors.As(err, new(usageError)) {
+		os.Exit(exitUsage)
+	}
+	os.Exit(exitError)
 }
 
-type ProcessEndpoint struct {
-	PID  int64
-	Port int
+// strictFailure returns the error reporting the line that failed parsing under -strict,
+// with its position, if err is a netflow.StrictError; nil otherwise
+func strictFailure(err error, sourceNames []string, offsets lineOffsets) error {
+	var strictErr *netflow.StrictError
+	if !errors.As(err, &strictErr) {
+		return nil
+	}
+	lineNum := offsets.lineNum(strictErr.Source, strictErr.LineNum)
+	return fmt.Errorf("-strict: %s:%d: cannot parse %q", sourceNames[strictErr.Source], lineNum, strictErr.Line)
 }
 
-// Edge represents a uniquely-identified TCP connection between two processes
-// (with the assumptions listed in ProcessEndpoints)
-type Edge struct {
-	Source ProcessEndpoint
-	Dest   ProcessEndpoint
+// logOutput receives the errors, warnings and summaries meant for the user: stderr, unless
+// -quiet, in which case only the exit code tells about failures
+var logOutput io.Writer = os.Stderr
+
+// warnf reports a non-fatal problem to the user
+func warnf(format string, args ...any) {
+	fmt.Fprintf(logOutput, "WARNING: "+format+"\n", args...)
 }
 
-// Regex to parse lines
-var regexLocalToRemote = regexp.MustCompile(`(.+):(\d+)<-(.+):(\d+)\|PID=(\d+) CMD=(.+)`)
-var regexRemoteToLocal = regexp.MustCompile(`(.+):(\d+)->(.+):(\d+)\|PID=(\d+) CMD=(.+)`)
+// infoOutput receives the informational messages enabled by -verbose
+var infoOutput io.Writer = io.Discard
 
-func parseLine(line string) (InputLine, error) {
-	var ret InputLine
-	var err error
-	var matches []string
+// infof reports progress details, only shown with -verbose
+func infof(format string, args ...any) {
+	fmt.Fprintf(infoOutput, "INFO: "+format+"\n", args...)
+}
 
-	if matches = regexLocalToRemote.FindStringSubmatch(line); len(matches) > 0 {
-		// Parsed reverse direction
-		ret.Dir = Local2Remote
-	} else if matches = regexRemoteToLocal.FindStringSubmatch(line); len(matches) > 0 {
-		// Parsed forward direction
-		ret.Dir = Remote2Local
-	} else {
-		return InputLine{}, fmt.Errorf("skipping invalid line: %s", line)
-	}
+// isFlagSet tells whether the flag was given on the command line, as opposed to left
+// to its default value
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
 
-	ret.RemoteIP = matches[1]
-	ret.RemotePort, err = strconv.Atoi(matches[2])
-	if err != nil {
-		return InputLine{}, fmt.Errorf("skipping invalid line: %s", line)
+func main() {
+	var modelOpts netflow.ModelOptions
+	flag.BoolVar(&modelOpts.Filter.DropLinkLocal, "drop-link-local", false, "drop connections involving link-local addresses (169.254.0.0/16, fe80::/10)")
+	flag.BoolVar(&modelOpts.Filter.DropMulticast, "drop-multicast", false, "drop connections involving multicast addresses")
+	flag.BoolVar(&modelOpts.Filter.DropULA, "drop-ula", false, "drop connections involving IPv6 unique local addresses (fc00::/7)")
+	flag.Var(&modelOpts.Filter.ExcludeProcesses, "exclude-process", "drop all the connections of the processes with this `name`, e.g. k3s-server (repeatable)")
+	var dstPorts netflow.PortSet
+	flag.Var(&dstPorts, "dst-ports", "only draw the connections towards these comma-separated destination (listening) `ports`, e.g. 5432,3306, and the processes at their ends (repeatable)")
+	mesh := flag.Bool("mesh", false, "fold the service mesh sidecars, listening on the -sidecar-ports, into the connections they proxy: app->sidecar->peer chains are drawn as app->peer edges labeled (via sidecar)")
+	var sidecarPorts netflow.PortSet
+	flag.Var(&sidecarPorts, "sidecar-ports", "comma-separated listening `ports` identifying the sidecars for -mesh (default 15001,15006, the ones of Envoy in Istio)")
+	var includeProcesses netflow.NameSet
+	flag.Var(&includeProcesses, "include-process", "only draw the connections of the processes with this `name`, and their peers; applied before -exclude-process (repeatable)")
+	flag.Var(&modelOpts.Filter.ExcludePIDs, "exclude-pid", "drop all the connections of the process with this `PID`, e.g. a noisy monitoring agent (repeatable)")
+	portsAllowlist := flag.String("ports-allowlist", "", "file listing the destination ports to draw, one per line; connections towards other ports are dropped")
+	portsDenylist := flag.String("ports-denylist", "", "file listing destination ports whose connections are dropped, one per line")
+	flag.IntVar(&modelOpts.MaxTrackedNodes, "max-tracked-nodes", 0, "bound memory usage by tracking at most this many processes, forgetting the least-recently-seen ones (0 = unlimited)")
+	flag.IntVar(&modelOpts.MaxTrackedEdges, "max-tracked-edges", 0, "bound memory usage by tracking at most this many edges, forgetting the least-recently-seen ones (0 = unlimited)")
+	flag.IntVar(&modelOpts.MaxNodes, "max-nodes", 0, "cap the graph to this many processes, dropping the ones seen afterwards; a banner node tells how many were dropped (0 = unlimited)")
+	flag.IntVar(&modelOpts.MaxEdges, "max-edges", 0, "cap the graph to this many edges, dropping the ones seen afterwards; a banner node tells how many were dropped (0 = unlimited)")
+	flag.BoolVar(&modelOpts.AllowSelfEdges, "allow-self-edges", false, "draw connections between two endpoints of the same process, dropped by default")
+	flag.DurationVar(&modelOpts.DedupWindow, "dedup-window", 0, "count the observations of the same edge less than this `duration` apart as a single connection, to tame reconnection churn; no-op if the input has no timestamps")
+	flag.IntVar(&modelOpts.Parallel, "parallel", 1, "number of goroutines parsing the input lines, to speed up huge inputs on multi-core machines (1 = serial)")
+	flag.BoolVar(&modelOpts.ConcurrentSources, "concurrent-inputs", false, "read all the inputs at the same time instead of one after the other, e.g. the live feeds of several hosts through named pipes")
+	flag.Float64Var(&modelOpts.Sample, "sample", 1, "randomly keep this `fraction` (0-1] of the input lines, to explore huge inputs")
+	seed := flag.Int64("seed", 0, "seed of the -sample selection, for reproducible runs (default random)")
+	explain := flag.String("explain", "", "print to stderr how each line observing the `src_ip:port->dst_ip:port` connection is processed, to tell why it is or is not drawn")
+	resolveHosts := flag.Bool("resolve-hosts", false, "resolve the endpoints reported as hostnames to IPs, through DNS; by default they are kept as-is")
+	printStats := flag.Bool("stats", false, "print a summary of the processing to stderr")
+	quiet := flag.Bool("quiet", false, "do not print anything to stderr, not even errors: only the output and the exit code; overrides -stats")
+	verbose := flag.Bool("verbose", false, "print informational messages to stderr, e.g. the detected compression of the inputs")
+	var renderOpts RenderOptions
+	resolveServices := flag.Bool("resolve-services", false, "annotate ports in edge labels with their service name, from a builtin table and /etc/services")
+	servicesFile := flag.String("services-file", "", "additional port-to-name mappings in /etc/services format; implies -resolve-services")
+	labelServices := flag.Bool("label-services", false, "replace the destination ports in edge labels with their service name, e.g. 5432 with postgres, from an embedded table of well-known ports")
+	flag.BoolVar(&renderOpts.GroupByPod, "group-by-pod", false, "draw the processes sharing the same container, as reported by the tracer, or else the same IP address (i.e. the same pod) inside a common cluster")
+	flag.BoolVar(&renderOpts.NoDegree, "no-degree", false, "do not show the fan-in/fan-out of each process in node labels")
+	flag.BoolVar(&renderOpts.NoPortsInLabel, "no-ports-in-label", false, "do not show the listening ports of each process in node labels")
+	flag.BoolVar(&renderOpts.FullLabels, "full-labels", false, "always show IP:port pairs in edge labels; by default intra-pod edges only show the ports")
+	flag.IntVar(&renderOpts.MaxNameLen, "max-name-len", 120, "truncate process names longer than this many characters in node labels, keeping the full name in the tooltip (0 = no limit)")
+	clusterLabelTemplate := flag.String("cluster-label-template", "", "Go text/template producing the labels of the pod clusters, with the fields {{.IP}}, {{.PodName}}, {{.Namespace}}, {{.ContainerID}} and {{.ProcessCount}}; implies -group-by-pod (default: the pod, else the container, else the IP)")
+	edgeLabelTemplate := flag.String("edge-label-template", "", "Go text/template producing the edge labels, with fields such as {{.SrcIP}}, {{.DstPort}}, {{.Count}} and {{.Protocol}}; see the README for the full list (default: the builtin labels)")
+	flag.BoolVar(&renderOpts.PortRecords, "port-records", false, "draw each process with listening ports as a table with a cell per port, the connections pointing at the cell of their destination port")
+	flag.BoolVar(&renderOpts.ClientCounts, "client-counts", false, "annotate the listening ports in node labels with their number of distinct clients, e.g. 8080 (37 clients)")
+	flag.BoolVar(&renderOpts.ShowRoles, "show-roles", false, "append the [client->server] roles, as inferred from the listening ports, to edge labels")
+	flag.BoolVar(&renderOpts.Bidirectional, "bidirectional", false, "draw the connections observed from both of their ends, by the client and by the server, with a double-headed arrow")
+	flag.BoolVar(&renderOpts.DirectionCounts, "direction-counts", false, "append to edge labels how many times the connection was observed by the client (fwd) and by the server (rev), flagging the edges observed far more often from one side as (incomplete capture?)")
+	flag.BoolVar(&renderOpts.EdgeAttrs, "edge-attrs", false, "attach the dst_port, protocol and count of each edge as custom DOT attributes, plus src_role and dst_role with -show-roles, for the tools processing the DOT output")
+	flag.BoolVar(&renderOpts.ShowExternal, "show-external", false, "draw the connections towards endpoints never observed locally (e.g. peers outside of the traced hosts) as edges to placeholder nodes")
+	flag.BoolVar(&renderOpts.CollapseExternal, "collapse-external", false, "merge all the placeholder nodes of -show-external into a single INTERNET node, or one node per -external-cidr, counting the connections; implies -show-external")
+	flag.Var(&renderOpts.ExternalCIDRs, "external-cidr", "`name=CIDR` bucket of the external peers merged by -collapse-external, e.g. 'aws=52.0.0.0/8'; the first matching bucket wins, the other peers go to INTERNET (repeatable)")
+	flag.StringVar(&renderOpts.WeightBy, "weight-by", weightByCount, "metric driving the edge widths: "+strings.Join(validWeightMetrics, ", ")+"; bytes and packets, reported by the BYTES= and PKTS= tokens of the tracer, are also shown in the edge labels")
+	flag.BoolVar(&renderOpts.NoWeight, "no-weight", false, "draw all the edges with the same width; by default the width grows with the number of times the connection was observed")
+	flag.BoolVar(&renderOpts.Legend, "legend", false, "add a legend explaining the colors and styles used in the DOT graph")
+	flag.BoolVar(&renderOpts.SizeByDegree, "size-by-degree", false, "draw the processes with many edges larger, growing with the logarithm of their number of edges up to a cap, to make the hubs stand out")
+	flag.BoolVar(&renderOpts.ColorByName, "color-by-name", false, "fill each node with a color derived from its process name, so that a service looks the same across graphs and runs")
+	flag.BoolVar(&renderOpts.ColorBySource, "color-by-source", false, "when merging several input files, color each node after the file(s) it was observed in")
+	flag.Var(&renderOpts.Styles, "style", "`regex=shape:color` style of the nodes whose process name matches the regex, e.g. 'nginx.*=box:blue'; the first matching rule wins (repeatable)")
+	flag.Var(&modelOpts.GroupCIDRs, "group-cidr", "`name=CIDR` range drawn as a single node named after it, e.g. 'rds-cluster=10.0.8.0/22', with the connections of all its IPs; the first matching range wins (repeatable)")
+	nodeMap := flag.String("node-map", "", "file mapping pod IPs or CIDRs to cluster node names, one \"<IP or CIDR> <node>\" per line: the pod clusters are drawn inside a cluster per node; implies -group-by-pod")
+	flag.Var(&renderOpts.NodeCIDRs, "node-cidr", "`name=CIDR` mapping of the pod CIDR of a cluster node; edges between different nodes are highlighted (repeatable)")
+	noHeader := flag.Bool("no-header", false, "do not label the DOT graph with the version of net_visualizer, the generation time, the inputs and the active filters")
+	noHeaderTimestamp := flag.Bool("no-header-timestamp", false, "leave the generation time out of the graph label, for reproducible outputs")
+	flag.StringVar(&renderOpts.RankDir, "rankdir", "", "direction of the DOT graph layout: "+strings.Join(validRankDirs, ", ")+" (default top-down)")
+	flag.StringVar(&renderOpts.Layout, "layout", "", "Graphviz layout engine hint: "+strings.Join(validLayouts, ", ")+" (default dot)")
+	kube := flag.Bool("kube", false, "resolve IPs to Kubernetes pods and namespaces, for node labels and pod clusters; implies -group-by-pod")
+	kubeconfig := flag.String("kubeconfig", "", "path to the kubeconfig used by -kube; defaults to the in-cluster config, then to $KUBECONFIG or ~/.kube/config")
+	regex := flag.String("regex", "", "custom regex to parse input lines, with named groups remote_ip, remote_port, local_ip, local_port, pid, cmd, dir")
+	inputFormat := flag.String("input-format", "text", "format of the inputs: text, the lines of the tracer, or proto, a stream of size-prefixed protobuf records (see netflow/record.proto)")
+	strict := flag.Bool("strict", false, "fail at the first input line that cannot be parsed, instead of skipping it; blank lines and tracer banners are still ignored")
+	diff := flag.Bool("diff", false, "compare two -format json snapshots given as arguments, old then new: print the added and removed processes and connections, and write the graph of the changes to -o, if given")
+	validate := flag.Bool("validate", false, "only check that every input line can be parsed, printing a summary; exits non-zero on failures")
+	format := flag.String("format", "dot", "output format: "+strings.Join(outputFormats, ", "))
+	output := flag.String("o", "", "write the output to this file instead of stdout")
+	var guard sizeGuard
+	flag.IntVar(&guard.Nodes, "warn-nodes", 2000, "warn when the graph has more than this many processes, too many for Graphviz to lay out in a reasonable time; svg, png and html outputs are then refused unless -force (0 = no limit)")
+	flag.IntVar(&guard.Edges, "warn-edges", 5000, "warn when the graph has more than this many edges, see -warn-nodes (0 = no limit)")
+	flag.BoolVar(&guard.Force, "force", false, "render the svg, png and html outputs even beyond -warn-nodes and -warn-edges")
+	staleAfter := flag.Duration("stale-after", 0, "drop the edges last observed more than this `duration` before the latest timestamp of the input; no-op if the input has no timestamps")
+	serversOnly := flag.Bool("servers-only", false, "only draw the servers, i.e. the processes accepting connections on a listening port, and the connections between them")
+	clientsOnly := flag.Bool("clients-only", false, "only draw the pure clients, i.e. the processes not accepting any connection, with the connections they make")
+	boundaryOnly := flag.Bool("boundary-only", false, "only draw the connections crossing pods, i.e. between different containers or IPs, or -kube pods; intra-pod edges are dropped")
+	namespaceBoundary := flag.Bool("namespace-boundary", false, "with -boundary-only and -kube, only draw the connections crossing namespaces instead of pods")
+	mergeForks := flag.Bool("merge-forks", false, "merge the processes sharing the same name and IP, e.g. the workers of a server, into a single node")
+	suppressChatty := flag.Int("suppress-chatty", 0, "collapse the processes with more than this many distinct edges into a single summarized node, without their edges (0 = disabled)")
+	focus := flag.String("focus", "", "only draw the process with this `PID or name` and its neighbors; with a name, all the processes with that name are focused")
+	focusDepth := flag.Int("focus-depth", 1, "with -focus, also draw the processes up to this many hops away from the focused ones")
+	minPeers := flag.Int("min-peers", 0, "only draw the services shared by at least this many distinct peers, i.e. client processes or external IPs: the connections towards the listening ports with fewer peers are dropped, with the processes left without any (0 = disabled)")
+	minConnections := flag.Int("min-connections-per-node", 0, "only draw the core of the graph, repeatedly pruning the processes with fewer than this many edges, e.g. the one-off clients (0 = disabled)")
+	splitComponents := flag.Bool("split-components", false, "write each connected component of the graph to its own file, named after -o (graph.dot -> graph-1.dot, ...) or topo-1.<format>, topo-2.<format>, ...")
+	tail := flag.Int("tail", 0, "only process the last `N` lines of each input, for a quick view of the most recent topology of a large capture (0 = all the lines)")
+	maxDuration := flag.Duration("max-duration", 0, "stop reading the input after this `duration` (e.g. 10m), writing the graph of what was read so far")
+	perNamespace := flag.Bool("per-namespace", false, "with -kube, write the graph of each namespace to its own file, named after -o (graph.dot -> graph-<namespace>.dot, ...) or <namespace>.<format>, plus the edges between namespaces to cross-namespace.<format>")
+	anonymize := flag.Bool("anonymize", false, "replace IPs, process names and pods with placeholders (host1, proc1, ...) in all the outputs, e.g. to share diagrams externally")
+	anonymizeMap := flag.String("anonymize-map", "", "with -anonymize, write the placeholder-to-identifier mapping to this `file`, for internal re-identification")
+	listen := flag.String("listen", "", "read the input from the feeders connecting to the unix domain socket at this `path`, instead of files or stdin, until interrupted; typically used with -watch")
+	errorsFile := flag.String("errors-file", "", "write every input line that cannot be parsed, with its input, line number and reason, to this `file`, e.g. to report a drift of the tracer format; the graph is written as usual")
+	dumpEndpoints := flag.String("dump-endpoints", "", "after processing, write the table correlating the local endpoints (IP:port) to the processes owning them to this `file`, or - for stderr, to debug why a connection is or is not drawn")
+	listListeners := flag.Bool("list-listeners", false, "instead of the graph, print a table of the processes with their listening ports")
+	watch := flag.Duration("watch", 0, "keep reading the input and rewrite the -o file with the updated graph at this `interval` (e.g. 5s)")
+	configPath := flag.String("config", "", "YAML or JSON `file` setting flags, as a mapping of their names to values, e.g. 'format: svg'; the flags given on the command line take precedence")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [trace-file ...]\n\nReads the tracer output from the given files, or from stdin when none is given.\n\nFlags:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *configPath != "" {
+		config, err := loadConfig(*configPath)
+		if err != nil {
+			fatal(err)
+		}
+		if err := applyConfig(flag.CommandLine, config, *configPath); err != nil {
+			fatal(usageError{err})
+		}
+	}
+	if *quiet {
+		logOutput = io.Discard
+		netflow.LogOutput = io.Discard
+	} else if *verbose {
+		infoOutput = os.Stderr
+		netflow.InfoOutput = os.Stderr
 	}
 
-	ret.LocalIP = matches[3]
-	ret.LocalPort, err = strconv.Atoi(matches[4])
-	if err != nil {
-		return InputLine{}, fmt.Errorf("skipping invalid line: %s", line)
+	if *diff {
+		if flag.NArg() != 2 {
+			fatal(usageError{errors.New("-diff requires two arguments: the old and the new -format json snapshots")})
+		}
+		if err := runDiff(flag.Arg(0), flag.Arg(1), *output); err != nil {
+			fatal(err)
+		}
+		return
 	}
 
-	ret.ProcessID, err = strconv.ParseInt(matches[5], 10, 64)
-	if err != nil {
-		return InputLine{}, fmt.Errorf("skipping invalid line: %s", line)
+	if err := validateFormat(*format); err != nil {
+		fatal(usageError{err})
+	}
+	if err := renderOpts.Validate(); err != nil {
+		fatal(usageError{err})
+	}
+	if *edgeLabelTemplate != "" {
+		var err error
+		if renderOpts.EdgeLabelTemplate, err = parseEdgeLabelTemplate(*edgeLabelTemplate); err != nil {
+			fatal(usageError{err})
+		}
+	}
+	if *clusterLabelTemplate != "" {
+		var err error
+		if renderOpts.ClusterLabelTemplate, err = parseClusterLabelTemplate(*clusterLabelTemplate); err != nil {
+			fatal(usageError{err})
+		}
+		renderOpts.GroupByPod = true
+	}
+	if modelOpts.Parallel < 1 {
+		fatal(usageError{fmt.Errorf("invalid -parallel %d", modelOpts.Parallel)})
+	}
+	if modelOpts.Sample <= 0 || modelOpts.Sample > 1 {
+		fatal(usageError{fmt.Errorf("invalid -sample %g: must be in (0, 1]", modelOpts.Sample)})
+	}
+	modelOpts.Seed = uint64(*seed)
+	if !isFlagSet("seed") {
+		modelOpts.Seed = uint64(time.Now().UnixNano())
+	}
+	if modelOpts.DedupWindow < 0 {
+		fatal(usageError{fmt.Errorf("invalid -dedup-window %s", modelOpts.DedupWindow)})
+	}
+	if *staleAfter < 0 {
+		fatal(usageError{fmt.Errorf("invalid -stale-after %s", *staleAfter)})
+	}
+	if len(sidecarPorts) == 0 {
+		sidecarPorts = netflow.DefaultSidecarPorts
+	} else if !*mesh {
+		fatal(usageError{errors.New("-sidecar-ports requires -mesh")})
+	}
+	if *minConnections < 0 {
+		fatal(usageError{fmt.Errorf("invalid -min-connections-per-node %d", *minConnections)})
+	}
+	if *minPeers < 0 {
+		fatal(usageError{fmt.Errorf("invalid -min-peers %d", *minPeers)})
+	}
+	if guard.Nodes < 0 || guard.Edges < 0 {
+		fatal(usageError{fmt.Errorf("invalid -warn-nodes %d or -warn-edges %d", guard.Nodes, guard.Edges)})
+	}
+	if *suppressChatty < 0 {
+		fatal(usageError{fmt.Errorf("invalid -suppress-chatty %d", *suppressChatty)})
+	}
+	if *focusDepth < 0 {
+		fatal(usageError{fmt.Errorf("invalid -focus-depth %d", *focusDepth)})
+	}
+	if *maxDuration < 0 {
+		fatal(usageError{fmt.Errorf("invalid -max-duration %s", *maxDuration)})
+	}
+	if *anonymizeMap != "" && !*anonymize {
+		fatal(usageError{errors.New("-anonymize-map requires -anonymize")})
+	}
+	if *perNamespace && (!*kube || *splitComponents) {
+		fatal(usageError{errors.New("-per-namespace requires -kube, and cannot be combined with -split-components")})
+	}
+	if *namespaceBoundary && (!*boundaryOnly || !*kube) {
+		fatal(usageError{errors.New("-namespace-boundary requires -boundary-only and -kube")})
+	}
+	if renderOpts.ColorByName && renderOpts.ColorBySource {
+		fatal(usageError{errors.New("-color-by-name and -color-by-source are mutually exclusive")})
+	}
+	if *serversOnly && *clientsOnly {
+		fatal(usageError{errors.New("-servers-only and -clients-only are mutually exclusive")})
+	}
+	if len(renderOpts.ExternalCIDRs) > 0 && !renderOpts.CollapseExternal {
+		fatal(usageError{errors.New("-external-cidr requires -collapse-external")})
+	}
+	renderOpts.ShowExternal = renderOpts.ShowExternal || renderOpts.CollapseExternal
+	if *listen != "" && (flag.NArg() > 0 || *validate) {
+		fatal(usageError{errors.New("-listen cannot be combined with input files or -validate")})
+	}
+	// with -format jsonl, -watch streams the new edges instead of rewriting the output
+	streamJSONL := *watch > 0 && *format == "jsonl"
+	if *watch < 0 || (*watch > 0 && *output == "" && !streamJSONL) {
+		fatal(usageError{errors.New("-watch requires a positive interval and an -o output file, unless -format jsonl")})
+	}
+	if *tail < 0 || (*tail > 0 && (*watch > 0 || *listen != "" || *inputFormat == "proto")) {
+		fatal(usageError{errors.New("-tail requires a positive number of lines, and cannot be combined with -watch, -listen or -input-format proto")})
+	}
+	if *dumpEndpoints != "" && *validate {
+		fatal(usageError{errors.New("-dump-endpoints cannot be combined with -validate")})
+	}
+	if *errorsFile != "" && *validate {
+		fatal(usageError{errors.New("-errors-file cannot be combined with -validate, that lists the invalid lines itself")})
+	}
+	if *listListeners && (*watch > 0 || *splitComponents || *perNamespace) {
+		fatal(usageError{errors.New("-list-listeners cannot be combined with -watch, -split-components or -per-namespace")})
+	}
+	if streamJSONL && (*splitComponents || *perNamespace) {
+		fatal(usageError{errors.New("-watch with -format jsonl cannot be combined with -split-components or -per-namespace")})
 	}
 
-	ret.ProcessName = matches[6]
+	switch *inputFormat {
+	case "text":
+	case "proto":
+		if *regex != "" || *validate {
+			fatal(usageError{errors.New("-input-format proto cannot be combined with -regex or -validate")})
+		}
+		modelOpts.BinaryInput = true
+	default:
+		fatal(usageError{fmt.Errorf("unknown input format %q; valid formats: text, proto", *inputFormat)})
+	}
 
-	return ret, nil
-}
+	modelOpts.Strict = *strict
 
-// IsValidLine checks if the local/remote IP addresses are worth showing in the DOT graph or not
-// E.g. filters out anything that is on the 127.0.0.0/8 network
-func IsValidLine(line InputLine) bool {
-	if line.LocalPort == 0 || line.RemotePort == 0 {
-		return false
+	if *regex != "" {
+		var err error
+		modelOpts.Parser, err = netflow.NewLineParser(*regex)
+		if err != nil {
+			fatal(usageError{err})
+		}
 	}
 
-	localIP := net.ParseIP(line.LocalIP)
-	remoteIP := net.ParseIP(line.RemoteIP)
-	loopbackNet := net.IPNet{
-		IP:   net.IPv4(127, 0, 0, 0),
-		Mask: net.CIDRMask(8, 32),
+	if *explain != "" {
+		conn, err := netflow.ParseConnection(*explain)
+		if err != nil {
+			fatal(usageError{fmt.Errorf("invalid -explain: %w", err)})
+		}
+		// explicitly requested: not affected by -quiet
+		modelOpts.Explain = &netflow.Explainer{Conn: conn, Out: os.Stderr}
 	}
-	if loopbackNet.Contains(localIP) || loopbackNet.Contains(remoteIP) {
-		return false
+	if *resolveHosts {
+		modelOpts.Hosts = netflow.NewHostResolver()
+	}
+	if *portsAllowlist != "" {
+		var err error
+		modelOpts.Filter.AllowPorts, err = netflow.LoadPortSet(*portsAllowlist)
+		if err != nil {
+			fatal(err)
+		}
+		if len(modelOpts.Filter.AllowPorts) == 0 {
+			warnf("%s is empty: all connections will be dropped", *portsAllowlist)
+		}
+	}
+	if *portsDenylist != "" {
+		var err error
+		modelOpts.Filter.DenyPorts, err = netflow.LoadPortSet(*portsDenylist)
+		if err != nil {
+			fatal(err)
+		}
 	}
 
-	if line.ProcessName == "k3s-server" {
-		// k3s-server is SO chatty... skip any TCP connection landing or departing from it
-		return false
+	if *nodeMap != "" {
+		var err error
+		renderOpts.NodeMap, err = loadNodeMap(*nodeMap)
+		if err != nil {
+			fatal(err)
+		}
+		if len(renderOpts.NodeMap) == 0 {
+			warnf("%s is empty: all the pods will be drawn as unscheduled", *nodeMap)
+		}
+		renderOpts.GroupByPod = true
 	}
 
-	return true
-}
+	if *resolveServices || *servicesFile != "" {
+		services, err := loadServices(*servicesFile)
+		if err != nil {
+			fatal(err)
+		}
+		renderOpts.Services = services
+	}
 
-func createGraphFromStdin() (*dot.Graph, error) {
-	// Create a new DOT graph
-	graph := dot.NewGraph(dot.Directed)
+	if *labelServices {
+		renderOpts.ServiceLabels = wellKnownServices()
+	}
 
-	// Maps to store nodes and edges
-	nodes := make(map[int64]ProcessEndpoints)         // PID -> Node
-	knownEndpoints := make(map[NetworkEndpoint]int64) // Endpoint (IP:Port) -> PID
-	edges := make(map[Edge]struct{})                  // Edge -> presence flag
+	// list the pods upfront: by the time the input is consumed, short-lived pods may be gone
+	var podResolver netflow.PodResolver
+	if *kube {
+		var err error
+		podResolver, err = newKubePodResolver(*kubeconfig)
+		if err != nil {
+			fatal(err)
+		}
+		renderOpts.GroupByPod = true
+	}
 
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		line := scanner.Text()
-		parsedLine, err := parseLine(line)
+	// input files are merged into a single topology; no file means stdin. Compressed
+	// inputs are transparently decompressed.
+	var sources []io.Reader
+	var offsets lineOffsets // of the lines of the inputs, with -tail
+	addSource := func(r io.Reader, name string) {
+		dr, codec, err := decompress(r, name)
 		if err != nil {
-			continue
+			fatal(fmt.Errorf("%s: %w", name, err))
+		}
+		if codec != "" {
+			infof("%s is %s compressed", name, codec)
 		}
+		var skipped func() (int, error)
+		if *tail > 0 {
+			if dr, skipped, err = tailInput(r, dr, codec, *tail); err != nil {
+				fatal(fmt.Errorf("%s: %w", name, err))
+			}
+		}
+		sources = append(sources, dr)
+		offsets = append(offsets, skipped)
+		renderOpts.SourceNames = append(renderOpts.SourceNames, name)
+	}
+	if flag.NArg() == 0 && *listen == "" {
+		addSource(os.Stdin, "stdin")
+	}
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		addSource(f, path)
+	}
 
-		// IP filter using net package
-		if !IsValidLine(parsedLine) {
-			//fmt.Printf("Skipping loopback IP line: %s\n", line)
-			continue
+	if *validate {
+		res, err := validateInput(sources, modelOpts.Parser)
+		netflow.FlushWarnings()
+		for i, f := range res.Failures {
+			res.Failures[i].LineNum = offsets.lineNum(f.Source, f.LineNum)
+		}
+		res.Print(logOutput, renderOpts.SourceNames)
+		if err != nil {
+			fatal(err)
+		}
+		if res.Failed > 0 {
+			fatal(fmt.Errorf("%d invalid lines", res.Failed))
 		}
+		return
+	}
 
-		// Create if the PID in this line is known or not
-		n, pidIsKnown := nodes[parsedLine.ProcessID]
-		if !pidIsKnown {
-			// found a new process
-			nodes[parsedLine.ProcessID] = ProcessEndpoints{
-				ProcessID:   parsedLine.ProcessID,
-				ProcessName: parsedLine.ProcessName,
-				LocalIP:     parsedLine.LocalIP,
-				LocalPorts:  []int{parsedLine.LocalPort},
-				DotNode:     graph.Node(fmt.Sprintf("PID=%d\nName=%s\nIP=%s", parsedLine.ProcessID, parsedLine.ProcessName, parsedLine.LocalIP)),
-			}
-		} else {
-			if n.LocalIP != parsedLine.LocalIP {
-				panic(fmt.Sprintf("assumption not respected: %s %s", n.LocalIP, parsedLine.LocalIP))
+	dest := "stdout"
+	if *output != "" {
+		dest = *output
+	}
+	var jsonl *jsonlStream
+	if streamJSONL {
+		w := io.Writer(os.Stdout)
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				fatal(err)
 			}
-			if n.ProcessID != parsedLine.ProcessID {
-				panic("logical bug??")
+			defer f.Close()
+			w = f
+		}
+		jsonl = newJSONLStream(w)
+	}
+	var anonymizer *netflow.Anonymizer
+	if *anonymize {
+		anonymizer = netflow.NewAnonymizer()
+	}
+	pods := podOptions{Resolver: podResolver, BoundaryOnly: *boundaryOnly, Anonymizer: anonymizer}
+	if *namespaceBoundary {
+		pods.Boundary = netflow.NamespaceBoundary
+	}
+	emit := func(model *netflow.Model) error {
+		if modelOpts.DedupWindow > 0 && model.Latest.IsZero() {
+			infof("the input has no timestamps: -dedup-window has no effect")
+		}
+		if *staleAfter > 0 {
+			if model.Latest.IsZero() {
+				infof("the input has no timestamps: -stale-after has no effect")
 			}
-			if n.ProcessName != parsedLine.ProcessName {
-				panic("PID reuse??")
+			model = model.DropStale(*staleAfter)
+		}
+		if len(includeProcesses) > 0 {
+			model = model.IncludeProcesses(includeProcesses)
+		}
+		if *mergeForks {
+			model = model.MergeForks()
+		}
+		if *mesh {
+			model = model.CollapseSidecars(sidecarPorts)
+		}
+		if *serversOnly {
+			model = model.ServersOnly()
+		} else if *clientsOnly {
+			model = model.ClientsOnly()
+		}
+		if len(dstPorts) > 0 {
+			model = model.DestinationPorts(dstPorts)
+		}
+		if *focus != "" {
+			model = model.Neighborhood(model.FindProcesses(*focus), *focusDepth)
+		}
+		if *minPeers > 0 {
+			var ports, pruned int
+			model, ports, pruned = model.MinPeers(*minPeers)
+			fmt.Fprintf(logOutput, "Pruned %d listening ports with fewer than %d peers, and %d processes left without connections\n", ports, *minPeers, pruned)
+		}
+		if *minConnections > 0 {
+			var pruned int
+			model, pruned = model.PruneLowDegree(*minConnections)
+			fmt.Fprintf(logOutput, "Pruned %d processes with fewer than %d connections\n", pruned, *minConnections)
+		}
+		if *suppressChatty > 0 {
+			model = model.CollapseChatty(*suppressChatty)
+		}
+		model = pods.apply(model)
+		if anonymizer != nil && *anonymizeMap != "" {
+			if err := writeFileAtomic(*anonymizeMap, anonymizer.WriteMapping); err != nil {
+				return fmt.Errorf("writing to %s: %w", *anonymizeMap, err)
 			}
-
-			// should we enrich existing process?
-			portIdx := slices.IndexFunc(n.LocalPorts, func(c int) bool { return c == parsedLine.LocalPort })
-			if portIdx == -1 {
-				// found a new exposed port
-				n.LocalPorts = append(n.LocalPorts, parsedLine.LocalPort)
-			} // else: port was already known... nothing to do
-
-			// update map
-			nodes[parsedLine.ProcessID] = n
 		}
-
-		// should we register the local endpoint to the local PID ?
-		localEp := NetworkEndpoint{
-			IP:   parsedLine.LocalIP,
-			Port: parsedLine.LocalPort,
+		if jsonl != nil {
+			if err := jsonl.emit(model); err != nil {
+				return fmt.Errorf("writing to %s: %w", dest, err)
+			}
+			return nil
 		}
-		e, localEpIsKnown := knownEndpoints[localEp]
-		if !localEpIsKnown {
-			// Register the local endpoint in the list of known endpoints:
-			knownEndpoints[localEp] = parsedLine.ProcessID
-		} else {
-			// already known... logical check:
-			if e != parsedLine.ProcessID {
-				panic("logical bug??")
+		writePart := func(path string, part *netflow.Model) error {
+			if err := guard.check(logOutput, part, *format); err != nil {
+				return fmt.Errorf("writing to %s: %w", path, err)
 			}
+			if err := writeOutputFile(path, *format, part, renderOpts); err != nil {
+				return fmt.Errorf("writing to %s: %w", path, err)
+			}
+			return nil
 		}
-
-		// If we know the PID listening on the remoteIP:remotePort endpoint,
-		// we can draw an edge:
-		remoteEp := NetworkEndpoint{
-			IP:   parsedLine.RemoteIP,
-			Port: parsedLine.RemotePort,
-		}
-		remotePID, isRemotePIDKnown := knownEndpoints[remoteEp]
-		if isRemotePIDKnown {
-			// we have all the info to build an edge
-			edge := Edge{
-				Source: ProcessEndpoint{
-					PID:  parsedLine.ProcessID,
-					Port: parsedLine.LocalPort,
-				},
-				Dest: ProcessEndpoint{
-					PID:  remotePID,
-					Port: parsedLine.RemotePort,
-				},
+		if *splitComponents {
+			components := model.Components()
+			fmt.Fprintf(logOutput, "Found %d connected components\n", len(components))
+			for i, c := range components {
+				if err := writePart(componentPath(*output, *format, i+1), c); err != nil {
+					return err
+				}
 			}
-			if parsedLine.Dir == Remote2Local {
-				// swap source/dest
-				x := edge.Source
-				edge.Source = edge.Dest
-				edge.Dest = x
+			return nil
+		}
+		if *perNamespace {
+			namespaces, cross := model.SplitByNamespace()
+			fmt.Fprintf(logOutput, "Found %d namespaces\n", len(namespaces))
+			for _, ns := range slices.Sorted(maps.Keys(namespaces)) {
+				part := ns
+				if part == "" {
+					part = "unknown"
+				}
+				if err := writePart(partPath(*output, *format, part), namespaces[ns]); err != nil {
+					return err
+				}
 			}
+			return writePart(partPath(*output, *format, "cross-namespace"), cross)
+		}
+		if err := guard.check(logOutput, model, *format); err != nil {
+			return fmt.Errorf("writing to %s: %w", dest, err)
+		}
+		var err error
+		if *output == "" {
+			err = writeOutput(os.Stdout, *format, model, renderOpts)
+		} else {
+			err = writeOutputFile(*output, *format, model, renderOpts)
+		}
+		if err != nil {
+			return fmt.Errorf("writing to %s: %w", dest, err)
+		}
+		return nil
+	}
 
-			// is this edge a new one?
-			if _, exists := edges[edge]; !exists {
-
-				// this edge has not been drawn yet...
-				sourceNode := nodes[edge.Source.PID]
-				destNode := nodes[edge.Dest.PID]
+	// the input is read until exhausted or interrupted (SIGINT or SIGTERM): on interruption
+	// the graph of the input read so far is still written out, and the exit status is 0.
+	// With -max-duration, reading stops at the deadline even if the input is still open
+	ctx, stop := interruptContext(context.Background())
+	defer stop()
+	interrupted := ctx
+	if *maxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *maxDuration)
+		defer cancel()
+	}
+	reportDeadline := func() {
+		switch {
+		case interrupted.Err() != nil:
+			fmt.Fprintf(logOutput, "Interrupted: the graph only covers the input read so far\n")
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			fmt.Fprintf(logOutput, "Duration limit of %s reached: the graph only covers the input read so far\n", *maxDuration)
+		}
+	}
 
-				label := fmt.Sprintf("%s:%d->%s:%d", sourceNode.LocalIP, edge.Source.Port, destNode.LocalIP, edge.Dest.Port)
-				sourceNode.DotNode.Edge(destNode.DotNode, label)
-				edges[edge] = struct{}{}
+	if *listen != "" {
+		l, err := listenUnix(*listen)
+		if err != nil {
+			fatal(err)
+		}
+		defer l.Close() // removes the socket file
+		infof("listening for feeders on %s", *listen)
+		sources = append(sources, listenSource(ctx, l))
+		renderOpts.SourceNames = append(renderOpts.SourceNames, *listen)
+	}
 
-				// register also the edge in the opposite direction
+	if *errorsFile != "" {
+		f, err := os.Create(*errorsFile)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		report := &errorReport{w: f, sourceNames: renderOpts.SourceNames, offsets: offsets, name: *errorsFile}
+		modelOpts.OnParseError = report.add
+	}
 
-			}
+	// the table of all the local endpoints, gathered before any graph-only option applies
+	dumpTable := func(b *netflow.Builder) error {
+		if *dumpEndpoints == "" {
+			return nil
 		}
-		//else:
-		// due to the way the input feed is designed, we'll have a second chance
-		// of drawing this edge later, typically in the next upcoming input line
-		// which should normally contain local/remote endpoints swapped.
-		// However it might happen that an edge does not get rendered because the
-		// remote party never gets discovered (e.g. it's an endpoint of a node outside
-		// kubernetes, e.g. in public internet, e.g. a remote image registry).
-		// This case should be improved by drawing a node in the graph with IP:PORT populated and PID=?
+		endpoints := b.KnownEndpoints()
+		return b.WithModel(func(model *netflow.Model) error {
+			if *dumpEndpoints == "-" {
+				// explicitly requested: not affected by -quiet
+				return writeEndpoints(os.Stderr, endpoints, model)
+			}
+			if err := writeFileAtomic(*dumpEndpoints, func(w io.Writer) error { return writeEndpoints(w, endpoints, model) }); err != nil {
+				return fmt.Errorf("writing to %s: %w", *dumpEndpoints, err)
+			}
+			return nil
+		})
 	}
 
-	// debug
-	/*
-		fmt.Printf("Found %d nodes:\n", len(nodes))
-		for _, n := range nodes {
-			fmt.Printf("%v\n", n)
+	if !*noHeader {
+		renderOpts.Header = &GraphHeader{
+			Version: toolVersion(),
+			Sources: renderOpts.SourceNames,
+			Filters: filterSummary(flag.CommandLine),
 		}
-	*/
+		if !*noHeaderTimestamp {
+			renderOpts.Header.Generated = time.Now()
+		}
+	}
 
-	return graph, nil
-}
+	if *watch > 0 {
+		b := netflow.NewBuilder(modelOpts)
+		err := runWatch(ctx, b, sources, *watch, emit)
+		netflow.FlushWarnings()
+		reportDeadline()
+		b.WithModel(func(model *netflow.Model) error {
+			reportDangling(logOutput, model)
+			reportNoEdges(logOutput, model)
+			reportAsymmetric(infoOutput, model)
+			if *printStats {
+				model.PrintStats(logOutput)
+			}
+			return nil
+		})
+		if err := strictFailure(err, renderOpts.SourceNames, offsets); err != nil {
+			fatal(err)
+		}
+		if err := dumpTable(b); err != nil {
+			fatal(err)
+		}
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
 
-func main() {
-	graph, err := createGraphFromStdin()
+	// on input errors the partial topology is still written out, before failing
+	b := netflow.NewBuilder(modelOpts)
+	inputErr := consumeSources(ctx, b, sources)
+	netflow.FlushWarnings()
+	if err := strictFailure(inputErr, renderOpts.SourceNames, offsets); err != nil {
+		// a gate on the input format: no graph out of an input that drifted
+		fatal(err)
+	}
+	if errors.Is(inputErr, context.DeadlineExceeded) || errors.Is(inputErr, context.Canceled) {
+		reportDeadline()
+		inputErr = nil
+	}
+	if err := dumpTable(b); err != nil {
+		fatal(err)
+	}
+	err := b.WithModel(func(model *netflow.Model) error {
+		reportDangling(logOutput, model)
+		reportNoEdges(logOutput, model)
+		reportAsymmetric(infoOutput, model)
+		if *printStats {
+			model.PrintStats(logOutput)
+		}
+		if *listListeners {
+			var err error
+			if *output == "" {
+				err = writeListeners(os.Stdout, model)
+			} else {
+				err = writeFileAtomic(*output, func(w io.Writer) error { return writeListeners(w, model) })
+			}
+			if err != nil {
+				return fmt.Errorf("writing to %s: %w", dest, err)
+			}
+			return nil
+		}
+		if *focus != "" && len(model.FindProcesses(*focus)) == 0 {
+			warnf("no process matches -focus %s: the graph is empty", *focus)
+		}
+		return emit(model)
+	})
 	if err != nil {
-		panic(err) // TODO: exit gracefully instead of panicking
+		fatal(err)
 	}
-
-	if _, err := os.Stdout.WriteString(graph.String()); err != nil {
-		fmt.Printf("Error writing to stdout: %v\n", err)
+	if inputErr != nil {
+		fatal(fmt.Errorf("%w: the topology is incomplete", inputErr))
 	}
 }
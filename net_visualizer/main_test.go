@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runMainChild runs main with the arguments of NET_VISUALIZER_ARGS when the test is the
+// child process started by runMain, and tells whether it did
+func runMainChild() bool {
+	if os.Getenv("NET_VISUALIZER_RUN_MAIN") != "1" {
+		return false
+	}
+	os.Args = append([]string{"net_visualizer"}, strings.Fields(os.Getenv("NET_VISUALIZER_ARGS"))...)
+	main()
+	return true
+}
+
+// runMain runs main with args in a child process, as it may exit the program, by running
+// the given test again; the test must start with runMainChild. It returns the exit status
+// and the combined output.
+func runMain(t *testing.T, test string, args ...string) (int, string) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^"+test+"$")
+	cmd.Env = append(os.Environ(), "NET_VISUALIZER_RUN_MAIN=1", "NET_VISUALIZER_ARGS="+strings.Join(args, " "))
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), string(out)
+	}
+	if err != nil {
+		t.Fatalf("running main: %v", err)
+	}
+	return 0, string(out)
+}
+
+func TestMainExitCodes(t *testing.T) {
+	if runMainChild() {
+		return
+	}
+
+	dir := t.TempDir()
+	// a well-formed input, with PID 7 reused by another command on the same IP
+	reused := filepath.Join(dir, "reused.trace")
+	if err := os.WriteFile(reused, []byte("10.0.0.1:80<-10.0.0.2:5000|PID=7 CMD=a\n10.0.0.1:81<-10.0.0.2:5001|PID=7 CMD=b\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "graph.dot")
+
+	for _, tc := range []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"PID reuse", []string{"-show-external", "-o", output, reused}, 0},
+		{"missing input", []string{"-o", output, filepath.Join(dir, "missing.trace")}, exitError},
+		{"invalid flag", []string{"-bogus", reused}, exitUsage},
+		{"invalid flag value", []string{"-tail", "-1", reused}, exitUsage},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if code, out := runMain(t, "TestMainExitCodes", tc.args...); code != tc.want {
+				t.Errorf("exit status %d, want %d:\n%s", code, tc.want, out)
+			}
+		})
+	}
+	graph, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("no graph written for the reused PID: %v", err)
+	}
+	if got := strings.Count(string(graph), "label=\"PID=7\\n"); got != 2 {
+		t.Errorf("got %d nodes with PID 7, want one per command:\n%s", got, graph)
+	}
+}
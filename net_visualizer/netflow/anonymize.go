@@ -0,0 +1,92 @@
+package netflow
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// Anonymizer replaces the identifiers of a model, i.e. IPs, process names, containers and pods, with
+// placeholders (host1, proc1, ...) preserving its structure, e.g. to share a diagram.
+// The same identifier always gets the same placeholder: reuse the Anonymizer to keep the
+// mapping stable across several models of the same run.
+type Anonymizer struct {
+	mapping map[string]map[string]string // kind -> original -> placeholder
+}
+
+func NewAnonymizer() *Anonymizer {
+	return &Anonymizer{mapping: make(map[string]map[string]string)}
+}
+
+// placeholder returns the placeholder of the identifier, allocating the next one of its
+// kind when seen for the first time
+func (a *Anonymizer) placeholder(kind, s string) string {
+	m := a.mapping[kind]
+	if m == nil {
+		m = make(map[string]string)
+		a.mapping[kind] = m
+	}
+	p, ok := m[s]
+	if !ok {
+		p = fmt.Sprintf("%s%d", kind, len(m)+1)
+		m[s] = p
+	}
+	return p
+}
+
+// Model returns an anonymized copy of the model. Placeholders are allocated in the
+// order of SortedNodes, so that the same input always gets the same mapping.
+func (a *Anonymizer) Model(m *Model) *Model {
+	ret := newModel()
+	ret.Stats = m.Stats
+	ret.Latest = m.Latest
+	ret.Edges = m.Edges
+	ret.Listening = m.Listening
+	ret.hidden = m.hidden
+	for _, n := range m.SortedNodes() {
+		anon := *n
+		anon.LocalIP = a.placeholder("host", n.LocalIP)
+		if n.CIDR != "" {
+			anon.CIDR = anon.LocalIP
+		}
+		anon.ProcessName = a.placeholder("proc", n.ProcessName)
+		if n.ContainerID != "" {
+			anon.ContainerID = a.placeholder("ctr", n.ContainerID)
+		}
+		if n.Pod != nil {
+			anon.Pod = &PodInfo{
+				Namespace: a.placeholder("ns", n.Pod.Namespace),
+				Name:      a.placeholder("pod", n.Pod.Name),
+			}
+		}
+		ret.Nodes[n.ProcessID] = &anon
+	}
+	for _, d := range m.SortedDangling() {
+		remote := NetworkEndpoint{IP: a.placeholder("host", d.Remote.IP), Port: d.Remote.Port}
+		if ret.Dangling[remote] == nil {
+			ret.Dangling[remote] = make(map[ProcessEndpoint]Direction)
+		}
+		ret.Dangling[remote][d.Local] = d.Dir
+	}
+	return ret
+}
+
+// WriteMapping writes the placeholders allocated so far, one "placeholder original" pair
+// per line, for the internal re-identification of an anonymized diagram
+func (a *Anonymizer) WriteMapping(w io.Writer) error {
+	for _, kind := range slices.Sorted(maps.Keys(a.mapping)) {
+		m := a.mapping[kind]
+		// sort by placeholder number: host2 before host10
+		originals := slices.SortedFunc(maps.Keys(m), func(x, y string) int {
+			return cmp.Or(cmp.Compare(len(m[x]), len(m[y])), cmp.Compare(m[x], m[y]))
+		})
+		for _, orig := range originals {
+			if _, err := fmt.Fprintf(w, "%s\t%s\n", m[orig], orig); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package netflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnonymizer(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace+"10.42.0.60:443<-10.42.0.55:5680|PID=723736 CMD=ncat\n", ModelOptions{})
+	model.ResolvePods(StaticPodResolver{"10.42.0.54": {Namespace: "shop", Name: "echo"}})
+	a := NewAnonymizer()
+	anon := a.Model(model)
+
+	// nodes are numbered in PID order: tcp-echo (722977) comes first
+	echo := anon.Nodes[722977]
+	if echo.LocalIP != "host1" || echo.ProcessName != "proc1" || echo.Pod.String() != "ns1/pod1" {
+		t.Errorf("anonymized tcp-echo = %+v, pod %s", echo, echo.Pod)
+	}
+	// all the ncat processes share the same placeholder, each IP gets its own
+	names := make(map[string]bool)
+	ips := make(map[string]bool)
+	for pid, n := range anon.Nodes {
+		if pid != 722977 {
+			names[n.ProcessName] = true
+		}
+		ips[n.LocalIP] = true
+		if strings.HasPrefix(n.LocalIP, "10.") || n.ProcessName == "ncat" {
+			t.Errorf("node %d is not anonymized: %+v", pid, n)
+		}
+	}
+	if len(names) != 1 || len(ips) != len(anon.Nodes) {
+		t.Errorf("got process names %v and IPs %v, want 1 name and %d IPs", names, ips, len(anon.Nodes))
+	}
+	for _, d := range anon.SortedDangling() {
+		if d.Remote.IP != "host7" {
+			t.Errorf("dangling remote = %+v, want host7", d.Remote)
+		}
+	}
+	if len(anon.Edges) != len(model.Edges) {
+		t.Errorf("got %d edges, want %d", len(anon.Edges), len(model.Edges))
+	}
+
+	// the mapping is stable, and the original model untouched
+	if again := a.Model(model); again.Nodes[723736].LocalIP != anon.Nodes[723736].LocalIP {
+		t.Errorf("the mapping changed between two runs")
+	}
+	if model.Nodes[722977].LocalIP != "10.42.0.54" {
+		t.Errorf("Model modified its argument")
+	}
+
+	var mapping strings.Builder
+	if err := a.WriteMapping(&mapping); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"host1\t10.42.0.54\n", "host7\t10.42.0.60\n", "ns1\tshop\n", "pod1\techo\n", "proc1\ttcp-echo\nproc2\tncat\n"} {
+		if !strings.Contains(mapping.String(), want) {
+			t.Errorf("mapping does not contain %q:\n%s", want, mapping.String())
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package netflow
+
+import "slices"
+
+// AsymmetryRatio is the ratio between the observations of a connection from its two
+// sides beyond which Asymmetric flags it
+const AsymmetryRatio = 10
+
+// Asymmetric tells whether the connection was observed from both of its sides, but far
+// more often from one than from the other, e.g. 100 times by the client and 2 times by
+// the server: the capture of the latter is likely incomplete, e.g. the tracer dropped
+// events, or the flow is unidirectional
+func (i *EdgeInfo) Asymmetric() bool {
+	lo, hi := min(i.CountBySource, i.CountByDest), max(i.CountBySource, i.CountByDest)
+	return lo > 0 && hi >= AsymmetryRatio*lo
+}
+
+// AsymmetricEdges returns the edges of the model flagged by EdgeInfo.Asymmetric, sorted
+func (m *Model) AsymmetricEdges() []Edge {
+	var ret []Edge
+	for e, info := range m.Edges {
+		if info.Asymmetric() {
+			ret = append(ret, e)
+		}
+	}
+	slices.SortFunc(ret, compareEdges)
+	return ret
+}
@@ -0,0 +1,48 @@
+package netflow
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBuildModelDirectionCounts(t *testing.T) {
+	// the client observes its connection to the API server 11 more times than tierTrace,
+	// whose lines are repeated for the database; the first line of each connection, seen
+	// before its other end is known, is dangling
+	client := "10.0.0.2:8080<-10.0.0.1:40000|PID=1 CMD=cli\n"
+	server := "10.0.0.1:40000->10.0.0.2:8080|PID=2 CMD=api\n"
+	trace := tierTrace + strings.Repeat(client, 11) + strings.Repeat(tierTrace[len(client)+len(server):], 2)
+	model := mustBuildModel(t, trace, ModelOptions{})
+
+	api := Edge{Source: ProcessEndpoint{PID: 1, Port: 40000}, Dest: ProcessEndpoint{PID: 2, Port: 8080}}
+	db := Edge{Source: ProcessEndpoint{PID: 2, Port: 41000}, Dest: ProcessEndpoint{PID: 3, Port: 5432}}
+	for _, tc := range []struct {
+		edge       Edge
+		fwd, rev   int
+		asymmetric bool
+	}{
+		{api, 11, 1, true},
+		{db, 2, 3, false},
+	} {
+		info := model.Edges[tc.edge]
+		if info == nil {
+			t.Fatalf("missing edge %+v", tc.edge)
+		}
+		if info.CountBySource != tc.fwd || info.CountByDest != tc.rev || info.Count != tc.fwd+tc.rev {
+			t.Errorf("edge %+v observed %d/%d times by the source/dest, %d in total; want %d/%d", tc.edge, info.CountBySource, info.CountByDest, info.Count, tc.fwd, tc.rev)
+		}
+		if info.Asymmetric() != tc.asymmetric {
+			t.Errorf("edge %+v Asymmetric() = %v, want %v", tc.edge, info.Asymmetric(), tc.asymmetric)
+		}
+	}
+	if edges := model.AsymmetricEdges(); !slices.Equal(edges, []Edge{api}) {
+		t.Errorf("AsymmetricEdges = %+v, want the edge towards the API server", edges)
+	}
+
+	// a connection only observed from one side is not asymmetric: its other side is
+	// just not traced
+	if (&EdgeInfo{Count: 50, CountBySource: 50}).Asymmetric() {
+		t.Errorf("a connection observed from a single side must not be asymmetric")
+	}
+}
@@ -0,0 +1,64 @@
+package netflow
+
+import "maps"
+
+// Boundary is the trust boundary selected by BoundaryOnly
+type Boundary int
+
+const (
+	PodBoundary       Boundary = iota // the pods, as resolved by ResolvePods, or else the containers of ProcessEndpoints.Group
+	NamespaceBoundary                 // the namespaces of the pods resolved by ResolvePods
+)
+
+// boundaryOf returns the identity of the side of the boundary the process is on
+func (b Boundary) boundaryOf(n *ProcessEndpoints) string {
+	if b == NamespaceBoundary {
+		return n.Namespace()
+	}
+	if n.Pod != nil {
+		return n.Pod.String()
+	}
+	return n.Group()
+}
+
+// BoundaryOnly returns the submodel made of the edges crossing the boundary, i.e. whose
+// ends are in different pods or namespaces, together with the processes at their ends,
+// e.g. for a security review of the traffic between trust domains. As in SplitByNamespace,
+// the processes whose pod is unknown share the "" namespace. The dangling connections
+// are kept, except the ones towards the IP of their own process, since their peers were
+// never observed inside the boundary. The statistics of the returned model are the ones
+// of the whole model.
+func (m *Model) BoundaryOnly(boundary Boundary) *Model {
+	ret := newModel()
+	ret.Stats = m.Stats
+	ret.Latest = m.Latest
+	ret.hidden = maps.Clone(m.hidden)
+	for e, info := range m.Edges {
+		src, dst := m.Nodes[e.Source.PID], m.Nodes[e.Dest.PID]
+		if boundary.boundaryOf(src) == boundary.boundaryOf(dst) {
+			ret.hide(e)
+			continue
+		}
+		ret.Edges[e] = info
+		ret.Nodes[e.Source.PID] = src
+		ret.Nodes[e.Dest.PID] = dst
+	}
+	for remote, locals := range m.Dangling {
+		for local, dir := range locals {
+			if remote.IP == m.Nodes[local.PID].LocalIP {
+				continue
+			}
+			if ret.Dangling[remote] == nil {
+				ret.Dangling[remote] = make(map[ProcessEndpoint]Direction)
+			}
+			ret.Dangling[remote][local] = dir
+			ret.Nodes[local.PID] = m.Nodes[local.PID]
+		}
+	}
+	for ep := range m.Listening {
+		if _, ok := ret.Nodes[ep.PID]; ok {
+			ret.Listening[ep] = struct{}{}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,44 @@
+package netflow
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestModelBoundaryOnly(t *testing.T) {
+	// the tiers of tierTrace, plus a proxy in the pod of the API server calling it, the
+	// API server calling an external database and an unobserved port of its own pod
+	trace := tierTrace + `10.0.0.2:8080<-10.0.0.2:45000|PID=4 CMD=proxy
+10.0.0.2:45000->10.0.0.2:8080|PID=2 CMD=api
+10.0.9.9:3306<-10.0.0.2:43000|PID=2 CMD=api
+10.0.0.2:9000<-10.0.0.2:46000|PID=2 CMD=api
+`
+	model := mustBuildModel(t, trace, ModelOptions{})
+	pods := model.BoundaryOnly(PodBoundary)
+	if pids := modelPIDs(pods); !slices.Equal(pids, []int64{1, 2, 3}) {
+		t.Errorf("got processes %v, want the client, the API server and the database", pids)
+	}
+	wantEdges := []Edge{
+		{Source: ProcessEndpoint{PID: 1, Port: 40000}, Dest: ProcessEndpoint{PID: 2, Port: 8080}},
+		{Source: ProcessEndpoint{PID: 2, Port: 41000}, Dest: ProcessEndpoint{PID: 3, Port: 5432}},
+	}
+	if edges := pods.SortedEdges(); !slices.Equal(edges, wantEdges) {
+		t.Errorf("SortedEdges = %+v, want %+v", edges, wantEdges)
+	}
+	if pods.NumDangling() != 1 || pods.Dangling[NetworkEndpoint{IP: "10.0.9.9", Port: 3306}] == nil {
+		t.Errorf("Dangling = %v, want only the connection to 10.0.9.9:3306", pods.Dangling)
+	}
+
+	model.ResolvePods(StaticPodResolver{
+		"10.0.0.1": {Namespace: "shop", Name: "cli"},
+		"10.0.0.2": {Namespace: "shop", Name: "api"},
+		"10.0.0.3": {Namespace: "data", Name: "db"},
+	})
+	namespaces := model.BoundaryOnly(NamespaceBoundary)
+	if edges := namespaces.SortedEdges(); !slices.Equal(edges, wantEdges[1:]) {
+		t.Errorf("SortedEdges = %+v, want only the edge towards the data namespace", edges)
+	}
+	if len(model.Edges) != 3 {
+		t.Errorf("the model was modified: %d edges", len(model.Edges))
+	}
+}
@@ -0,0 +1,53 @@
+package netflow
+
+import "maps"
+
+// CollapseChatty returns a copy of the model where the processes with more than
+// maxEdges distinct edges are collapsed: their edges are dropped and the node records
+// how many were folded, in ProcessEndpoints.Collapsed. The receiver is left untouched.
+func (m *Model) CollapseChatty(maxEdges int) *Model {
+	degrees := m.Degrees()
+	chatty := make(map[int64]struct{})
+	for pid, d := range degrees {
+		if d.In+d.Out > maxEdges {
+			chatty[pid] = struct{}{}
+		}
+	}
+
+	ret := newModel()
+	ret.Stats = m.Stats
+	ret.Latest = m.Latest
+	ret.hidden = maps.Clone(m.hidden)
+	for pid, n := range m.Nodes {
+		if _, ok := chatty[pid]; ok {
+			collapsed := *n
+			collapsed.Collapsed = degrees[pid].In + degrees[pid].Out
+			n = &collapsed
+		}
+		ret.Nodes[pid] = n
+	}
+	for e, info := range m.Edges {
+		_, src := chatty[e.Source.PID]
+		_, dst := chatty[e.Dest.PID]
+		if !src && !dst {
+			ret.Edges[e] = info
+		} else {
+			ret.hide(e)
+		}
+	}
+	for remote, locals := range m.Dangling {
+		for local, dir := range locals {
+			if _, ok := chatty[local.PID]; ok {
+				continue
+			}
+			if ret.Dangling[remote] == nil {
+				ret.Dangling[remote] = make(map[ProcessEndpoint]Direction)
+			}
+			ret.Dangling[remote][local] = dir
+		}
+	}
+	for ep := range m.Listening {
+		ret.Listening[ep] = struct{}{}
+	}
+	return ret
+}
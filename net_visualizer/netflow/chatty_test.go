@@ -0,0 +1,41 @@
+package netflow
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestModelCollapseChatty(t *testing.T) {
+	// a chatty server with 5 clients, next to the sample trace
+	var trace strings.Builder
+	trace.WriteString(sampleTrace)
+	for i := range 5 {
+		fmt.Fprintf(&trace, "10.42.1.1:6443<-10.42.1.%d:40000|PID=%d CMD=client\n", 10+i, 200+i)
+		fmt.Fprintf(&trace, "10.42.1.%d:40000->10.42.1.1:6443|PID=100 CMD=k3s-server\n", 10+i)
+	}
+	model := mustBuildModel(t, trace.String(), ModelOptions{})
+	edges := len(model.Edges)
+
+	collapsed := model.CollapseChatty(3)
+	if got := collapsed.Nodes[100].Collapsed; got != 5 {
+		t.Errorf("Collapsed = %d, want 5", got)
+	}
+	if len(collapsed.Nodes) != len(model.Nodes) || len(collapsed.Edges) != edges-5 {
+		t.Errorf("got %d nodes and %d edges, want %d and %d", len(collapsed.Nodes), len(collapsed.Edges), len(model.Nodes), edges-5)
+	}
+	for e := range collapsed.Edges {
+		if e.Source.PID == 100 || e.Dest.PID == 100 {
+			t.Errorf("edge %+v of the collapsed process was kept", e)
+		}
+	}
+	// the servers of the sample trace have 2 edges each, below the threshold
+	if n := collapsed.Nodes[722977]; n.Collapsed != 0 {
+		t.Errorf("process %d should not be collapsed", n.ProcessID)
+	}
+
+	// the original model is untouched
+	if model.Nodes[100].Collapsed != 0 || len(model.Edges) != edges {
+		t.Errorf("CollapseChatty modified its receiver")
+	}
+}
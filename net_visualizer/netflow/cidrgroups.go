@@ -0,0 +1,63 @@
+package netflow
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// CIDRGroup names an IP range drawn as a single synthetic node, e.g. a managed
+// database fleet spanning a subnet
+type CIDRGroup struct {
+	Name string
+	Net  *net.IPNet
+}
+
+// groupPID returns the PID of the synthetic node of the i-th group: synthetic nodes have
+// negative PIDs, so that they never clash with the traced processes
+func groupPID(i int) int64 {
+	return -int64(i + 1)
+}
+
+// CIDRGroups is the list of -group-cidr mappings; it implements flag.Value so that the
+// flag can be repeated
+type CIDRGroups []CIDRGroup
+
+func (g *CIDRGroups) String() string {
+	if g == nil {
+		return ""
+	}
+	ret := make([]string, 0, len(*g))
+	for _, m := range *g {
+		ret = append(ret, m.Name+"="+m.Net.String())
+	}
+	return strings.Join(ret, ",")
+}
+
+// Set parses a "name=CIDR" mapping
+func (g *CIDRGroups) Set(value string) error {
+	name, cidr, found := strings.Cut(value, "=")
+	if !found || name == "" {
+		return fmt.Errorf("expected <name>=<CIDR>, got %q", value)
+	}
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return err
+	}
+	*g = append(*g, CIDRGroup{Name: name, Net: ipNet})
+	return nil
+}
+
+// Match returns the index of the first group containing the IP, or -1
+func (g CIDRGroups) Match(ip string) int {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return -1
+	}
+	for i, m := range g {
+		if m.Net.Contains(parsed) {
+			return i
+		}
+	}
+	return -1
+}
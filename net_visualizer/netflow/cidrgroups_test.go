@@ -0,0 +1,87 @@
+package netflow
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCIDRGroups(t *testing.T) {
+	var groups CIDRGroups
+	for _, v := range []string{"rds=10.0.8.0/22", "cache=10.0.12.0/24"} {
+		if err := groups.Set(v); err != nil {
+			t.Fatalf("Set(%q) returned unexpected error: %v", v, err)
+		}
+	}
+	if got := groups.String(); got != "rds=10.0.8.0/22,cache=10.0.12.0/24" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := groups.Match("10.0.11.7"); got != 0 {
+		t.Errorf("Match(10.0.11.7) = %d, want 0", got)
+	}
+	if got := groups.Match("10.0.13.1"); got != -1 {
+		t.Errorf("Match(10.0.13.1) = %d, want -1", got)
+	}
+	for _, bad := range []string{"10.0.8.0/22", "=10.0.8.0/22", "rds=10.0.8.0"} {
+		if err := groups.Set(bad); err == nil {
+			t.Errorf("Set(%q) expected an error", bad)
+		}
+	}
+}
+
+func TestBuildModelGroupCIDRs(t *testing.T) {
+	var groups CIDRGroups
+	groups.Set("rds=10.0.8.0/22")
+	// two clients connecting to three database replicas, never traced themselves
+	model := mustBuildModel(t, `10.0.8.10:5432<-10.42.0.1:40000|PID=100 CMD=api
+10.0.9.20:5432<-10.42.0.1:40001|PID=100 CMD=api
+10.0.10.30:5432<-10.42.0.1:40002|PID=100 CMD=api
+10.0.10.30:5432<-10.42.0.1:40002|PID=100 CMD=api
+10.0.8.10:5432<-10.42.0.2:40000|PID=200 CMD=worker
+`, ModelOptions{GroupCIDRs: groups})
+
+	g := model.Nodes[-1]
+	if g == nil || g.ProcessName != "rds" || g.CIDR != "10.0.8.0/22" || !slices.Equal(g.LocalPorts, []int{5432}) {
+		t.Fatalf("synthetic node = %+v, want rds on 10.0.8.0/22 port 5432", g)
+	}
+	if got := model.ListeningPorts(g); !slices.Equal(got, []int{5432}) {
+		t.Errorf("ListeningPorts = %v, want [5432]", got)
+	}
+	if len(model.Nodes) != 3 || model.NumDangling() != 0 {
+		t.Errorf("got %d nodes and %d dangling connections, want 3 and none", len(model.Nodes), model.NumDangling())
+	}
+	// one edge per client port, aggregating the IPs of the range
+	if d := model.Degrees()[-1]; d.In != 4 {
+		t.Errorf("synthetic node in-degree = %d, want 4", d.In)
+	}
+	if info := model.Edges[Edge{Source: ProcessEndpoint{PID: 100, Port: 40002}, Dest: ProcessEndpoint{PID: -1, Port: 5432}}]; info == nil || info.Count != 2 {
+		t.Errorf("edge info = %+v, want a count of 2", info)
+	}
+
+	// traced processes inside the range are folded into the synthetic node as well
+	model = mustBuildModel(t, `10.0.8.10:5432<-10.42.0.1:40000|PID=100 CMD=api
+10.42.0.1:40000->10.0.8.10:5432|PID=300 CMD=postgres
+`, ModelOptions{GroupCIDRs: groups})
+	if _, ok := model.Nodes[300]; ok || len(model.Nodes) != 2 || len(model.Edges) != 1 {
+		t.Errorf("got nodes %v and %d edges, want the api and rds nodes with 1 edge", modelPIDs(model), len(model.Edges))
+	}
+}
+
+func TestBuildModelGroupCIDRsMaxTrackedNodes(t *testing.T) {
+	var groups CIDRGroups
+	groups.Set("rds=10.0.8.0/22")
+	groups.Set("cache=10.0.12.0/24")
+	// the worker and the cache push the api and the rds range out of the 2 tracked nodes
+	model := mustBuildModel(t, `10.0.8.10:5432<-10.42.0.1:40000|PID=100 CMD=api
+10.0.12.5:6379<-10.42.0.2:40000|PID=200 CMD=worker
+`, ModelOptions{GroupCIDRs: groups, MaxTrackedNodes: 2})
+	if got := modelPIDs(model); !slices.Equal(got, []int64{-2, 200}) {
+		t.Errorf("got nodes %v, want the worker and the cache range only", got)
+	}
+	want := Edge{Source: ProcessEndpoint{PID: 200, Port: 40000}, Dest: ProcessEndpoint{PID: -2, Port: 6379}}
+	if len(model.Edges) != 1 || model.Edges[want] == nil {
+		t.Errorf("got edges %+v, want only %+v", model.SortedEdges(), want)
+	}
+	if model.Stats.EvictedNodes != 2 {
+		t.Errorf("EvictedNodes = %d, want 2", model.Stats.EvictedNodes)
+	}
+}
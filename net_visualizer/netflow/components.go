@@ -0,0 +1,103 @@
+package netflow
+
+import (
+	"cmp"
+	"slices"
+)
+
+// unionFind is a disjoint-set forest over PIDs, with path compression and union by size
+type unionFind struct {
+	parent map[int64]int64
+	size   map[int64]int
+}
+
+func newUnionFind() *unionFind {
+	return &unionFind{parent: make(map[int64]int64), size: make(map[int64]int)}
+}
+
+// Find returns the representative of the set containing pid, creating a singleton set
+// for PIDs never seen before
+func (u *unionFind) Find(pid int64) int64 {
+	parent, ok := u.parent[pid]
+	if !ok {
+		u.parent[pid] = pid
+		u.size[pid] = 1
+		return pid
+	}
+	if parent == pid {
+		return pid
+	}
+	root := u.Find(parent)
+	u.parent[pid] = root
+	return root
+}
+
+// Union merges the sets containing a and b
+func (u *unionFind) Union(a, b int64) {
+	rootA, rootB := u.Find(a), u.Find(b)
+	if rootA == rootB {
+		return
+	}
+	if u.size[rootA] < u.size[rootB] {
+		rootA, rootB = rootB, rootA
+	}
+	u.parent[rootB] = rootA
+	u.size[rootA] += u.size[rootB]
+}
+
+// Components splits the model into its connected components, treating edges as
+// undirected. Components are sorted by decreasing number of nodes, then by lowest PID.
+func (m *Model) Components() []*Model {
+	uf := newUnionFind()
+	for pid := range m.Nodes {
+		uf.Find(pid)
+	}
+	for e := range m.Edges {
+		uf.Union(e.Source.PID, e.Dest.PID)
+	}
+
+	byRoot := make(map[int64]*Model)
+	minPID := make(map[*Model]int64)
+	for pid, n := range m.Nodes {
+		root := uf.Find(pid)
+		c, ok := byRoot[root]
+		if !ok {
+			c = newModel()
+			c.Latest = m.Latest
+			c.hidden = m.hidden
+			byRoot[root] = c
+			minPID[c] = pid
+		}
+		c.Nodes[pid] = n
+		minPID[c] = min(minPID[c], pid)
+	}
+	for e, info := range m.Edges {
+		byRoot[uf.Find(e.Source.PID)].Edges[e] = info
+	}
+	for remote, locals := range m.Dangling {
+		for local, dir := range locals {
+			c := byRoot[uf.Find(local.PID)]
+			if c.Dangling[remote] == nil {
+				c.Dangling[remote] = make(map[ProcessEndpoint]Direction)
+			}
+			c.Dangling[remote][local] = dir
+		}
+	}
+	for ep := range m.Listening {
+		if c, ok := byRoot[uf.Find(ep.PID)]; ok {
+			c.Listening[ep] = struct{}{}
+		}
+	}
+
+	ret := make([]*Model, 0, len(byRoot))
+	for _, c := range byRoot {
+		ret = append(ret, c)
+	}
+	slices.SortFunc(ret, func(a, b *Model) int {
+		return cmp.Or(
+			cmp.Compare(len(b.Nodes), len(a.Nodes)),
+			cmp.Compare(minPID[a], minPID[b]),
+		)
+	})
+	return ret
+}
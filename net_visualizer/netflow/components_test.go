@@ -0,0 +1,40 @@
+package netflow
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestModelComponents(t *testing.T) {
+	// sampleTrace has two servers, each with two clients
+	trace := sampleTrace + `10.42.0.99:6000<-10.42.0.98:7000|PID=900 CMD=lonely
+`
+	model := mustBuildModel(t, trace, ModelOptions{})
+	components := model.Components()
+	if len(components) != 3 {
+		t.Fatalf("got %d components, want 3", len(components))
+	}
+
+	wantPIDs := [][]int64{
+		{722977, 723736, 723753},
+		{723429, 723715, 723801},
+		{900},
+	}
+	for i, c := range components {
+		var pids []int64
+		for _, n := range c.SortedNodes() {
+			pids = append(pids, n.ProcessID)
+		}
+		if !slices.Equal(pids, wantPIDs[i]) {
+			t.Errorf("component %d PIDs = %v, want %v", i+1, pids, wantPIDs[i])
+		}
+		for e := range c.Edges {
+			if c.Nodes[e.Source.PID] == nil || c.Nodes[e.Dest.PID] == nil {
+				t.Errorf("component %d has dangling edge %+v", i+1, e)
+			}
+		}
+	}
+	if len(components[0].Edges)+len(components[1].Edges) != len(model.Edges) || len(components[2].Edges) != 0 {
+		t.Errorf("edges not split correctly across components")
+	}
+}
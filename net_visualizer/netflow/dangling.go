@@ -0,0 +1,42 @@
+package netflow
+
+import (
+	"cmp"
+	"slices"
+)
+
+// DanglingConnection is a connection whose remote endpoint was never observed as the
+// local side of any line, see Model.Dangling
+type DanglingConnection struct {
+	Local  ProcessEndpoint
+	Remote NetworkEndpoint
+	Dir    Direction
+}
+
+// SortedDangling returns the dangling connections, sorted by remote endpoint and then
+// by local endpoint
+func (m *Model) SortedDangling() []DanglingConnection {
+	var ret []DanglingConnection
+	for remote, locals := range m.Dangling {
+		for local, dir := range locals {
+			ret = append(ret, DanglingConnection{Local: local, Remote: remote, Dir: dir})
+		}
+	}
+	slices.SortFunc(ret, func(a, b DanglingConnection) int {
+		return cmp.Or(
+			cmp.Compare(a.Remote.IP, b.Remote.IP),
+			cmp.Compare(a.Remote.Port, b.Remote.Port),
+			compareEndpoints(a.Local, b.Local),
+		)
+	})
+	return ret
+}
+
+// NumDangling counts the dangling connections
+func (m *Model) NumDangling() int {
+	n := 0
+	for _, locals := range m.Dangling {
+		n += len(locals)
+	}
+	return n
+}
@@ -0,0 +1,16 @@
+// Package netflow turns the output of the ebpf_netflow_tracer into a process-level
+// connection graph.
+//
+// Each tracer line reports one side of a TCP connection: ParseLine (or a LineParser,
+// for custom formats) turns it into an InputLine, IsValidLine drops the noise, and a
+// Builder correlates the two sides of each connection into the Edge between the two
+// processes. BuildModel does all of this for a single input:
+//
+//	model, err := netflow.BuildModel(os.Stdin, netflow.ModelOptions{})
+//	for _, e := range model.SortedEdges() {
+//		fmt.Println(model.Nodes[e.Source.PID].ProcessName, "->", model.Nodes[e.Dest.PID].ProcessName)
+//	}
+//
+// The resulting Model is format-agnostic: rendering it is left to the caller, see
+// Model.Export for a flat view of it.
+package netflow
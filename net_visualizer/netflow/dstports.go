@@ -0,0 +1,48 @@
+package netflow
+
+import "maps"
+
+// DestinationPorts returns the submodel made of the edges towards the given destination
+// ports, i.e. the ports on the accepting side of the connections, together with the
+// processes at their ends, e.g. to review who talks to the databases. Unlike
+// FilterOptions.AllowPorts, applied to the lines while building the model, the edges are
+// selected once their direction is known, so that the client ports never match. The
+// dangling connections towards those ports are kept as well. The statistics of the
+// returned model are the ones of the whole model.
+func (m *Model) DestinationPorts(ports PortSet) *Model {
+	ret := newModel()
+	ret.Stats = m.Stats
+	ret.Latest = m.Latest
+	ret.hidden = maps.Clone(m.hidden)
+	for e, info := range m.Edges {
+		if ports.Contains(e.Dest.Port) {
+			ret.Edges[e] = info
+			ret.Nodes[e.Source.PID] = m.Nodes[e.Source.PID]
+			ret.Nodes[e.Dest.PID] = m.Nodes[e.Dest.PID]
+		} else {
+			ret.hide(e)
+		}
+	}
+	for remote, locals := range m.Dangling {
+		for local, dir := range locals {
+			destPort := remote.Port
+			if dir == Remote2Local {
+				destPort = local.Port
+			}
+			if !ports.Contains(destPort) {
+				continue
+			}
+			if ret.Dangling[remote] == nil {
+				ret.Dangling[remote] = make(map[ProcessEndpoint]Direction)
+			}
+			ret.Dangling[remote][local] = dir
+			ret.Nodes[local.PID] = m.Nodes[local.PID]
+		}
+	}
+	for ep := range m.Listening {
+		if _, ok := ret.Nodes[ep.PID]; ok {
+			ret.Listening[ep] = struct{}{}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,61 @@
+package netflow
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestModelDestinationPorts(t *testing.T) {
+	// the tiers of tierTrace, plus the API server calling etcd, an external database,
+	// and being called by an external client
+	trace := tierTrace + `10.0.0.4:2379<-10.0.0.2:42000|PID=2 CMD=api
+10.0.0.2:42000->10.0.0.4:2379|PID=4 CMD=etcd
+10.0.9.9:3306<-10.0.0.2:43000|PID=2 CMD=api
+10.0.9.8:50000->10.0.0.2:8080|PID=2 CMD=api
+`
+	model := mustBuildModel(t, trace, ModelOptions{})
+	var ports PortSet
+	if err := ports.Set("5432, 3306"); err != nil {
+		t.Fatal(err)
+	}
+	db := model.DestinationPorts(ports)
+	if pids := modelPIDs(db); !slices.Equal(pids, []int64{2, 3}) {
+		t.Errorf("got processes %v, want the API server and the database", pids)
+	}
+	wantEdges := []Edge{{Source: ProcessEndpoint{PID: 2, Port: 41000}, Dest: ProcessEndpoint{PID: 3, Port: 5432}}}
+	if edges := db.SortedEdges(); !slices.Equal(edges, wantEdges) {
+		t.Errorf("SortedEdges = %+v, want %+v", edges, wantEdges)
+	}
+	// the connection to the external database is kept, not the one from the external client
+	if db.NumDangling() != 1 || db.Dangling[NetworkEndpoint{IP: "10.0.9.9", Port: 3306}] == nil {
+		t.Errorf("Dangling = %v, want the connection to 10.0.9.9:3306", db.Dangling)
+	}
+	// the client port 41000 of the API server is not a destination port
+	if err := ports.Set("41000"); err != nil {
+		t.Fatal(err)
+	}
+	if edges := model.DestinationPorts(ports).SortedEdges(); !slices.Equal(edges, wantEdges) {
+		t.Errorf("SortedEdges = %+v, want only the edge towards 5432", edges)
+	}
+	if len(model.Nodes) != 4 || len(model.Edges) != 3 {
+		t.Errorf("DestinationPorts must leave the receiver untouched")
+	}
+}
+
+func TestPortSetFlag(t *testing.T) {
+	var ports PortSet
+	if err := ports.Set("6443,2379"); err != nil {
+		t.Fatalf("Set returned unexpected error: %v", err)
+	}
+	if err := ports.Set("5432"); err != nil {
+		t.Fatalf("Set returned unexpected error: %v", err)
+	}
+	if got := ports.String(); got != "2379,5432,6443" {
+		t.Errorf("String = %q, want 2379,5432,6443", got)
+	}
+	for _, value := range []string{"", "5432,", "db", "0", "65536"} {
+		if err := new(PortSet).Set(value); err == nil {
+			t.Errorf("Set(%q) expected an error", value)
+		}
+	}
+}
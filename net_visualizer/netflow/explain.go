@@ -0,0 +1,89 @@
+package netflow
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Connection identifies a TCP connection by its two endpoints; it matches the lines
+// observing it from either side
+type Connection struct {
+	Source NetworkEndpoint
+	Dest   NetworkEndpoint
+}
+
+// ParseConnection parses a "src_ip:port->dst_ip:port" connection
+func ParseConnection(s string) (Connection, error) {
+	src, dst, found := strings.Cut(s, "->")
+	if !found {
+		return Connection{}, fmt.Errorf("expected <src_ip>:<port>-><dst_ip>:<port>, got %q", s)
+	}
+	var c Connection
+	for _, ep := range []struct {
+		s   string
+		out *NetworkEndpoint
+	}{{src, &c.Source}, {dst, &c.Dest}} {
+		host, port, err := net.SplitHostPort(strings.TrimSpace(ep.s))
+		if err != nil {
+			return Connection{}, err
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil || p <= 0 || p > 65535 {
+			return Connection{}, fmt.Errorf("invalid port %q", port)
+		}
+		*ep.out = NetworkEndpoint{IP: host, Port: p}
+	}
+	return c, nil
+}
+
+func (c Connection) String() string {
+	return net.JoinHostPort(c.Source.IP, strconv.Itoa(c.Source.Port)) + "->" +
+		net.JoinHostPort(c.Dest.IP, strconv.Itoa(c.Dest.Port))
+}
+
+// sameEndpoint compares an endpoint of the connection with the one of a line, tolerating
+// the different spellings of the same IP
+func sameEndpoint(ep NetworkEndpoint, ip string, port int) bool {
+	if ep.Port != port {
+		return false
+	}
+	a, b := net.ParseIP(ep.IP), net.ParseIP(ip)
+	if a != nil && b != nil {
+		return a.Equal(b)
+	}
+	return ep.IP == ip
+}
+
+// matches tells whether the line observes the connection; the lines that do not parse
+// match if they mention both endpoints
+func (c Connection) matches(raw string, line InputLine, err error) bool {
+	if err != nil {
+		return strings.Contains(raw, c.Source.IP+":"+strconv.Itoa(c.Source.Port)) &&
+			strings.Contains(raw, c.Dest.IP+":"+strconv.Itoa(c.Dest.Port))
+	}
+	return (sameEndpoint(c.Source, line.LocalIP, line.LocalPort) && sameEndpoint(c.Dest, line.RemoteIP, line.RemotePort)) ||
+		(sameEndpoint(c.Dest, line.LocalIP, line.LocalPort) && sameEndpoint(c.Source, line.RemoteIP, line.RemotePort))
+}
+
+// Explainer reports, for every input line observing a connection, the stage of the model
+// building that accepted or rejected it, to tell why the connection is or is not drawn
+type Explainer struct {
+	Conn Connection
+	Out  io.Writer
+}
+
+// explain reports the verdict about the n-th line, if it observes the connection
+func (b *Builder) explain(matched bool, n int, raw string, format string, args ...any) {
+	if !matched {
+		return
+	}
+	fmt.Fprintf(b.opts.Explain.Out, "EXPLAIN: line %d: %s\n    %s\n", n, raw, fmt.Sprintf(format, args...))
+}
+
+// edgeName describes the edge in the explanations
+func edgeName(e Edge) string {
+	return fmt.Sprintf("PID %d port %d -> PID %d port %d", e.Source.PID, e.Source.Port, e.Dest.PID, e.Dest.Port)
+}
@@ -0,0 +1,62 @@
+package netflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConnection(t *testing.T) {
+	c, err := ParseConnection("10.42.0.54:5671->[fd00::1]:443")
+	if err != nil {
+		t.Fatalf("ParseConnection returned unexpected error: %v", err)
+	}
+	want := Connection{Source: NetworkEndpoint{IP: "10.42.0.54", Port: 5671}, Dest: NetworkEndpoint{IP: "fd00::1", Port: 443}}
+	if c != want {
+		t.Errorf("ParseConnection = %+v, want %+v", c, want)
+	}
+	if got := c.String(); got != "10.42.0.54:5671->[fd00::1]:443" {
+		t.Errorf("String = %q", got)
+	}
+	for _, s := range []string{"", "10.42.0.54:5671", "10.42.0.54->10.42.0.55:5672", "10.42.0.54:0->10.42.0.55:5672", "10.42.0.54:5671->10.42.0.55:x"} {
+		if _, err := ParseConnection(s); err == nil {
+			t.Errorf("ParseConnection(%q) should fail", s)
+		}
+	}
+}
+
+func TestBuildModelExplain(t *testing.T) {
+	trace := `10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat
+garbage 10.42.0.55:5672 10.42.0.54:5671
+10.42.0.58:5673<-10.42.0.56:5674|PID=723715 CMD=ncat
+10.42.0.55:5672->10.42.0.54:5671|PID=722977 CMD=tcp-echo
+10.42.0.55:5672->10.42.0.54:5671|PID=42 CMD=sidecar
+`
+	var out strings.Builder
+	conn, err := ParseConnection("10.42.0.54:5671->10.42.0.55:5672")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := ModelOptions{Explain: &Explainer{Conn: conn, Out: &out}}
+	opts.Filter.ExcludePIDs = PIDSet{42: {}}
+	model := mustBuildModel(t, trace, opts)
+	if len(model.Edges) != 1 {
+		t.Errorf("got %d edges, want 1: explaining must not change the model", len(model.Edges))
+	}
+
+	// the unrelated line 3 is not reported
+	want := []string{
+		"EXPLAIN: line 1: ", "pending: no process seen yet with 10.42.0.54:5671",
+		"EXPLAIN: line 2: ", "rejected: invalid line",
+		"EXPLAIN: line 4: ", "accepted: edge PID 723736 port 5672 -> PID 722977 port 5671, observed 1 time(s)",
+		"EXPLAIN: line 5: ", "rejected: process 42 (sidecar) is excluded",
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("got explanation:\n%s\nwant %d lines", out.String(), len(want))
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(strings.TrimSpace(lines[i]), prefix) {
+			t.Errorf("line %d of the explanation is %q, want it to start with %q", i+1, lines[i], prefix)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package netflow
+
+import (
+	"fmt"
+	"time"
+)
+
+// protocolTCP is the only protocol observed by the tracer so far
+const protocolTCP = "tcp"
+
+// ExportNode is the flat, format-independent view of a process node, shared by all
+// the output formats other than DOT
+type ExportNode struct {
+	ID        string `json:"id"`
+	PID       int64  `json:"pid"`
+	Name      string `json:"name"`
+	IP        string `json:"ip"`
+	Container string `json:"container,omitempty"`
+	Ports     []int  `json:"ports"` // listening ports only
+	InDegree  int    `json:"in_degree"`
+	OutDegree int    `json:"out_degree"`
+
+	// EphemeralPorts counts the client ports folded out of Ports
+	EphemeralPorts int `json:"ephemeral_ports"`
+
+	// Collapsed counts the edges folded by Model.CollapseChatty
+	Collapsed int `json:"collapsed,omitempty"`
+
+	// Forks lists the PIDs merged by Model.MergeForks
+	Forks []int64 `json:"forks,omitempty"`
+}
+
+// ExportEdge is the flat, format-independent view of an edge; Source and Target
+// reference ExportNode.ID
+type ExportEdge struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	SrcPort  int    `json:"src_port"`
+	DstPort  int    `json:"dst_port"`
+	Protocol string `json:"protocol"`
+	Count    int    `json:"count"`
+	Bytes    int64  `json:"bytes,omitempty"`   // traffic volume, when reported by the tracer
+	Packets  int64  `json:"packets,omitempty"` // number of packets, when reported by the tracer
+
+	// FirstSeen and LastSeen are the RFC 3339 timestamps of the earliest and most recent
+	// observations of the connection, when the tracer reports them
+	FirstSeen string `json:"first_seen,omitempty"`
+	LastSeen  string `json:"last_seen,omitempty"`
+
+	// ViaSidecar flags the connections proxied by a service mesh sidecar, see
+	// Model.CollapseSidecars
+	ViaSidecar bool `json:"via_sidecar,omitempty"`
+}
+
+// FormatTimestamp returns the RFC 3339 form of an observation timestamp, or "" for the
+// zero timestamp of the inputs without timestamps
+func FormatTimestamp(ts time.Time) string {
+	if ts.IsZero() {
+		return ""
+	}
+	return ts.Format(time.RFC3339Nano)
+}
+
+// exportNodeID returns the identifier of the node in the exported formats
+func exportNodeID(pid int64) string {
+	return fmt.Sprintf("n%d", pid)
+}
+
+// Export flattens the model into sorted lists of nodes and edges
+func (m *Model) Export() ([]ExportNode, []ExportEdge) {
+	degrees := m.Degrees()
+	nodes := make([]ExportNode, 0, len(m.Nodes))
+	for _, n := range m.SortedNodes() {
+		ports := m.ListeningPorts(n)
+		if ports == nil {
+			ports = []int{}
+		}
+		nodes = append(nodes, ExportNode{
+			ID:             exportNodeID(n.ProcessID),
+			PID:            n.PID(),
+			Name:           n.ProcessName,
+			IP:             n.LocalIP,
+			Container:      n.ContainerID,
+			Ports:          ports,
+			InDegree:       degrees[n.ProcessID].In,
+			OutDegree:      degrees[n.ProcessID].Out,
+			EphemeralPorts: len(n.LocalPorts) - len(ports),
+			Collapsed:      n.Collapsed,
+			Forks:          n.Forks,
+		})
+	}
+
+	edges := make([]ExportEdge, 0, len(m.Edges))
+	for _, e := range m.SortedEdges() {
+		edges = append(edges, ExportEdge{
+			Source:     exportNodeID(e.Source.PID),
+			Target:     exportNodeID(e.Dest.PID),
+			SrcPort:    e.Source.Port,
+			DstPort:    e.Dest.Port,
+			Protocol:   protocolTCP,
+			Count:      m.Edges[e].Count,
+			Bytes:      m.Edges[e].Bytes,
+			Packets:    m.Edges[e].Packets,
+			FirstSeen:  FormatTimestamp(m.Edges[e].FirstSeen),
+			LastSeen:   FormatTimestamp(m.Edges[e].LastSeen),
+			ViaSidecar: m.Edges[e].ViaSidecar,
+		})
+	}
+	return nodes, edges
+}
@@ -0,0 +1,175 @@
+package netflow
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FilterOptions collects the optional filters applied by IsValidLine, on top of the
+// ones that are always active (zero ports, loopback)
+type FilterOptions struct {
+	DropLinkLocal bool // drop 169.254.0.0/16, fe80::/10 and link-local multicast endpoints
+	DropMulticast bool // drop any multicast endpoint (224.0.0.0/4, ff00::/8)
+	DropULA       bool // drop the IPv6 unique local endpoints (fc00::/7)
+
+	// ExcludePIDs and ExcludeProcesses drop all the lines of the given processes, by PID
+	// or by name; they are applied while building the model, see Stats.LinesExcluded
+	ExcludePIDs      PIDSet
+	ExcludeProcesses NameSet
+
+	// AllowPorts, when non-nil, keeps only the connections towards these destination ports;
+	// DenyPorts drops the connections towards these destination ports
+	AllowPorts PortSet
+	DenyPorts  PortSet
+
+	// Funcs are additional filters, e.g. set by the programs importing the package to drop
+	// lines after an external database: a line is dropped as soon as one of them returns
+	// false. They are called in order after all the builtin filters, so that they never
+	// see the lines with zero ports, the loopback ones nor the ones dropped by the
+	// options above
+	Funcs []FilterFunc
+}
+
+// PIDSet is the set of -exclude-pid values; it implements flag.Value so that the flag
+// can be repeated
+type PIDSet map[int64]struct{}
+
+func (s *PIDSet) String() string {
+	if s == nil {
+		return ""
+	}
+	pids := slices.Sorted(maps.Keys(*s))
+	ret := make([]string, 0, len(pids))
+	for _, pid := range pids {
+		ret = append(ret, strconv.FormatInt(pid, 10))
+	}
+	return strings.Join(ret, ",")
+}
+
+// Set adds a PID to the set
+func (s *PIDSet) Set(value string) error {
+	pid, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || pid <= 0 {
+		return fmt.Errorf("invalid PID %q", value)
+	}
+	if *s == nil {
+		*s = make(PIDSet)
+	}
+	(*s)[pid] = struct{}{}
+	return nil
+}
+
+// Contains tells whether the PID belongs to the set; a nil set contains nothing
+func (s PIDSet) Contains(pid int64) bool {
+	_, ok := s[pid]
+	return ok
+}
+
+// NameSet is the set of -exclude-process values; it implements flag.Value so that the
+// flag can be repeated
+type NameSet map[string]struct{}
+
+func (s *NameSet) String() string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join(slices.Sorted(maps.Keys(*s)), ",")
+}
+
+// Set adds a process name to the set
+func (s *NameSet) Set(value string) error {
+	if value == "" {
+		return fmt.Errorf("invalid empty process name")
+	}
+	if *s == nil {
+		*s = make(NameSet)
+	}
+	(*s)[value] = struct{}{}
+	return nil
+}
+
+// Contains tells whether the name belongs to the set; a nil set contains nothing
+func (s NameSet) Contains(name string) bool {
+	_, ok := s[name]
+	return ok
+}
+
+// FilterFunc tells whether a parsed line is worth showing: the lines for which it
+// returns false are dropped, see FilterOptions.Funcs
+type FilterFunc func(InputLine) bool
+
+// funcs returns the optional filters enabled in the options, followed by Funcs
+func (opts FilterOptions) funcs() []FilterFunc {
+	var ret []FilterFunc
+	if opts.DropLinkLocal {
+		ret = append(ret, isNotLinkLocal)
+	}
+	if opts.DropMulticast {
+		ret = append(ret, isNotMulticast)
+	}
+	if opts.DropULA {
+		ret = append(ret, isNotULA)
+	}
+	if opts.AllowPorts != nil {
+		allow := opts.AllowPorts
+		ret = append(ret, func(line InputLine) bool { return allow.Contains(line.DestPort()) })
+	}
+	if opts.DenyPorts != nil {
+		deny := opts.DenyPorts
+		ret = append(ret, func(line InputLine) bool { return !deny.Contains(line.DestPort()) })
+	}
+	return append(ret, opts.Funcs...)
+}
+
+// isNotLinkLocal is the FilterFunc of FilterOptions.DropLinkLocal
+func isNotLinkLocal(line InputLine) bool {
+	localIP, remoteIP := net.ParseIP(line.LocalIP), net.ParseIP(line.RemoteIP)
+	return !localIP.IsLinkLocalUnicast() && !remoteIP.IsLinkLocalUnicast() &&
+		!localIP.IsLinkLocalMulticast() && !remoteIP.IsLinkLocalMulticast()
+}
+
+// isNotMulticast is the FilterFunc of FilterOptions.DropMulticast
+func isNotMulticast(line InputLine) bool {
+	return !net.ParseIP(line.LocalIP).IsMulticast() && !net.ParseIP(line.RemoteIP).IsMulticast()
+}
+
+// ulaNet is the IPv6 unique local range, dropped by FilterOptions.DropULA
+var ulaNet = net.IPNet{IP: net.ParseIP("fc00::"), Mask: net.CIDRMask(7, 128)}
+
+// isNotULA is the FilterFunc of FilterOptions.DropULA
+func isNotULA(line InputLine) bool {
+	return !ulaNet.Contains(net.ParseIP(line.LocalIP)) && !ulaNet.Contains(net.ParseIP(line.RemoteIP))
+}
+
+// IsValidLine checks if the local/remote IP addresses are worth showing in the DOT graph or not
+// E.g. filters out the loopback endpoints, on 127.0.0.0/8 or ::1, plus the optional ranges
+// enabled in the FilterOptions and its Funcs.
+// Endpoints that are not IPs (e.g. unresolved hostnames) are kept: the address-based
+// filters only apply to IPs.
+func IsValidLine(line InputLine, opts FilterOptions) bool {
+	return isValidLine(line, opts.funcs())
+}
+
+// isValidLine is IsValidLine, given the filters of the FilterOptions
+func isValidLine(line InputLine, filters []FilterFunc) bool {
+	// listening sockets have no remote endpoint
+	if line.LocalPort == 0 || (line.RemotePort == 0 && line.State != StateListen) {
+		return false
+	}
+
+	// ParseIP returns nil for hostnames, and nil IPs never match the checks below
+	if net.ParseIP(line.LocalIP).IsLoopback() || net.ParseIP(line.RemoteIP).IsLoopback() {
+		return false
+	}
+
+	for _, filter := range filters {
+		if !filter(line) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package netflow
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIsValidLine(t *testing.T) {
+	mkLine := func(remoteIP, localIP string) InputLine {
+		return InputLine{
+			Dir:         Local2Remote,
+			RemoteIP:    remoteIP,
+			RemotePort:  5671,
+			LocalIP:     localIP,
+			LocalPort:   5672,
+			ProcessID:   1234,
+			ProcessName: "ncat",
+		}
+	}
+	dropAll := FilterOptions{DropLinkLocal: true, DropMulticast: true}
+
+	tests := []struct {
+		name string
+		line InputLine
+		opts FilterOptions
+		want bool
+	}{
+		{"routable IPv4", mkLine("10.42.0.54", "10.42.0.55"), dropAll, true},
+		{"zero local port", InputLine{RemoteIP: "10.42.0.54", RemotePort: 80, LocalIP: "10.42.0.55"}, FilterOptions{}, false},
+		{"loopback", mkLine("127.0.0.1", "10.42.0.55"), FilterOptions{}, false},
+		{"hostname endpoint kept", mkLine("localhost", "10.42.0.55"), dropAll, true},
+		{"k3s-server kept", InputLine{RemoteIP: "10.42.0.54", RemotePort: 80, LocalIP: "10.42.0.55", LocalPort: 1, ProcessName: "k3s-server"}, FilterOptions{}, true},
+
+		{"IPv4 link-local kept by default", mkLine("169.254.169.254", "10.42.0.55"), FilterOptions{}, true},
+		{"IPv4 link-local dropped", mkLine("169.254.169.254", "10.42.0.55"), FilterOptions{DropLinkLocal: true}, false},
+		{"IPv6 link-local kept by default", mkLine("fe80::1", "fe80::2"), FilterOptions{}, true},
+		{"IPv6 link-local dropped", mkLine("2001:db8::1", "fe80::2"), FilterOptions{DropLinkLocal: true}, false},
+		{"IPv4 link-local multicast dropped as link-local", mkLine("224.0.0.251", "10.42.0.55"), FilterOptions{DropLinkLocal: true}, false},
+		{"IPv6 link-local multicast dropped as link-local", mkLine("ff02::fb", "2001:db8::2"), FilterOptions{DropLinkLocal: true}, false},
+		{"link-local not dropped by multicast filter", mkLine("169.254.169.254", "10.42.0.55"), FilterOptions{DropMulticast: true}, true},
+
+		{"IPv4 multicast kept by default", mkLine("239.1.2.3", "10.42.0.55"), FilterOptions{}, true},
+		{"IPv4 multicast dropped", mkLine("239.1.2.3", "10.42.0.55"), FilterOptions{DropMulticast: true}, false},
+		{"IPv6 multicast dropped", mkLine("ff05::2", "2001:db8::2"), FilterOptions{DropMulticast: true}, false},
+		{"global multicast not dropped by link-local filter", mkLine("239.1.2.3", "10.42.0.55"), FilterOptions{DropLinkLocal: true}, true},
+
+		{"IPv6 loopback", mkLine("::1", "2001:db8::2"), FilterOptions{}, false},
+		{"IPv4 loopback beyond 127.0.0.1", mkLine("10.42.0.54", "127.8.9.10"), FilterOptions{}, false},
+		{"IPv6 ULA kept by default", mkLine("fc00::1", "2001:db8::2"), FilterOptions{}, true},
+		{"IPv6 ULA dropped", mkLine("fc00::1", "2001:db8::2"), FilterOptions{DropULA: true}, false},
+		{"IPv6 ULA fd00::/8 dropped", mkLine("2001:db8::1", "fd12:3456::2"), FilterOptions{DropULA: true}, false},
+		{"routable IPv6 kept", mkLine("2001:db8::1", "2606:4700::1111"), FilterOptions{DropLinkLocal: true, DropMulticast: true, DropULA: true}, true},
+		{"IPv4 private kept by the ULA filter", mkLine("10.42.0.54", "192.168.1.2"), FilterOptions{DropULA: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsValidLine(tt.line, tt.opts); got != tt.want {
+				t.Errorf("IsValidLine(%+v, %+v) = %v, want %v", tt.line, tt.opts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterFuncs(t *testing.T) {
+	// a registry of decommissioned hosts, e.g. from an external database
+	decommissioned := map[string]bool{"10.0.0.3": true}
+	var seen []string
+	opts := ModelOptions{Filter: FilterOptions{
+		DenyPorts: PortSet{8080: {}},
+		Funcs: []FilterFunc{func(line InputLine) bool {
+			seen = append(seen, line.RemoteIP)
+			return !decommissioned[line.RemoteIP] && !decommissioned[line.LocalIP]
+		}},
+	}}
+	trace := tierTrace + `127.0.0.1:9090<-127.0.0.1:45000|PID=7 CMD=prober
+10.0.0.4:2379<-10.0.0.2:42000|PID=2 CMD=api
+10.0.0.2:42000->10.0.0.4:2379|PID=4 CMD=etcd
+`
+	model := mustBuildModel(t, trace, opts)
+	// only the connection from the API server to etcd is left
+	if pids := modelPIDs(model); !slices.Equal(pids, []int64{2, 4}) {
+		t.Errorf("got processes %v, want the API server and etcd", pids)
+	}
+	if model.Stats.LinesFiltered != 5 {
+		t.Errorf("LinesFiltered = %d, want 5", model.Stats.LinesFiltered)
+	}
+	// the builtin filters run first: the custom one never sees the loopback line, nor the
+	// ones towards the denied port
+	want := []string{"10.0.0.3", "10.0.0.2", "10.0.0.4", "10.0.0.2"}
+	if !slices.Equal(seen, want) {
+		t.Errorf("the custom filter saw the lines towards %v, want %v", seen, want)
+	}
+}
+
+func TestPIDSet(t *testing.T) {
+	var s PIDSet
+	if s.Contains(1) {
+		t.Errorf("nil PIDSet should be empty")
+	}
+	for _, v := range []string{"42", "7", "42"} {
+		if err := s.Set(v); err != nil {
+			t.Errorf("Set(%q) returned unexpected error: %v", v, err)
+		}
+	}
+	if !s.Contains(42) || !s.Contains(7) || s.Contains(8) {
+		t.Errorf("unexpected PIDSet content: %s", s.String())
+	}
+	if got := s.String(); got != "7,42" {
+		t.Errorf("String() = %q, want %q", got, "7,42")
+	}
+	for _, v := range []string{"", "abc", "0", "-3"} {
+		if err := s.Set(v); err == nil {
+			t.Errorf("Set(%q) expected an error", v)
+		}
+	}
+}
+
+func TestNameSet(t *testing.T) {
+	var s NameSet
+	if s.Contains("k3s-server") {
+		t.Errorf("nil NameSet should be empty")
+	}
+	for _, v := range []string{"k3s-server", "containerd", "k3s-server"} {
+		if err := s.Set(v); err != nil {
+			t.Errorf("Set(%q) returned unexpected error: %v", v, err)
+		}
+	}
+	if !s.Contains("k3s-server") || s.Contains("k3s") {
+		t.Errorf("unexpected NameSet content: %s", s.String())
+	}
+	if got := s.String(); got != "containerd,k3s-server" {
+		t.Errorf("String() = %q, want %q", got, "containerd,k3s-server")
+	}
+	if err := s.Set(""); err == nil {
+		t.Errorf("Set(\"\") expected an error")
+	}
+}
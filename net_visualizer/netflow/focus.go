@@ -0,0 +1,101 @@
+package netflow
+
+import (
+	"maps"
+	"strconv"
+)
+
+// FindProcesses returns the sorted PIDs of the processes designated by the query: the
+// processes with that PID if the query is a known PID, possibly several of them in
+// distinct PID namespaces, else all the processes with that name
+func (m *Model) FindProcesses(query string) []int64 {
+	var ret []int64
+	if pid, err := strconv.ParseInt(query, 10, 64); err == nil {
+		for _, n := range m.SortedNodes() {
+			if n.PID() == pid {
+				ret = append(ret, n.ProcessID)
+			}
+		}
+		if ret != nil {
+			return ret
+		}
+	}
+	for _, n := range m.SortedNodes() {
+		if n.ProcessName == query {
+			ret = append(ret, n.ProcessID)
+		}
+	}
+	return ret
+}
+
+// Neighborhood returns the submodel made of the focus processes and of the processes at
+// most depth hops away from them, treating edges as undirected. The statistics of the
+// returned model are the ones of the whole model.
+func (m *Model) Neighborhood(focus []int64, depth int) *Model {
+	adjacent := make(map[int64][]int64)
+	for e := range m.Edges {
+		adjacent[e.Source.PID] = append(adjacent[e.Source.PID], e.Dest.PID)
+		adjacent[e.Dest.PID] = append(adjacent[e.Dest.PID], e.Source.PID)
+	}
+
+	// breadth-first search, one hop at a time
+	keep := make(map[int64]struct{})
+	var frontier []int64
+	for _, pid := range focus {
+		if _, ok := m.Nodes[pid]; ok {
+			keep[pid] = struct{}{}
+			frontier = append(frontier, pid)
+		}
+	}
+	for hop := 0; hop < depth && len(frontier) > 0; hop++ {
+		var next []int64
+		for _, pid := range frontier {
+			for _, peer := range adjacent[pid] {
+				if _, seen := keep[peer]; !seen {
+					keep[peer] = struct{}{}
+					next = append(next, peer)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return m.subModel(keep)
+}
+
+// subModel returns the part of the model involving only the given processes
+func (m *Model) subModel(pids map[int64]struct{}) *Model {
+	ret := newModel()
+	ret.Stats = m.Stats
+	ret.Latest = m.Latest
+	ret.hidden = maps.Clone(m.hidden)
+	for pid := range pids {
+		ret.Nodes[pid] = m.Nodes[pid]
+	}
+	for e, info := range m.Edges {
+		_, src := pids[e.Source.PID]
+		_, dst := pids[e.Dest.PID]
+		if src && dst {
+			ret.Edges[e] = info
+		} else {
+			ret.hide(e)
+		}
+	}
+	for remote, locals := range m.Dangling {
+		for local, dir := range locals {
+			if _, ok := pids[local.PID]; !ok {
+				continue
+			}
+			if ret.Dangling[remote] == nil {
+				ret.Dangling[remote] = make(map[ProcessEndpoint]Direction)
+			}
+			ret.Dangling[remote][local] = dir
+		}
+	}
+	for ep := range m.Listening {
+		if _, ok := pids[ep.PID]; ok {
+			ret.Listening[ep] = struct{}{}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,75 @@
+package netflow
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// chainTrace returns a trace where process i (PID 100+i, on 10.42.0.i) connects to
+// process i+1, for i in [1, n)
+func chainTrace(n int) string {
+	var trace strings.Builder
+	for i := 1; i < n; i++ {
+		fmt.Fprintf(&trace, "10.42.0.%d:8080<-10.42.0.%d:40000|PID=%d CMD=svc%d\n", i+1, i, 100+i, i)
+		fmt.Fprintf(&trace, "10.42.0.%d:40000->10.42.0.%d:8080|PID=%d CMD=svc%d\n", i, i+1, 101+i, i+1)
+	}
+	return trace.String()
+}
+
+func modelPIDs(m *Model) []int64 {
+	var pids []int64
+	for _, n := range m.SortedNodes() {
+		pids = append(pids, n.ProcessID)
+	}
+	return pids
+}
+
+func TestModelNeighborhood(t *testing.T) {
+	model := mustBuildModel(t, chainTrace(6), ModelOptions{})
+
+	for _, tc := range []struct {
+		depth int
+		want  []int64
+	}{
+		{0, []int64{103}},
+		{1, []int64{102, 103, 104}},
+		{2, []int64{101, 102, 103, 104, 105}},
+		{10, []int64{101, 102, 103, 104, 105, 106}},
+	} {
+		sub := model.Neighborhood([]int64{103}, tc.depth)
+		if got := modelPIDs(sub); !slices.Equal(got, tc.want) {
+			t.Errorf("depth %d: PIDs = %v, want %v", tc.depth, got, tc.want)
+		}
+		if len(sub.Edges) != len(tc.want)-1 {
+			t.Errorf("depth %d: got %d edges, want %d", tc.depth, len(sub.Edges), len(tc.want)-1)
+		}
+	}
+
+	// the direction of the edges does not matter, and several processes can be focused
+	if got := modelPIDs(model.Neighborhood([]int64{101, 106}, 1)); !slices.Equal(got, []int64{101, 102, 105, 106}) {
+		t.Errorf("PIDs = %v, want [101 102 105 106]", got)
+	}
+	if got := model.Neighborhood([]int64{999}, 1); len(got.Nodes) != 0 {
+		t.Errorf("unknown focus PIDs should give an empty model, got %v", modelPIDs(got))
+	}
+}
+
+func TestModelFindProcesses(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, ModelOptions{})
+	for _, tc := range []struct {
+		query string
+		want  []int64
+	}{
+		{"722977", []int64{722977}},
+		{"tcp-echo", []int64{722977}},
+		{"ncat", []int64{723429, 723715, 723736, 723753, 723801}},
+		{"12345", nil},
+		{"nginx", nil},
+	} {
+		if got := model.FindProcesses(tc.query); !slices.Equal(got, tc.want) {
+			t.Errorf("FindProcesses(%q) = %v, want %v", tc.query, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package netflow
+
+import "slices"
+
+// MergeForks returns a copy of the model where the processes sharing the same name and
+// container (or LocalIP, when the tracer does not report containers), e.g. the worker processes of a server, are merged into a single node. The
+// merged node takes the lowest PID, the union of the ports and the edges of all of its
+// processes, whose PIDs are listed in ProcessEndpoints.Forks. Edges between processes
+// merged together are dropped, as self-edges. The receiver is left untouched.
+func (m *Model) MergeForks() *Model {
+	type forkKey struct {
+		name      string
+		container string
+	}
+	groups := make(map[forkKey][]int64)
+	for pid, n := range m.Nodes {
+		key := forkKey{n.ProcessName, n.Group()}
+		groups[key] = append(groups[key], pid)
+	}
+
+	ret := newModel()
+	ret.Stats = m.Stats
+	ret.Latest = m.Latest
+	merged := make(map[int64]int64, len(m.Nodes)) // PID -> PID of the merged node
+	for _, pids := range groups {
+		slices.Sort(pids)
+		leader := m.Nodes[pids[0]]
+		if len(pids) == 1 {
+			ret.Nodes[leader.ProcessID] = leader
+			merged[leader.ProcessID] = leader.ProcessID
+			continue
+		}
+		n := *leader
+		n.LocalPorts, n.Sources = nil, nil
+		n.Forks = pids
+		for _, pid := range pids {
+			merged[pid] = n.ProcessID
+			for _, port := range m.Nodes[pid].LocalPorts {
+				if !slices.Contains(n.LocalPorts, port) {
+					n.LocalPorts = append(n.LocalPorts, port)
+				}
+			}
+			for _, src := range m.Nodes[pid].Sources {
+				if !slices.Contains(n.Sources, src) {
+					n.Sources = append(n.Sources, src)
+				}
+			}
+		}
+		slices.Sort(n.Sources)
+		ret.Nodes[n.ProcessID] = &n
+	}
+
+	remap := func(ep ProcessEndpoint) ProcessEndpoint {
+		return ProcessEndpoint{PID: merged[ep.PID], Port: ep.Port}
+	}
+	for e, info := range m.Edges {
+		edge := Edge{Source: remap(e.Source), Dest: remap(e.Dest)}
+		if edge.Source.PID == edge.Dest.PID && e.Source.PID != e.Dest.PID {
+			continue
+		}
+		if existing, ok := ret.Edges[edge]; ok {
+			ret.Edges[edge] = existing.merge(info)
+		} else {
+			ret.Edges[edge] = info
+		}
+	}
+	for remote, locals := range m.Dangling {
+		ret.Dangling[remote] = make(map[ProcessEndpoint]Direction, len(locals))
+		for local, dir := range locals {
+			ret.Dangling[remote][remap(local)] = dir
+		}
+	}
+	for ep := range m.Listening {
+		ret.Listening[remap(ep)] = struct{}{}
+	}
+	return ret
+}
@@ -0,0 +1,64 @@
+package netflow
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestModelMergeForks(t *testing.T) {
+	// three nginx workers on the same IP, each serving one client, and one more nginx
+	// on another IP
+	var trace strings.Builder
+	for i := range 3 {
+		fmt.Fprintf(&trace, "10.42.0.1:80<-10.42.0.%d:40000|PID=%d CMD=client\n", 10+i, 200+i)
+		fmt.Fprintf(&trace, "10.42.0.%d:40000->10.42.0.1:80|PID=%d CMD=nginx\n", 10+i, 102-i)
+	}
+	trace.WriteString("10.42.0.2:80<-10.42.0.20:40000|PID=300 CMD=client\n")
+	trace.WriteString("10.42.0.20:40000->10.42.0.2:80|PID=110 CMD=nginx\n")
+	model := mustBuildModel(t, trace.String(), ModelOptions{})
+
+	merged := model.MergeForks()
+	if len(merged.Nodes) != 6 {
+		t.Fatalf("got %d nodes, want 6", len(merged.Nodes))
+	}
+	n := merged.Nodes[100]
+	if n == nil || !slices.Equal(n.Forks, []int64{100, 101, 102}) {
+		t.Fatalf("merged node = %+v, want PID 100 with forks [100 101 102]", n)
+	}
+	if !slices.Equal(merged.ListeningPorts(n), []int{80}) {
+		t.Errorf("merged ListeningPorts = %v, want [80]", merged.ListeningPorts(n))
+	}
+	if merged.Nodes[110].Forks != nil {
+		t.Errorf("nginx on another IP should not be merged: %+v", merged.Nodes[110])
+	}
+	if d := merged.Degrees()[100]; d.In != 3 || len(merged.Edges) != 4 {
+		t.Errorf("merged node in-degree = %d with %d edges, want 3 and 4", d.In, len(merged.Edges))
+	}
+
+	// the original model is untouched
+	if len(model.Nodes) != 8 || model.Nodes[100].Forks != nil {
+		t.Errorf("MergeForks modified its receiver")
+	}
+}
+
+func TestModelMergeForksByContainer(t *testing.T) {
+	// two nginx workers in the same container, and one more nginx in another container
+	// of the same pod, that shares the IP
+	model := mustBuildModel(t, `10.42.0.1:80<-10.42.0.10:40000|PID=200 CMD=client
+10.42.0.10:40000->10.42.0.1:80|PID=100 CONTAINER=web CMD=nginx
+10.42.0.1:80<-10.42.0.11:40000|PID=201 CMD=client
+10.42.0.11:40000->10.42.0.1:80|PID=101 CONTAINER=web CMD=nginx
+10.42.0.1:8080<-10.42.0.12:40000|PID=202 CMD=client
+10.42.0.12:40000->10.42.0.1:8080|PID=102 CONTAINER=admin CMD=nginx
+`, ModelOptions{})
+
+	merged := model.MergeForks()
+	if n := merged.Nodes[100]; n == nil || !slices.Equal(n.Forks, []int64{100, 101}) {
+		t.Errorf("merged node = %+v, want PID 100 with forks [100 101]", n)
+	}
+	if n := merged.Nodes[102]; n == nil || n.Forks != nil {
+		t.Errorf("nginx in another container should not be merged: %+v", n)
+	}
+}
@@ -0,0 +1,57 @@
+package netflow
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// hostLookupTimeout bounds each DNS query issued by -resolve-hosts
+const hostLookupTimeout = 2 * time.Second
+
+// HostResolver turns hostname endpoints, which some tracer builds may emit instead of
+// IPs, into IP addresses so that they can be correlated with the other lines.
+// Each hostname is looked up only once.
+type HostResolver struct {
+	lookup func(host string) ([]net.IP, error)
+	cache  map[string]string // hostname -> IP; the hostname itself when it does not resolve
+}
+
+// NewHostResolver returns a HostResolver querying the system DNS resolver
+func NewHostResolver() *HostResolver {
+	return &HostResolver{
+		lookup: func(host string) ([]net.IP, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), hostLookupTimeout)
+			defer cancel()
+			return net.DefaultResolver.LookupIP(ctx, "ip", host)
+		},
+		cache: make(map[string]string),
+	}
+}
+
+// Resolve returns the IP of the host, preferring IPv4 addresses. IPs are returned as-is,
+// and so are hostnames on a nil resolver or when the lookup fails.
+func (r *HostResolver) Resolve(host string) string {
+	if r == nil || net.ParseIP(host) != nil {
+		return host
+	}
+	if ip, ok := r.cache[host]; ok {
+		return ip
+	}
+
+	ret := host
+	ips, err := r.lookup(host)
+	if err != nil || len(ips) == 0 {
+		warnRepeatedf("hostnames that cannot be resolved", "cannot resolve %q, keeping it as-is: %v", host, err)
+	} else {
+		ret = ips[0].String()
+		for _, ip := range ips {
+			if ip.To4() != nil {
+				ret = ip.String()
+				break
+			}
+		}
+	}
+	r.cache[host] = ret
+	return ret
+}
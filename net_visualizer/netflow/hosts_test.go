@@ -0,0 +1,78 @@
+package netflow
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func fakeHostResolver(hosts map[string][]net.IP, lookups *int) *HostResolver {
+	r := NewHostResolver()
+	r.lookup = func(host string) ([]net.IP, error) {
+		*lookups++
+		if ips, ok := hosts[host]; ok {
+			return ips, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	return r
+}
+
+func TestHostResolver(t *testing.T) {
+	var lookups int
+	r := fakeHostResolver(map[string][]net.IP{
+		"echo.default.svc": {net.ParseIP("fd00::54"), net.ParseIP("10.42.0.54")},
+		"localhost":        {net.ParseIP("127.0.0.1")},
+	}, &lookups)
+
+	for _, tc := range []struct{ host, want string }{
+		{"echo.default.svc", "10.42.0.54"}, // IPv4 preferred
+		{"echo.default.svc", "10.42.0.54"},
+		{"10.42.0.55", "10.42.0.55"},
+		{"unknown.example", "unknown.example"},
+		{"unknown.example", "unknown.example"},
+	} {
+		if got := r.Resolve(tc.host); got != tc.want {
+			t.Errorf("Resolve(%q) = %q, want %q", tc.host, got, tc.want)
+		}
+	}
+	if lookups != 2 {
+		t.Errorf("got %d lookups, want 2: answers must be cached and IPs never looked up", lookups)
+	}
+
+	var disabled *HostResolver
+	if got := disabled.Resolve("echo.default.svc"); got != "echo.default.svc" {
+		t.Errorf("nil resolver Resolve = %q, want the hostname unchanged", got)
+	}
+}
+
+func TestBuildModelHostnameEndpoints(t *testing.T) {
+	// the client reports the server by name, the server reports its own IP
+	trace := `echo.default.svc:5671<-10.42.0.55:5672|PID=723736 CMD=ncat
+10.42.0.55:5672->10.42.0.54:5671|PID=722977 CMD=tcp-echo
+localhost:8080<-10.42.0.55:5673|PID=723736 CMD=ncat
+`
+	want := Edge{Source: ProcessEndpoint{PID: 723736, Port: 5672}, Dest: ProcessEndpoint{PID: 722977, Port: 5671}}
+
+	// by default hostnames are kept as-is, and are not subject to the loopback check
+	model := mustBuildModel(t, trace, ModelOptions{})
+	if model.Stats.LinesFiltered != 0 {
+		t.Errorf("LinesFiltered = %d, want 0: hostnames must not be classified as loopback", model.Stats.LinesFiltered)
+	}
+	if _, ok := model.Edges[want]; !ok {
+		t.Errorf("edges = %+v, want %+v", model.SortedEdges(), want)
+	}
+
+	var lookups int
+	hosts := fakeHostResolver(map[string][]net.IP{
+		"echo.default.svc": {net.ParseIP("10.42.0.54")},
+		"localhost":        {net.ParseIP("127.0.0.1")},
+	}, &lookups)
+	model = mustBuildModel(t, trace, ModelOptions{Hosts: hosts})
+	if _, ok := model.Edges[want]; !ok || len(model.Edges) != 1 {
+		t.Errorf("edges = %+v, want only %+v", model.SortedEdges(), want)
+	}
+	if model.Stats.LinesFiltered != 1 {
+		t.Errorf("LinesFiltered = %d, want 1: localhost resolves to loopback", model.Stats.LinesFiltered)
+	}
+}
@@ -0,0 +1,57 @@
+package netflow
+
+import "maps"
+
+// IncludeProcesses returns the submodel made of the edges with at least one endpoint in
+// a process with one of the given names, together with the processes at their ends.
+// The other processes are only used to correlate the connections of the included ones.
+// The statistics of the returned model are the ones of the whole model.
+func (m *Model) IncludeProcesses(names NameSet) *Model {
+	included := make(map[int64]struct{})
+	for pid, n := range m.Nodes {
+		if names.Contains(n.ProcessName) {
+			included[pid] = struct{}{}
+		}
+	}
+	return m.touching(included)
+}
+
+// touching returns the part of the model made of the given processes, of the edges with
+// at least one endpoint in them and of the processes at the other end of those edges
+func (m *Model) touching(pids map[int64]struct{}) *Model {
+	ret := newModel()
+	ret.Stats = m.Stats
+	ret.Latest = m.Latest
+	ret.hidden = maps.Clone(m.hidden)
+	for pid := range pids {
+		ret.Nodes[pid] = m.Nodes[pid]
+	}
+	for e, info := range m.Edges {
+		_, src := pids[e.Source.PID]
+		_, dst := pids[e.Dest.PID]
+		if src || dst {
+			ret.Edges[e] = info
+			ret.Nodes[e.Source.PID] = m.Nodes[e.Source.PID]
+			ret.Nodes[e.Dest.PID] = m.Nodes[e.Dest.PID]
+		} else {
+			ret.hide(e)
+		}
+	}
+	for remote, locals := range m.Dangling {
+		for local, dir := range locals {
+			if _, ok := pids[local.PID]; !ok {
+				continue
+			}
+			if ret.Dangling[remote] == nil {
+				ret.Dangling[remote] = make(map[ProcessEndpoint]Direction)
+			}
+			ret.Dangling[remote][local] = dir
+		}
+	}
+	for ep := range m.Listening {
+		if _, ok := ret.Nodes[ep.PID]; ok {
+			ret.Listening[ep] = struct{}{}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,45 @@
+package netflow
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestModelIncludeProcesses(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		include, exclude NameSet
+		wantNodes        []int64
+		wantEdges        int
+	}{
+		// the clients of tcp-echo are drawn, not the other ncat processes
+		{"include only", NameSet{"tcp-echo": {}}, nil, []int64{722977, 723736, 723753}, 2},
+		{"exclude only", nil, NameSet{"tcp-echo": {}}, []int64{723429, 723715, 723736, 723753, 723801}, 2},
+		// the peers of tcp-echo are excluded: its connections are dangling
+		{"include and exclude", NameSet{"tcp-echo": {}}, NameSet{"ncat": {}}, []int64{722977}, 0},
+		{"excluded after included", NameSet{"tcp-echo": {}}, NameSet{"tcp-echo": {}}, nil, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			whole := mustBuildModel(t, sampleTrace, ModelOptions{Filter: FilterOptions{ExcludeProcesses: tc.exclude}})
+			model := whole
+			if tc.include != nil {
+				model = whole.IncludeProcesses(tc.include)
+			}
+			if pids := modelPIDs(model); !slices.Equal(pids, tc.wantNodes) {
+				t.Errorf("got processes %v, want %v", pids, tc.wantNodes)
+			}
+			if len(model.Edges) != tc.wantEdges {
+				t.Errorf("got %d edges, want %d", len(model.Edges), tc.wantEdges)
+			}
+			for e := range model.Edges {
+				src, dst := model.Nodes[e.Source.PID], model.Nodes[e.Dest.PID]
+				if tc.include != nil && !tc.include.Contains(src.ProcessName) && !tc.include.Contains(dst.ProcessName) {
+					t.Errorf("edge %+v has no included endpoint", e)
+				}
+			}
+			if model.Stats != whole.Stats {
+				t.Errorf("IncludeProcesses must keep the statistics of the whole model")
+			}
+		})
+	}
+}
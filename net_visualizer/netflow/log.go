@@ -0,0 +1,74 @@
+package netflow
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// LogOutput receives the warnings about the processing, e.g. evicted nodes or hostnames
+// that do not resolve; set it to io.Discard to silence them
+var LogOutput io.Writer = os.Stderr
+
+// InfoOutput receives the informational messages about the processing, e.g. the retried
+// reads of the inputs; they are discarded by default
+var InfoOutput io.Writer = io.Discard
+
+// infof reports an informational message, for the verbose mode
+func infof(format string, args ...any) {
+	fmt.Fprintf(InfoOutput, "INFO: "+format+"\n", args...)
+}
+
+// warnf reports a non-fatal problem to the user
+func warnf(format string, args ...any) {
+	fmt.Fprintf(LogOutput, "WARNING: "+format+"\n", args...)
+}
+
+// maxRepeatedWarnings is the number of warnings of the same kind reported one by one by
+// warnRepeatedf; the following ones are only counted
+const maxRepeatedWarnings = 10
+
+// repeated counts the warnings of each kind reported by warnRepeatedf since the last
+// FlushWarnings; kinds lists them in the order they were first seen
+var repeated struct {
+	mu     sync.Mutex
+	counts map[string]int
+	kinds  []string
+}
+
+// warnRepeatedf reports a problem that may occur for a large number of lines, e.g. for
+// every line of a misbehaving tracer: past maxRepeatedWarnings warnings of the same kind,
+// described by what they are about, they are only counted and summarized by
+// FlushWarnings, so that they do not drown the other ones
+func warnRepeatedf(kind, format string, args ...any) {
+	repeated.mu.Lock()
+	defer repeated.mu.Unlock()
+	if repeated.counts == nil {
+		repeated.counts = make(map[string]int)
+	}
+	if _, seen := repeated.counts[kind]; !seen {
+		repeated.kinds = append(repeated.kinds, kind)
+	}
+	repeated.counts[kind]++
+	if n := repeated.counts[kind]; n <= maxRepeatedWarnings {
+		warnf(format, args...)
+		if n == maxRepeatedWarnings {
+			warnf("further warnings about %s are only counted", kind)
+		}
+	}
+}
+
+// FlushWarnings summarizes the warnings left out by warnRepeatedf since the last call,
+// e.g. "2 more warnings about ...", and resets their counts; it is meant to be called
+// once the input is consumed
+func FlushWarnings() {
+	repeated.mu.Lock()
+	defer repeated.mu.Unlock()
+	for _, kind := range repeated.kinds {
+		if n := repeated.counts[kind]; n > maxRepeatedWarnings {
+			warnf("%d more warnings about %s", n-maxRepeatedWarnings, kind)
+		}
+	}
+	repeated.counts, repeated.kinds = nil, nil
+}
@@ -0,0 +1,50 @@
+package netflow
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWarnRepeated(t *testing.T) {
+	// forget the warnings of the other tests
+	LogOutput = io.Discard
+	FlushWarnings()
+	var warnings bytes.Buffer
+	LogOutput = &warnings
+	defer func() { LogOutput = os.Stderr }()
+
+	// a tracer build that always reports the opposite direction
+	line := "10.42.0.55:5672->10.42.0.54:5671|PID=722977 DIR=out CMD=tcp-echo"
+	for range 1000 {
+		if _, err := ParseLine(line); err != nil {
+			t.Fatalf("ParseLine returned unexpected error: %v", err)
+		}
+	}
+	warnRepeatedf("something else", "a single warning of another kind")
+	FlushWarnings()
+
+	lines := strings.Split(strings.TrimSuffix(warnings.String(), "\n"), "\n")
+	if n := strings.Count(warnings.String(), "the arrow contradicts DIR=out"); n != maxRepeatedWarnings {
+		t.Errorf("got %d individual warnings, want %d:\n%s", n, maxRepeatedWarnings, warnings.String())
+	}
+	want := []string{
+		"WARNING: further warnings about lines whose arrow contradicts their DIR token are only counted",
+		"WARNING: a single warning of another kind",
+		"WARNING: 990 more warnings about lines whose arrow contradicts their DIR token",
+	}
+	if len(lines) != maxRepeatedWarnings+len(want) || !slices.Equal(lines[maxRepeatedWarnings:], want) {
+		t.Errorf("got warnings:\n%s\nwant them to end with:\n%s", warnings.String(), strings.Join(want, "\n"))
+	}
+
+	// the counts start over after a flush
+	warnings.Reset()
+	ParseLine(line)
+	FlushWarnings()
+	if n := strings.Count(warnings.String(), "\n"); n != 1 {
+		t.Errorf("got %d warnings after the flush, want 1:\n%s", n, warnings.String())
+	}
+}
@@ -0,0 +1,46 @@
+package netflow
+
+import "container/list"
+
+// lru tracks the recency of a bounded set of keys. It does not store any value: callers
+// keep their own maps and use the evicted keys reported by Touch to prune them.
+type lru[K comparable] struct {
+	capacity int
+	order    *list.List // front = most recently used
+	index    map[K]*list.Element
+}
+
+// newLRU returns a tracker holding at most capacity keys; capacity <= 0 means unbounded
+func newLRU[K comparable](capacity int) *lru[K] {
+	return &lru[K]{
+		capacity: capacity,
+		order:    list.New(),
+		index:    make(map[K]*list.Element),
+	}
+}
+
+// Touch marks the key as the most recently used one. If this pushes the tracker past its
+// capacity, the least recently used key is dropped and returned.
+func (l *lru[K]) Touch(key K) (evicted K, didEvict bool) {
+	if el, ok := l.index[key]; ok {
+		l.order.MoveToFront(el)
+		return evicted, false
+	}
+	l.index[key] = l.order.PushFront(key)
+	if l.capacity <= 0 || l.order.Len() <= l.capacity {
+		return evicted, false
+	}
+	oldest := l.order.Back()
+	l.order.Remove(oldest)
+	evicted = oldest.Value.(K)
+	delete(l.index, evicted)
+	return evicted, true
+}
+
+// Remove forgets the key, if tracked
+func (l *lru[K]) Remove(key K) {
+	if el, ok := l.index[key]; ok {
+		l.order.Remove(el)
+		delete(l.index, key)
+	}
+}
@@ -0,0 +1,32 @@
+package netflow
+
+import "testing"
+
+func TestLRU(t *testing.T) {
+	l := newLRU[int](2)
+	for _, k := range []int{1, 2} {
+		if _, evicted := l.Touch(k); evicted {
+			t.Fatalf("Touch(%d) evicted below capacity", k)
+		}
+	}
+	// refresh 1 so that 2 becomes the least recently used
+	if _, evicted := l.Touch(1); evicted {
+		t.Fatalf("Touch of a tracked key must not evict")
+	}
+	if got, evicted := l.Touch(3); !evicted || got != 2 {
+		t.Errorf("Touch(3) = (%d, %v), want (2, true)", got, evicted)
+	}
+	l.Remove(1)
+	if _, evicted := l.Touch(4); evicted {
+		t.Errorf("Touch(4) evicted although Remove(1) freed a slot")
+	}
+}
+
+func TestLRUUnbounded(t *testing.T) {
+	l := newLRU[int](0)
+	for k := 0; k < 1000; k++ {
+		if _, evicted := l.Touch(k); evicted {
+			t.Fatalf("unbounded tracker evicted key on Touch(%d)", k)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package netflow
+
+import (
+	"maps"
+	"slices"
+)
+
+// DefaultSidecarPorts are the ports of the Envoy sidecars of Istio, intercepting the
+// outbound (15001) and inbound (15006) traffic of the pods
+var DefaultSidecarPorts = PortSet{15001: {}, 15006: {}}
+
+// CollapseSidecars returns a copy of the model where the service mesh sidecars, i.e. the
+// processes listening on one of the sidecarPorts, are folded into the connections they
+// proxy: each app->sidecar->peer chain becomes a single app->peer edge, flagged with
+// EdgeInfo.ViaSidecar and carrying the info of the hop towards the peer, so that
+// app->sidecar->sidecar->app chains become a single edge between the two apps. The
+// sidecar does not tell which app its connections are proxied for: when it proxies
+// several processes, e.g. two containers of the same pod, each of its connections is
+// drawn from all of them. The sidecars without any connection on either side are left
+// as they are. The receiver is left untouched.
+func (m *Model) CollapseSidecars(sidecarPorts PortSet) *Model {
+	ret := newModel()
+	ret.Stats = m.Stats
+	ret.Latest = m.Latest
+	ret.hidden = maps.Clone(m.hidden)
+	maps.Copy(ret.Nodes, m.Nodes)
+	maps.Copy(ret.Edges, m.Edges)
+	maps.Copy(ret.Listening, m.Listening)
+	for remote, locals := range m.Dangling {
+		ret.Dangling[remote] = maps.Clone(locals)
+	}
+	for _, pid := range m.sidecars(sidecarPorts) {
+		ret.collapseSidecar(pid)
+	}
+	return ret
+}
+
+// sidecars returns the sorted PIDs of the processes listening on one of the ports
+func (m *Model) sidecars(ports PortSet) []int64 {
+	set := make(map[int64]struct{})
+	for ep := range m.Listening {
+		if ports.Contains(ep.Port) {
+			set[ep.PID] = struct{}{}
+		}
+	}
+	return slices.Sorted(maps.Keys(set))
+}
+
+// collapseSidecar replaces, in place, the sidecar and its edges with edges from the
+// processes it proxies, see CollapseSidecars
+func (m *Model) collapseSidecar(pid int64) {
+	var in, out []Edge
+	for e := range m.Edges {
+		switch {
+		case e.Source.PID == pid && e.Dest.PID == pid:
+			// self-edges are not proxied connections
+		case e.Dest.PID == pid:
+			in = append(in, e)
+		case e.Source.PID == pid:
+			out = append(out, e)
+		}
+	}
+	var dangling []NetworkEndpoint
+	for remote, locals := range m.Dangling {
+		for local := range locals {
+			if local.PID == pid {
+				dangling = append(dangling, remote)
+				break
+			}
+		}
+	}
+	if len(in) == 0 || len(out)+len(dangling) == 0 {
+		return
+	}
+
+	// the connections are drawn from the first client endpoint of each proxied process
+	slices.SortFunc(in, compareEdges)
+	var clients []ProcessEndpoint
+	for _, e := range in {
+		if !slices.ContainsFunc(clients, func(c ProcessEndpoint) bool { return c.PID == e.Source.PID }) {
+			clients = append(clients, e.Source)
+		}
+	}
+	for _, o := range out {
+		for _, client := range clients {
+			if client.PID == o.Dest.PID {
+				continue
+			}
+			bridged := Edge{Source: client, Dest: o.Dest}
+			info := m.Edges[o]
+			if existing, ok := m.Edges[bridged]; ok {
+				info = existing.merge(info)
+			} else {
+				copied := *info
+				info = &copied
+			}
+			info.ViaSidecar = true
+			m.Edges[bridged] = info
+		}
+	}
+	for _, remote := range dangling {
+		for local, dir := range m.Dangling[remote] {
+			if local.PID != pid {
+				continue
+			}
+			delete(m.Dangling[remote], local)
+			for _, client := range clients {
+				m.Dangling[remote][client] = dir
+			}
+		}
+	}
+
+	for _, e := range append(in, out...) {
+		delete(m.Edges, e)
+		m.hide(e)
+	}
+	for ep := range m.Listening {
+		if ep.PID == pid {
+			delete(m.Listening, ep)
+		}
+	}
+	delete(m.Nodes, pid)
+}
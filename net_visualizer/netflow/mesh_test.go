@@ -0,0 +1,55 @@
+package netflow
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestModelCollapseSidecars(t *testing.T) {
+	// the frontend of pod 10.0.1.1 calls the backend of pod 10.0.2.1 through the Envoy
+	// sidecars of both pods; its sidecar also proxies a call to an external API
+	trace := `0.0.0.0:0<-10.0.1.1:15001|PID=11 STATE=LISTEN CMD=envoy
+0.0.0.0:0<-10.0.2.1:15006|PID=21 STATE=LISTEN CMD=envoy
+0.0.0.0:0<-10.0.2.1:8080|PID=20 STATE=LISTEN CMD=backend
+10.0.1.1:15001<-10.0.1.1:40000|PID=10 CMD=frontend
+10.0.2.1:15006<-10.0.1.1:41000|PID=11 CMD=envoy
+10.0.2.1:8080<-10.0.2.1:42000|PID=21 CMD=envoy
+203.0.113.5:443<-10.0.1.1:43000|PID=11 CMD=envoy
+`
+	model := mustBuildModel(t, trace, ModelOptions{})
+	if len(model.Nodes) != 4 || len(model.Edges) != 3 {
+		t.Fatalf("got %d nodes and %d edges, want the 4 processes of the chain and its 3 hops", len(model.Nodes), len(model.Edges))
+	}
+
+	mesh := model.CollapseSidecars(DefaultSidecarPorts)
+	if pids := modelPIDs(mesh); !slices.Equal(pids, []int64{10, 20}) {
+		t.Errorf("got processes %v, want the frontend and the backend", pids)
+	}
+	want := Edge{Source: ProcessEndpoint{PID: 10, Port: 40000}, Dest: ProcessEndpoint{PID: 20, Port: 8080}}
+	if edges := mesh.SortedEdges(); !slices.Equal(edges, []Edge{want}) {
+		t.Fatalf("SortedEdges = %+v, want the single edge %+v", edges, want)
+	}
+	if info := mesh.Edges[want]; !info.ViaSidecar || info.Count != 1 {
+		t.Errorf("got %+v, want the edge via sidecar observed once", info)
+	}
+	// the connection to the external API is drawn from the frontend as well
+	external := mesh.Dangling[NetworkEndpoint{IP: "203.0.113.5", Port: 443}]
+	if _, ok := external[ProcessEndpoint{PID: 10, Port: 40000}]; !ok || len(external) != 1 {
+		t.Errorf("Dangling = %v, want the external API called from the frontend", mesh.Dangling)
+	}
+	if _, edges := mesh.Export(); len(edges) != 1 || !edges[0].ViaSidecar {
+		t.Errorf("Export = %+v, want the edge flagged via_sidecar", edges)
+	}
+
+	if len(model.Nodes) != 4 || len(model.Edges) != 3 || model.Edges[want] != nil {
+		t.Errorf("CollapseSidecars must leave the receiver untouched")
+	}
+	// without any listening sidecar port, nothing changes
+	var ports PortSet
+	if err := ports.Set("15090"); err != nil {
+		t.Fatal(err)
+	}
+	if same := model.CollapseSidecars(ports); len(same.Nodes) != 4 || len(same.Edges) != 3 {
+		t.Errorf("got %d nodes and %d edges, want the model unchanged", len(same.Nodes), len(same.Edges))
+	}
+}
@@ -0,0 +1,919 @@
+package netflow
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// NetworkEndpoint represents a generic IP:port pair, which is locally-relevant, i.e. is unique only within
+// a particular container/POD assuming that IPs do not change over the container/POD lifetime
+type NetworkEndpoint struct {
+	IP   string
+	Port int
+}
+
+// ProcessEndpoints collects all the endpoints (intended as IP:port pairs) that are exposed
+// by the same process. This model assumes that:
+//   - each Kube container exposes 1 network interface (no multi-networks via non-standard CNIs e.g. SRIOV)
+//   - the network interface of each container has a single IP address assigned
+//   - the IP address of the network interface of each container does not change during the lifetime of the PIDs
+//     living inside the PODs and using the network
+type ProcessEndpoints struct {
+	ProcessID   int64
+	ProcessName string
+	LocalIP     string
+	LocalPorts  []int
+	Pod         *PodInfo // pod owning LocalIP, when the Kubernetes enrichment is active and the IP resolves
+	ContainerID string   // container of the process, when reported by the tracer
+	CIDR        string   // range of the synthetic node standing for a ModelOptions.GroupCIDRs entry; empty for processes
+	Sources     []int    // indexes of the inputs this process was observed in
+	Collapsed   int      // number of distinct edges folded by CollapseChatty; 0 if drawn as usual
+	Forks       []int64  // sorted PIDs of the processes merged by MergeForks, including ProcessID; nil if not merged
+
+	// SharedPID is the PID reported by the tracer when it is also used by a process of
+	// another PID namespace: ProcessID is then a synthetic key, see aliasPID; 0 otherwise
+	SharedPID int64
+}
+
+// PID returns the PID of the process as reported by the tracer
+func (n *ProcessEndpoints) PID() int64 {
+	if n.SharedPID != 0 {
+		return n.SharedPID
+	}
+	return n.ProcessID
+}
+
+// Group returns the identity of the container of the process: its ContainerID when the
+// tracer reports it, its LocalIP otherwise, assuming a single IP per pod
+func (n *ProcessEndpoints) Group() string {
+	if n.ContainerID != "" {
+		return n.ContainerID
+	}
+	return n.LocalIP
+}
+
+type ProcessEndpoint struct {
+	PID  int64
+	Port int
+}
+
+// Edge represents a uniquely-identified TCP connection between two processes
+// (with the assumptions listed in ProcessEndpoints). It points from the process that
+// initiated the connection (the client) to the one that accepted it (the listener),
+// whichever side the connection was observed from.
+type Edge struct {
+	Source ProcessEndpoint
+	Dest   ProcessEndpoint
+}
+
+// EdgeInfo holds what is known about an Edge beyond its identity
+type EdgeInfo struct {
+	Count     int       // number of input lines that observed this connection
+	FirstSeen time.Time // timestamp of the earliest of those lines; zero without timestamps
+	LastSeen  time.Time // timestamp of the most recent of those lines; zero without timestamps
+	Bytes     int64     // traffic volume reported by those lines; 0 if the tracer does not report it
+	Packets   int64     // number of packets reported by those lines; 0 if the tracer does not report it
+
+	// SeenBySource and SeenByDest tell whether the connection was observed from the
+	// client side, resp. from the server side: it is observed from both sides when
+	// both ends of it are traced
+	SeenBySource bool
+	SeenByDest   bool
+
+	// CountBySource and CountByDest split Count after the side the lines observed the
+	// connection from: a large difference between the two, when both ends are traced,
+	// hints at lost observations, see Asymmetric
+	CountBySource int
+	CountByDest   int
+
+	// ViaSidecar flags the edges standing for connections proxied by a service mesh
+	// sidecar, see CollapseSidecars
+	ViaSidecar bool
+}
+
+// merge returns the info of an edge observed as both i and o
+func (i *EdgeInfo) merge(o *EdgeInfo) *EdgeInfo {
+	ret := &EdgeInfo{
+		Count:     i.Count + o.Count,
+		FirstSeen: i.FirstSeen,
+		LastSeen:  i.LastSeen,
+		Bytes:     addCounters(i.Bytes, o.Bytes),
+		Packets:   addCounters(i.Packets, o.Packets),
+
+		SeenBySource:  i.SeenBySource || o.SeenBySource,
+		SeenByDest:    i.SeenByDest || o.SeenByDest,
+		CountBySource: i.CountBySource + o.CountBySource,
+		CountByDest:   i.CountByDest + o.CountByDest,
+		ViaSidecar:    i.ViaSidecar || o.ViaSidecar,
+	}
+	ret.observedAt(o.FirstSeen)
+	ret.observedAt(o.LastSeen)
+	return ret
+}
+
+// observedAt extends the active window of the edge to include ts; a zero ts, from a line
+// without timestamp, leaves it unchanged
+func (i *EdgeInfo) observedAt(ts time.Time) {
+	if ts.IsZero() {
+		return
+	}
+	if i.FirstSeen.IsZero() || ts.Before(i.FirstSeen) {
+		i.FirstSeen = ts
+	}
+	if ts.After(i.LastSeen) {
+		i.LastSeen = ts
+	}
+}
+
+// addCounters sums two non-negative traffic counters, saturating at math.MaxInt64
+// rather than wrapping around to a negative volume
+func addCounters(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+// Model is the process-to-process connectivity graph extracted from the tracer output.
+// It is independent of any output format: renderers walk it via SortedNodes/SortedEdges
+// so that the same input always produces the same output.
+type Model struct {
+	Nodes map[int64]*ProcessEndpoints // PID -> Node
+	Edges map[Edge]*EdgeInfo          // Edge -> info
+
+	// Listening collects the server-side endpoints: the ones reported in LISTEN state, and
+	// the ones observed accepting a connection or, when the tracer does not report the
+	// socket state, connected to several distinct peers. All the other ports are ephemeral
+	// client ports.
+	Listening map[ProcessEndpoint]struct{}
+
+	// Dangling collects the remote endpoints never observed as the local side of a line,
+	// each with the local endpoints connected to it and the direction of the connection:
+	// either the capture is incomplete or the peer lives outside of the traced hosts
+	Dangling map[NetworkEndpoint]map[ProcessEndpoint]Direction
+
+	// Latest is the most recent timestamp read from the input; zero if it has none
+	Latest time.Time
+
+	Stats Stats
+
+	// hidden collects the endpoints of the edges dropped by the transformations that only
+	// keep a part of the model, e.g. Neighborhood, that are thus not idle
+	hidden map[ProcessEndpoint]struct{}
+}
+
+// ModelOptions controls how the Model gets built out of the input lines
+type ModelOptions struct {
+	Parser *LineParser // nil means the builtin tracer format
+	Filter FilterOptions
+
+	// BinaryInput reads the inputs as streams of size-prefixed Record messages, see
+	// record.proto, instead of text lines; Parser and Parallel are then ignored
+	BinaryInput bool
+
+	// MaxTrackedNodes and MaxTrackedEdges bound the memory used while processing huge inputs:
+	// once the limit is hit the least-recently-seen process (or edge) is forgotten.
+	// Zero means unbounded.
+	MaxTrackedNodes int
+	MaxTrackedEdges int
+
+	// MaxNodes and MaxEdges cap the size of the resulting graph: once the limit is hit,
+	// new processes (or edges) are dropped and only counted. Zero means unlimited.
+	MaxNodes int
+	MaxEdges int
+
+	// AllowSelfEdges keeps the edges between two endpoints of the same process, which
+	// are otherwise dropped as noise
+	AllowSelfEdges bool
+
+	// GroupCIDRs maps all the endpoints inside each range to a single synthetic node,
+	// named after the range, whose edges aggregate the ones of all the IPs in the range
+	GroupCIDRs CIDRGroups
+
+	// Hosts, when non-nil, resolves the hostname endpoints to IPs; by default they are
+	// kept as-is
+	Hosts *HostResolver
+
+	// DedupWindow, when positive, counts the observations of the same edge less than
+	// this duration apart as a single one, e.g. the churn of health checks; it needs
+	// timestamps, the lines without any are always counted
+	DedupWindow time.Duration
+
+	// Strict makes Consume fail with a StrictError at the first line that cannot be
+	// parsed, blank lines and tracer banners aside, instead of skipping it
+	Strict bool
+
+	// OnParseError, when non-nil, is called with every line that cannot be parsed, blank
+	// lines and tracer banners aside, whether or not Strict is set. It is called in the
+	// order of the lines of each input, with the Builder locked.
+	OnParseError func(*StrictError)
+
+	// Explain, when non-nil, reports the outcome of the processing of every line
+	// observing its connection
+	Explain *Explainer
+
+	// ConcurrentSources makes ConsumeAll, and BuildModelFromSources, read all the sources
+	// at the same time instead of one after the other, e.g. the live feeds of several
+	// hosts, which never end: their lines are correlated in the order they arrive, under
+	// the lock of the Builder. The model then depends on how the lines interleave only
+	// for the details that depend on their order anyway, e.g. the orientation of the
+	// edges whose listener is unknown; the renderers walk SortedNodes and SortedEdges, so
+	// that the output does not depend on the order the nodes and edges were added in.
+	ConcurrentSources bool
+
+	// Parallel, when greater than 1, is the number of goroutines parsing the lines of
+	// each input; the lines are still correlated in input order, so the model is the
+	// same as with serial parsing
+	Parallel int
+
+	// Sample, when in (0, 1), randomly keeps that fraction of the parsed lines, to explore
+	// huge inputs; the selection only depends on Seed and on the order of the lines
+	Sample float64
+	Seed   uint64
+}
+
+func newModel() *Model {
+	return &Model{
+		Nodes:     make(map[int64]*ProcessEndpoints),
+		Edges:     make(map[Edge]*EdgeInfo),
+		Listening: make(map[ProcessEndpoint]struct{}),
+		Dangling:  make(map[NetworkEndpoint]map[ProcessEndpoint]Direction),
+	}
+}
+
+// hide records the endpoints of an edge left out of the model
+func (m *Model) hide(e Edge) {
+	if m.hidden == nil {
+		m.hidden = make(map[ProcessEndpoint]struct{})
+	}
+	m.hidden[e.Source] = struct{}{}
+	m.hidden[e.Dest] = struct{}{}
+}
+
+// SortedNodes returns all the nodes of the model ordered by PID
+func (m *Model) SortedNodes() []*ProcessEndpoints {
+	ret := make([]*ProcessEndpoints, 0, len(m.Nodes))
+	for _, n := range m.Nodes {
+		ret = append(ret, n)
+	}
+	slices.SortFunc(ret, func(a, b *ProcessEndpoints) int {
+		return cmp.Or(cmp.Compare(a.PID(), b.PID()), cmp.Compare(a.SharedPID, b.SharedPID), cmp.Compare(a.ProcessID, b.ProcessID))
+	})
+	return ret
+}
+
+// SortedEdges returns all the edges of the model ordered by source PID/port and then dest PID/port
+func (m *Model) SortedEdges() []Edge {
+	ret := make([]Edge, 0, len(m.Edges))
+	for e := range m.Edges {
+		ret = append(ret, e)
+	}
+	slices.SortFunc(ret, compareEdges)
+	return ret
+}
+
+func compareEndpoints(a, b ProcessEndpoint) int {
+	return cmp.Or(
+		cmp.Compare(a.PID, b.PID),
+		cmp.Compare(a.Port, b.Port),
+	)
+}
+
+func compareEdges(a, b Edge) int {
+	return cmp.Or(
+		compareEndpoints(a.Source, b.Source),
+		compareEndpoints(a.Dest, b.Dest),
+	)
+}
+
+// NodeDegree counts the distinct edges entering (fan-in) and leaving (fan-out) a node
+type NodeDegree struct {
+	In  int
+	Out int
+}
+
+// Degrees computes the fan-in/fan-out of every node, once all the input has been consumed
+func (m *Model) Degrees() map[int64]NodeDegree {
+	ret := make(map[int64]NodeDegree, len(m.Nodes))
+	for e := range m.Edges {
+		src := ret[e.Source.PID]
+		src.Out++
+		ret[e.Source.PID] = src
+		dst := ret[e.Dest.PID]
+		dst.In++
+		ret[e.Dest.PID] = dst
+	}
+	return ret
+}
+
+// ResolvePods attaches to each node the pod owning its LocalIP, if known to the resolver
+func (m *Model) ResolvePods(resolver PodResolver) {
+	for _, n := range m.Nodes {
+		if pod, ok := resolver.ResolvePod(n.LocalIP); ok {
+			n.Pod = &pod
+		}
+	}
+}
+
+// Builder holds the state needed to correlate input lines into a Model.
+// The same builder can consume several inputs, so that endpoints observed in one of
+// them can be matched against connections observed in another.
+// The model is only updated while holding mu, so that it can be rendered by
+// another goroutine (see WithModel) while the input is still being consumed.
+type Builder struct {
+	mu             sync.Mutex
+	opts           ModelOptions
+	model          *Model
+	knownEndpoints map[NetworkEndpoint]int64    // Endpoint (IP:Port) -> PID
+	sharedEps      map[NetworkEndpoint]struct{} // endpoints claimed by several PIDs, to warn only once
+	nodesLRU       *lru[int64]
+	edgesLRU       *lru[Edge]
+	selfEdges      map[Edge]struct{} // suppressed self-edges, to count them only once
+	repaired       map[Edge]struct{} // edges whose direction was repaired, to report them only once
+
+	// firstPeer records the only peer seen so far by each local endpoint not yet known
+	// to be listening; a second distinct peer marks the endpoint as listening
+	firstPeer map[ProcessEndpoint]NetworkEndpoint
+
+	// processes and edges dropped because of MaxNodes / MaxEdges
+	truncatedNodes map[int64]struct{}
+	truncatedEdges map[Edge]struct{}
+
+	filters []FilterFunc // the optional filters of ModelOptions.Filter, see IsValidLine
+	sampler *rand.Rand   // nil unless ModelOptions.Sample is set
+
+	// node keys of the processes sharing their PID with another PID namespace, by PID
+	pidAliases map[int64][]int64
+	aliasCount int
+}
+
+// NewBuilder returns a Builder with an empty model
+func NewBuilder(opts ModelOptions) *Builder {
+	b := &Builder{
+		opts:           opts,
+		model:          newModel(),
+		knownEndpoints: make(map[NetworkEndpoint]int64),
+		sharedEps:      make(map[NetworkEndpoint]struct{}),
+		nodesLRU:       newLRU[int64](opts.MaxTrackedNodes),
+		edgesLRU:       newLRU[Edge](opts.MaxTrackedEdges),
+		selfEdges:      make(map[Edge]struct{}),
+		repaired:       make(map[Edge]struct{}),
+		firstPeer:      make(map[ProcessEndpoint]NetworkEndpoint),
+		truncatedNodes: make(map[int64]struct{}),
+		truncatedEdges: make(map[Edge]struct{}),
+		pidAliases:     make(map[int64][]int64),
+		filters:        opts.Filter.funcs(),
+	}
+	if opts.Sample > 0 && opts.Sample < 1 {
+		b.sampler = rand.New(rand.NewPCG(opts.Seed, opts.Seed))
+	}
+	return b
+}
+
+// touchNode marks the process as the most recently seen one, forgetting the
+// least-recently-seen one when this exceeds MaxTrackedNodes
+func (b *Builder) touchNode(pid int64) {
+	if evictedPID, evicted := b.nodesLRU.Touch(pid); evicted {
+		if b.model.Stats.EvictedNodes == 0 {
+			warnf("more than %d processes seen: forgetting the least-recently-seen ones; "+
+				"their connections will be missing from the graph unless observed again", b.opts.MaxTrackedNodes)
+		}
+		b.evictNode(evictedPID)
+	}
+}
+
+// evictNode removes the process from the model, together with all its edges and endpoints
+func (b *Builder) evictNode(pid int64) {
+	n, ok := b.model.Nodes[pid]
+	if !ok {
+		return
+	}
+	for _, port := range n.LocalPorts {
+		ep := NetworkEndpoint{IP: n.LocalIP, Port: port}
+		if b.knownEndpoints[ep] == pid {
+			delete(b.knownEndpoints, ep)
+		}
+		delete(b.model.Listening, ProcessEndpoint{PID: pid, Port: port})
+		delete(b.firstPeer, ProcessEndpoint{PID: pid, Port: port})
+	}
+	for remote, locals := range b.model.Dangling {
+		for local := range locals {
+			if local.PID == pid {
+				delete(locals, local)
+			}
+		}
+		if len(locals) == 0 {
+			delete(b.model.Dangling, remote)
+		}
+	}
+	for e := range b.model.Edges {
+		if e.Source.PID == pid || e.Dest.PID == pid {
+			delete(b.model.Edges, e)
+			b.edgesLRU.Remove(e)
+		}
+	}
+	delete(b.model.Nodes, pid)
+	b.model.Stats.EvictedNodes++
+}
+
+// Consume reads all the lines of the given input, tagging them with the source index.
+// If reading fails midway, the lines read so far are kept in the model; transient read
+// errors, e.g. a read interrupted by a signal, are retried first.
+func (b *Builder) Consume(r io.Reader, source int) error {
+	r = &retryReader{r: r}
+	if b.opts.BinaryInput {
+		return b.consumeRecords(r, source)
+	}
+	if b.opts.Parallel > 1 {
+		return b.consumeParallel(r, source)
+	}
+	scanner := bufio.NewScanner(r)
+	linesRead := 0
+	for scanner.Scan() {
+		linesRead++
+		b.mu.Lock()
+		err := b.addLine(scanner.Text(), source)
+		err = b.strictError(scanner.Text(), linesRead, source, err)
+		b.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading input after %d lines: %w", linesRead, err)
+	}
+	return nil
+}
+
+// trackListening tells apart listening and ephemeral ports: the local endpoint is listening
+// if it accepted a connection, or if it was connected to more than one distinct peer.
+// The latter heuristic is only needed when the tracer does not report the socket state.
+func (b *Builder) trackListening(local ProcessEndpoint, peer NetworkEndpoint, dir Direction, state SocketState) {
+	if _, ok := b.model.Listening[local]; ok {
+		return
+	}
+	first, seen := b.firstPeer[local]
+	switch {
+	case state == StateListen, dir == Remote2Local:
+		b.model.Listening[local] = struct{}{}
+		delete(b.firstPeer, local)
+	case state == StateEstablished:
+		// an outgoing connection: the local endpoint is a client one
+	case seen && first != peer:
+		b.model.Listening[local] = struct{}{}
+		delete(b.firstPeer, local)
+	case !seen:
+		b.firstPeer[local] = peer
+	}
+}
+
+// ListeningPorts returns the sorted listening ports of the process, see Model.Listening
+func (m *Model) ListeningPorts(n *ProcessEndpoints) []int {
+	var ret []int
+	for _, port := range n.LocalPorts {
+		if _, ok := m.Listening[ProcessEndpoint{PID: n.ProcessID, Port: port}]; ok {
+			ret = append(ret, port)
+		}
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+// orient returns the edge of the connection between the local and remote endpoints of a
+// line, pointing from the initiator to the listener. The direction of the line tells them
+// apart, unless it contradicts the listening ports detected so far: the two sides of a
+// connection may report inconsistent directions, e.g. when the tracer guesses them. When
+// neither endpoint is known to be listening, the connection keeps the orientation of its
+// first observation, so that both sides always land on the same edge; should the listener
+// be detected afterwards, the edge is turned around on the next observation.
+func (b *Builder) orient(local, remote ProcessEndpoint, dir Direction) Edge {
+	edge := Edge{Source: local, Dest: remote}
+	if dir == Remote2Local {
+		edge = Edge{Source: remote, Dest: local}
+	}
+	_, srcListening := b.model.Listening[edge.Source]
+	_, dstListening := b.model.Listening[edge.Dest]
+	if srcListening == dstListening {
+		// undecided: stick to the first observation
+		reversed := Edge{Source: edge.Dest, Dest: edge.Source}
+		if _, ok := b.model.Edges[edge]; !ok {
+			if _, ok := b.model.Edges[reversed]; ok {
+				return reversed
+			}
+		}
+		return edge
+	}
+	if srcListening {
+		edge = Edge{Source: edge.Dest, Dest: edge.Source}
+		b.repairDirection(edge)
+	}
+	reversed := Edge{Source: edge.Dest, Dest: edge.Source}
+	if info, ok := b.model.Edges[reversed]; ok {
+		// drawn the other way before the listener was detected
+		info.SeenBySource, info.SeenByDest = info.SeenByDest, info.SeenBySource
+		info.CountBySource, info.CountByDest = info.CountByDest, info.CountBySource
+		if existing, ok := b.model.Edges[edge]; ok {
+			info = existing.merge(info)
+		}
+		b.model.Edges[edge] = info
+		delete(b.model.Edges, reversed)
+		b.edgesLRU.Remove(reversed)
+		b.repairDirection(edge)
+	}
+	return edge
+}
+
+// repairDirection reports that the direction of a line contradicted the listening port
+// of the edge, which is drawn towards the listener
+func (b *Builder) repairDirection(edge Edge) {
+	if _, ok := b.repaired[edge]; ok {
+		return
+	}
+	b.repaired[edge] = struct{}{}
+	b.model.Stats.DirectionsRepaired++
+	warnRepeatedf("connections whose direction contradicts the listening port", "the direction reported for the connection %s:%d-%s:%d contradicts the listening port: drawing it towards %s:%d",
+		b.model.Nodes[edge.Source.PID].LocalIP, edge.Source.Port, b.model.Nodes[edge.Dest.PID].LocalIP, edge.Dest.Port,
+		b.model.Nodes[edge.Dest.PID].LocalIP, edge.Dest.Port)
+}
+
+// isDuplicate tells whether an observation of the edge at ts falls within the
+// ModelOptions.DedupWindow of the previous one
+func (b *Builder) isDuplicate(info *EdgeInfo, ts time.Time) bool {
+	if b.opts.DedupWindow <= 0 || ts.IsZero() || info.LastSeen.IsZero() {
+		return false
+	}
+	d := ts.Sub(info.LastSeen)
+	return d < b.opts.DedupWindow && d > -b.opts.DedupWindow
+}
+
+// addGroupEndpoint registers the remote endpoint of the line to the synthetic node of the
+// grouped range it belongs to, creating the node if needed, and returns its PID
+func (b *Builder) addGroupEndpoint(group int, line InputLine) int64 {
+	pid := groupPID(group)
+	n, ok := b.model.Nodes[pid]
+	if !ok {
+		g := b.opts.GroupCIDRs[group]
+		n = &ProcessEndpoints{ProcessID: pid, ProcessName: g.Name, LocalIP: line.RemoteIP, CIDR: line.RemoteIP}
+		b.model.Nodes[pid] = n
+	}
+	if !slices.Contains(n.LocalPorts, line.RemotePort) {
+		n.LocalPorts = append(n.LocalPorts, line.RemotePort)
+	}
+	if !slices.Contains(n.Sources, line.Source) {
+		n.Sources = append(n.Sources, line.Source)
+	}
+	if line.Dir == Local2Remote {
+		// the range accepted the connection
+		b.model.Listening[ProcessEndpoint{PID: pid, Port: line.RemotePort}] = struct{}{}
+	}
+	return pid
+}
+
+// WithModel runs fn on the model built so far, excluding any concurrent update
+func (b *Builder) WithModel(fn func(*Model) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return fn(b.model)
+}
+
+// KnownEndpoints returns a copy of the table correlating the local endpoints observed so
+// far to the processes owning them, keyed like Model.Nodes, e.g. to debug why a remote
+// endpoint was or was not correlated to a process. It must not be called from WithModel
+func (b *Builder) KnownEndpoints() map[NetworkEndpoint]int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return maps.Clone(b.knownEndpoints)
+}
+
+// addLine parses, filters and correlates a single input line
+func (b *Builder) addLine(line string, source int) error {
+	parsedLine, err := b.opts.Parser.Parse(line)
+	b.addParsedLine(line, parsedLine, err, source)
+	return err
+}
+
+// StrictError reports a line that could not be parsed: the first one is returned by
+// Consume under ModelOptions.Strict, and all of them are passed to ModelOptions.OnParseError
+type StrictError struct {
+	Source  int // index of the input the line comes from
+	LineNum int // 1-based line number within that input, or record number for BinaryInput
+	Line    string
+	Err     error // the parsing error
+}
+
+func (e *StrictError) Error() string {
+	return fmt.Sprintf("invalid line %d: %s", e.LineNum, e.Line)
+}
+
+func (e *StrictError) Unwrap() error {
+	return e.Err
+}
+
+// strictError returns the StrictError of a line that failed parsing with err, or nil
+// if it did not fail, if the failure is expected (blank lines and tracer banners) or
+// without ModelOptions.Strict. Unexpected failures are also passed to
+// ModelOptions.OnParseError. It must be called with b.mu held.
+func (b *Builder) strictError(line string, lineNum, source int, err error) error {
+	if err == nil || IsBannerLine(line) {
+		return nil
+	}
+	strictErr := &StrictError{Source: source, LineNum: lineNum, Line: line, Err: err}
+	if b.opts.OnParseError != nil {
+		b.opts.OnParseError(strictErr)
+	}
+	if !b.opts.Strict {
+		return nil
+	}
+	return strictErr
+}
+
+// addParsedLine filters and correlates a single input line, given the result of its
+// parsing
+func (b *Builder) addParsedLine(line string, parsedLine InputLine, err error, source int) {
+	model := b.model
+	opts := b.opts
+
+	model.Stats.LinesRead++
+	lineNo := model.Stats.LinesRead
+	explain := opts.Explain != nil && opts.Explain.Conn.matches(line, parsedLine, err)
+	if err != nil {
+		model.Stats.LinesInvalid++
+		b.explain(explain, lineNo, line, "rejected: invalid line: %v", err)
+		return
+	}
+	if b.sampler != nil && b.sampler.Float64() >= opts.Sample {
+		model.Stats.LinesSampledOut++
+		b.explain(explain, lineNo, line, "rejected: sampled out")
+		return
+	}
+	parsedLine.Source = source
+	if parsedLine.Timestamp.After(model.Latest) {
+		model.Latest = parsedLine.Timestamp
+	}
+	parsedLine.RemoteIP = opts.Hosts.Resolve(parsedLine.RemoteIP)
+	parsedLine.LocalIP = opts.Hosts.Resolve(parsedLine.LocalIP)
+
+	if opts.Filter.ExcludePIDs.Contains(parsedLine.ProcessID) || opts.Filter.ExcludeProcesses.Contains(parsedLine.ProcessName) {
+		model.Stats.LinesExcluded++
+		b.explain(explain, lineNo, line, "rejected: process %d (%s) is excluded", parsedLine.ProcessID, parsedLine.ProcessName)
+		return
+	}
+
+	// IP filter using net package
+	if !isValidLine(parsedLine, b.filters) {
+		model.Stats.LinesFiltered++
+		b.explain(explain, lineNo, line, "rejected: dropped by the filters")
+		return
+	}
+
+	// the endpoints inside a grouped range belong to its synthetic node
+	localGroup := opts.GroupCIDRs.Match(parsedLine.LocalIP)
+	if localGroup >= 0 {
+		g := opts.GroupCIDRs[localGroup]
+		parsedLine.ProcessID, parsedLine.ProcessName, parsedLine.LocalIP = groupPID(localGroup), g.Name, g.Net.String()
+	}
+	remoteGroup := opts.GroupCIDRs.Match(parsedLine.RemoteIP)
+	if remoteGroup >= 0 {
+		parsedLine.RemoteIP = opts.GroupCIDRs[remoteGroup].Net.String()
+	}
+
+	// Create if the PID in this line is known or not
+	pid := parsedLine.ProcessID
+	parsedLine.ProcessID = b.nodeKey(parsedLine)
+	n, pidIsKnown := model.Nodes[parsedLine.ProcessID]
+	if parsedLine.State == StateListen && net.ParseIP(parsedLine.LocalIP).IsUnspecified() {
+		// bound to all the interfaces: the IP of the process is only known from its connections
+		if !pidIsKnown {
+			model.Stats.LinesFiltered++
+			b.explain(explain, lineNo, line, "rejected: listening on all the interfaces, by a process whose IP is not known yet")
+			return
+		}
+		parsedLine.LocalIP = n.LocalIP
+	}
+	if !pidIsKnown && opts.MaxNodes > 0 && len(model.Nodes) >= opts.MaxNodes {
+		if _, seen := b.truncatedNodes[parsedLine.ProcessID]; !seen {
+			if len(b.truncatedNodes) == 0 {
+				warnf("more than %d processes seen: the graph is truncated", opts.MaxNodes)
+			}
+			b.truncatedNodes[parsedLine.ProcessID] = struct{}{}
+			model.Stats.TruncatedNodes++
+		}
+		b.explain(explain, lineNo, line, "rejected: too many processes, the graph is truncated")
+		return
+	}
+	if !pidIsKnown {
+		// found a new process
+		model.Nodes[parsedLine.ProcessID] = &ProcessEndpoints{
+			ProcessID:   parsedLine.ProcessID,
+			SharedPID:   sharedPID(pid, parsedLine.ProcessID),
+			ProcessName: parsedLine.ProcessName,
+			LocalIP:     parsedLine.LocalIP,
+			LocalPorts:  []int{parsedLine.LocalPort},
+			Sources:     []int{parsedLine.Source},
+			ContainerID: parsedLine.ContainerID,
+		}
+		if localGroup >= 0 {
+			model.Nodes[parsedLine.ProcessID].CIDR = parsedLine.LocalIP
+		}
+	} else {
+		// nodeKey only returns the key of a known node for the lines of the same
+		// process, see sameProcess: should we enrich it?
+		portIdx := slices.IndexFunc(n.LocalPorts, func(c int) bool { return c == parsedLine.LocalPort })
+		if portIdx == -1 {
+			// found a new exposed port
+			n.LocalPorts = append(n.LocalPorts, parsedLine.LocalPort)
+		} // else: port was already known... nothing to do
+		if !slices.Contains(n.Sources, parsedLine.Source) {
+			n.Sources = append(n.Sources, parsedLine.Source)
+		}
+		if n.ContainerID == "" {
+			n.ContainerID = parsedLine.ContainerID
+		}
+	}
+
+	b.touchNode(parsedLine.ProcessID)
+
+	// should we register the local endpoint to the local PID ?
+	localEp := NetworkEndpoint{
+		IP:   parsedLine.LocalIP,
+		Port: parsedLine.LocalPort,
+	}
+	e, localEpIsKnown := b.knownEndpoints[localEp]
+	if !localEpIsKnown {
+		// Register the local endpoint in the list of known endpoints:
+		b.knownEndpoints[localEp] = parsedLine.ProcessID
+		// and stop considering it as dangling, if anybody referenced it
+		delete(model.Dangling, localEp)
+	} else if e != parsedLine.ProcessID {
+		// the endpoint is shared by several processes, e.g. SO_REUSEPORT listeners, the
+		// workers of a server or a socket handed over to another process: it is handed
+		// over to the most recently seen one, that gets the edges from now on
+		if _, warned := b.sharedEps[localEp]; !warned {
+			b.sharedEps[localEp] = struct{}{}
+			warnRepeatedf("endpoints shared by several processes", "%s:%d is shared by PIDs %d and %d: drawing its connections to the most recently seen one",
+				localEp.IP, localEp.Port, e, parsedLine.ProcessID)
+		}
+		b.knownEndpoints[localEp] = parsedLine.ProcessID
+	}
+
+	// If we know the PID listening on the remoteIP:remotePort endpoint,
+	// we can draw an edge. Endpoints are always matched on both the IP and the port, so
+	// that the processes of the same pod, sharing the IP, are told apart by their ports:
+	// only the endpoints of the same process make a self-edge.
+	remoteEp := NetworkEndpoint{
+		IP:   parsedLine.RemoteIP,
+		Port: parsedLine.RemotePort,
+	}
+	b.trackListening(ProcessEndpoint{PID: parsedLine.ProcessID, Port: parsedLine.LocalPort}, remoteEp, parsedLine.Dir, parsedLine.State)
+	if parsedLine.State == StateListen {
+		// no connection to draw
+		b.explain(explain, lineNo, line, "accepted: listening port %d of process %d, without any connection to draw", parsedLine.LocalPort, parsedLine.ProcessID)
+		return
+	}
+
+	remotePID, isRemotePIDKnown := b.knownEndpoints[remoteEp]
+	if remoteGroup >= 0 {
+		remotePID, isRemotePIDKnown = b.addGroupEndpoint(remoteGroup, parsedLine), true
+	}
+	if !isRemotePIDKnown {
+		// due to the way the input feed is designed, we'll have a second chance
+		// of drawing this edge later, typically in the next upcoming input line
+		// which should normally contain local/remote endpoints swapped.
+		// However it might happen that an edge does not get rendered because the
+		// remote party never gets discovered (e.g. it's an endpoint of a node outside
+		// kubernetes, e.g. in public internet, e.g. a remote image registry).
+		// Until then, the connection is tracked as dangling.
+		local := ProcessEndpoint{PID: parsedLine.ProcessID, Port: parsedLine.LocalPort}
+		if model.Dangling[remoteEp] == nil {
+			model.Dangling[remoteEp] = make(map[ProcessEndpoint]Direction)
+		}
+		model.Dangling[remoteEp][local] = parsedLine.Dir
+		b.explain(explain, lineNo, line, "pending: no process seen yet with %s:%d as local endpoint, the connection is dangling until a line of its peer shows up", remoteEp.IP, remoteEp.Port)
+		return
+	}
+
+	// we have all the info to build an edge
+	edge := b.orient(
+		ProcessEndpoint{PID: parsedLine.ProcessID, Port: parsedLine.LocalPort},
+		ProcessEndpoint{PID: remotePID, Port: parsedLine.RemotePort},
+		parsedLine.Dir)
+
+	if edge.Source.PID == edge.Dest.PID && !opts.AllowSelfEdges {
+		// e.g. sidecar-to-main-container traffic within the same process: just noise
+		if _, exists := b.selfEdges[edge]; !exists {
+			b.selfEdges[edge] = struct{}{}
+			model.Stats.SelfEdgesSuppressed++
+		}
+		b.explain(explain, lineNo, line, "rejected: self-edge of process %d", edge.Source.PID)
+		return
+	}
+
+	// register the edge; duplicates collapse into the same map key
+	info, exists := model.Edges[edge]
+	if !exists && opts.MaxEdges > 0 && len(model.Edges) >= opts.MaxEdges {
+		if _, seen := b.truncatedEdges[edge]; !seen {
+			if len(b.truncatedEdges) == 0 {
+				warnf("more than %d edges seen: the graph is truncated", opts.MaxEdges)
+			}
+			b.truncatedEdges[edge] = struct{}{}
+			model.Stats.TruncatedEdges++
+		}
+		b.explain(explain, lineNo, line, "rejected: too many edges, the graph is truncated")
+		return
+	}
+	if !exists {
+		info = &EdgeInfo{}
+		model.Edges[edge] = info
+	}
+	bySource := edge.Source == (ProcessEndpoint{PID: parsedLine.ProcessID, Port: parsedLine.LocalPort})
+	if b.isDuplicate(info, parsedLine.Timestamp) {
+		model.Stats.LinesDeduplicated++
+		b.explain(explain, lineNo, line, "accepted: edge %s, not counted as observed again within the dedup window", edgeName(edge))
+	} else {
+		info.Count++
+		if bySource {
+			info.CountBySource++
+		} else {
+			info.CountByDest++
+		}
+		info.Bytes = addCounters(info.Bytes, parsedLine.Bytes)
+		info.Packets = addCounters(info.Packets, parsedLine.Packets)
+		b.explain(explain, lineNo, line, "accepted: edge %s, observed %d time(s)", edgeName(edge), info.Count)
+	}
+	info.observedAt(parsedLine.Timestamp)
+	if bySource {
+		info.SeenBySource = true
+	} else {
+		info.SeenByDest = true
+	}
+	// the synthetic nodes of the grouped ranges are only tracked here
+	if b.touchNode(remotePID); model.Edges[edge] == nil {
+		// forgotten together with the least-recently-seen process, under a tiny limit
+		return
+	}
+	if evictedEdge, evicted := b.edgesLRU.Touch(edge); evicted {
+		if model.Stats.EvictedEdges == 0 {
+			warnf("more than %d edges seen: forgetting the least-recently-seen ones; "+
+				"the graph will only contain the most recently observed connections", opts.MaxTrackedEdges)
+		}
+		delete(model.Edges, evictedEdge)
+		model.Stats.EvictedEdges++
+	}
+}
+
+// BuildModel consumes the tracer output from the given reader and correlates the
+// connections into a process-level Model.
+// If reading fails midway, the partially-built model is returned together with the error.
+func BuildModel(r io.Reader, opts ModelOptions) (*Model, error) {
+	return BuildModelFromSources([]io.Reader{r}, opts)
+}
+
+// BuildModelFromSources merges several tracer outputs (e.g. captured on different hosts)
+// into a single Model; each line is tagged with the index of the reader it comes from.
+// The first read error stops the processing and is returned with the partial model.
+func BuildModelFromSources(sources []io.Reader, opts ModelOptions) (*Model, error) {
+	b := NewBuilder(opts)
+	err := b.ConsumeAll(sources)
+	return b.model, err
+}
+
+// ConsumeAll feeds the sources to the builder, each line being tagged with the index of
+// its source, one after the other or, with ModelOptions.ConcurrentSources, all at the
+// same time. It returns the first read error: after it, the following sources are not
+// read when they are consumed one after the other.
+func (b *Builder) ConsumeAll(sources []io.Reader) error {
+	if !b.opts.ConcurrentSources {
+		for i, r := range sources {
+			if err := b.Consume(r, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errs := make([]error, len(sources))
+	var wg sync.WaitGroup
+	for i, r := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = b.Consume(r, i)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
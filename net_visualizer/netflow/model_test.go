@@ -0,0 +1,874 @@
+package netflow
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sampleTrace is a small excerpt of a real tracer output, with two clients connecting to
+// each of two servers
+const sampleTrace = `Attaching 5 probes...
+Listening for TCPv4 traffic...
+10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat
+10.42.0.55:5672->10.42.0.54:5671|PID=722977 CMD=tcp-echo
+10.42.0.58:5673<-10.42.0.56:5674|PID=723715 CMD=ncat
+10.42.0.56:5674->10.42.0.58:5673|PID=723429 CMD=ncat
+10.42.0.54:5671<-10.42.0.53:5672|PID=723753 CMD=ncat
+10.42.0.53:5672->10.42.0.54:5671|PID=722977 CMD=tcp-echo
+10.42.0.58:5673<-10.42.0.57:5674|PID=723801 CMD=ncat
+10.42.0.57:5674->10.42.0.58:5673|PID=723429 CMD=ncat
+127.0.0.1:8080<-127.0.0.1:52114|PID=19190 CMD=coredns
+`
+
+func mustBuildModel(t *testing.T, trace string, opts ModelOptions) *Model {
+	t.Helper()
+	model, err := BuildModel(strings.NewReader(trace), opts)
+	if err != nil {
+		t.Fatalf("BuildModel returned unexpected error: %v", err)
+	}
+	return model
+}
+
+func TestBuildModelSortedOutput(t *testing.T) {
+	model, err := BuildModel(strings.NewReader(sampleTrace), ModelOptions{})
+	if err != nil {
+		t.Fatalf("BuildModel returned unexpected error: %v", err)
+	}
+
+	var pids []int64
+	for _, n := range model.SortedNodes() {
+		pids = append(pids, n.ProcessID)
+	}
+	wantPIDs := []int64{722977, 723429, 723715, 723736, 723753, 723801}
+	if !slices.Equal(pids, wantPIDs) {
+		t.Errorf("SortedNodes PIDs = %v, want %v", pids, wantPIDs)
+	}
+
+	wantEdges := []Edge{
+		{Source: ProcessEndpoint{PID: 723715, Port: 5674}, Dest: ProcessEndpoint{PID: 723429, Port: 5673}},
+		{Source: ProcessEndpoint{PID: 723736, Port: 5672}, Dest: ProcessEndpoint{PID: 722977, Port: 5671}},
+		{Source: ProcessEndpoint{PID: 723753, Port: 5672}, Dest: ProcessEndpoint{PID: 722977, Port: 5671}},
+		{Source: ProcessEndpoint{PID: 723801, Port: 5674}, Dest: ProcessEndpoint{PID: 723429, Port: 5673}},
+	}
+	if edges := model.SortedEdges(); !slices.Equal(edges, wantEdges) {
+		t.Errorf("SortedEdges = %+v, want %+v", edges, wantEdges)
+	}
+}
+
+func TestBuildModelMaxTrackedEdges(t *testing.T) {
+	model, err := BuildModel(strings.NewReader(sampleTrace), ModelOptions{MaxTrackedEdges: 1})
+	if err != nil {
+		t.Fatalf("BuildModel returned unexpected error: %v", err)
+	}
+	wantEdges := []Edge{
+		{Source: ProcessEndpoint{PID: 723801, Port: 5674}, Dest: ProcessEndpoint{PID: 723429, Port: 5673}},
+	}
+	if edges := model.SortedEdges(); !slices.Equal(edges, wantEdges) {
+		t.Errorf("SortedEdges = %+v, want only the most recent edge %+v", edges, wantEdges)
+	}
+	if model.Stats.EvictedEdges != 3 {
+		t.Errorf("EvictedEdges = %d, want 3", model.Stats.EvictedEdges)
+	}
+}
+
+func TestBuildModelMaxTrackedNodes(t *testing.T) {
+	model, err := BuildModel(strings.NewReader(sampleTrace), ModelOptions{MaxTrackedNodes: 2})
+	if err != nil {
+		t.Fatalf("BuildModel returned unexpected error: %v", err)
+	}
+	if len(model.Nodes) != 2 {
+		t.Errorf("got %d nodes, want 2", len(model.Nodes))
+	}
+	for _, e := range model.SortedEdges() {
+		if model.Nodes[e.Source.PID] == nil || model.Nodes[e.Dest.PID] == nil {
+			t.Errorf("edge %+v references an evicted node", e)
+		}
+	}
+	// the last client/server pair is observed last, hence must survive
+	wantEdges := []Edge{
+		{Source: ProcessEndpoint{PID: 723801, Port: 5674}, Dest: ProcessEndpoint{PID: 723429, Port: 5673}},
+	}
+	if edges := model.SortedEdges(); !slices.Equal(edges, wantEdges) {
+		t.Errorf("SortedEdges = %+v, want %+v", edges, wantEdges)
+	}
+	if model.Stats.EvictedNodes != 6 {
+		t.Errorf("EvictedNodes = %d, want 6", model.Stats.EvictedNodes)
+	}
+}
+
+func TestBuildModelHardCaps(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, ModelOptions{MaxNodes: 3})
+	// the first three processes seen are kept, whatever their connections
+	wantPIDs := []int64{722977, 723715, 723736}
+	var pids []int64
+	for _, n := range model.SortedNodes() {
+		pids = append(pids, n.ProcessID)
+	}
+	if !slices.Equal(pids, wantPIDs) {
+		t.Errorf("SortedNodes PIDs = %v, want %v", pids, wantPIDs)
+	}
+	if model.Stats.TruncatedNodes != 3 {
+		t.Errorf("TruncatedNodes = %d, want 3", model.Stats.TruncatedNodes)
+	}
+	for e := range model.Edges {
+		if model.Nodes[e.Source.PID] == nil || model.Nodes[e.Dest.PID] == nil {
+			t.Errorf("dangling edge %+v", e)
+		}
+	}
+
+	model = mustBuildModel(t, sampleTrace, ModelOptions{MaxEdges: 1})
+	if len(model.Edges) != 1 || len(model.Nodes) != 6 {
+		t.Errorf("got %d nodes and %d edges, want 6 and 1", len(model.Nodes), len(model.Edges))
+	}
+	if model.Stats.TruncatedEdges != 3 {
+		t.Errorf("TruncatedEdges = %d, want 3", model.Stats.TruncatedEdges)
+	}
+}
+
+// selfEdgeTrace contains a process connecting to another port of its own, twice
+const selfEdgeTrace = `10.42.0.9:8080<-10.42.0.9:40000|PID=100 CMD=app
+10.42.0.9:40000->10.42.0.9:8080|PID=100 CMD=app
+10.42.0.9:8080<-10.42.0.9:40000|PID=100 CMD=app
+10.42.0.9:40000->10.42.0.9:8080|PID=100 CMD=app
+`
+
+func TestBuildModelSelfEdges(t *testing.T) {
+	model := mustBuildModel(t, selfEdgeTrace, ModelOptions{})
+	if len(model.Edges) != 0 {
+		t.Errorf("self-edges should be suppressed by default, got %+v", model.SortedEdges())
+	}
+	if model.Stats.SelfEdgesSuppressed != 1 {
+		t.Errorf("SelfEdgesSuppressed = %d, want 1", model.Stats.SelfEdgesSuppressed)
+	}
+
+	model = mustBuildModel(t, selfEdgeTrace, ModelOptions{AllowSelfEdges: true})
+	wantEdges := []Edge{
+		{Source: ProcessEndpoint{PID: 100, Port: 40000}, Dest: ProcessEndpoint{PID: 100, Port: 8080}},
+	}
+	if edges := model.SortedEdges(); !slices.Equal(edges, wantEdges) {
+		t.Errorf("SortedEdges = %+v, want %+v", edges, wantEdges)
+	}
+	if model.Stats.SelfEdgesSuppressed != 0 {
+		t.Errorf("SelfEdgesSuppressed = %d, want 0 with AllowSelfEdges", model.Stats.SelfEdgesSuppressed)
+	}
+}
+
+// sameIPTrace has two processes of the same pod, an application listening on 8080 and
+// its sidecar, talking to each other over the pod IP: only the ports tell them apart
+const sameIPTrace = `10.42.0.9:8080<-10.42.0.9:40000|PID=200 CMD=sidecar
+10.42.0.9:40000->10.42.0.9:8080|PID=100 CMD=app
+10.42.0.9:8080<-10.42.0.9:40000|PID=200 CMD=sidecar
+10.42.0.9:8080<-10.42.0.10:50000|PID=300 CMD=client
+10.42.0.10:50000->10.42.0.9:8080|PID=100 CMD=app
+`
+
+func TestBuildModelSameIPDifferentPorts(t *testing.T) {
+	// the same connection seen from the application side first
+	lines := strings.SplitAfter(sameIPTrace, "\n")
+	reordered := lines[1] + lines[0] + lines[1] + strings.Join(lines[3:], "")
+
+	for name, trace := range map[string]string{"client first": sameIPTrace, "server first": reordered} {
+		t.Run(name, func(t *testing.T) {
+			model := mustBuildModel(t, trace, ModelOptions{})
+			if len(model.Nodes) != 3 {
+				t.Errorf("got %d nodes, want 3: the processes sharing the IP must not be merged", len(model.Nodes))
+			}
+			wantEdges := []Edge{
+				{Source: ProcessEndpoint{PID: 200, Port: 40000}, Dest: ProcessEndpoint{PID: 100, Port: 8080}},
+				{Source: ProcessEndpoint{PID: 300, Port: 50000}, Dest: ProcessEndpoint{PID: 100, Port: 8080}},
+			}
+			if edges := model.SortedEdges(); !slices.Equal(edges, wantEdges) {
+				t.Errorf("SortedEdges = %+v, want %+v", edges, wantEdges)
+			}
+			if model.Stats.SelfEdgesSuppressed != 0 {
+				t.Errorf("SelfEdgesSuppressed = %d, want 0", model.Stats.SelfEdgesSuppressed)
+			}
+			if len(model.Dangling) != 0 {
+				t.Errorf("Dangling = %v, want none", model.Dangling)
+			}
+		})
+	}
+}
+
+func TestBuildModelExcludePIDs(t *testing.T) {
+	var excluded PIDSet
+	if err := excluded.Set("723429"); err != nil {
+		t.Fatal(err)
+	}
+	model := mustBuildModel(t, sampleTrace, ModelOptions{Filter: FilterOptions{ExcludePIDs: excluded}})
+
+	if _, ok := model.Nodes[723429]; ok {
+		t.Errorf("excluded PID 723429 should not be in the model")
+	}
+	// the clients of the excluded server are still there, just not connected to it
+	if len(model.Nodes) != 5 || len(model.Edges) != 2 {
+		t.Errorf("got %d nodes and %d edges, want 5 and 2", len(model.Nodes), len(model.Edges))
+	}
+	if model.Stats.LinesExcluded != 2 {
+		t.Errorf("LinesExcluded = %d, want 2", model.Stats.LinesExcluded)
+	}
+}
+
+func TestBuildModelExcludeProcesses(t *testing.T) {
+	var excluded NameSet
+	if err := excluded.Set("tcp-echo"); err != nil {
+		t.Fatal(err)
+	}
+	model := mustBuildModel(t, sampleTrace, ModelOptions{Filter: FilterOptions{ExcludeProcesses: excluded}})
+
+	if _, ok := model.Nodes[722977]; ok {
+		t.Errorf("excluded process tcp-echo should not be in the model")
+	}
+	if len(model.Nodes) != 5 || len(model.Edges) != 2 || model.Stats.LinesExcluded != 2 {
+		t.Errorf("got %d nodes, %d edges and %d excluded lines, want 5, 2 and 2",
+			len(model.Nodes), len(model.Edges), model.Stats.LinesExcluded)
+	}
+}
+
+func TestBuildModelSharedEndpoint(t *testing.T) {
+	var warnings bytes.Buffer
+	LogOutput = &warnings
+	defer func() { LogOutput = os.Stderr }()
+
+	// the listening socket 10.42.0.1:80 is handed over from PID 10 to PID 20, e.g. on a
+	// hot reload
+	model := mustBuildModel(t, `10.42.0.10:40000->10.42.0.1:80|PID=10 CMD=haproxy
+10.42.0.1:80<-10.42.0.10:40000|PID=100 CMD=client
+10.42.0.11:40000->10.42.0.1:80|PID=20 CMD=haproxy-new
+10.42.0.1:80<-10.42.0.11:40000|PID=101 CMD=client
+10.42.0.12:40000->10.42.0.1:80|PID=20 CMD=haproxy-new
+10.42.0.1:80<-10.42.0.12:40000|PID=102 CMD=client
+`, ModelOptions{})
+
+	servers := make(map[int64]int64)
+	for e := range model.Edges {
+		servers[e.Source.PID] = e.Dest.PID
+	}
+	want := map[int64]int64{100: 10, 101: 20, 102: 20}
+	if len(servers) != len(want) || servers[100] != 10 || servers[101] != 20 || servers[102] != 20 {
+		t.Errorf("client -> server PIDs = %v, want %v", servers, want)
+	}
+	if got := strings.Count(warnings.String(), "is shared by PIDs 10 and 20"); got != 1 {
+		t.Errorf("got %d warnings about the shared endpoint, want 1: %q", got, warnings.String())
+	}
+}
+
+func TestBuildModelRepairsDirection(t *testing.T) {
+	client := ProcessEndpoint{PID: 200, Port: 40000}
+	server := ProcessEndpoint{PID: 100, Port: 8080}
+	for _, tc := range []struct {
+		name  string
+		trace string
+		count int
+	}{
+		{
+			// both sides claim to have initiated the connection, but 8080 is listening
+			name: "contradicted by a known listener",
+			trace: `0.0.0.0:0<-10.0.0.1:8080|PID=100 STATE=LISTEN CMD=server
+10.0.0.1:8080<-10.0.0.2:40000|PID=200 CMD=client
+10.0.0.2:40000<-10.0.0.1:8080|PID=100 CMD=server
+`,
+			count: 2,
+		},
+		{
+			// the edge is first drawn the wrong way, since 8080 is only detected as
+			// listening once it accepts another connection: it is turned around on the
+			// next observation
+			name: "listener detected afterwards",
+			trace: `10.0.0.1:8080<-10.0.0.2:40000|PID=200 CMD=client
+10.0.0.2:40000<-10.0.0.1:8080|PID=100 CMD=server
+10.0.0.3:50000->10.0.0.1:8080|PID=100 CMD=server
+10.0.0.1:8080<-10.0.0.2:40000|PID=200 CMD=client
+`,
+			count: 2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var warnings bytes.Buffer
+			LogOutput = &warnings
+			defer func() { LogOutput = os.Stderr }()
+
+			model := mustBuildModel(t, tc.trace, ModelOptions{})
+			wantEdges := []Edge{{Source: client, Dest: server}}
+			if edges := model.SortedEdges(); !slices.Equal(edges, wantEdges) {
+				t.Fatalf("SortedEdges = %+v, want %+v", edges, wantEdges)
+			}
+			if got := model.Edges[wantEdges[0]].Count; got != tc.count {
+				t.Errorf("Count = %d, want %d", got, tc.count)
+			}
+			if model.Stats.DirectionsRepaired != 1 {
+				t.Errorf("DirectionsRepaired = %d, want 1", model.Stats.DirectionsRepaired)
+			}
+			if want := "contradicts the listening port: drawing it towards 10.0.0.1:8080"; strings.Count(warnings.String(), want) != 1 {
+				t.Errorf("want a single warning about the repair, got %q", warnings.String())
+			}
+		})
+	}
+}
+
+// failingReader yields its data and then fails, like a broken pipe would
+type failingReader struct {
+	data io.Reader
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		return n, r.err
+	}
+	return n, err
+}
+
+func TestBuildModelReadError(t *testing.T) {
+	readErr := errors.New("decompression fault")
+	// only the first client/server pair makes it before the failure
+	prefix := strings.Join(strings.Split(sampleTrace, "\n")[:4], "\n") + "\n"
+
+	model, err := BuildModel(&failingReader{data: strings.NewReader(prefix), err: readErr}, ModelOptions{})
+	if !errors.Is(err, readErr) {
+		t.Fatalf("BuildModel error = %v, want %v", err, readErr)
+	}
+	if model == nil {
+		t.Fatalf("BuildModel must return the partial model on read errors")
+	}
+	wantEdges := []Edge{
+		{Source: ProcessEndpoint{PID: 723736, Port: 5672}, Dest: ProcessEndpoint{PID: 722977, Port: 5671}},
+	}
+	if edges := model.SortedEdges(); !slices.Equal(edges, wantEdges) {
+		t.Errorf("SortedEdges = %+v, want %+v", edges, wantEdges)
+	}
+
+	// a clean EOF is not an error
+	if _, err := BuildModel(strings.NewReader(prefix), ModelOptions{}); err != nil {
+		t.Errorf("BuildModel on clean EOF returned error: %v", err)
+	}
+}
+
+func TestBuildModelFromSources(t *testing.T) {
+	// host A only observed the client side, host B only the server side
+	hostA := `10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat
+`
+	hostB := `10.42.0.55:5672->10.42.0.54:5671|PID=722977 CMD=tcp-echo
+10.42.0.77:40000->10.42.0.54:5671|PID=722977 CMD=tcp-echo
+`
+	model, err := BuildModelFromSources([]io.Reader{strings.NewReader(hostA), strings.NewReader(hostB)}, ModelOptions{})
+	if err != nil {
+		t.Fatalf("BuildModelFromSources returned unexpected error: %v", err)
+	}
+
+	wantEdges := []Edge{
+		{Source: ProcessEndpoint{PID: 723736, Port: 5672}, Dest: ProcessEndpoint{PID: 722977, Port: 5671}},
+	}
+	if edges := model.SortedEdges(); !slices.Equal(edges, wantEdges) {
+		t.Errorf("SortedEdges = %+v, want %+v", edges, wantEdges)
+	}
+	if got := model.Nodes[723736].Sources; !slices.Equal(got, []int{0}) {
+		t.Errorf("client Sources = %v, want [0]", got)
+	}
+	if got := model.Nodes[722977].Sources; !slices.Equal(got, []int{1}) {
+		t.Errorf("server Sources = %v, want [1]", got)
+	}
+
+	// the same process observed by both hosts is a single node tagged with both sources
+	model, err = BuildModelFromSources([]io.Reader{strings.NewReader(hostB), strings.NewReader(hostB)}, ModelOptions{})
+	if err != nil {
+		t.Fatalf("BuildModelFromSources returned unexpected error: %v", err)
+	}
+	if len(model.Nodes) != 1 {
+		t.Errorf("got %d nodes, want 1", len(model.Nodes))
+	}
+	if got := model.Nodes[722977].Sources; !slices.Equal(got, []int{0, 1}) {
+		t.Errorf("Sources = %v, want [0 1]", got)
+	}
+}
+
+func TestListeningPorts(t *testing.T) {
+	// one server port, 50 clients; the server also connects to a database
+	var trace strings.Builder
+	for i := range 50 {
+		fmt.Fprintf(&trace, "10.42.0.%d:40000->10.42.0.1:8080|PID=100 CMD=server\n", 10+i)
+		fmt.Fprintf(&trace, "10.42.0.1:8080<-10.42.0.%d:40000|PID=%d CMD=client\n", 10+i, 200+i)
+	}
+	trace.WriteString("10.42.0.2:5432<-10.42.0.1:45000|PID=100 CMD=server\n")
+	model := mustBuildModel(t, trace.String(), ModelOptions{})
+
+	server := model.Nodes[100]
+	if got := model.ListeningPorts(server); !slices.Equal(got, []int{8080}) {
+		t.Errorf("server ListeningPorts = %v, want [8080]", got)
+	}
+	if got := model.ListeningPorts(model.Nodes[200]); len(got) != 0 {
+		t.Errorf("client ListeningPorts = %v, want none", got)
+	}
+	nodes, _ := model.Export()
+	if nodes[0].PID != 100 || !slices.Equal(nodes[0].Ports, []int{8080}) || nodes[0].EphemeralPorts != 1 {
+		t.Errorf("exported server = %+v, want ports [8080] and 1 ephemeral port", nodes[0])
+	}
+
+	// without any accept, an endpoint connected to several distinct peers is still a listener
+	model = mustBuildModel(t, `10.42.0.10:40000<-10.42.0.1:8080|PID=100 CMD=server
+10.42.0.11:40001<-10.42.0.1:8080|PID=100 CMD=server
+10.42.0.12:40002<-10.42.0.1:9090|PID=100 CMD=server
+10.42.0.12:40002<-10.42.0.1:9090|PID=100 CMD=server
+`, ModelOptions{})
+	if got := model.ListeningPorts(model.Nodes[100]); !slices.Equal(got, []int{8080}) {
+		t.Errorf("ListeningPorts = %v, want [8080]", got)
+	}
+}
+
+func TestListeningPortsSocketState(t *testing.T) {
+	// with the socket state, connecting to several peers does not make a listener,
+	// while a LISTEN socket does, even without any connection
+	model := mustBuildModel(t, `0.0.0.0:0<-10.42.0.1:9090|PID=100 STATE=LISTEN CMD=server
+10.42.0.10:40000<-10.42.0.1:8080|PID=100 STATE=ESTABLISHED CMD=server
+10.42.0.11:40001<-10.42.0.1:8080|PID=100 STATE=ESTABLISHED CMD=server
+10.42.0.12:40002->10.42.0.1:7070|PID=100 STATE=ESTABLISHED CMD=server
+`, ModelOptions{})
+	if got := model.ListeningPorts(model.Nodes[100]); !slices.Equal(got, []int{7070, 9090}) {
+		t.Errorf("ListeningPorts = %v, want [7070 9090]", got)
+	}
+	if got := model.IdleListening(); len(got) != 1 {
+		t.Errorf("IdleListening = %v, want only 100:9090", got)
+	} else if _, ok := got[ProcessEndpoint{PID: 100, Port: 9090}]; !ok {
+		t.Errorf("IdleListening = %v, want only 100:9090", got)
+	}
+	if n := model.NumDangling(); n != 3 {
+		t.Errorf("got %d dangling connections, want 3: LISTEN sockets have no peer", n)
+	}
+
+	// a wildcard LISTEN socket takes the IP of the process, once known
+	model = mustBuildModel(t, `0.0.0.0:0<-0.0.0.0:9090|PID=100 STATE=LISTEN CMD=server
+10.42.0.10:40000<-10.42.0.1:8080|PID=100 CMD=server
+0.0.0.0:0<-0.0.0.0:9090|PID=100 STATE=LISTEN CMD=server
+`, ModelOptions{})
+	if got := model.ListeningPorts(model.Nodes[100]); !slices.Equal(got, []int{9090}) || model.Nodes[100].LocalIP != "10.42.0.1" {
+		t.Errorf("ListeningPorts = %v, IP = %s; want [9090] on 10.42.0.1", got, model.Nodes[100].LocalIP)
+	}
+	if model.Stats.LinesFiltered != 1 {
+		t.Errorf("LinesFiltered = %d, want 1", model.Stats.LinesFiltered)
+	}
+}
+
+func TestBuildModelDangling(t *testing.T) {
+	// the second connection of PID 723736 never appears from the other side
+	model := mustBuildModel(t, sampleTrace+`10.42.0.60:443<-10.42.0.55:5680|PID=723736 CMD=ncat
+10.42.0.61:40000->10.42.0.58:5673|PID=723429 CMD=ncat
+`, ModelOptions{})
+	want := []DanglingConnection{
+		{Local: ProcessEndpoint{PID: 723736, Port: 5680}, Remote: NetworkEndpoint{IP: "10.42.0.60", Port: 443}, Dir: Local2Remote},
+		{Local: ProcessEndpoint{PID: 723429, Port: 5673}, Remote: NetworkEndpoint{IP: "10.42.0.61", Port: 40000}, Dir: Remote2Local},
+	}
+	if got := model.SortedDangling(); !slices.Equal(got, want) {
+		t.Errorf("SortedDangling = %+v, want %+v", got, want)
+	}
+	if got := model.NumDangling(); got != 2 {
+		t.Errorf("NumDangling = %d, want 2", got)
+	}
+
+	// in the sample trace each client line precedes the server one: the dangling
+	// connections recorded meanwhile must be cleared
+	if got := mustBuildModel(t, sampleTrace, ModelOptions{}).SortedDangling(); len(got) != 0 {
+		t.Errorf("SortedDangling = %+v, want none", got)
+	}
+}
+
+func TestBuildModelSample(t *testing.T) {
+	var trace strings.Builder
+	for i := range 1000 {
+		fmt.Fprintf(&trace, "10.42.1.1:443<-10.42.0.1:%d|PID=%d CMD=client\n", 10000+i, 100+i)
+	}
+	build := func(sample float64, seed uint64) *Model {
+		return mustBuildModel(t, trace.String(), ModelOptions{Sample: sample, Seed: seed})
+	}
+
+	model := build(0.5, 42)
+	if n := model.Stats.LinesSampledOut; n < 400 || n > 600 {
+		t.Errorf("LinesSampledOut = %d, want about half of the 1000 lines", n)
+	}
+	if len(model.Nodes) != 1000-model.Stats.LinesSampledOut {
+		t.Errorf("got %d nodes, want one per kept line", len(model.Nodes))
+	}
+
+	// the same seed selects the same lines
+	again := build(0.5, 42)
+	if !slices.Equal(modelPIDs(model), modelPIDs(again)) {
+		t.Errorf("two runs with the same seed selected different lines")
+	}
+	if other := build(0.5, 43); slices.Equal(modelPIDs(model), modelPIDs(other)) {
+		t.Errorf("two runs with different seeds selected the same lines")
+	}
+
+	if model := build(1, 42); model.Stats.LinesSampledOut != 0 || len(model.Nodes) != 1000 {
+		t.Errorf("Sample=1 dropped %d lines", model.Stats.LinesSampledOut)
+	}
+}
+
+func TestBuildModelDedupWindow(t *testing.T) {
+	// once the first line made tcp-echo known, the same connection is observed 5 times:
+	// in a burst of 3 observations 1s apart (counting as 1), then 1 minute later, then
+	// once more on a line without timestamp
+	trace := `10.42.0.54:5671<-10.42.0.55:5672|PID=723736 TS=2024-05-01T10:00:00Z CMD=ncat
+10.42.0.55:5672->10.42.0.54:5671|PID=722977 TS=2024-05-01T10:00:01Z CMD=tcp-echo
+10.42.0.54:5671<-10.42.0.55:5672|PID=723736 TS=2024-05-01T10:00:02Z CMD=ncat
+10.42.0.55:5672->10.42.0.54:5671|PID=722977 TS=2024-05-01T10:00:03Z CMD=tcp-echo
+10.42.0.54:5671<-10.42.0.55:5672|PID=723736 TS=2024-05-01T10:01:03Z CMD=ncat
+10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat
+`
+	count := func(model *Model) int {
+		if len(model.Edges) != 1 {
+			t.Fatalf("got %d edges, want 1", len(model.Edges))
+		}
+		for _, info := range model.Edges {
+			return info.Count
+		}
+		return 0
+	}
+
+	if got := count(mustBuildModel(t, trace, ModelOptions{})); got != 5 {
+		t.Errorf("Count = %d without -dedup-window, want 5", got)
+	}
+	model := mustBuildModel(t, trace, ModelOptions{DedupWindow: 5 * time.Second})
+	if got := count(model); got != 3 {
+		t.Errorf("Count = %d with a 5s window, want 3", got)
+	}
+	if model.Stats.LinesDeduplicated != 2 {
+		t.Errorf("LinesDeduplicated = %d, want 2", model.Stats.LinesDeduplicated)
+	}
+	if got := count(mustBuildModel(t, trace, ModelOptions{DedupWindow: time.Hour})); got != 2 {
+		t.Errorf("Count = %d with a 1h window, want 2", got)
+	}
+
+	// without timestamps, every line is counted
+	noTS := regexp.MustCompile(` TS=\S+`).ReplaceAllString(trace, "")
+	if got := count(mustBuildModel(t, noTS, ModelOptions{DedupWindow: time.Hour})); got != 5 {
+		t.Errorf("Count = %d without timestamps, want 5", got)
+	}
+}
+
+func TestBuildModelTrafficCounters(t *testing.T) {
+	// once the first line made tcp-echo known, the same connection is observed 4 times,
+	// from both ends, with or without counters
+	trace := `10.42.0.54:5671<-10.42.0.55:5672|PID=723736 BYTES=100 PKTS=1 CMD=ncat
+10.42.0.55:5672->10.42.0.54:5671|PID=722977 BYTES=1000 PKTS=10 CMD=tcp-echo
+10.42.0.54:5671<-10.42.0.55:5672|PID=723736 BYTES=500 PKTS=5 CMD=ncat
+10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat
+10.42.0.55:5672->10.42.0.54:5671|PID=722977 BYTES=24 PKTS=2 CMD=tcp-echo
+`
+	model := mustBuildModel(t, trace, ModelOptions{})
+	if len(model.Edges) != 1 {
+		t.Fatalf("got %d edges, want 1", len(model.Edges))
+	}
+	for _, info := range model.Edges {
+		if info.Count != 4 || info.Bytes != 1524 || info.Packets != 17 {
+			t.Errorf("got %+v, want 4 observations of 1524 bytes in 17 packets", info)
+		}
+	}
+
+	// the sums saturate rather than wrap around
+	huge := strings.ReplaceAll(trace, "BYTES=1000 ", "BYTES=9223372036854775807 ")
+	for _, info := range mustBuildModel(t, huge, ModelOptions{}).Edges {
+		if info.Bytes != math.MaxInt64 {
+			t.Errorf("Bytes = %d, want the saturated math.MaxInt64", info.Bytes)
+		}
+	}
+}
+
+func TestBuildModelIPv4MappedIPv6(t *testing.T) {
+	// the client reports both endpoints in their IPv4-mapped IPv6 form, the server in
+	// their IPv4 form
+	trace := `::ffff:10.42.0.54:5671<-::ffff:10.42.0.55:5672|PID=723736 CMD=ncat
+10.42.0.55:5672->10.42.0.54:5671|PID=722977 CMD=tcp-echo
+::ffff:10.42.0.54:5671<-::ffff:10.42.0.55:5672|PID=723736 CMD=ncat
+`
+	model := mustBuildModel(t, trace, ModelOptions{})
+	if len(model.Nodes) != 2 {
+		t.Fatalf("got %d nodes, want 2: %v", len(model.Nodes), model.Nodes)
+	}
+	if ip := model.Nodes[723736].LocalIP; ip != "10.42.0.55" {
+		t.Errorf("LocalIP = %s, want the IPv4 form 10.42.0.55", ip)
+	}
+	want := Edge{Source: ProcessEndpoint{PID: 723736, Port: 5672}, Dest: ProcessEndpoint{PID: 722977, Port: 5671}}
+	info, ok := model.Edges[want]
+	if len(model.Edges) != 1 || !ok {
+		t.Fatalf("got edges %v, want the single edge %v", model.Edges, want)
+	}
+	if info.Count != 2 {
+		t.Errorf("Count = %d, want 2", info.Count)
+	}
+}
+
+func TestBuildModelPIDNamespaces(t *testing.T) {
+	// PID 1 of two different containers: a client, then the server it connects to
+	trace := `10.42.1.8:80<-10.42.0.9:40000|PID=1 CMD=curl
+10.42.0.9:40000->10.42.1.8:80|PID=1 CMD=nginx
+10.42.1.8:80<-10.42.0.9:40000|PID=1 CMD=curl
+10.42.0.9:40000->10.42.1.8:80|PID=1 CMD=nginx
+`
+	model := mustBuildModel(t, trace, ModelOptions{})
+	if len(model.Nodes) != 2 {
+		t.Fatalf("got %d nodes, want 2: %v", len(model.Nodes), model.Nodes)
+	}
+	nodes := model.SortedNodes()
+	client, server := nodes[0], nodes[1]
+	if client.ProcessName != "curl" || client.LocalIP != "10.42.0.9" || server.ProcessName != "nginx" || server.LocalIP != "10.42.1.8" {
+		t.Fatalf("got nodes %+v and %+v, want curl on 10.42.0.9 and then nginx on 10.42.1.8", client, server)
+	}
+	if client.PID() != 1 || server.PID() != 1 {
+		t.Errorf("got PIDs %d and %d, want 1 for both", client.PID(), server.PID())
+	}
+	want := Edge{Source: ProcessEndpoint{PID: client.ProcessID, Port: 40000}, Dest: ProcessEndpoint{PID: server.ProcessID, Port: 80}}
+	info, ok := model.Edges[want]
+	if len(model.Edges) != 1 || !ok {
+		t.Fatalf("got edges %v, want the single edge %v", model.Edges, want)
+	}
+	if info.Count != 3 {
+		t.Errorf("Count = %d, want 3", info.Count)
+	}
+	if got := model.FindProcesses("1"); len(got) != 2 {
+		t.Errorf("FindProcesses(1) = %v, want both processes", got)
+	}
+
+	// on the same IP, the containers tell the processes apart
+	trace = `10.42.1.8:80<-10.42.0.9:40000|PID=1 CONTAINER=c1 CMD=curl
+10.42.1.8:80<-10.42.0.9:40001|PID=1 CONTAINER=c2 CMD=wget
+10.42.1.8:80<-10.42.0.9:40002|PID=1 CMD=curl
+`
+	model = mustBuildModel(t, trace, ModelOptions{})
+	if len(model.Nodes) != 2 {
+		t.Fatalf("got %d nodes, want 2: %v", len(model.Nodes), model.Nodes)
+	}
+	for _, n := range model.Nodes {
+		if n.PID() != 1 {
+			t.Errorf("node %+v has PID %d, want 1", n, n.PID())
+		}
+	}
+	if ports := model.Nodes[1].LocalPorts; len(ports) != 2 {
+		t.Errorf("the first process has ports %v, want 40000 and 40002", ports)
+	}
+}
+
+func TestBuildModelPIDReuse(t *testing.T) {
+	// PID 7 of the same IP runs a then b, e.g. reused or after an exec
+	model := mustBuildModel(t, `10.0.0.1:80<-10.0.0.2:5000|PID=7 CMD=a
+10.0.0.1:81<-10.0.0.2:5001|PID=7 CMD=b
+10.0.0.1:82<-10.0.0.2:5002|PID=7 CMD=a
+`, ModelOptions{})
+	if len(model.Nodes) != 2 {
+		t.Fatalf("got %d nodes, want 2: %v", len(model.Nodes), model.Nodes)
+	}
+	first, ok := model.Nodes[7]
+	if !ok || first.ProcessName != "a" || len(first.LocalPorts) != 2 {
+		t.Errorf("got the node %+v for PID 7, want a with ports 5000 and 5002", first)
+	}
+	for key, n := range model.Nodes {
+		if key != 7 && (n.ProcessName != "b" || n.PID() != 7) {
+			t.Errorf("got the node %+v, want b with PID 7", n)
+		}
+	}
+}
+
+func TestBuildModelSeenBy(t *testing.T) {
+	// the API server is observed from both ends, the database only reports its listening
+	// socket
+	trace := `10.0.0.2:8080<-10.0.0.1:40000|PID=1 CMD=cli
+10.0.0.1:40000->10.0.0.2:8080|PID=2 CMD=api
+0.0.0.0:0<-10.0.0.3:5432|PID=3 STATE=LISTEN CMD=db
+10.0.0.3:5432<-10.0.0.2:41000|PID=2 CMD=api
+`
+	model := mustBuildModel(t, trace, ModelOptions{})
+	for _, tc := range []struct {
+		edge             Edge
+		bySource, byDest bool
+	}{
+		{Edge{Source: ProcessEndpoint{PID: 1, Port: 40000}, Dest: ProcessEndpoint{PID: 2, Port: 8080}}, false, true},
+		{Edge{Source: ProcessEndpoint{PID: 2, Port: 41000}, Dest: ProcessEndpoint{PID: 3, Port: 5432}}, true, false},
+	} {
+		info, ok := model.Edges[tc.edge]
+		if !ok {
+			t.Errorf("edge %v missing from %v", tc.edge, model.Edges)
+			continue
+		}
+		if info.SeenBySource != tc.bySource || info.SeenByDest != tc.byDest {
+			t.Errorf("edge %v: SeenBySource = %v, SeenByDest = %v, want %v and %v", tc.edge, info.SeenBySource, info.SeenByDest, tc.bySource, tc.byDest)
+		}
+	}
+
+	// the client observes it again
+	model = mustBuildModel(t, trace+"10.0.0.2:8080<-10.0.0.1:40000|PID=1 CMD=cli\n", ModelOptions{})
+	if info := model.Edges[Edge{Source: ProcessEndpoint{PID: 1, Port: 40000}, Dest: ProcessEndpoint{PID: 2, Port: 8080}}]; !info.SeenBySource || !info.SeenByDest {
+		t.Errorf("got %+v, want the edge observed from both ends", info)
+	}
+}
+
+func TestBuildModelFirstLastSeen(t *testing.T) {
+	// once the first line made tcp-echo known, the connection is observed from both
+	// ends, out of order, and once without timestamp
+	trace := `10.42.0.54:5671<-10.42.0.55:5672|PID=723736 TS=2024-05-01T10:00:00Z CMD=ncat
+10.42.0.55:5672->10.42.0.54:5671|PID=722977 TS=2024-05-01T10:00:05Z CMD=tcp-echo
+10.42.0.54:5671<-10.42.0.55:5672|PID=723736 TS=2024-05-01T10:00:02Z CMD=ncat
+10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat
+10.42.0.55:5672->10.42.0.54:5671|PID=722977 TS=2024-05-01T10:03:00Z CMD=tcp-echo
+10.42.0.54:5671<-10.42.0.55:5672|PID=723736 TS=2024-05-01T10:01:00Z CMD=ncat
+`
+	model := mustBuildModel(t, trace, ModelOptions{})
+	if len(model.Edges) != 1 {
+		t.Fatalf("got %d edges, want 1", len(model.Edges))
+	}
+	for _, info := range model.Edges {
+		first, last := time.Date(2024, 5, 1, 10, 0, 2, 0, time.UTC), time.Date(2024, 5, 1, 10, 3, 0, 0, time.UTC)
+		if !info.FirstSeen.Equal(first) || !info.LastSeen.Equal(last) {
+			t.Errorf("got the window %s - %s, want %s - %s", info.FirstSeen, info.LastSeen, first, last)
+		}
+		_, edges := model.Export()
+		if edges[0].FirstSeen != "2024-05-01T10:00:02Z" || edges[0].LastSeen != "2024-05-01T10:03:00Z" {
+			t.Errorf("exported %+v, want the RFC 3339 window", edges[0])
+		}
+	}
+
+	// without timestamps, the window is unknown
+	for _, info := range mustBuildModel(t, regexp.MustCompile(` TS=\S+`).ReplaceAllString(trace, ""), ModelOptions{}).Edges {
+		if !info.FirstSeen.IsZero() || !info.LastSeen.IsZero() {
+			t.Errorf("got the window %s - %s, want none", info.FirstSeen, info.LastSeen)
+		}
+	}
+}
+
+func TestBuildModelStrict(t *testing.T) {
+	// banners, blank lines and filtered-out lines are no parse failures
+	trace := "Attaching 5 probes...\n\n" +
+		"127.0.0.1:5671<-127.0.0.1:5672|PID=1 CMD=loopback\n" +
+		sampleTrace[strings.Index(sampleTrace, "\n")+1:]
+	for _, parallel := range []int{0, 4} {
+		model, err := BuildModel(strings.NewReader(trace), ModelOptions{Strict: true, Parallel: parallel})
+		if err != nil {
+			t.Fatalf("BuildModel with -parallel %d returned unexpected error: %v", parallel, err)
+		}
+		if want := mustBuildModel(t, sampleTrace, ModelOptions{}).Stats.LinesFiltered + 1; model.Stats.LinesFiltered != want {
+			t.Errorf("LinesFiltered = %d, want %d", model.Stats.LinesFiltered, want)
+		}
+
+		bad := trace + "10.42.0.54:5671<-10.42.0.55|PID=723736 CMD=ncat\n" + sampleTrace
+		lineNum := strings.Count(trace, "\n") + 1
+		model, err = BuildModel(strings.NewReader(bad), ModelOptions{Strict: true, Parallel: parallel})
+		var strictErr *StrictError
+		if !errors.As(err, &strictErr) {
+			t.Fatalf("BuildModel with -parallel %d returned %v, want a StrictError", parallel, err)
+		}
+		if strictErr.LineNum != lineNum || strictErr.Line != "10.42.0.54:5671<-10.42.0.55|PID=723736 CMD=ncat" {
+			t.Errorf("got %+v, want line %d", strictErr, lineNum)
+		}
+		// the lines after the bad one are not read
+		if model.Stats.LinesRead != lineNum {
+			t.Errorf("LinesRead = %d, want %d", model.Stats.LinesRead, lineNum)
+		}
+	}
+}
+
+func TestBuildModelOnParseError(t *testing.T) {
+	first := "Attaching 5 probes...\n\ngarbage\n" + sampleTrace + "10.42.0.54:99999<-10.42.0.55:5672|PID=1 CMD=ncat\n"
+	second := "10.42.0.54:5671<-10.42.0.55|PID=723736 CMD=ncat\n"
+	type failure struct {
+		source, lineNum int
+		reason          ParseErrorReason
+	}
+	want := []failure{
+		{0, 3, ReasonNoMatch},
+		{0, strings.Count(first, "\n"), ReasonOutOfRange},
+		{1, 1, ReasonNoMatch},
+	}
+	for _, parallel := range []int{0, 4} {
+		var got []failure
+		opts := ModelOptions{Parallel: parallel, OnParseError: func(e *StrictError) {
+			var parseErr *ParseError
+			if !errors.As(e, &parseErr) {
+				t.Errorf("line %d failed with %v, want a ParseError", e.LineNum, e.Err)
+			}
+			got = append(got, failure{e.Source, e.LineNum, parseErr.Reason})
+		}}
+		model, err := BuildModelFromSources([]io.Reader{strings.NewReader(first), strings.NewReader(second)}, opts)
+		if err != nil {
+			t.Fatalf("BuildModelFromSources with -parallel %d returned unexpected error: %v", parallel, err)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("with -parallel %d, got the failures %+v, want %+v", parallel, got, want)
+		}
+		// the invalid lines are still skipped as usual
+		if len(model.Edges) != len(mustBuildModel(t, sampleTrace, ModelOptions{}).Edges) {
+			t.Errorf("got %d edges, want the ones of sampleTrace", len(model.Edges))
+		}
+	}
+}
+
+// syntheticTrace generates a representative trace of n lines: servers listening on a
+// handful of ports, each called by many clients through ephemeral ports, mostly observed
+// from both sides, with the occasional IPv6, timestamped, invalid and loopback line
+func syntheticTrace(n int) string {
+	rng := rand.New(rand.NewPCG(3, 4))
+	ports := []int{80, 443, 5432, 6379, 8080}
+	var trace strings.Builder
+	for i := 0; i < n; {
+		server := rng.IntN(100)
+		client := rng.IntN(1000)
+		port := ports[server%len(ports)]
+		ephemeral := 32768 + rng.IntN(28000)
+		serverIP := fmt.Sprintf("10.42.%d.%d", server/250, server%250)
+		clientIP := fmt.Sprintf("10.43.%d.%d", client/250, client%250)
+		switch rng.IntN(20) {
+		case 0:
+			serverIP = fmt.Sprintf("fd00::%x", server)
+			clientIP = fmt.Sprintf("fd01::%x", client)
+		case 1:
+			trace.WriteString("garbage\n")
+			i++
+			continue
+		case 2:
+			trace.WriteString("127.0.0.1:8080<-127.0.0.1:52114|PID=19190 CMD=coredns\n")
+			i++
+			continue
+		}
+		ts := float64(1714557600 + i)
+		fmt.Fprintf(&trace, "%s:%d<-%s:%d|PID=%d TS=%g CMD=client-%d\n", serverIP, port, clientIP, ephemeral, 10000+client, ts, client)
+		i++
+		if i < n && rng.IntN(10) > 0 {
+			fmt.Fprintf(&trace, "%s:%d->%s:%d|PID=%d TS=%g CMD=server-%d\n", clientIP, ephemeral, serverIP, port, 100+server, ts, server)
+			i++
+		}
+	}
+	return trace.String()
+}
+
+func TestSyntheticTrace(t *testing.T) {
+	trace := syntheticTrace(1000)
+	if lines := strings.Count(trace, "\n"); lines != 1000 {
+		t.Errorf("syntheticTrace(1000) generated %d lines", lines)
+	}
+	model := mustBuildModel(t, trace, ModelOptions{})
+	if model.Stats.LinesInvalid == 0 || len(model.Edges) == 0 {
+		t.Errorf("syntheticTrace must mix valid and invalid lines, got %+v", model.Stats)
+	}
+}
+
+func BenchmarkBuildModel(b *testing.B) {
+	for _, lines := range []int{1000, 10000, 100000} {
+		trace := syntheticTrace(lines)
+		b.Run(fmt.Sprintf("lines=%d", lines), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(trace)))
+			for range b.N {
+				if _, err := BuildModel(strings.NewReader(trace), ModelOptions{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
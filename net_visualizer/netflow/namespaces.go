@@ -0,0 +1,51 @@
+package netflow
+
+import "maps"
+
+// Namespace returns the Kubernetes namespace of the process, or "" if its pod is unknown
+func (n *ProcessEndpoints) Namespace() string {
+	if n.Pod == nil {
+		return ""
+	}
+	return n.Pod.Namespace
+}
+
+// SplitByNamespace partitions the model after the namespaces of the pods, as resolved by
+// ResolvePods: each namespace gets the model of its processes and of the edges between
+// them, the processes whose pod is unknown being grouped under "". The edges crossing
+// namespaces are returned separately, in a model that only holds them and their ends.
+func (m *Model) SplitByNamespace() (map[string]*Model, *Model) {
+	members := make(map[string]map[int64]struct{})
+	for pid, n := range m.Nodes {
+		ns := n.Namespace()
+		if members[ns] == nil {
+			members[ns] = make(map[int64]struct{})
+		}
+		members[ns][pid] = struct{}{}
+	}
+	ret := make(map[string]*Model, len(members))
+	for ns, pids := range members {
+		ret[ns] = m.subModel(pids)
+	}
+
+	cross := newModel()
+	cross.Stats = m.Stats
+	cross.Latest = m.Latest
+	cross.hidden = maps.Clone(m.hidden)
+	for e, info := range m.Edges {
+		src, dst := m.Nodes[e.Source.PID], m.Nodes[e.Dest.PID]
+		if src.Namespace() == dst.Namespace() {
+			cross.hide(e)
+			continue
+		}
+		cross.Edges[e] = info
+		cross.Nodes[src.ProcessID] = src
+		cross.Nodes[dst.ProcessID] = dst
+	}
+	for ep := range m.Listening {
+		if _, ok := cross.Nodes[ep.PID]; ok {
+			cross.Listening[ep] = struct{}{}
+		}
+	}
+	return ret, cross
+}
@@ -0,0 +1,49 @@
+package netflow
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestModelSplitByNamespace(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, ModelOptions{})
+	model.ResolvePods(StaticPodResolver{
+		"10.42.0.54": {Namespace: "shop", Name: "echo"},
+		"10.42.0.55": {Namespace: "shop", Name: "client-1"},
+		"10.42.0.53": {Namespace: "batch", Name: "client-2"},
+		"10.42.0.58": {Namespace: "batch", Name: "server"},
+	})
+	namespaces, cross := model.SplitByNamespace()
+
+	if got := slices.Sorted(maps.Keys(namespaces)); !slices.Equal(got, []string{"", "batch", "shop"}) {
+		t.Fatalf("namespaces = %q, want the unknown one, batch and shop", got)
+	}
+	want := map[string][]int64{
+		"":      {723715, 723801},
+		"batch": {723429, 723753},
+		"shop":  {722977, 723736},
+	}
+	for ns, pids := range want {
+		var got []int64
+		for _, n := range namespaces[ns].SortedNodes() {
+			got = append(got, n.ProcessID)
+		}
+		if !slices.Equal(got, pids) {
+			t.Errorf("namespace %q PIDs = %v, want %v", ns, got, pids)
+		}
+	}
+	if len(namespaces["shop"].Edges) != 1 || len(namespaces["batch"].Edges) != 0 {
+		t.Errorf("intra-namespace edges not split correctly")
+	}
+
+	// batch/client-2 -> shop/echo, and the two unknown clients -> batch/server
+	if len(cross.Edges) != 3 || len(cross.Nodes) != 5 {
+		t.Errorf("cross-namespace model has %d edges and %d nodes, want 3 and 5", len(cross.Edges), len(cross.Nodes))
+	}
+	for e := range cross.Edges {
+		if cross.Nodes[e.Source.PID].Namespace() == cross.Nodes[e.Dest.PID].Namespace() {
+			t.Errorf("intra-namespace edge %+v in the cross-namespace model", e)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package netflow
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// maxBatchLines bounds the number of lines handed over to a parsing worker at once
+const maxBatchLines = 1024
+
+// parseBatch is a run of consecutive input lines, parsed by one of the workers of
+// consumeParallel
+type parseBatch struct {
+	seq    int // position of the batch in the input
+	lines  []string
+	parsed []InputLine
+	errs   []error
+}
+
+func (p *parseBatch) parse(parser *LineParser) {
+	p.parsed = make([]InputLine, len(p.lines))
+	p.errs = make([]error, len(p.lines))
+	for i, line := range p.lines {
+		p.parsed[i], p.errs[i] = parser.Parse(line)
+	}
+}
+
+// consumeParallel is the ModelOptions.Parallel variant of Consume: one goroutine reads
+// the lines, that are parsed in batches by the workers, and the parsed lines are then
+// correlated in input order, as Consume would. Batches are cut as soon as no more
+// lines are readily available, so that a live input is not delayed.
+// When the input is abandoned midway, on a StrictError, the batching goroutine and the
+// workers are stopped before returning; the reader, that may be blocked reading a live
+// input, stops at its next line.
+func (b *Builder) consumeParallel(r io.Reader, source int) error {
+	done := make(chan struct{})
+	lines := make(chan string, maxBatchLines)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	var stages sync.WaitGroup
+	batches := make(chan *parseBatch, b.opts.Parallel)
+	stages.Add(1)
+	go func() {
+		defer stages.Done()
+		defer close(batches)
+		for seq := 0; ; seq++ {
+			var line string
+			var ok bool
+			select {
+			case line, ok = <-lines:
+			case <-done:
+				return
+			}
+			if !ok {
+				return
+			}
+			batch := &parseBatch{seq: seq, lines: []string{line}}
+		fill:
+			for len(batch.lines) < maxBatchLines {
+				select {
+				case line, ok := <-lines:
+					if !ok {
+						break fill
+					}
+					batch.lines = append(batch.lines, line)
+				default:
+					break fill
+				}
+			}
+			select {
+			case batches <- batch:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	parsed := make(chan *parseBatch, b.opts.Parallel)
+	var workers sync.WaitGroup
+	for range b.opts.Parallel {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for batch := range batches {
+				batch.parse(b.opts.Parser)
+				select {
+				case parsed <- batch:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	stages.Add(1)
+	go func() {
+		defer stages.Done()
+		workers.Wait()
+		close(parsed)
+	}()
+	// stop waits for the goroutines blocked on the channels when the input is abandoned
+	stop := func() {
+		close(done)
+		stages.Wait()
+	}
+
+	// the workers complete the batches out of order: hold them until their turn
+	pending := make(map[int]*parseBatch)
+	next, linesRead := 0, 0
+	for batch := range parsed {
+		pending[batch.seq] = batch
+		for batch := pending[next]; batch != nil; batch = pending[next] {
+			delete(pending, next)
+			next++
+			b.mu.Lock()
+			for i := range batch.lines {
+				b.addParsedLine(batch.lines[i], batch.parsed[i], batch.errs[i], source)
+				if err := b.strictError(batch.lines[i], linesRead+i+1, source, batch.errs[i]); err != nil {
+					b.mu.Unlock()
+					stop()
+					return err
+				}
+			}
+			b.mu.Unlock()
+			linesRead += len(batch.lines)
+		}
+	}
+	if err := <-readErr; err != nil {
+		return fmt.Errorf("error reading input after %d lines: %w", linesRead, err)
+	}
+	return nil
+}
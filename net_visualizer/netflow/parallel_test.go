@@ -0,0 +1,139 @@
+package netflow
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// randomTrace generates a trace of n connections between random processes, with both
+// sides of most of them, some invalid and some loopback lines
+func randomTrace(n int) string {
+	rng := rand.New(rand.NewPCG(1, 2))
+	var trace strings.Builder
+	for i := range n {
+		server := rng.IntN(50)
+		client := 50 + rng.IntN(200)
+		port := 40000 + i%20000
+		fmt.Fprintf(&trace, "10.42.0.%d:8080<-10.42.1.%d:%d|PID=%d CMD=client-%d\n", server, client, port, 1000+client, client)
+		if rng.IntN(10) > 0 {
+			fmt.Fprintf(&trace, "10.42.1.%d:%d->10.42.0.%d:8080|PID=%d CMD=server-%d\n", client, port, server, 100+server, server)
+		}
+		switch rng.IntN(50) {
+		case 0:
+			trace.WriteString("garbage\n")
+		case 1:
+			trace.WriteString("127.0.0.1:8080<-127.0.0.1:52114|PID=19190 CMD=coredns\n")
+		}
+	}
+	return trace.String()
+}
+
+func TestConsumeParallelMatchesSerial(t *testing.T) {
+	trace := randomTrace(20000)
+	for _, opts := range []ModelOptions{
+		{},
+		{MaxTrackedNodes: 100, MaxEdges: 5000},
+		{Sample: 0.5, Seed: 42},
+	} {
+		serial := mustBuildModel(t, trace, opts)
+		opts.Parallel = 4
+		parallel := mustBuildModel(t, trace, opts)
+		if !reflect.DeepEqual(serial, parallel) {
+			t.Errorf("with %+v the model differs from the serial one: %d nodes, %d edges, %+v; want %d nodes, %d edges, %+v",
+				opts, len(parallel.Nodes), len(parallel.Edges), parallel.Stats, len(serial.Nodes), len(serial.Edges), serial.Stats)
+		}
+	}
+}
+
+func TestConsumeParallelReadError(t *testing.T) {
+	readErr := errors.New("broken pipe")
+	r := io.MultiReader(strings.NewReader(randomTrace(5000)), iotest.ErrReader(readErr))
+	model, err := BuildModel(r, ModelOptions{Parallel: 4})
+	if !errors.Is(err, readErr) {
+		t.Fatalf("BuildModel error = %v, want %v", err, readErr)
+	}
+	if want := mustBuildModel(t, randomTrace(5000), ModelOptions{}); !reflect.DeepEqual(model, want) {
+		t.Errorf("the lines read before the error must all be kept")
+	}
+}
+
+func TestConsumeParallelStrictStops(t *testing.T) {
+	before := runtime.NumGoroutine()
+	// the invalid line comes early, followed by enough lines to fill all the channels
+	trace := "garbage\n" + randomTrace(100000)
+	if _, err := BuildModel(strings.NewReader(trace), ModelOptions{Parallel: 4, Strict: true}); !errors.As(err, new(*StrictError)) {
+		t.Fatalf("BuildModel error = %v, want a StrictError", err)
+	}
+	// the reader stops at its next line, shortly after the others
+	for deadline := time.Now().Add(5 * time.Second); runtime.NumGoroutine() > before; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left running after the StrictError, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func BenchmarkConsume(b *testing.B) {
+	trace := randomTrace(100000)
+	for _, parallel := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("parallel=%d", parallel), func(b *testing.B) {
+			b.SetBytes(int64(len(trace)))
+			for range b.N {
+				if _, err := BuildModel(strings.NewReader(trace), ModelOptions{Parallel: parallel}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestConsumeAllConcurrentSources(t *testing.T) {
+	// two live feeds, each one only ending once the other one was read: reading them one
+	// after the other would never end
+	clientR, clientW := io.Pipe()
+	serverR, serverW := io.Pipe()
+	go func() {
+		io.WriteString(clientW, "10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat\n")
+		io.WriteString(serverW, "10.42.0.55:5672->10.42.0.54:5671|PID=722977 CMD=tcp-echo\n")
+		serverW.Close()
+		io.WriteString(clientW, "10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat\n")
+		clientW.Close()
+	}()
+
+	result := make(chan *Model, 1)
+	go func() {
+		model, err := BuildModelFromSources([]io.Reader{clientR, serverR}, ModelOptions{ConcurrentSources: true})
+		if err != nil {
+			t.Errorf("BuildModelFromSources returned unexpected error: %v", err)
+		}
+		result <- model
+	}()
+	var model *Model
+	select {
+	case model = <-result:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the sources were not read concurrently")
+	}
+	want := Edge{Source: ProcessEndpoint{PID: 723736, Port: 5672}, Dest: ProcessEndpoint{PID: 722977, Port: 5671}}
+	if len(model.Nodes) != 2 || len(model.Edges) != 1 || model.Edges[want] == nil {
+		t.Fatalf("got nodes %v and edges %v, want the single edge %v", model.Nodes, model.Edges, want)
+	}
+	if sources := model.Nodes[722977].Sources; !reflect.DeepEqual(sources, []int{1}) {
+		t.Errorf("the server was observed in the sources %v, want the second one", sources)
+	}
+
+	// the first read error is returned, once all the sources are exhausted
+	readErr := errors.New("connection reset")
+	_, err := BuildModelFromSources([]io.Reader{strings.NewReader(sampleTrace), iotest.ErrReader(readErr)}, ModelOptions{ConcurrentSources: true})
+	if !errors.Is(err, readErr) {
+		t.Errorf("got error %v, want %v", err, readErr)
+	}
+}
@@ -0,0 +1,383 @@
+package netflow
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Direction int
+
+const (
+	Remote2Local Direction = iota
+	Local2Remote
+)
+
+// SocketState is the state of the local socket, reported by some tracer builds
+type SocketState int
+
+const (
+	StateUnknown     SocketState = iota // no state reported: the roles are inferred heuristically
+	StateListen                         // a listening socket: the local endpoint is a server
+	StateEstablished                    // a connected socket: the direction tells the roles apart
+)
+
+// parseState converts a STATE token to a SocketState
+func parseState(s string) (SocketState, bool) {
+	switch strings.ToUpper(s) {
+	case "LISTEN":
+		return StateListen, true
+	case "ESTABLISHED":
+		return StateEstablished, true
+	default:
+		return StateUnknown, false
+	}
+}
+
+// InputLine represents 1 line in the input of tcp_correlator, which is the output of tcp_tracer
+type InputLine struct {
+	Dir         Direction
+	RemoteIP    string // TODO: use net.IP instead
+	RemotePort  int
+	LocalIP     string // TODO: use net.IP instead
+	LocalPort   int
+	ProcessID   int64
+	ProcessName string
+	Timestamp   time.Time   // when the connection was observed; zero if the tracer does not report it
+	State       SocketState // StateUnknown if the tracer does not report it
+	ContainerID string      // container ID or cgroup path of the process; empty if the tracer does not report it
+	Bytes       int64       // traffic volume reported with the observation; 0 if the tracer does not report it
+	Packets     int64       // number of packets reported with the observation; 0 if the tracer does not report it
+	Source      int         // index of the input this line was read from, when merging several traces
+}
+
+// DestPort returns the port of the accepting side of the connection, or the listening
+// port of a listening socket
+func (l InputLine) DestPort() int {
+	if l.Dir == Remote2Local || l.State == StateListen {
+		return l.LocalPort
+	}
+	return l.RemotePort
+}
+
+// ParseErrorReason tells why a line could not be parsed
+type ParseErrorReason int
+
+const (
+	ReasonNoMatch      ParseErrorReason = iota // the line does not match the expected format
+	ReasonBadPort                              // a port is not a number
+	ReasonBadPID                               // the PID is not a number
+	ReasonOutOfRange                           // a port or the PID is a number out of its range
+	ReasonBadDirection                         // the DIR token, or the dir group of a -regex, is unknown
+	ReasonBadTimestamp                         // the TS token is neither RFC 3339 nor seconds
+	ReasonBadState                             // the STATE token is neither LISTEN nor ESTABLISHED
+	ReasonBadCounter                           // a BYTES or PKTS token is not a non-negative number
+)
+
+func (r ParseErrorReason) String() string {
+	switch r {
+	case ReasonNoMatch:
+		return "does not match the expected format"
+	case ReasonBadPort:
+		return "invalid port"
+	case ReasonBadPID:
+		return "invalid PID"
+	case ReasonOutOfRange:
+		return "port or PID out of range"
+	case ReasonBadDirection:
+		return "invalid direction"
+	case ReasonBadTimestamp:
+		return "invalid timestamp"
+	case ReasonBadState:
+		return "invalid socket state"
+	case ReasonBadCounter:
+		return "invalid traffic counter"
+	default:
+		return fmt.Sprintf("ParseErrorReason(%d)", int(r))
+	}
+}
+
+// ParseError is the error returned for the lines that cannot be parsed. errors.Is
+// matches it against a ParseError of the same Reason, e.g.
+// errors.Is(err, &ParseError{Reason: ReasonBadPort}), whatever the line
+type ParseError struct {
+	Line   string
+	Reason ParseErrorReason
+	Err    error // the underlying conversion error, if any
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("skipping invalid line (%s): %s", e.Reason, e.Line)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ParseError) Is(target error) bool {
+	t, ok := target.(*ParseError)
+	return ok && t.Reason == e.Reason
+}
+
+// parseError returns the ParseError of the line for the reason
+func parseError(line string, reason ParseErrorReason, err error) error {
+	return &ParseError{Line: line, Reason: reason, Err: err}
+}
+
+// numberError returns the ParseError of a field failing strconv with err: badReason for
+// a malformed number, ReasonOutOfRange for an overflow
+func numberError(line string, badReason ParseErrorReason, err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return parseError(line, ReasonOutOfRange, err)
+	}
+	return parseError(line, badReason, err)
+}
+
+// Regex to parse lines. Some tracer builds add an explicit DIR=in/DIR=out token, that
+// takes precedence over the arrow, or that replaces it altogether, and/or a TS token
+// with the time of the observation, a STATE token (LISTEN or ESTABLISHED), a CONTAINER
+// token with the container ID or cgroup path of the process and/or BYTES and PKTS tokens
+// with the traffic volume of the observation.
+var regexLocalToRemote = regexp.MustCompile(`(.+):(\d+)<-(.+):(\d+)\|PID=(\d+)(?: TS=(\S+))?(?: DIR=(\S+))?(?: STATE=(\S+))?(?: CONTAINER=(\S+))?(?: BYTES=(\d+))?(?: PKTS=(\d+))? CMD=(.+)`)
+var regexRemoteToLocal = regexp.MustCompile(`(.+):(\d+)->(.+):(\d+)\|PID=(\d+)(?: TS=(\S+))?(?: DIR=(\S+))?(?: STATE=(\S+))?(?: CONTAINER=(\S+))?(?: BYTES=(\d+))?(?: PKTS=(\d+))? CMD=(.+)`)
+var regexExplicitDir = regexp.MustCompile(`(.+):(\d+)\s+(.+):(\d+)\|PID=(\d+)(?: TS=(\S+))? DIR=(\S+)(?: STATE=(\S+))?(?: CONTAINER=(\S+))?(?: BYTES=(\d+))?(?: PKTS=(\d+))? CMD=(.+)`)
+
+// regexTracerBanner matches the informational lines printed by bpftrace and by the tracer
+// itself at startup/shutdown, which are expected in the input and are not failures
+var regexTracerBanner = regexp.MustCompile(`^(Attaching \d+ probes?\.\.\.|Listening for TCPv4 traffic\.\.\.|Exiting TCPv4 traffic monitor\.)$`)
+
+// IsBannerLine tells whether the line is blank or a tracer banner: such lines never
+// parse, but are no sign of a format drift
+func IsBannerLine(line string) bool {
+	return line == "" || regexTracerBanner.MatchString(line)
+}
+
+// ParseLine parses a line in the builtin ebpf_netflow_tracer format
+func ParseLine(line string) (InputLine, error) {
+	var dir Direction
+	var matches []string
+	hasArrow := true
+
+	if matches = regexLocalToRemote.FindStringSubmatch(line); len(matches) > 0 {
+		// Parsed reverse direction
+		dir = Local2Remote
+	} else if matches = regexRemoteToLocal.FindStringSubmatch(line); len(matches) > 0 {
+		// Parsed forward direction
+		dir = Remote2Local
+	} else if matches = regexExplicitDir.FindStringSubmatch(line); len(matches) > 0 {
+		hasArrow = false
+	} else {
+		return InputLine{}, parseError(line, ReasonNoMatch, nil)
+	}
+
+	if token := matches[7]; token != "" {
+		explicit, ok := parseDirection(token)
+		if !ok {
+			return InputLine{}, parseError(line, ReasonBadDirection, nil)
+		}
+		if hasArrow && explicit != dir {
+			warnRepeatedf("lines whose arrow contradicts their DIR token", "the arrow contradicts DIR=%s, trusting the latter: %s", token, line)
+		}
+		dir = explicit
+	}
+
+	return newInputLine(line, dir, matches[1], matches[2], matches[3], matches[4], matches[5], matches[12], matches[6], matches[8], matches[9], matches[10], matches[11])
+}
+
+// parseDirection converts an arrow ("->", "<-") or a DIR token ("in", "out") to a Direction
+func parseDirection(s string) (Direction, bool) {
+	switch s {
+	case "->", "in":
+		return Remote2Local, true
+	case "<-", "out":
+		return Local2Remote, true
+	default:
+		return 0, false
+	}
+}
+
+// parseTimestamp accepts RFC 3339 timestamps and (fractional) seconds, e.g. since the
+// epoch or since boot: only the differences between timestamps matter
+func parseTimestamp(s string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, true
+	}
+	secs, err := strconv.ParseFloat(s, 64)
+	if err != nil || secs < 0 || secs > 1e12 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(secs*1e9)).UTC(), true
+}
+
+// newInputLine converts the raw fields captured from a line into an InputLine; ts, state,
+// container, bytes and packets are empty when the line has no such field
+func newInputLine(line string, dir Direction, remoteIP, remotePort, localIP, localPort, pid, cmd, ts, state, container, bytes, packets string) (InputLine, error) {
+	var err error
+	ret := InputLine{Dir: dir}
+
+	ret.RemoteIP = normalizeIP(remoteIP)
+	if ret.RemotePort, err = parsePort(line, remotePort); err != nil {
+		return InputLine{}, err
+	}
+
+	ret.LocalIP = normalizeIP(localIP)
+	if ret.LocalPort, err = parsePort(line, localPort); err != nil {
+		return InputLine{}, err
+	}
+
+	ret.ProcessID, err = strconv.ParseInt(pid, 10, 64)
+	if err != nil {
+		return InputLine{}, numberError(line, ReasonBadPID, err)
+	}
+
+	ret.ProcessName = sanitizeProcessName(cmd)
+	ret.ContainerID = container
+
+	if ts != "" {
+		var ok bool
+		if ret.Timestamp, ok = parseTimestamp(ts); !ok {
+			return InputLine{}, parseError(line, ReasonBadTimestamp, nil)
+		}
+	}
+
+	if state != "" {
+		var ok bool
+		if ret.State, ok = parseState(state); !ok {
+			return InputLine{}, parseError(line, ReasonBadState, nil)
+		}
+	}
+
+	if bytes != "" {
+		var ok bool
+		if ret.Bytes, ok = parseCounter(bytes); !ok {
+			return InputLine{}, parseError(line, ReasonBadCounter, nil)
+		}
+	}
+
+	if packets != "" {
+		var ok bool
+		if ret.Packets, ok = parseCounter(packets); !ok {
+			return InputLine{}, parseError(line, ReasonBadCounter, nil)
+		}
+	}
+
+	return ret, nil
+}
+
+// parsePort parses a port field of the line, up to 65535
+func parsePort(line, s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, numberError(line, ReasonBadPort, err)
+	}
+	if port < 0 || port > 65535 {
+		return 0, parseError(line, ReasonOutOfRange, nil)
+	}
+	return port, nil
+}
+
+// normalizeIP converts the IPv4-mapped IPv6 addresses (::ffff:10.0.0.1), reported by
+// dual-stack sockets, to their IPv4 form, so that both forms identify the same endpoint.
+// Other addresses, and hostnames, are returned unchanged.
+func normalizeIP(s string) string {
+	if !strings.Contains(s, ":") {
+		return s
+	}
+	if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+		return ip.To4().String()
+	}
+	return s
+}
+
+// parseCounter parses a non-negative traffic counter; values beyond the int64 range,
+// that a 64-bit unsigned kernel counter may reach, saturate at math.MaxInt64
+func parseCounter(s string) (int64, bool) {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if errors.Is(err, strconv.ErrRange) && !strings.HasPrefix(s, "-") {
+		return math.MaxInt64, true
+	}
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// sanitizeProcessName cleans up the greedily-captured process name: trailing whitespace
+// is dropped, while control characters and invalid UTF-8 sequences are replaced by '?'
+func sanitizeProcessName(name string) string {
+	name = strings.ToValidUTF8(strings.TrimRightFunc(name, unicode.IsSpace), "?")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return '?'
+		}
+		return r
+	}, name)
+}
+
+// requiredRegexGroups lists the named groups that a user-supplied -regex must define
+var requiredRegexGroups = []string{"remote_ip", "remote_port", "local_ip", "local_port", "pid", "cmd", "dir"}
+
+// LineParser parses lines using a user-supplied regex made of named capture groups,
+// for tracer builds whose output format differs from the builtin one.
+// The "dir" group must capture "->" or "in" for incoming connections, "<-" or "out"
+// for outgoing ones. The optional "ts" group captures the timestamp of the line, the
+// optional "state" group the LISTEN or ESTABLISHED state of the socket, the optional
+// "container" group the container ID or cgroup path of the process, the optional
+// "bytes" and "packets" groups the traffic volume of the observation.
+type LineParser struct {
+	re     *regexp.Regexp
+	groups map[string]int // group name -> submatch index; -1 for the missing optional groups
+}
+
+// NewLineParser compiles the regex and checks that it defines all the required groups
+func NewLineParser(expr string) (*LineParser, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %w", err)
+	}
+	p := &LineParser{re: re, groups: make(map[string]int)}
+	for _, name := range requiredRegexGroups {
+		idx := re.SubexpIndex(name)
+		if idx == -1 {
+			return nil, fmt.Errorf("regex %q does not define the required named group (?P<%s>...); required groups: %v", expr, name, requiredRegexGroups)
+		}
+		p.groups[name] = idx
+	}
+	p.groups["ts"] = re.SubexpIndex("ts")
+	p.groups["state"] = re.SubexpIndex("state")
+	p.groups["container"] = re.SubexpIndex("container")
+	p.groups["bytes"] = re.SubexpIndex("bytes")
+	p.groups["packets"] = re.SubexpIndex("packets")
+	return p, nil
+}
+
+// Parse parses a line; a nil LineParser falls back to the builtin format
+func (p *LineParser) Parse(line string) (InputLine, error) {
+	if p == nil {
+		return ParseLine(line)
+	}
+
+	matches := p.re.FindStringSubmatch(line)
+	if matches == nil {
+		return InputLine{}, parseError(line, ReasonNoMatch, nil)
+	}
+	group := func(name string) string {
+		if idx := p.groups[name]; idx >= 0 {
+			return matches[idx]
+		}
+		return ""
+	}
+
+	dir, ok := parseDirection(group("dir"))
+	if !ok {
+		return InputLine{}, parseError(line, ReasonBadDirection, nil)
+	}
+
+	return newInputLine(line, dir, group("remote_ip"), group("remote_port"), group("local_ip"), group("local_port"), group("pid"), group("cmd"), group("ts"), group("state"), group("container"), group("bytes"), group("packets"))
+}
@@ -0,0 +1,561 @@
+package netflow
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    InputLine
+		wantErr bool
+	}{
+		{
+			name: "outgoing connection (Local2Remote)",
+			line: "10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat",
+			want: InputLine{
+				Dir:         Local2Remote,
+				RemoteIP:    "10.42.0.54",
+				RemotePort:  5671,
+				LocalIP:     "10.42.0.55",
+				LocalPort:   5672,
+				ProcessID:   723736,
+				ProcessName: "ncat",
+			},
+		},
+		{
+			name: "incoming connection (Remote2Local)",
+			line: "10.42.0.55:5672->10.42.0.54:5671|PID=722977 CMD=tcp-echo",
+			want: InputLine{
+				Dir:         Remote2Local,
+				RemoteIP:    "10.42.0.55",
+				RemotePort:  5672,
+				LocalIP:     "10.42.0.54",
+				LocalPort:   5671,
+				ProcessID:   722977,
+				ProcessName: "tcp-echo",
+			},
+		},
+		{
+			name: "process name with embedded spaces",
+			line: "10.42.0.1:50392->10.42.0.4:10250|PID=19110 CMD=metrics server --secure",
+			want: InputLine{
+				Dir:         Remote2Local,
+				RemoteIP:    "10.42.0.1",
+				RemotePort:  50392,
+				LocalIP:     "10.42.0.4",
+				LocalPort:   10250,
+				ProcessID:   19110,
+				ProcessName: "metrics server --secure",
+			},
+		},
+		{
+			name:    "non-numeric remote port",
+			line:    "10.42.0.54:http<-10.42.0.55:5672|PID=723736 CMD=ncat",
+			wantErr: true,
+		},
+		{
+			name:    "non-numeric local port",
+			line:    "10.42.0.54:5671<-10.42.0.55:abc|PID=723736 CMD=ncat",
+			wantErr: true,
+		},
+		{
+			name:    "port overflowing int",
+			line:    "10.42.0.54:99999999999999999999<-10.42.0.55:5672|PID=723736 CMD=ncat",
+			wantErr: true,
+		},
+		{
+			name:    "PID overflowing int64",
+			line:    "10.42.0.54:5671<-10.42.0.55:5672|PID=99999999999999999999 CMD=ncat",
+			wantErr: true,
+		},
+		{
+			name:    "missing PID/CMD suffix",
+			line:    "10.42.0.54:5671<-10.42.0.55:5672",
+			wantErr: true,
+		},
+		{
+			name:    "missing CMD",
+			line:    "10.42.0.54:5671<-10.42.0.55:5672|PID=723736",
+			wantErr: true,
+		},
+		{
+			name:    "no direction arrow",
+			line:    "10.42.0.54:5671 10.42.0.55:5672|PID=723736 CMD=ncat",
+			wantErr: true,
+		},
+		{
+			name:    "tracer banner",
+			line:    "Listening for TCPv4 traffic...",
+			wantErr: true,
+		},
+		{
+			name:    "empty line",
+			line:    "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLine(tt.line)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseLine(%q) = %+v, expected an error", tt.line, got)
+				}
+				if got != (InputLine{}) {
+					t.Errorf("ParseLine(%q) returned non-zero InputLine on error: %+v", tt.line, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLine(%q) returned unexpected error: %v", tt.line, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLineErrorReasons(t *testing.T) {
+	tests := []struct {
+		line string
+		want ParseErrorReason
+	}{
+		{"garbage", ReasonNoMatch},
+		{"10.42.0.54:70000<-10.42.0.55:5672|PID=723736 CMD=ncat", ReasonOutOfRange},
+		{"10.42.0.54:5671<-10.42.0.55:99999999999999999999|PID=723736 CMD=ncat", ReasonOutOfRange},
+		{"10.42.0.54:5671<-10.42.0.55:5672|PID=9223372036854775808 CMD=ncat", ReasonOutOfRange},
+		{"10.42.0.54:5671 10.42.0.55:5672|PID=723736 DIR=sideways CMD=ncat", ReasonBadDirection},
+		{"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 TS=yesterday CMD=ncat", ReasonBadTimestamp},
+		{"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 STATE=CLOSED CMD=ncat", ReasonBadState},
+	}
+	for _, tt := range tests {
+		_, err := ParseLine(tt.line)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("ParseLine(%q) = %v, want a *ParseError", tt.line, err)
+			continue
+		}
+		if parseErr.Reason != tt.want || parseErr.Line != tt.line {
+			t.Errorf("ParseLine(%q) failed with reason %q on line %q, want %q", tt.line, parseErr.Reason, parseErr.Line, tt.want)
+		}
+		if !errors.Is(err, &ParseError{Reason: tt.want}) {
+			t.Errorf("errors.Is(%v, ParseError{%q}) = false", err, tt.want)
+		}
+	}
+
+	// the malformed numbers can only come from a custom regex
+	p, err := NewLineParser(`(?P<remote_ip>\S+) (?P<remote_port>\S+) (?P<dir>\S+) (?P<local_ip>\S+) (?P<local_port>\S+) (?P<pid>\S+) (?P<cmd>.+)`)
+	if err != nil {
+		t.Fatalf("NewLineParser returned unexpected error: %v", err)
+	}
+	for line, want := range map[string]ParseErrorReason{
+		"10.0.0.1 http -> 10.0.0.2 5672 1 ncat":   ReasonBadPort,
+		"10.0.0.1 8080 -> 10.0.0.2 5672 one ncat": ReasonBadPID,
+		"10.0.0.1 8080 <> 10.0.0.2 5672 1 ncat":   ReasonBadDirection,
+		"10.0.0.1 8080":                           ReasonNoMatch,
+	} {
+		if _, err := p.Parse(line); !errors.Is(err, &ParseError{Reason: want}) {
+			t.Errorf("Parse(%q) = %v, want reason %q", line, err, want)
+		}
+	}
+	if _, err := p.Parse("10.0.0.1 http -> 10.0.0.2 5672 1 ncat"); !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("the ParseError must wrap the strconv error, got %v", err)
+	}
+}
+
+func TestParseLineSanitizesProcessName(t *testing.T) {
+	for _, tc := range []struct {
+		cmd, want string
+	}{
+		{"ncat   \t ", "ncat"},
+		{"say \"hello\"", "say \"hello\""},
+		{`printf a\nb`, `printf a\nb`},
+		{"bad\x01name\x7f", "bad?name?"},
+		{"latin1 \xe9t\xe9", "latin1 ?t?"},
+	} {
+		line, err := ParseLine("10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=" + tc.cmd)
+		if err != nil {
+			t.Fatalf("ParseLine returned unexpected error: %v", err)
+		}
+		if line.ProcessName != tc.want {
+			t.Errorf("CMD=%q: ProcessName = %q, want %q", tc.cmd, line.ProcessName, tc.want)
+		}
+	}
+}
+
+func TestParseLineExplicitDirection(t *testing.T) {
+	var warnings bytes.Buffer
+	LogOutput = &warnings
+	defer func() { LogOutput = os.Stderr }()
+	FlushWarnings() // start counting the repeated warnings afresh
+
+	for _, tc := range []struct {
+		name     string
+		line     string
+		wantDir  Direction
+		wantWarn bool
+	}{
+		{"arrow only", "10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat", Local2Remote, false},
+		{"token only, outgoing", "10.42.0.54:5671 10.42.0.55:5672|PID=723736 DIR=out CMD=ncat", Local2Remote, false},
+		{"token only, incoming", "10.42.0.55:5672  10.42.0.54:5671|PID=722977 DIR=in CMD=tcp-echo", Remote2Local, false},
+		{"arrow and token agree", "10.42.0.55:5672->10.42.0.54:5671|PID=722977 DIR=in CMD=tcp-echo", Remote2Local, false},
+		{"arrow and token conflict", "10.42.0.55:5672->10.42.0.54:5671|PID=722977 DIR=out CMD=tcp-echo", Local2Remote, true},
+	} {
+		warnings.Reset()
+		got, err := ParseLine(tc.line)
+		if err != nil {
+			t.Errorf("%s: ParseLine returned unexpected error: %v", tc.name, err)
+			continue
+		}
+		if got.Dir != tc.wantDir {
+			t.Errorf("%s: Dir = %v, want %v", tc.name, got.Dir, tc.wantDir)
+		}
+		if got.ProcessName == "" || strings.Contains(got.ProcessName, "DIR=") {
+			t.Errorf("%s: ProcessName = %q, the DIR token must not leak into it", tc.name, got.ProcessName)
+		}
+		if gotWarn := warnings.Len() > 0; gotWarn != tc.wantWarn {
+			t.Errorf("%s: warning = %q, want a warning: %v", tc.name, warnings.String(), tc.wantWarn)
+		}
+	}
+
+	if _, err := ParseLine("10.42.0.54:5671 10.42.0.55:5672|PID=723736 DIR=sideways CMD=ncat"); err == nil {
+		t.Errorf("ParseLine with an invalid DIR token expected an error")
+	}
+}
+
+func TestLineParserCustomRegex(t *testing.T) {
+	p, err := NewLineParser(`^(?P<pid>\d+) (?P<cmd>\S+) (?P<dir>in|out) (?P<local_ip>[^ ]+) (?P<local_port>\d+) (?P<remote_ip>[^ ]+) (?P<remote_port>\d+)$`)
+	if err != nil {
+		t.Fatalf("NewLineParser returned unexpected error: %v", err)
+	}
+
+	got, err := p.Parse("723736 ncat out 10.42.0.55 5672 10.42.0.54 5671")
+	if err != nil {
+		t.Fatalf("Parse returned unexpected error: %v", err)
+	}
+	want := InputLine{
+		Dir:         Local2Remote,
+		RemoteIP:    "10.42.0.54",
+		RemotePort:  5671,
+		LocalIP:     "10.42.0.55",
+		LocalPort:   5672,
+		ProcessID:   723736,
+		ProcessName: "ncat",
+	}
+	if got != want {
+		t.Errorf("Parse = %+v, want %+v", got, want)
+	}
+
+	got, err = p.Parse("722977 tcp-echo in 10.42.0.54 5671 10.42.0.55 5672")
+	if err != nil || got.Dir != Remote2Local {
+		t.Errorf("Parse of incoming line = %+v, %v; want Remote2Local", got, err)
+	}
+
+	for _, line := range []string{
+		"",
+		"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat",
+		"723736 ncat out 10.42.0.55 99999999999999999999 10.42.0.54 5671",
+	} {
+		if _, err := p.Parse(line); err == nil {
+			t.Errorf("Parse(%q) expected an error", line)
+		}
+	}
+}
+
+func TestLineParserInvalidDirection(t *testing.T) {
+	p, err := NewLineParser(`(?P<remote_ip>.+):(?P<remote_port>\d+)(?P<dir>..)(?P<local_ip>.+):(?P<local_port>\d+)\|PID=(?P<pid>\d+) CMD=(?P<cmd>.+)`)
+	if err != nil {
+		t.Fatalf("NewLineParser returned unexpected error: %v", err)
+	}
+	if _, err := p.Parse("10.42.0.54:5671<>10.42.0.55:5672|PID=723736 CMD=ncat"); err == nil {
+		t.Errorf("Parse with an unknown direction token expected an error")
+	}
+	// the builtin format expressed as a custom regex must behave like ParseLine
+	line := "10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat"
+	got, err := p.Parse(line)
+	want, _ := ParseLine(line)
+	if err != nil || got != want {
+		t.Errorf("Parse(%q) = %+v, %v; want %+v", line, got, err, want)
+	}
+}
+
+func TestNewLineParserValidation(t *testing.T) {
+	if _, err := NewLineParser(`(?P<remote_ip>.+`); err == nil {
+		t.Errorf("expected an error for a regex that does not compile")
+	}
+	_, err := NewLineParser(`(?P<remote_ip>.+):(?P<remote_port>\d+)(?P<dir>..)(?P<local_ip>.+):(?P<local_port>\d+)\|PID=(?P<pid>\d+)`)
+	if err == nil || !strings.Contains(err.Error(), "cmd") {
+		t.Errorf("expected an error naming the missing 'cmd' group, got %v", err)
+	}
+}
+
+func TestNilLineParserUsesBuiltinFormat(t *testing.T) {
+	var p *LineParser
+	line := "10.42.0.55:5672->10.42.0.54:5671|PID=722977 CMD=tcp-echo"
+	got, err := p.Parse(line)
+	want, _ := ParseLine(line)
+	if err != nil || got != want {
+		t.Errorf("Parse(%q) = %+v, %v; want %+v", line, got, err, want)
+	}
+}
+
+func TestParseLineTimestamp(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		want time.Time
+	}{
+		{"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat", time.Time{}},
+		{"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 TS=2024-05-01T10:00:00Z CMD=ncat", time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+		{"10.42.0.55:5672->10.42.0.54:5671|PID=722977 TS=1714557600.5 DIR=in CMD=tcp-echo", time.Date(2024, 5, 1, 10, 0, 0, 5e8, time.UTC)},
+		{"10.42.0.54:5671 10.42.0.55:5672|PID=723736 TS=42 DIR=out CMD=ncat", time.Unix(42, 0).UTC()},
+	} {
+		got, err := ParseLine(tc.line)
+		if err != nil {
+			t.Errorf("ParseLine(%q) returned unexpected error: %v", tc.line, err)
+			continue
+		}
+		if !got.Timestamp.Equal(tc.want) || got.ProcessName == "" || strings.Contains(got.ProcessName, "TS=") {
+			t.Errorf("ParseLine(%q) = %+v, want timestamp %s", tc.line, got, tc.want)
+		}
+	}
+
+	for _, line := range []string{
+		"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 TS=yesterday CMD=ncat",
+		"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 TS=-5 CMD=ncat",
+	} {
+		if _, err := ParseLine(line); err == nil {
+			t.Errorf("ParseLine(%q) expected an error", line)
+		}
+	}
+
+	// custom regexes can capture the timestamp with the optional "ts" group
+	p, err := NewLineParser(`^(?P<ts>\S+) (?P<pid>\d+) (?P<cmd>\S+) (?P<dir>in|out) (?P<local_ip>\S+) (?P<local_port>\d+) (?P<remote_ip>\S+) (?P<remote_port>\d+)$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.Parse("1714557600 723736 ncat out 10.42.0.55 5672 10.42.0.54 5671")
+	if err != nil || !got.Timestamp.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Parse = %+v, %v; want a timestamp", got, err)
+	}
+}
+
+func TestParseLineState(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		want SocketState
+	}{
+		{"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat", StateUnknown},
+		{"0.0.0.0:0<-10.42.0.55:5672|PID=723736 STATE=LISTEN CMD=ncat", StateListen},
+		{"10.42.0.55:5672->10.42.0.54:5671|PID=722977 TS=42 DIR=in STATE=established CMD=tcp-echo", StateEstablished},
+		{"10.42.0.54:5671 10.42.0.55:5672|PID=723736 DIR=out STATE=ESTABLISHED CMD=ncat", StateEstablished},
+	} {
+		got, err := ParseLine(tc.line)
+		if err != nil {
+			t.Errorf("ParseLine(%q) returned unexpected error: %v", tc.line, err)
+			continue
+		}
+		if got.State != tc.want || strings.Contains(got.ProcessName, "STATE=") {
+			t.Errorf("ParseLine(%q) = %+v, want state %d", tc.line, got, tc.want)
+		}
+	}
+
+	if _, err := ParseLine("10.42.0.54:5671<-10.42.0.55:5672|PID=723736 STATE=CLOSE_WAIT CMD=ncat"); err == nil {
+		t.Errorf("ParseLine with an unknown state expected an error")
+	}
+
+	// a listening socket has no peer but is valid
+	got, _ := ParseLine("0.0.0.0:0<-10.42.0.55:5672|PID=723736 STATE=LISTEN CMD=ncat")
+	if !IsValidLine(got, FilterOptions{}) || got.DestPort() != 5672 {
+		t.Errorf("LISTEN line %+v should be valid, with destination port 5672", got)
+	}
+
+	// custom regexes can capture the state with the optional "state" group
+	p, err := NewLineParser(`^(?P<state>\S+) (?P<pid>\d+) (?P<cmd>\S+) (?P<dir>in|out) (?P<local_ip>\S+) (?P<local_port>\d+) (?P<remote_ip>\S+) (?P<remote_port>\d+)$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := p.Parse("LISTEN 723736 ncat in 10.42.0.55 5672 0.0.0.0 0"); err != nil || got.State != StateListen {
+		t.Errorf("Parse = %+v, %v; want a LISTEN state", got, err)
+	}
+}
+
+func TestParseLineContainer(t *testing.T) {
+	for _, tc := range []struct {
+		line, want string
+	}{
+		{"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat", ""},
+		{"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CONTAINER=3f2a9c CMD=ncat", "3f2a9c"},
+		{"10.42.0.55:5672->10.42.0.54:5671|PID=722977 TS=42 DIR=in STATE=ESTABLISHED CONTAINER=/kubepods/pod1/3f2a9c CMD=tcp-echo", "/kubepods/pod1/3f2a9c"},
+		{"10.42.0.54:5671 10.42.0.55:5672|PID=723736 DIR=out CONTAINER=3f2a9c CMD=ncat", "3f2a9c"},
+	} {
+		got, err := ParseLine(tc.line)
+		if err != nil {
+			t.Errorf("ParseLine(%q) returned unexpected error: %v", tc.line, err)
+			continue
+		}
+		if got.ContainerID != tc.want || strings.Contains(got.ProcessName, "CONTAINER=") {
+			t.Errorf("ParseLine(%q) = %+v, want container %q", tc.line, got, tc.want)
+		}
+	}
+
+	// custom regexes can capture the container with the optional "container" group
+	p, err := NewLineParser(`^(?P<container>\S+) (?P<pid>\d+) (?P<cmd>\S+) (?P<dir>in|out) (?P<local_ip>\S+) (?P<local_port>\d+) (?P<remote_ip>\S+) (?P<remote_port>\d+)$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := p.Parse("3f2a9c 723736 ncat out 10.42.0.55 5672 10.42.0.54 5671"); err != nil || got.ContainerID != "3f2a9c" {
+		t.Errorf("Parse = %+v, %v; want container 3f2a9c", got, err)
+	}
+}
+
+func TestParseLineTrafficCounters(t *testing.T) {
+	for _, tc := range []struct {
+		line           string
+		bytes, packets int64
+	}{
+		{"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat", 0, 0},
+		{"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 BYTES=1500 PKTS=3 CMD=ncat", 1500, 3},
+		{"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 PKTS=3 CMD=ncat", 0, 3},
+		{"10.42.0.55:5672->10.42.0.54:5671|PID=722977 TS=42 DIR=in STATE=ESTABLISHED CONTAINER=3f2a9c BYTES=64 CMD=tcp-echo", 64, 0},
+		// beyond the int64 range, as a wrapped-around unsigned kernel counter may be
+		{"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 BYTES=18446744073709551615 CMD=ncat", math.MaxInt64, 0},
+	} {
+		got, err := ParseLine(tc.line)
+		if err != nil {
+			t.Errorf("ParseLine(%q) returned unexpected error: %v", tc.line, err)
+			continue
+		}
+		if got.Bytes != tc.bytes || got.Packets != tc.packets || strings.Contains(got.ProcessName, "=") {
+			t.Errorf("ParseLine(%q) = %+v, want %d bytes and %d packets", tc.line, got, tc.bytes, tc.packets)
+		}
+	}
+
+	// custom regexes can capture the counters with the optional "bytes" and "packets" groups
+	p, err := NewLineParser(`^(?P<pid>\d+) (?P<cmd>\S+) (?P<dir>in|out) (?P<local_ip>\S+) (?P<local_port>\d+) (?P<remote_ip>\S+) (?P<remote_port>\d+) (?P<bytes>\S+) (?P<packets>\S+)$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := p.Parse("723736 ncat out 10.42.0.55 5672 10.42.0.54 5671 1500 3"); err != nil || got.Bytes != 1500 || got.Packets != 3 {
+		t.Errorf("Parse = %+v, %v; want 1500 bytes and 3 packets", got, err)
+	}
+	for _, line := range []string{
+		"723736 ncat out 10.42.0.55 5672 10.42.0.54 5671 -1 3",
+		"723736 ncat out 10.42.0.55 5672 10.42.0.54 5671 1500 many",
+	} {
+		if _, err := p.Parse(line); err == nil {
+			t.Errorf("Parse(%q) expected an error", line)
+		}
+	}
+}
+
+func FuzzParseLine(f *testing.F) {
+	for _, seed := range []string{
+		"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat",
+		"10.42.0.55:5672->10.42.0.54:5671|PID=722977 CMD=tcp-echo",
+		"10.42.0.54:5671 10.42.0.55:5672|PID=723736 DIR=out CMD=ncat",
+		"0.0.0.0:0<-10.42.0.55:5672|PID=723736 TS=42 STATE=LISTEN CONTAINER=3f2a9c CMD=ncat",
+		"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 TS=2024-05-01T10:00:00Z CMD=ncat",
+		"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 TS=1714557600.5 DIR=out CMD=ncat",
+		"[fe80::1]:443<-[fe80::2]:5000|PID=1 CMD=v6",
+		"Attaching 5 probes...",
+		"",
+		"10.42.0.54:99999999999999999999<-10.42.0.55:5672|PID=723736 CMD=ncat",
+		"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 DIR=sideways CMD=ncat",
+		"10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=\x00\xff",
+	} {
+		f.Add(seed)
+	}
+	LogOutput = io.Discard
+	f.Cleanup(func() { LogOutput = os.Stderr })
+
+	f.Fuzz(func(t *testing.T, line string) {
+		got, err := ParseLine(line)
+		if err != nil {
+			if got != (InputLine{}) {
+				t.Errorf("ParseLine(%q) returned %+v together with error %v", line, got, err)
+			}
+			return
+		}
+		if got.Dir != Remote2Local && got.Dir != Local2Remote {
+			t.Errorf("ParseLine(%q) returned invalid direction %d", line, got.Dir)
+		}
+		if got.RemotePort < 0 || got.LocalPort < 0 || got.ProcessID < 0 {
+			t.Errorf("ParseLine(%q) returned negative fields: %+v", line, got)
+		}
+		if !utf8.ValidString(got.ProcessName) {
+			t.Errorf("ParseLine(%q) returned invalid UTF-8 process name %q", line, got.ProcessName)
+		}
+	})
+}
+
+func TestParseLineIPv4MappedIPv6(t *testing.T) {
+	for _, tc := range []struct {
+		line              string
+		localIP, remoteIP string
+	}{
+		{"::ffff:10.42.0.54:5671<-::ffff:10.42.0.55:5672|PID=723736 CMD=ncat", "10.42.0.55", "10.42.0.54"},
+		{"::FFFF:10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat", "10.42.0.55", "10.42.0.54"},
+		// the other IPv6 addresses are kept as they are
+		{"fd00::54:5671<-::1:5672|PID=723736 CMD=ncat", "::1", "fd00::54"},
+		{"::10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat", "10.42.0.55", "::10.42.0.54"},
+	} {
+		got, err := ParseLine(tc.line)
+		if err != nil {
+			t.Errorf("ParseLine(%q) returned unexpected error: %v", tc.line, err)
+			continue
+		}
+		if got.LocalIP != tc.localIP || got.RemoteIP != tc.remoteIP {
+			t.Errorf("ParseLine(%q) = %+v, want local IP %s and remote IP %s", tc.line, got, tc.localIP, tc.remoteIP)
+		}
+	}
+}
+
+func TestParseLineLargePID(t *testing.T) {
+	line := "10.42.0.54:5671<-10.42.0.55:5672|PID=9223372036854775807 CMD=ncat"
+	got, err := ParseLine(line)
+	if err != nil {
+		t.Fatalf("ParseLine(%q) returned unexpected error: %v", line, err)
+	}
+	if got.ProcessID != math.MaxInt64 {
+		t.Errorf("ProcessID = %d, want %d", got.ProcessID, int64(math.MaxInt64))
+	}
+
+	line = "10.42.0.54:5671<-10.42.0.55:5672|PID=9223372036854775808 CMD=ncat"
+	if _, err := ParseLine(line); err == nil {
+		t.Errorf("ParseLine(%q) did not return an error for a PID overflowing int64", line)
+	}
+}
+
+func BenchmarkParseLine(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		line string
+	}{
+		{"valid", "10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat"},
+		{"tokens", "10.42.0.55:5672->10.42.0.54:5671|PID=722977 TS=1714557600.5 DIR=in STATE=ESTABLISHED BYTES=1500 PKTS=3 CMD=tcp-echo"},
+		{"ipv6", "fd00::54:5671<-fd00::55:5672|PID=723736 CMD=ncat"},
+		{"ipv4-mapped", "::ffff:10.42.0.54:5671<-::ffff:10.42.0.55:5672|PID=723736 CMD=ncat"},
+		{"invalid", "garbage"},
+		{"banner", "Attaching 5 probes..."},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				ParseLine(bc.line)
+			}
+		})
+	}
+}
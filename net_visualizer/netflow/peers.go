@@ -0,0 +1,87 @@
+package netflow
+
+import "maps"
+
+// PeerCounts returns the number of distinct peers connected to each listening endpoint
+// of the model: the client processes, whatever the number of their connections, and the
+// IPs of the clients never observed locally
+func (m *Model) PeerCounts() map[ProcessEndpoint]int {
+	// a peer is either a process or, when never observed locally, an IP
+	type peer struct {
+		pid int64
+		ip  string
+	}
+	peers := make(map[ProcessEndpoint]map[peer]struct{})
+	addPeer := func(ep ProcessEndpoint, p peer) {
+		if peers[ep] == nil {
+			peers[ep] = make(map[peer]struct{})
+		}
+		peers[ep][p] = struct{}{}
+	}
+	for e := range m.Edges {
+		if _, ok := m.Listening[e.Dest]; ok {
+			addPeer(e.Dest, peer{pid: e.Source.PID})
+		}
+	}
+	for remote, locals := range m.Dangling {
+		for local, dir := range locals {
+			if _, ok := m.Listening[local]; ok && dir == Remote2Local {
+				addPeer(local, peer{ip: remote.IP})
+			}
+		}
+	}
+	ret := make(map[ProcessEndpoint]int, len(peers))
+	for ep, set := range peers {
+		ret[ep] = len(set)
+	}
+	return ret
+}
+
+// MinPeers returns the submodel made of the listening endpoints connected to at least
+// minPeers distinct peers, as counted by PeerCounts, e.g. to tell the services shared by
+// many clients from the one-off connections: only the edges towards those endpoints are
+// kept, together with the processes at their ends, and the processes left without any
+// edge are pruned. The dangling connections of the external clients towards those
+// endpoints are kept as well. It also returns the number of removed listening endpoints
+// and processes. The statistics of the returned model are the ones of the whole model.
+func (m *Model) MinPeers(minPeers int) (ret *Model, removedPorts, removedNodes int) {
+	counts := m.PeerCounts()
+	qualifies := func(ep ProcessEndpoint) bool {
+		_, ok := m.Listening[ep]
+		return ok && counts[ep] >= minPeers
+	}
+
+	ret = newModel()
+	ret.Stats = m.Stats
+	ret.Latest = m.Latest
+	ret.hidden = maps.Clone(m.hidden)
+	for e, info := range m.Edges {
+		if qualifies(e.Dest) {
+			ret.Edges[e] = info
+			ret.Nodes[e.Source.PID] = m.Nodes[e.Source.PID]
+			ret.Nodes[e.Dest.PID] = m.Nodes[e.Dest.PID]
+		} else {
+			ret.hide(e)
+		}
+	}
+	for remote, locals := range m.Dangling {
+		for local, dir := range locals {
+			if dir != Remote2Local || !qualifies(local) {
+				continue
+			}
+			if ret.Dangling[remote] == nil {
+				ret.Dangling[remote] = make(map[ProcessEndpoint]Direction)
+			}
+			ret.Dangling[remote][local] = dir
+			ret.Nodes[local.PID] = m.Nodes[local.PID]
+		}
+	}
+	for ep := range m.Listening {
+		if qualifies(ep) {
+			ret.Listening[ep] = struct{}{}
+		} else {
+			removedPorts++
+		}
+	}
+	return ret, removedPorts, len(m.Nodes) - len(ret.Nodes)
+}
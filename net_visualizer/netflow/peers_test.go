@@ -0,0 +1,61 @@
+package netflow
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestModelMinPeers(t *testing.T) {
+	// the API server on 8080 is called by two CLIs and an external client, the database
+	// on 5432 only by the API server, through two connections, and the cache on 6379 only
+	// by the second CLI
+	trace := `10.0.0.2:8080<-10.0.0.1:40000|PID=1 CMD=cli
+10.0.0.1:40000->10.0.0.2:8080|PID=2 CMD=api
+10.0.0.2:8080<-10.0.0.5:40000|PID=5 CMD=cli
+10.0.0.5:40000->10.0.0.2:8080|PID=2 CMD=api
+10.0.9.8:50000->10.0.0.2:8080|PID=2 CMD=api
+10.0.0.3:5432<-10.0.0.2:41000|PID=2 CMD=api
+10.0.0.2:41000->10.0.0.3:5432|PID=3 CMD=db
+10.0.0.3:5432<-10.0.0.2:41001|PID=2 CMD=api
+10.0.0.2:41001->10.0.0.3:5432|PID=3 CMD=db
+10.0.0.6:6379<-10.0.0.5:42000|PID=5 CMD=cli
+10.0.0.5:42000->10.0.0.6:6379|PID=6 CMD=cache
+`
+	model := mustBuildModel(t, trace, ModelOptions{})
+	counts := model.PeerCounts()
+	for ep, want := range map[ProcessEndpoint]int{
+		{PID: 2, Port: 8080}: 3,
+		{PID: 3, Port: 5432}: 1,
+		{PID: 6, Port: 6379}: 1,
+	} {
+		if counts[ep] != want {
+			t.Errorf("PeerCounts()[%v] = %d, want %d", ep, counts[ep], want)
+		}
+	}
+
+	shared, ports, nodes := model.MinPeers(2)
+	if ports != 2 || nodes != 2 {
+		t.Errorf("removed %d ports and %d processes, want the database and the cache", ports, nodes)
+	}
+	if pids := modelPIDs(shared); !slices.Equal(pids, []int64{1, 2, 5}) {
+		t.Errorf("got processes %v, want the API server and its clients", pids)
+	}
+	for e := range shared.Edges {
+		if e.Dest != (ProcessEndpoint{PID: 2, Port: 8080}) {
+			t.Errorf("unexpected edge %+v", e)
+		}
+	}
+	if len(shared.Edges) != 2 || shared.NumDangling() != 1 {
+		t.Errorf("got %d edges and %d dangling connections, want the ones towards 8080", len(shared.Edges), shared.NumDangling())
+	}
+	if _, ok := shared.Listening[ProcessEndpoint{PID: 3, Port: 5432}]; ok {
+		t.Errorf("the database port is still listening")
+	}
+
+	if _, ports, nodes := model.MinPeers(1); ports != 0 || nodes != 0 {
+		t.Errorf("MinPeers(1) removed %d ports and %d processes, want none", ports, nodes)
+	}
+	if len(model.Nodes) != 5 || len(model.Edges) != 5 {
+		t.Errorf("MinPeers must leave the receiver untouched")
+	}
+}
@@ -0,0 +1,56 @@
+package netflow
+
+import (
+	"math"
+	"net"
+)
+
+// aliasPID returns the node key of the i-th process sharing its PID with an already
+// known process of another PID namespace, e.g. PID 1 in every container: alias keys
+// start from the lowest int64, so that they never clash with the traced processes nor
+// with the synthetic nodes of the CIDR groups
+func aliasPID(i int) int64 {
+	return math.MinInt64 + int64(i)
+}
+
+// sameProcess tells whether the line may come from the process n: the PIDs are local to
+// PID namespaces, so the same PID observed from another IP, or from another container
+// when the tracer reports both, belongs to another process. So does the same PID with
+// another command, once the PID is reused or the process exec'd another program.
+// Listening sockets bound to all the interfaces don't tell the IP of their process.
+func sameProcess(n *ProcessEndpoints, line InputLine) bool {
+	if line.ContainerID != "" && n.ContainerID != "" && line.ContainerID != n.ContainerID {
+		return false
+	}
+	if n.ProcessName != line.ProcessName {
+		return false
+	}
+	return n.LocalIP == line.LocalIP || (line.State == StateListen && net.ParseIP(line.LocalIP).IsUnspecified())
+}
+
+// nodeKey returns the key of the node of the process of the line in the model: its PID,
+// unless the PID is already taken by another process, see sameProcess
+func (b *Builder) nodeKey(line InputLine) int64 {
+	n, ok := b.model.Nodes[line.ProcessID]
+	if !ok || sameProcess(n, line) {
+		return line.ProcessID
+	}
+	for _, key := range b.pidAliases[line.ProcessID] {
+		if n, ok := b.model.Nodes[key]; ok && sameProcess(n, line) {
+			return key
+		}
+	}
+	key := aliasPID(b.aliasCount)
+	b.aliasCount++
+	b.pidAliases[line.ProcessID] = append(b.pidAliases[line.ProcessID], key)
+	return key
+}
+
+// sharedPID returns the ProcessEndpoints.SharedPID of the process with the given PID
+// and node key
+func sharedPID(pid, key int64) int64 {
+	if pid == key {
+		return 0
+	}
+	return pid
+}
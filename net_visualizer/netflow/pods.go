@@ -0,0 +1,26 @@
+package netflow
+
+// PodInfo identifies the Kubernetes pod owning an IP address
+type PodInfo struct {
+	Name      string
+	Namespace string
+}
+
+func (p PodInfo) String() string {
+	return p.Namespace + "/" + p.Name
+}
+
+// PodResolver maps IP addresses to the pods they are assigned to.
+// The Kubernetes-backed implementation lives in the CLI, behind the "kube" build tag, so
+// that this package does not depend on client-go.
+type PodResolver interface {
+	ResolvePod(ip string) (PodInfo, bool)
+}
+
+// StaticPodResolver is a PodResolver backed by a precomputed IP->pod map
+type StaticPodResolver map[string]PodInfo
+
+func (s StaticPodResolver) ResolvePod(ip string) (PodInfo, bool) {
+	p, ok := s[ip]
+	return p, ok
+}
@@ -0,0 +1,81 @@
+package netflow
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PortSet is a set of TCP ports, as loaded from -ports-allowlist / -ports-denylist
+type PortSet map[int]struct{}
+
+// Contains tells whether the port belongs to the set; a nil set contains nothing
+func (s PortSet) Contains(port int) bool {
+	_, ok := s[port]
+	return ok
+}
+
+func (s *PortSet) String() string {
+	if s == nil {
+		return ""
+	}
+	ports := slices.Sorted(maps.Keys(*s))
+	ret := make([]string, 0, len(ports))
+	for _, port := range ports {
+		ret = append(ret, strconv.Itoa(port))
+	}
+	return strings.Join(ret, ",")
+}
+
+// Set adds a comma-separated list of ports to the set, e.g. "5432,3306"; it implements
+// flag.Value, for -dst-ports
+func (s *PortSet) Set(value string) error {
+	if *s == nil {
+		*s = make(PortSet)
+	}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		port, err := strconv.Atoi(field)
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid port %q, expected a number between 1 and 65535", field)
+		}
+		(*s)[port] = struct{}{}
+	}
+	return nil
+}
+
+// LoadPortSet reads a file listing one port number per line; '#' starts a comment and
+// blank lines are ignored. Any entry that is not a port number is reported as an error.
+// The returned set is never nil, even for an empty file.
+func LoadPortSet(path string) (PortSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ret := make(PortSet)
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		port, err := strconv.Atoi(line)
+		if err != nil || port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("%s:%d: invalid port %q, expected a number between 1 and 65535", path, lineNum, line)
+		}
+		ret[port] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ret, nil
+}
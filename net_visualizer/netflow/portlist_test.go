@@ -0,0 +1,68 @@
+package netflow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTempFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ports")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPortSet(t *testing.T) {
+	ports, err := LoadPortSet(writeTempFile(t, "# interesting ports\n6443\n\n  5671  # amqp\n"))
+	if err != nil {
+		t.Fatalf("LoadPortSet returned unexpected error: %v", err)
+	}
+	if len(ports) != 2 || !ports.Contains(6443) || !ports.Contains(5671) {
+		t.Errorf("LoadPortSet = %v, want 6443 and 5671", ports)
+	}
+
+	ports, err = LoadPortSet(writeTempFile(t, "# nothing here\n"))
+	if err != nil {
+		t.Fatalf("LoadPortSet returned unexpected error: %v", err)
+	}
+	if ports == nil || len(ports) != 0 {
+		t.Errorf("empty file should give an empty, non-nil set, got %#v", ports)
+	}
+
+	for _, content := range []string{"http\n", "0\n", "65536\n", "80 443\n"} {
+		if _, err := LoadPortSet(writeTempFile(t, content)); err == nil {
+			t.Errorf("LoadPortSet(%q) expected an error", content)
+		}
+	}
+}
+
+func TestIsValidLinePortLists(t *testing.T) {
+	// outgoing connection towards port 5671, incoming connection on port 5671
+	outgoing := InputLine{Dir: Local2Remote, RemoteIP: "10.42.0.54", RemotePort: 5671, LocalIP: "10.42.0.55", LocalPort: 5672}
+	incoming := InputLine{Dir: Remote2Local, RemoteIP: "10.42.0.55", RemotePort: 5672, LocalIP: "10.42.0.54", LocalPort: 5671}
+
+	tests := []struct {
+		name string
+		opts FilterOptions
+		want bool
+	}{
+		{"no lists", FilterOptions{}, true},
+		{"allowed", FilterOptions{AllowPorts: PortSet{5671: {}}}, true},
+		{"not allowed", FilterOptions{AllowPorts: PortSet{6443: {}}}, false},
+		{"source port is not enough", FilterOptions{AllowPorts: PortSet{5672: {}}}, false},
+		{"empty allowlist", FilterOptions{AllowPorts: PortSet{}}, false},
+		{"denied", FilterOptions{DenyPorts: PortSet{5671: {}}}, false},
+		{"not denied", FilterOptions{DenyPorts: PortSet{5672: {}}}, true},
+		{"empty denylist", FilterOptions{DenyPorts: PortSet{}}, true},
+	}
+	for _, tt := range tests {
+		for _, line := range []InputLine{outgoing, incoming} {
+			if got := IsValidLine(line, tt.opts); got != tt.want {
+				t.Errorf("%s: IsValidLine(%+v) = %v, want %v", tt.name, line, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package netflow
+
+// servers returns the processes accepting at least one of the edges of the model on a
+// listening port
+func (m *Model) servers() map[int64]struct{} {
+	ret := make(map[int64]struct{})
+	for e := range m.Edges {
+		if _, ok := m.Listening[e.Dest]; ok {
+			ret[e.Dest.PID] = struct{}{}
+		}
+	}
+	return ret
+}
+
+// ServersOnly returns the submodel made of the servers, i.e. the processes accepting at
+// least one connection on a listening port, and of the edges between them: the pure
+// clients, e.g. CLI tools, are pruned. The statistics of the returned model are the ones
+// of the whole model.
+func (m *Model) ServersOnly() *Model {
+	return m.subModel(m.servers())
+}
+
+// ClientsOnly is the inverse of ServersOnly: it returns the submodel made of the pure
+// clients, i.e. the processes not accepting any connection on a listening port, and of
+// their edges, together with the servers at the other end of them. The statistics of
+// the returned model are the ones of the whole model.
+func (m *Model) ClientsOnly() *Model {
+	servers := m.servers()
+	clients := make(map[int64]struct{})
+	for pid := range m.Nodes {
+		if _, ok := servers[pid]; !ok {
+			clients[pid] = struct{}{}
+		}
+	}
+	return m.touching(clients)
+}
+
+// PruneLowDegree returns the core of the model: the submodel left after repeatedly
+// removing the processes with fewer than minDegree edges, counting both directions, until
+// all the remaining ones have at least minDegree, as removing a process can drop its
+// neighbors below the threshold. It also returns the number of removed processes. The
+// statistics of the returned model are the ones of the whole model.
+func (m *Model) PruneLowDegree(minDegree int) (*Model, int) {
+	keep := make(map[int64]struct{}, len(m.Nodes))
+	for pid := range m.Nodes {
+		keep[pid] = struct{}{}
+	}
+	for {
+		degrees := make(map[int64]int, len(keep))
+		for e := range m.Edges {
+			_, src := keep[e.Source.PID]
+			_, dst := keep[e.Dest.PID]
+			if src && dst {
+				degrees[e.Source.PID]++
+				degrees[e.Dest.PID]++
+			}
+		}
+		pruned := false
+		for pid := range keep {
+			if degrees[pid] < minDegree {
+				delete(keep, pid)
+				pruned = true
+			}
+		}
+		if !pruned {
+			break
+		}
+	}
+	return m.subModel(keep), len(m.Nodes) - len(keep)
+}
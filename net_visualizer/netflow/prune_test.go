@@ -0,0 +1,82 @@
+package netflow
+
+import (
+	"slices"
+	"testing"
+)
+
+// tierTrace has a CLI tool calling an API server, that in turn calls a database
+const tierTrace = `10.0.0.2:8080<-10.0.0.1:40000|PID=1 CMD=cli
+10.0.0.1:40000->10.0.0.2:8080|PID=2 CMD=api
+10.0.0.3:5432<-10.0.0.2:41000|PID=2 CMD=api
+10.0.0.2:41000->10.0.0.3:5432|PID=3 CMD=db
+`
+
+func TestModelServersOnly(t *testing.T) {
+	model := mustBuildModel(t, tierTrace, ModelOptions{})
+	servers := model.ServersOnly()
+	if pids := modelPIDs(servers); !slices.Equal(pids, []int64{2, 3}) {
+		t.Errorf("got processes %v, want the API server and the database", pids)
+	}
+	wantEdges := []Edge{{Source: ProcessEndpoint{PID: 2, Port: 41000}, Dest: ProcessEndpoint{PID: 3, Port: 5432}}}
+	if edges := servers.SortedEdges(); !slices.Equal(edges, wantEdges) {
+		t.Errorf("SortedEdges = %+v, want %+v", edges, wantEdges)
+	}
+	// the port of the API server has connections, although they are not drawn
+	if idle := servers.IdleListening(); len(idle) != 0 {
+		t.Errorf("IdleListening = %v, want none", idle)
+	}
+	if len(model.Nodes) != 3 || len(model.Edges) != 2 {
+		t.Errorf("ServersOnly must leave the receiver untouched")
+	}
+}
+
+func TestModelClientsOnly(t *testing.T) {
+	model := mustBuildModel(t, tierTrace, ModelOptions{})
+	clients := model.ClientsOnly()
+	if pids := modelPIDs(clients); !slices.Equal(pids, []int64{1, 2}) {
+		t.Errorf("got processes %v, want the CLI tool and the API server it calls", pids)
+	}
+	wantEdges := []Edge{{Source: ProcessEndpoint{PID: 1, Port: 40000}, Dest: ProcessEndpoint{PID: 2, Port: 8080}}}
+	if edges := clients.SortedEdges(); !slices.Equal(edges, wantEdges) {
+		t.Errorf("SortedEdges = %+v, want %+v", edges, wantEdges)
+	}
+	if _, ok := clients.Listening[ProcessEndpoint{PID: 2, Port: 8080}]; !ok {
+		t.Errorf("the listening port of the API server should be kept")
+	}
+}
+
+func TestModelPruneLowDegree(t *testing.T) {
+	// a triangle of services, with the CLI -> API -> DB chain of tierTrace hanging from
+	// one of them: 10.0.0.5 calls both the API server and 10.0.0.6, that calls the API
+	// server as well
+	trace := tierTrace + `10.0.0.2:8080<-10.0.0.5:40001|PID=5 CMD=gateway
+10.0.0.5:40001->10.0.0.2:8080|PID=2 CMD=api
+10.0.0.6:9090<-10.0.0.5:40002|PID=5 CMD=gateway
+10.0.0.5:40002->10.0.0.6:9090|PID=6 CMD=auth
+10.0.0.2:8080<-10.0.0.6:40003|PID=6 CMD=auth
+10.0.0.6:40003->10.0.0.2:8080|PID=2 CMD=api
+`
+	model := mustBuildModel(t, trace, ModelOptions{})
+	core, pruned := model.PruneLowDegree(2)
+	// the CLI tool and the database have a single edge; once they are gone, the API server
+	// is left with the two edges of the triangle
+	if pids := modelPIDs(core); !slices.Equal(pids, []int64{2, 5, 6}) {
+		t.Errorf("got processes %v, want the triangle", pids)
+	}
+	if pruned != 2 || len(core.Edges) != 3 {
+		t.Errorf("pruned %d processes leaving %d edges, want 2 and 3", pruned, len(core.Edges))
+	}
+
+	// the chain is pruned from its ends, then the API server is left without edges
+	chain, pruned := mustBuildModel(t, tierTrace, ModelOptions{}).PruneLowDegree(2)
+	if pruned != 3 || len(chain.Nodes) != 0 || len(chain.Edges) != 0 {
+		t.Errorf("pruned %d processes leaving %v, want the whole chain pruned", pruned, chain.Nodes)
+	}
+	if _, pruned := model.PruneLowDegree(0); pruned != 0 {
+		t.Errorf("PruneLowDegree(0) pruned %d processes, want none", pruned)
+	}
+	if len(model.Nodes) != 5 || len(model.Edges) != 5 {
+		t.Errorf("PruneLowDegree must leave the receiver untouched")
+	}
+}
@@ -0,0 +1,192 @@
+package netflow
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// maxRecordSize bounds the size of a binary record, so that a corrupted size prefix
+// cannot make the reader allocate an arbitrary amount of memory
+const maxRecordSize = 64 << 10
+
+// the field numbers and enum values of the Record message, see record.proto
+const (
+	recordRemoteIP    protowire.Number = 1
+	recordRemotePort  protowire.Number = 2
+	recordLocalIP     protowire.Number = 3
+	recordLocalPort   protowire.Number = 4
+	recordPID         protowire.Number = 5
+	recordCmd         protowire.Number = 6
+	recordDir         protowire.Number = 7
+	recordTimestampNs protowire.Number = 8
+	recordState       protowire.Number = 9
+	recordContainer   protowire.Number = 10
+	recordBytes       protowire.Number = 11
+	recordPackets     protowire.Number = 12
+
+	recordDirIn            = 1
+	recordDirOut           = 2
+	recordStateListen      = 1
+	recordStateEstablished = 2
+)
+
+// consumeRecords is the ModelOptions.BinaryInput variant of Consume: the input is a
+// stream of size-prefixed Record messages, decoded straight into InputLines. A record
+// that cannot be decoded is counted as an invalid line, as in the text format, while a
+// stream that cannot be split into records any more is a read error.
+func (b *Builder) consumeRecords(r io.Reader, source int) error {
+	br := bufio.NewReader(r)
+	recordsRead := 0
+	for {
+		size, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil && size > maxRecordSize {
+			err = fmt.Errorf("record of %d bytes, larger than the maximum of %d", size, maxRecordSize)
+		}
+		buf := make([]byte, size)
+		if err == nil {
+			_, err = io.ReadFull(br, buf)
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("error reading input after %d records: %w", recordsRead, err)
+		}
+		recordsRead++
+
+		parsedLine, err := decodeRecord(buf)
+		line := formatInputLine(parsedLine)
+		if err != nil {
+			line = fmt.Sprintf("binary record %d", recordsRead)
+			err = fmt.Errorf("skipping invalid record %d: %w", recordsRead, err)
+		}
+		b.mu.Lock()
+		b.addParsedLine(line, parsedLine, err, source)
+		err = b.strictError(line, recordsRead, source, err)
+		b.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// decodeRecord decodes a Record message; unknown fields are skipped, for forward
+// compatibility
+func decodeRecord(buf []byte) (InputLine, error) {
+	var ret InputLine
+	var dir uint64
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return InputLine{}, protowire.ParseError(n)
+		}
+		buf = buf[n:]
+
+		var v uint64
+		var s string
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(buf)
+		case protowire.BytesType:
+			var raw []byte
+			raw, n = protowire.ConsumeBytes(buf)
+			s = string(raw)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, buf)
+		}
+		if n < 0 {
+			return InputLine{}, protowire.ParseError(n)
+		}
+		buf = buf[n:]
+
+		switch num {
+		case recordRemoteIP:
+			ret.RemoteIP = normalizeIP(s)
+		case recordRemotePort:
+			ret.RemotePort = int(v)
+		case recordLocalIP:
+			ret.LocalIP = normalizeIP(s)
+		case recordLocalPort:
+			ret.LocalPort = int(v)
+		case recordPID:
+			ret.ProcessID = int64(v)
+		case recordCmd:
+			ret.ProcessName = sanitizeProcessName(s)
+		case recordDir:
+			dir = v
+		case recordTimestampNs:
+			if ns := int64(v); ns != 0 {
+				ret.Timestamp = time.Unix(0, ns).UTC()
+			}
+		case recordState:
+			switch v {
+			case recordStateListen:
+				ret.State = StateListen
+			case recordStateEstablished:
+				ret.State = StateEstablished
+			}
+		case recordContainer:
+			ret.ContainerID = s
+		case recordBytes:
+			ret.Bytes = int64(min(v, math.MaxInt64))
+		case recordPackets:
+			ret.Packets = int64(min(v, math.MaxInt64))
+		}
+	}
+
+	switch dir {
+	case recordDirIn:
+		ret.Dir = Remote2Local
+	case recordDirOut:
+		ret.Dir = Local2Remote
+	default:
+		return InputLine{}, fmt.Errorf("invalid direction %d", dir)
+	}
+	if ret.RemotePort > 65535 || ret.LocalPort > 65535 {
+		return InputLine{}, fmt.Errorf("port out of range")
+	}
+	return ret, nil
+}
+
+// formatInputLine returns the line of the builtin text format equivalent to a decoded
+// record, for the messages that quote the input, e.g. -explain
+func formatInputLine(l InputLine) string {
+	arrow := "->"
+	if l.Dir == Local2Remote {
+		arrow = "<-"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s:%d%s%s:%d|PID=%d", l.RemoteIP, l.RemotePort, arrow, l.LocalIP, l.LocalPort, l.ProcessID)
+	if !l.Timestamp.IsZero() {
+		b.WriteString(" TS=" + l.Timestamp.Format(time.RFC3339Nano))
+	}
+	switch l.State {
+	case StateListen:
+		b.WriteString(" STATE=LISTEN")
+	case StateEstablished:
+		b.WriteString(" STATE=ESTABLISHED")
+	}
+	if l.ContainerID != "" {
+		b.WriteString(" CONTAINER=" + l.ContainerID)
+	}
+	if l.Bytes > 0 {
+		b.WriteString(" BYTES=" + strconv.FormatInt(l.Bytes, 10))
+	}
+	if l.Packets > 0 {
+		b.WriteString(" PKTS=" + strconv.FormatInt(l.Packets, 10))
+	}
+	b.WriteString(" CMD=" + l.ProcessName)
+	return b.String()
+}
@@ -0,0 +1,137 @@
+package netflow
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// appendRecord appends the Record message of the line to buf, preceded by its size, as
+// a tracer would write it
+func appendRecord(buf []byte, l InputLine) []byte {
+	var msg []byte
+	appendString := func(num protowire.Number, s string) {
+		if s != "" {
+			msg = protowire.AppendTag(msg, num, protowire.BytesType)
+			msg = protowire.AppendString(msg, s)
+		}
+	}
+	appendVarint := func(num protowire.Number, v uint64) {
+		if v != 0 {
+			msg = protowire.AppendTag(msg, num, protowire.VarintType)
+			msg = protowire.AppendVarint(msg, v)
+		}
+	}
+	appendString(recordRemoteIP, l.RemoteIP)
+	appendVarint(recordRemotePort, uint64(l.RemotePort))
+	appendString(recordLocalIP, l.LocalIP)
+	appendVarint(recordLocalPort, uint64(l.LocalPort))
+	appendVarint(recordPID, uint64(l.ProcessID))
+	appendString(recordCmd, l.ProcessName)
+	if l.Dir == Remote2Local {
+		appendVarint(recordDir, recordDirIn)
+	} else {
+		appendVarint(recordDir, recordDirOut)
+	}
+	if !l.Timestamp.IsZero() {
+		appendVarint(recordTimestampNs, uint64(l.Timestamp.UnixNano()))
+	}
+	switch l.State {
+	case StateListen:
+		appendVarint(recordState, recordStateListen)
+	case StateEstablished:
+		appendVarint(recordState, recordStateEstablished)
+	}
+	appendString(recordContainer, l.ContainerID)
+	appendVarint(recordBytes, uint64(l.Bytes))
+	appendVarint(recordPackets, uint64(l.Packets))
+
+	buf = protowire.AppendVarint(buf, uint64(len(msg)))
+	return append(buf, msg...)
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	lines := []InputLine{
+		{Dir: Local2Remote, RemoteIP: "10.42.0.54", RemotePort: 5671, LocalIP: "10.42.0.55", LocalPort: 5672, ProcessID: 723736, ProcessName: "ncat"},
+		{
+			Dir: Remote2Local, RemoteIP: "10.42.0.55", RemotePort: 5672, LocalIP: "10.42.0.54", LocalPort: 5671, ProcessID: 722977, ProcessName: "tcp-echo",
+			Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 123, time.UTC), State: StateEstablished, ContainerID: "/kubepods/pod1/3f2a9c", Bytes: 1500, Packets: 3,
+		},
+		{Dir: Local2Remote, RemoteIP: "0.0.0.0", LocalIP: "fd00::54", LocalPort: 8080, ProcessID: 1, ProcessName: "nginx: master", State: StateListen},
+	}
+	var stream []byte
+	for _, l := range lines {
+		stream = appendRecord(stream, l)
+	}
+
+	var got []InputLine
+	for len(stream) > 0 {
+		size, n := protowire.ConsumeVarint(stream)
+		if n < 0 {
+			t.Fatalf("invalid size prefix: %v", protowire.ParseError(n))
+		}
+		l, err := decodeRecord(stream[n : n+int(size)])
+		if err != nil {
+			t.Fatalf("decodeRecord returned unexpected error: %v", err)
+		}
+		got = append(got, l)
+		stream = stream[n+int(size):]
+	}
+	if !reflect.DeepEqual(got, lines) {
+		t.Errorf("decoded %+v, want %+v", got, lines)
+	}
+
+	// the equivalent text line parses back to the same InputLine
+	for _, l := range lines {
+		if parsed, err := ParseLine(formatInputLine(l)); err != nil || parsed != l {
+			t.Errorf("ParseLine(%q) = %+v, %v; want %+v", formatInputLine(l), parsed, err, l)
+		}
+	}
+}
+
+func TestBuildModelBinaryInput(t *testing.T) {
+	// the records of the lines of sampleTrace build the same model as the lines
+	var text strings.Builder
+	var stream []byte
+	for _, line := range strings.Split(sampleTrace, "\n") {
+		if l, err := ParseLine(line); err == nil {
+			text.WriteString(line + "\n")
+			stream = appendRecord(stream, l)
+		}
+	}
+	want := mustBuildModel(t, text.String(), ModelOptions{})
+	got, err := BuildModel(bytes.NewReader(stream), ModelOptions{BinaryInput: true})
+	if err != nil {
+		t.Fatalf("BuildModel returned unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("the binary input built %+v, want %+v", got, want)
+	}
+
+	// an undecodable record is an invalid line, a truncated stream a read error
+	invalid := protowire.AppendVarint(nil, 2)
+	invalid = append(invalid, protowire.AppendTag(nil, recordDir, protowire.VarintType)...)
+	invalid = protowire.AppendVarint(invalid, 7)
+	withInvalid := append(append([]byte{}, invalid...), stream...)
+	got, err = BuildModel(bytes.NewReader(withInvalid), ModelOptions{BinaryInput: true})
+	if err != nil || got.Stats.LinesInvalid != 1 || len(got.Edges) != len(want.Edges) {
+		t.Errorf("got %+v, %v; want the invalid record to be skipped", got.Stats, err)
+	}
+	got, err = BuildModel(bytes.NewReader(stream[:len(stream)-1]), ModelOptions{BinaryInput: true})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("BuildModel of a truncated stream returned %v, want io.ErrUnexpectedEOF", err)
+	}
+	if len(got.Edges) == 0 {
+		t.Error("the records read before the truncation must be kept")
+	}
+	oversized := protowire.AppendVarint(nil, maxRecordSize+1)
+	if _, err := BuildModel(bytes.NewReader(oversized), ModelOptions{BinaryInput: true}); err == nil || !strings.Contains(err.Error(), "larger than the maximum") {
+		t.Errorf("BuildModel of an oversized record returned %v", err)
+	}
+}
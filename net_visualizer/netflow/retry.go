@@ -0,0 +1,51 @@
+package netflow
+
+import (
+	"errors"
+	"io"
+	"syscall"
+	"time"
+)
+
+// maxReadRetries is the number of consecutive transient errors tolerated by retryReader
+// before giving up on the input
+const maxReadRetries = 5
+
+// readRetryDelay is the wait before the first retry of a read, doubled at every retry
+var readRetryDelay = 10 * time.Millisecond
+
+// isTransient tells whether a read error is worth retrying: an interrupted system call,
+// a non-blocking input with no data yet, or any error telling it is temporary
+func isTransient(err error) bool {
+	var temporary interface{ Temporary() bool }
+	return errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN) ||
+		(errors.As(err, &temporary) && temporary.Temporary())
+}
+
+// retryReader retries the reads of an input failing with transient errors, e.g. a pipe
+// whose writer gets interrupted by a signal, up to maxReadRetries times in a row, so that
+// a live capture does not end at the first hiccup; EOF and the other errors are returned
+// as-is. A transient error along with some data is dropped, the data being returned.
+type retryReader struct {
+	r io.Reader
+}
+
+func (rr *retryReader) Read(p []byte) (int, error) {
+	delay := readRetryDelay
+	for retry := 1; ; retry++ {
+		n, err := rr.r.Read(p)
+		if err == nil || !isTransient(err) {
+			return n, err
+		}
+		if n > 0 {
+			// the data is consumed now, the next read will retry
+			return n, nil
+		}
+		if retry > maxReadRetries {
+			return 0, err
+		}
+		infof("retrying the read of the input after a transient error (%d/%d): %v", retry, maxReadRetries, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
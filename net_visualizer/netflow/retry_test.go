@@ -0,0 +1,72 @@
+package netflow
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// flakyReader fails with err, failures times in a row, before every read of r
+type flakyReader struct {
+	r        io.Reader
+	err      error
+	failures int
+	failed   int
+}
+
+func (f *flakyReader) Read(p []byte) (int, error) {
+	if f.failed < f.failures {
+		f.failed++
+		return 0, f.err
+	}
+	f.failed = 0
+	return f.r.Read(p)
+}
+
+func TestConsumeRetriesTransientErrors(t *testing.T) {
+	delay := readRetryDelay
+	readRetryDelay = 0
+	var info strings.Builder
+	InfoOutput = &info
+	t.Cleanup(func() {
+		readRetryDelay = delay
+		InfoOutput = io.Discard
+	})
+
+	// a few bytes per read, so that the reads in the middle of the input fail as well
+	r := &flakyReader{r: &chunkReader{r: strings.NewReader(tierTrace), size: 16}, err: fmt.Errorf("read stdin: %w", syscall.EINTR), failures: maxReadRetries}
+	b := NewBuilder(ModelOptions{})
+	if err := b.Consume(r, 0); err != nil {
+		t.Fatalf("Consume failed with transient errors: %v", err)
+	}
+	if len(b.model.Edges) != 2 {
+		t.Errorf("got %d edges, want the 2 edges of the whole input", len(b.model.Edges))
+	}
+	if !strings.Contains(info.String(), "retrying the read of the input after a transient error (1/5)") {
+		t.Errorf("the retries are not reported:\n%s", info.String())
+	}
+
+	// past maxReadRetries in a row, the error is returned
+	r = &flakyReader{r: strings.NewReader(tierTrace), err: syscall.EAGAIN, failures: maxReadRetries + 1}
+	if err := NewBuilder(ModelOptions{}).Consume(r, 0); !errors.Is(err, syscall.EAGAIN) {
+		t.Errorf("Consume = %v, want EAGAIN", err)
+	}
+	// the other errors are not retried
+	r = &flakyReader{r: strings.NewReader(tierTrace), err: io.ErrUnexpectedEOF, failures: 1}
+	if err := NewBuilder(ModelOptions{}).Consume(r, 0); !errors.Is(err, io.ErrUnexpectedEOF) || r.failed != 1 {
+		t.Errorf("Consume = %v after %d reads, want io.ErrUnexpectedEOF at once", err, r.failed)
+	}
+}
+
+// chunkReader reads at most size bytes at a time
+type chunkReader struct {
+	r    io.Reader
+	size int
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	return c.r.Read(p[:min(len(p), c.size)])
+}
@@ -0,0 +1,82 @@
+package netflow
+
+// EdgeRole tells which side of an edge acts as the client and which one as the server
+type EdgeRole int
+
+const (
+	RoleUnknown      EdgeRole = iota // neither endpoint is a known listener
+	RoleClientServer                 // the edge goes from the client to the listening server
+	RoleServerClient                 // the edge goes from the listening server to the client
+)
+
+func (r EdgeRole) String() string {
+	switch r {
+	case RoleClientServer:
+		return "client->server"
+	case RoleServerClient:
+		return "server->client"
+	default:
+		return "unknown"
+	}
+}
+
+// Endpoints returns the roles of the source and of the destination of the edge:
+// "client", "server" or, when neither endpoint is a known listener, "unknown"
+func (r EdgeRole) Endpoints() (src, dst string) {
+	switch r {
+	case RoleClientServer:
+		return "client", "server"
+	case RoleServerClient:
+		return "server", "client"
+	default:
+		return "unknown", "unknown"
+	}
+}
+
+// Role infers the client/server roles of the edge endpoints: the endpoint that was observed
+// accepting connections is the server. If both endpoints were, the edge direction (which
+// goes from the connection initiator to the acceptor) is trusted.
+func (m *Model) Role(e Edge) EdgeRole {
+	if _, ok := m.Listening[e.Dest]; ok {
+		return RoleClientServer
+	}
+	if _, ok := m.Listening[e.Source]; ok {
+		return RoleServerClient
+	}
+	return RoleUnknown
+}
+
+// ClientCounts returns the number of distinct remote endpoints connected to each
+// listening endpoint of the model
+func (m *Model) ClientCounts() map[ProcessEndpoint]int {
+	ret := make(map[ProcessEndpoint]int)
+	for e := range m.Edges {
+		// edges are unique per pair of endpoints: each one is a distinct client
+		if _, ok := m.Listening[e.Dest]; ok {
+			ret[e.Dest]++
+		}
+	}
+	return ret
+}
+
+// IdleListening returns the listening endpoints without any connection in the model,
+// e.g. the ones only reported in LISTEN state; the endpoints whose edges were left out
+// of a partial view of the model, e.g. by Neighborhood, are not idle
+func (m *Model) IdleListening() map[ProcessEndpoint]struct{} {
+	ret := make(map[ProcessEndpoint]struct{})
+	for ep := range m.Listening {
+		if _, ok := m.hidden[ep]; !ok {
+			ret[ep] = struct{}{}
+		}
+	}
+	for e := range m.Edges {
+		delete(ret, e.Source)
+		delete(ret, e.Dest)
+	}
+	for _, locals := range m.Dangling {
+		for local := range locals {
+			delete(ret, local)
+		}
+	}
+	return ret
+}
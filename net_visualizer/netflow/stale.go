@@ -0,0 +1,22 @@
+package netflow
+
+import "time"
+
+// DropStale returns the model without the edges last observed more than threshold before
+// the latest timestamp of the input; the nodes are shared with the receiver. Edges
+// without timestamps are kept: without timestamps in the input, nothing is dropped.
+func (m *Model) DropStale(threshold time.Duration) *Model {
+	ret := newModel()
+	ret.Stats = m.Stats
+	ret.Latest = m.Latest
+	ret.Nodes = m.Nodes
+	ret.Listening = m.Listening
+	ret.Dangling = m.Dangling
+	cutoff := m.Latest.Add(-threshold)
+	for e, info := range m.Edges {
+		if info.LastSeen.IsZero() || !info.LastSeen.Before(cutoff) {
+			ret.Edges[e] = info
+		}
+	}
+	return ret
+}
@@ -0,0 +1,43 @@
+package netflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestModelDropStale(t *testing.T) {
+	// the connection to tcp-echo is last seen at 10:00:05, the one to PID 723429 at 10:09:00,
+	// the latest timestamp being 10:10:00
+	trace := `10.42.0.54:5671<-10.42.0.55:5672|PID=723736 TS=2024-05-01T10:00:00Z CMD=ncat
+10.42.0.55:5672->10.42.0.54:5671|PID=722977 TS=2024-05-01T10:00:05Z CMD=tcp-echo
+10.42.0.58:5673<-10.42.0.56:5674|PID=723715 TS=2024-05-01T10:08:00Z CMD=ncat
+10.42.0.56:5674->10.42.0.58:5673|PID=723429 TS=2024-05-01T10:09:00Z CMD=ncat
+10.42.0.99:443<-10.42.0.56:5690|PID=723715 TS=2024-05-01T10:10:00Z CMD=ncat
+`
+	model := mustBuildModel(t, trace, ModelOptions{})
+	if want := time.Date(2024, 5, 1, 10, 10, 0, 0, time.UTC); !model.Latest.Equal(want) {
+		t.Errorf("Latest = %s, want %s", model.Latest, want)
+	}
+
+	fresh := model.DropStale(5 * time.Minute)
+	if len(fresh.Edges) != 1 {
+		t.Fatalf("got %d edges, want 1", len(fresh.Edges))
+	}
+	for e, info := range fresh.Edges {
+		if e.Dest.PID != 723429 || !info.LastSeen.Equal(time.Date(2024, 5, 1, 10, 9, 0, 0, time.UTC)) {
+			t.Errorf("kept edge %+v last seen %s, want the one towards 723429", e, info.LastSeen)
+		}
+	}
+	if len(fresh.Nodes) != len(model.Nodes) || len(model.Edges) != 2 {
+		t.Errorf("DropStale must only drop edges, and leave the receiver untouched")
+	}
+	if got := model.DropStale(time.Hour); len(got.Edges) != 2 {
+		t.Errorf("got %d edges with a 1h threshold, want 2", len(got.Edges))
+	}
+
+	// without timestamps, nothing is dropped
+	model = mustBuildModel(t, sampleTrace, ModelOptions{})
+	if got := model.DropStale(time.Nanosecond); len(got.Edges) != len(model.Edges) {
+		t.Errorf("got %d edges, want all the %d edges of a trace without timestamps", len(got.Edges), len(model.Edges))
+	}
+}
@@ -0,0 +1,92 @@
+package netflow
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// statsTopPorts is the number of destination ports in the histogram of PrintStats
+const statsTopPorts = 10
+
+// statsBarWidth is the length of the bar of the most used port in that histogram
+const statsBarWidth = 40
+
+// Stats collects counters about the processing of the input, printed with -stats
+type Stats struct {
+	LinesRead           int // total number of input lines
+	LinesInvalid        int // lines that could not be parsed
+	LinesFiltered       int // parsed lines dropped by IsValidLine
+	LinesExcluded       int // parsed lines dropped because of FilterOptions.ExcludePIDs/ExcludeProcesses
+	LinesSampledOut     int // parsed lines dropped because of ModelOptions.Sample
+	LinesDeduplicated   int // lines not counted because of ModelOptions.DedupWindow
+	SelfEdgesSuppressed int // distinct edges from a process to itself, dropped unless ModelOptions.AllowSelfEdges
+	DirectionsRepaired  int // distinct edges reported in the direction opposite to their listening port
+	EvictedNodes        int // nodes dropped to honour ModelOptions.MaxTrackedNodes
+	EvictedEdges        int // edges dropped to honour ModelOptions.MaxTrackedEdges
+	TruncatedNodes      int // distinct processes dropped to honour ModelOptions.MaxNodes
+	TruncatedEdges      int // distinct edges dropped to honour ModelOptions.MaxEdges
+}
+
+// PrintStats writes a human-readable summary of the processing stats and of the model size
+func (m *Model) PrintStats(w io.Writer) {
+	fmt.Fprintf(w, "Lines read:             %d\n", m.Stats.LinesRead)
+	fmt.Fprintf(w, "Lines invalid:          %d\n", m.Stats.LinesInvalid)
+	fmt.Fprintf(w, "Lines filtered:         %d\n", m.Stats.LinesFiltered)
+	fmt.Fprintf(w, "Lines excluded:         %d\n", m.Stats.LinesExcluded)
+	fmt.Fprintf(w, "Lines sampled out:      %d\n", m.Stats.LinesSampledOut)
+	fmt.Fprintf(w, "Lines deduplicated:     %d\n", m.Stats.LinesDeduplicated)
+	fmt.Fprintf(w, "Self-edges suppressed:  %d\n", m.Stats.SelfEdgesSuppressed)
+	fmt.Fprintf(w, "Directions repaired:    %d\n", m.Stats.DirectionsRepaired)
+	fmt.Fprintf(w, "Nodes evicted:          %d\n", m.Stats.EvictedNodes)
+	fmt.Fprintf(w, "Edges evicted:          %d\n", m.Stats.EvictedEdges)
+	fmt.Fprintf(w, "Nodes truncated:        %d\n", m.Stats.TruncatedNodes)
+	fmt.Fprintf(w, "Edges truncated:        %d\n", m.Stats.TruncatedEdges)
+	fmt.Fprintf(w, "Nodes:                  %d\n", len(m.Nodes))
+	if len(m.Edges) == 0 && m.Stats.LinesRead > 0 {
+		fmt.Fprintf(w, "Edges:                  0 (no connection could be drawn)\n")
+	} else {
+		fmt.Fprintf(w, "Edges:                  %d\n", len(m.Edges))
+	}
+	fmt.Fprintf(w, "Dangling connections:   %d\n", m.NumDangling())
+
+	ports := m.PortHistogram()
+	if len(ports) == 0 {
+		return
+	}
+	if len(ports) > statsTopPorts {
+		fmt.Fprintf(w, "Top %d destination ports (connections), out of %d:\n", statsTopPorts, len(ports))
+		ports = ports[:statsTopPorts]
+	} else {
+		fmt.Fprintf(w, "Destination ports (connections):\n")
+	}
+	for _, p := range ports {
+		bar := max(1, p.Connections*statsBarWidth/ports[0].Connections)
+		fmt.Fprintf(w, "  %5d %8d %s\n", p.Port, p.Connections, strings.Repeat("#", bar))
+	}
+}
+
+// PortCount is the number of connections observed towards a destination port
+type PortCount struct {
+	Port        int
+	Connections int // sum of the EdgeInfo.Count of the edges towards the port
+}
+
+// PortHistogram returns the number of connections towards each destination port, the
+// most used first, ties broken by port number
+func (m *Model) PortHistogram() []PortCount {
+	counts := make(map[int]int)
+	for e, info := range m.Edges {
+		counts[e.Dest.Port] += info.Count
+	}
+	ret := make([]PortCount, 0, len(counts))
+	for port, n := range counts {
+		ret = append(ret, PortCount{Port: port, Connections: n})
+	}
+	slices.SortFunc(ret, func(a, b PortCount) int {
+		return cmp.Or(cmp.Compare(b.Connections, a.Connections), cmp.Compare(a.Port, b.Port))
+	})
+	return ret
+}
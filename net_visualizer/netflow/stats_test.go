@@ -0,0 +1,44 @@
+package netflow
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestModelPortHistogram(t *testing.T) {
+	// ties are broken by port number
+	model := mustBuildModel(t, sampleTrace, ModelOptions{})
+	want := []PortCount{{Port: 5671, Connections: 3}, {Port: 5673, Connections: 3}}
+	if got := model.PortHistogram(); !slices.Equal(got, want) {
+		t.Errorf("PortHistogram = %+v, want %+v", got, want)
+	}
+
+	// the connection towards port 1000+i is observed by 2*i+1 correlated lines, the first
+	// one being dangling: 1011 is the most used port
+	var trace strings.Builder
+	for i := range 12 {
+		for range i + 1 {
+			fmt.Fprintf(&trace, "10.0.0.1:%d<-10.0.0.2:40000|PID=1 CMD=client\n", 1000+i)
+			fmt.Fprintf(&trace, "10.0.0.2:40000->10.0.0.1:%d|PID=2 CMD=server\n", 1000+i)
+		}
+	}
+	model = mustBuildModel(t, trace.String(), ModelOptions{})
+	var out strings.Builder
+	model.PrintStats(&out)
+	_, histogram, found := strings.Cut(out.String(), "Top 10 destination ports (connections), out of 12:\n")
+	if !found {
+		t.Fatalf("no histogram in the stats:\n%s", out.String())
+	}
+	lines := strings.Split(strings.TrimSuffix(histogram, "\n"), "\n")
+	if len(lines) != 10 {
+		t.Fatalf("got %d ports in the histogram, want 10:\n%s", len(lines), histogram)
+	}
+	if first := strings.Fields(lines[0]); first[0] != "1011" || first[1] != "23" || len(first[2]) != statsBarWidth {
+		t.Errorf("first line of the histogram is %q, want port 1011 with 23 connections and the longest bar", lines[0])
+	}
+	if last := strings.Fields(lines[9]); last[0] != "1002" {
+		t.Errorf("last line of the histogram is %q, want port 1002", lines[9])
+	}
+}
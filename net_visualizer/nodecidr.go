@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// NodeCIDR associates a cluster node with a CIDR containing the IPs of the pods it hosts
+type NodeCIDR struct {
+	Name string
+	Net  *net.IPNet
+}
+
+// NodeCIDRs is the list of -node-cidr mappings; it implements flag.Value so that the
+// flag can be repeated
+type NodeCIDRs []NodeCIDR
+
+func (n *NodeCIDRs) String() string {
+	if n == nil {
+		return ""
+	}
+	ret := make([]string, 0, len(*n))
+	for _, m := range *n {
+		ret = append(ret, m.Name+"="+m.Net.String())
+	}
+	return strings.Join(ret, ",")
+}
+
+// Set parses a "name=CIDR" mapping
+func (n *NodeCIDRs) Set(value string) error {
+	name, cidr, found := strings.Cut(value, "=")
+	if !found || name == "" {
+		return fmt.Errorf("expected <node-name>=<CIDR>, got %q", value)
+	}
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return err
+	}
+	*n = append(*n, NodeCIDR{Name: name, Net: ipNet})
+	return nil
+}
+
+// NodeOf returns the node whose CIDR contains the IP; the first matching mapping wins
+func (n NodeCIDRs) NodeOf(ip string) (string, bool) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "", false
+	}
+	for _, m := range n {
+		if m.Net.Contains(parsed) {
+			return m.Name, true
+		}
+	}
+	return "", false
+}
+
+// IsCrossNode tells whether the two IPs are known to live on different nodes.
+// When either IP does not belong to any mapped CIDR, the answer is false.
+func (n NodeCIDRs) IsCrossNode(ip1, ip2 string) bool {
+	node1, ok1 := n.NodeOf(ip1)
+	node2, ok2 := n.NodeOf(ip2)
+	return ok1 && ok2 && node1 != node2
+}
+
+// loadNodeMap reads a -node-map file: one "<IP or CIDR> <node-name>" mapping per line,
+// as in /etc/hosts; '#' starts a comment and blank lines are ignored. A plain IP maps
+// a single pod, a CIDR all the pods in it; the first matching line wins.
+func loadNodeMap(path string) (NodeCIDRs, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ret := NodeCIDRs{} // never nil, even for an empty file
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected <IP or CIDR> <node-name>, got %q", path, lineNum, strings.TrimSpace(line))
+		}
+		cidr := fields[0]
+		if ip := net.ParseIP(cidr); ip != nil {
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid IP or CIDR %q", path, lineNum, fields[0])
+		}
+		ret = append(ret, NodeCIDR{Name: fields[1], Net: ipNet})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ret, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestNodeCIDRs(t *testing.T) {
+	var cidrs NodeCIDRs
+	for _, v := range []string{"node-a=10.42.0.0/24", "node-b=10.42.1.0/24"} {
+		if err := cidrs.Set(v); err != nil {
+			t.Fatalf("Set(%q) returned unexpected error: %v", v, err)
+		}
+	}
+	if got := cidrs.String(); got != "node-a=10.42.0.0/24,node-b=10.42.1.0/24" {
+		t.Errorf("String() = %q", got)
+	}
+
+	if node, ok := cidrs.NodeOf("10.42.1.7"); !ok || node != "node-b" {
+		t.Errorf("NodeOf(10.42.1.7) = %q, %v; want node-b", node, ok)
+	}
+	if _, ok := cidrs.NodeOf("192.168.0.1"); ok {
+		t.Errorf("NodeOf(192.168.0.1) should not resolve")
+	}
+
+	tests := []struct {
+		ip1, ip2 string
+		want     bool
+	}{
+		{"10.42.0.5", "10.42.1.5", true},
+		{"10.42.0.5", "10.42.0.6", false},
+		{"10.42.0.5", "192.168.0.1", false},
+		{"not-an-ip", "10.42.1.5", false},
+	}
+	for _, tt := range tests {
+		if got := cidrs.IsCrossNode(tt.ip1, tt.ip2); got != tt.want {
+			t.Errorf("IsCrossNode(%s, %s) = %v, want %v", tt.ip1, tt.ip2, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"10.42.0.0/24", "=10.42.0.0/24", "node=10.42.0.0"} {
+		if err := cidrs.Set(bad); err == nil {
+			t.Errorf("Set(%q) expected an error", bad)
+		}
+	}
+}
+
+func TestRenderDOTCrossNodeEdges(t *testing.T) {
+	trace := `10.42.1.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat
+10.42.0.55:5672->10.42.1.54:5671|PID=722977 CMD=tcp-echo
+10.42.1.54:5671<-10.42.1.56:5672|PID=723737 CMD=ncat
+10.42.1.56:5672->10.42.1.54:5671|PID=722977 CMD=tcp-echo
+`
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+	var cidrs NodeCIDRs
+	cidrs.Set("node-a=10.42.0.0/24")
+	cidrs.Set("node-b=10.42.1.0/24")
+
+	out := renderDOT(model, RenderOptions{NodeCIDRs: cidrs}).String()
+	if got := strings.Count(out, `color="red"`); got != 1 {
+		t.Errorf("got %d highlighted edges, want 1:\n%s", got, out)
+	}
+	if !strings.Contains(out, `[color="red",label="10.42.0.55:5672->10.42.1.54:5671",style="bold"`) {
+		t.Errorf("cross-node edge is not highlighted:\n%s", out)
+	}
+
+	out = renderDOT(model, RenderOptions{}).String()
+	if strings.Contains(out, "red") {
+		t.Errorf("no edge should be highlighted without node CIDRs:\n%s", out)
+	}
+}
+
+func TestLoadNodeMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes")
+	content := `# pods of worker-1
+10.42.0.53 worker-1
+10.42.0.54	worker-1 # tcp-echo
+10.42.1.0/24 worker-2
+
+fd00::55 worker-3
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := loadNodeMap(path)
+	if err != nil {
+		t.Fatalf("loadNodeMap returned unexpected error: %v", err)
+	}
+	for ip, want := range map[string]string{"10.42.0.53": "worker-1", "10.42.0.54": "worker-1", "10.42.1.7": "worker-2", "fd00::55": "worker-3", "10.42.0.55": ""} {
+		if got, _ := nodes.NodeOf(ip); got != want {
+			t.Errorf("NodeOf(%s) = %q, want %q", ip, got, want)
+		}
+	}
+
+	for _, bad := range []string{"10.42.0.53\n", "10.42.0.53 worker-1 extra\n", "10.42.0.999 worker-1\n"} {
+		if err := os.WriteFile(path, []byte(bad), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadNodeMap(path); err == nil || !strings.Contains(err.Error(), path+":1:") {
+			t.Errorf("loadNodeMap(%q) returned %v, want an error pointing at the line", bad, err)
+		}
+	}
+}
+
+func TestRenderDOTNodeMap(t *testing.T) {
+	// tcp-echo alone on worker-1, the ncat processes on worker-2, but for 10.42.0.57 and
+	// 10.42.0.58, left unscheduled
+	var nodes NodeCIDRs
+	for _, m := range []string{"worker-1=10.42.0.54/32", "worker-2=10.42.0.52/31", "worker-2=10.42.0.55/32", "worker-2=10.42.0.56/32"} {
+		if err := nodes.Set(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	out := renderDOT(model, RenderOptions{GroupByPod: true, NodeMap: nodes, NoDegree: true}).String()
+
+	// every node cluster holds the pod clusters, that hold the processes; the labels of
+	// the clusters come after their subgraphs
+	type cluster struct {
+		label string
+		pods  []string
+	}
+	clusters := map[string][]string{}
+	var stack []*cluster
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "subgraph cluster_"):
+			stack = append(stack, &cluster{})
+		case strings.HasPrefix(line, "label="):
+			stack[len(stack)-1].label = strings.Trim(strings.TrimPrefix(line, "label="), `";`)
+		case line == "}" && len(stack) > 0:
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.pods = append(parent.pods, top.label)
+			} else {
+				clusters[top.label] = top.pods
+			}
+		}
+	}
+	want := map[string][]string{
+		"worker-1":    {"10.42.0.54"},
+		"worker-2":    {"10.42.0.53", "10.42.0.55", "10.42.0.56"},
+		"unscheduled": {"10.42.0.57", "10.42.0.58"},
+	}
+	for node, pods := range want {
+		got := slices.Sorted(slices.Values(clusters[node]))
+		if !slices.Equal(got, pods) {
+			t.Errorf("cluster %s holds the pods %v, want %v:\n%s", node, got, pods, out)
+		}
+	}
+	if len(clusters) != len(want) {
+		t.Errorf("got the node clusters %v, want %v", clusters, want)
+	}
+}
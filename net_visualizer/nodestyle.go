@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// NodeStyle is the Graphviz shape and color given to the processes whose name matches
+// the pattern; empty fields keep the default
+type NodeStyle struct {
+	Pattern *regexp.Regexp
+	Shape   string
+	Color   string
+}
+
+// NodeStyles is the list of -style rules; it implements flag.Value so that the flag can
+// be repeated
+type NodeStyles []NodeStyle
+
+func (s *NodeStyles) String() string {
+	if s == nil {
+		return ""
+	}
+	ret := make([]string, 0, len(*s))
+	for _, r := range *s {
+		ret = append(ret, r.Pattern.String()+"="+r.Shape+":"+r.Color)
+	}
+	return strings.Join(ret, ",")
+}
+
+// Set parses a "regex=shape:color" rule, where either the shape or the color can be
+// empty. The regex is split at the last '=', so that it can contain one.
+func (s *NodeStyles) Set(value string) error {
+	i := strings.LastIndex(value, "=")
+	if i <= 0 {
+		return fmt.Errorf("expected <regex>=<shape>:<color>, got %q", value)
+	}
+	shape, color, _ := strings.Cut(value[i+1:], ":")
+	if shape == "" && color == "" {
+		return fmt.Errorf("expected <regex>=<shape>:<color>, got %q", value)
+	}
+	re, err := regexp.Compile(value[:i])
+	if err != nil {
+		return err
+	}
+	*s = append(*s, NodeStyle{Pattern: re, Shape: shape, Color: color})
+	return nil
+}
+
+// Match returns the style of the process name; the first matching rule wins
+func (s NodeStyles) Match(name string) (NodeStyle, bool) {
+	for _, r := range s {
+		if r.Pattern.MatchString(name) {
+			return r, true
+		}
+	}
+	return NodeStyle{}, false
+}
+
+// Apply sets the attributes of the style on the node
+func (r NodeStyle) Apply(node *dotNode) {
+	if r.Shape != "" {
+		node.Attr("shape", r.Shape)
+	}
+	if r.Color != "" {
+		node.Attr("color", r.Color)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestNodeStyles(t *testing.T) {
+	var styles NodeStyles
+	for _, v := range []string{"^nginx=box:blue", "^envoy=:#ff0000", "^(ncat|nc)$=ellipse:", "^nginx-ingress=diamond:red"} {
+		if err := styles.Set(v); err != nil {
+			t.Fatalf("Set(%q) returned unexpected error: %v", v, err)
+		}
+	}
+	if got := styles.String(); got != "^nginx=box:blue,^envoy=:#ff0000,^(ncat|nc)$=ellipse:,^nginx-ingress=diamond:red" {
+		t.Errorf("String() = %q", got)
+	}
+
+	tests := []struct {
+		name         string
+		shape, color string
+		ok           bool
+	}{
+		{"nginx", "box", "blue", true},
+		{"nginx-ingress", "box", "blue", true}, // the first matching rule wins
+		{"envoy-sidecar", "", "#ff0000", true},
+		{"ncat", "ellipse", "", true},
+		{"tcp-echo", "", "", false},
+	}
+	for _, tt := range tests {
+		style, ok := styles.Match(tt.name)
+		if ok != tt.ok || style.Shape != tt.shape || style.Color != tt.color {
+			t.Errorf("Match(%q) = %+v, %v; want %s:%s, %v", tt.name, style, ok, tt.shape, tt.color, tt.ok)
+		}
+	}
+
+	for _, bad := range []string{"nginx", "=box:blue", "nginx=", "nginx=:", "(=box"} {
+		if err := styles.Set(bad); err == nil {
+			t.Errorf("Set(%q) expected an error", bad)
+		}
+	}
+}
+
+func TestRenderDOTNodeStyles(t *testing.T) {
+	var styles NodeStyles
+	if err := styles.Set("^tcp-echo$=box:blue"); err != nil {
+		t.Fatal(err)
+	}
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	out := renderDOT(model, RenderOptions{Styles: styles, NoDegree: true}).String()
+	if want := `[color="blue",label="PID=722977\nName=tcp-echo\nIP=10.42.0.54\nListen=5671",shape="box",tooltip=`; !strings.Contains(out, want) {
+		t.Errorf("DOT output does not contain %s:\n%s", want, out)
+	}
+	if strings.Count(out, `shape="box"`) != 1 {
+		t.Errorf("only the matching node should be styled:\n%s", out)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"net_visualizer/netflow"
+)
+
+// outputFormats lists the values accepted by -format
+var outputFormats = []string{"dot", "graphml", "json", "jsonl", "csv", "inventory", "svg", "png", "html"}
+
+// validateFormat checks the -format value before any input gets consumed
+func validateFormat(format string) error {
+	for _, f := range outputFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q; valid formats: %s", format, strings.Join(outputFormats, ", "))
+}
+
+// writeOutput serializes the model in the requested format
+func writeOutput(w io.Writer, format string, model *netflow.Model, opts RenderOptions) error {
+	switch format {
+	case "dot":
+		_, err := io.WriteString(w, renderDOT(model, opts).String())
+		return err
+	case "graphml":
+		return writeGraphML(w, model)
+	case "json":
+		return writeJSON(w, model)
+	case "jsonl":
+		return writeJSONL(w, model)
+	case "csv":
+		return writeCSV(w, model)
+	case "inventory":
+		return writeInventory(w, model)
+	case "svg", "png":
+		return writeImage(w, format, model, opts)
+	case "html":
+		return writeHTML(w, model, opts)
+	default:
+		return validateFormat(format)
+	}
+}
+
+// writeOutputFile serializes the model to the given path, atomically
+func writeOutputFile(path, format string, model *netflow.Model, opts RenderOptions) error {
+	return writeFileAtomic(path, func(w io.Writer) error {
+		return writeOutput(w, format, model, opts)
+	})
+}
+
+// writeFileAtomic writes the file through a temporary file that is then renamed over
+// path, so that a viewer watching it never observes a partially written graph
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op once renamed
+
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// componentPath returns the file the i-th component (1-based) is written to with
+// -split-components: the -o path with "-<i>" inserted before the extension, or
+// topo-<i>.<format> when writing to stdout
+func componentPath(output, format string, i int) string {
+	if output == "" {
+		return partPath("", format, fmt.Sprintf("topo-%d", i))
+	}
+	return partPath(output, format, strconv.Itoa(i))
+}
+
+// partPath returns the file a part of the graph is written to, when the output is split
+// in several files: the -o path with "-<part>" inserted before the extension, or
+// <part>.<format> when writing to stdout
+func partPath(output, format, part string) string {
+	if output == "" {
+		return part + "." + format
+	}
+	ext := filepath.Ext(output)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(output, ext), part, ext)
+}
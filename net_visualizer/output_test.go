@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestValidateFormat(t *testing.T) {
+	for _, f := range outputFormats {
+		if err := validateFormat(f); err != nil {
+			t.Errorf("validateFormat(%q) returned unexpected error: %v", f, err)
+		}
+	}
+	if err := validateFormat("pdf"); err == nil {
+		t.Errorf("validateFormat(pdf) expected an error")
+	}
+}
+
+func TestWriteOutputFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "graph.dot")
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	for range 2 {
+		// the second write replaces the first one
+		if err := writeOutputFile(path, "dot", model, RenderOptions{}); err != nil {
+			t.Fatalf("writeOutputFile returned unexpected error: %v", err)
+		}
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := renderDOT(model, RenderOptions{}).String(); string(got) != want {
+		t.Errorf("file content = %s, want %s", got, want)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestPartPath(t *testing.T) {
+	for _, tc := range []struct {
+		output, format, want string
+	}{
+		{"", "dot", "kube-system.dot"},
+		{"", "json", "kube-system.json"},
+		{"out/graph.dot", "dot", "out/graph-kube-system.dot"},
+		{"graph", "dot", "graph-kube-system"},
+	} {
+		if got := partPath(tc.output, tc.format, "kube-system"); got != tc.want {
+			t.Errorf("partPath(%q, %q, kube-system) = %q, want %q", tc.output, tc.format, got, tc.want)
+		}
+	}
+}
+
+func TestComponentPath(t *testing.T) {
+	for _, tc := range []struct {
+		output, format, want string
+	}{
+		{"", "dot", "topo-2.dot"},
+		{"", "json", "topo-2.json"},
+		{"out/graph.dot", "dot", "out/graph-2.dot"},
+		{"graph", "dot", "graph-2"},
+	} {
+		if got := componentPath(tc.output, tc.format, 2); got != tc.want {
+			t.Errorf("componentPath(%q, %q, 2) = %q, want %q", tc.output, tc.format, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import "net_visualizer/netflow"
+
+// podOptions gathers the steps of the emitted models that depend on the real IPs
+type podOptions struct {
+	Resolver     netflow.PodResolver // with -kube
+	BoundaryOnly bool
+	Boundary     netflow.Boundary
+	Anonymizer   *netflow.Anonymizer // with -anonymize
+}
+
+// apply attaches their pod to the processes and keeps the connections crossing the
+// boundary, before replacing the identifiers with placeholders: the pods are looked up
+// by the real IPs, and anonymized in turn
+func (opts podOptions) apply(model *netflow.Model) *netflow.Model {
+	if opts.Resolver != nil {
+		model.ResolvePods(opts.Resolver)
+	}
+	if opts.BoundaryOnly {
+		model = model.BoundaryOnly(opts.Boundary)
+	}
+	if opts.Anonymizer != nil {
+		model = opts.Anonymizer.Model(model)
+	}
+	return model
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestPodOptionsAnonymize(t *testing.T) {
+	resolver := netflow.StaticPodResolver{
+		"10.42.0.54": {Name: "tcp-echo-5d8f", Namespace: "default"},
+		"10.42.0.55": {Name: "traffic-sink-a", Namespace: "traffic"},
+	}
+	opts := podOptions{Resolver: resolver, BoundaryOnly: true, Boundary: netflow.NamespaceBoundary, Anonymizer: netflow.NewAnonymizer()}
+	model := opts.apply(mustBuildModel(t, sampleTrace, netflow.ModelOptions{}))
+
+	// the pods are resolved by the real IPs, then anonymized
+	var pods []string
+	for _, n := range model.SortedNodes() {
+		if strings.HasPrefix(n.LocalIP, "10.") {
+			t.Errorf("node %+v is not anonymized", n)
+		}
+		if n.Pod != nil {
+			pods = append(pods, n.Pod.String())
+		}
+	}
+	if want := "ns1/pod1 ns2/pod2"; strings.Join(pods, " ") != want {
+		t.Errorf("got the pods %v, want %s", pods, want)
+	}
+	// the edges towards default cross the boundary, from traffic and from an unknown pod;
+	// the ones of 10.42.0.58 stay in the "" namespace
+	if len(model.Edges) != 2 {
+		t.Errorf("got %d edges, want the 2 towards default: %v", len(model.Edges), model.SortedEdges())
+	}
+	out := renderDOT(model, RenderOptions{GroupByPod: true}).String()
+	if strings.Contains(out, "tcp-echo-5d8f") || !strings.Contains(out, `label="ns1/pod1"`) {
+		t.Errorf("the pod clusters should carry the anonymized pods:\n%s", out)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// portCellID returns the identifier of the cell of a listening port in a PortRecords
+// node label, that the edges towards the port attach to
+func portCellID(port int) string {
+	return fmt.Sprintf("p%d", port)
+}
+
+// portRecordLabel returns the HTML-like label of a process node drawn with PortRecords:
+// a table whose header holds the lines of the usual label and whose second row has a
+// cell per listening port, annotated with its clients and whether it is idle
+func portRecordLabel(header string, listening, idle []int, clients map[int]int) string {
+	lines := strings.Split(header, "\n")
+	for i, line := range lines {
+		lines[i] = html.EscapeString(line)
+	}
+	var b strings.Builder
+	b.WriteString(`<TABLE BORDER="0" CELLBORDER="1" CELLSPACING="0" CELLPADDING="4">`)
+	fmt.Fprintf(&b, `<TR><TD COLSPAN="%d">%s</TD></TR><TR>`, len(listening), strings.Join(lines, "<BR/>"))
+	for _, port := range listening {
+		text := strconv.Itoa(port)
+		if clients != nil {
+			text = portsWithClients([]int{port}, clients)
+		}
+		if slices.Contains(idle, port) {
+			text += " (idle)"
+		}
+		fmt.Fprintf(&b, `<TD PORT="%s">%s</TD>`, portCellID(port), html.EscapeString(text))
+	}
+	b.WriteString("</TR></TABLE>")
+	return b.String()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestRenderDOTPortRecords(t *testing.T) {
+	// ncat listens on 5673 and 9090, the latter without any connection
+	trace := sampleTrace + "0.0.0.0:0<-10.42.0.58:9090|PID=723429 STATE=LISTEN CMD=ncat\n"
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+	out := renderDOT(model, RenderOptions{PortRecords: true, NoDegree: true}).String()
+	for _, want := range []string{
+		`<TD COLSPAN="2">PID=723429<BR/>Name=ncat<BR/>IP=10.42.0.58</TD>`,
+		`<TD PORT="p5673">5673</TD><TD PORT="p9090">9090 (idle)</TD>`,
+		`headport="p5673"`,
+		`shape="plain"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	// the clients have no listening port: their label is the usual one
+	if !strings.Contains(out, `label="PID=723715\nName=ncat\nIP=10.42.0.56"`) {
+		t.Errorf("the clients must keep their usual label:\n%s", out)
+	}
+	if strings.Contains(out, "Listen=") {
+		t.Errorf("the listening ports must only appear as cells:\n%s", out)
+	}
+}
+
+func TestPortRecordLabelEscapesHTML(t *testing.T) {
+	got := portRecordLabel("Name=<a&b>", []int{80}, nil, map[int]int{80: 2})
+	want := `<TABLE BORDER="0" CELLBORDER="1" CELLSPACING="0" CELLPADDING="4"><TR><TD COLSPAN="1">Name=&lt;a&amp;b&gt;</TD></TR><TR><TD PORT="p80">80 (2 clients)</TD></TR></TABLE>`
+	if got != want {
+		t.Errorf("portRecordLabel = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,541 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"net_visualizer/netflow"
+)
+
+// RenderOptions controls how the model is turned into labels and attributes
+type RenderOptions struct {
+	Services       ServiceNames // when non-nil, ports with a known service name are annotated in edge labels
+	ServiceLabels  ServiceNames // when non-nil, destination ports with a known service name are replaced by it in edge labels
+	GroupByPod     bool         // draw the processes sharing the same container, or LocalIP, inside a common cluster
+	NoDegree       bool         // omit the fan-in/fan-out counters from node labels
+	NoPortsInLabel bool         // omit the listening ports from node labels
+	ClientCounts   bool         // annotate the listening ports in node labels with their number of distinct clients
+	FullLabels     bool         // always show IPs in edge labels, even when both endpoints share the same IP
+	ShowRoles      bool         // append the client/server roles of the endpoints to edge labels
+	MaxNameLen     int          // truncate process names longer than this many characters in node labels (0 = no limit)
+	ShowExternal   bool         // draw placeholder nodes for the remote endpoints never observed locally
+	Legend         bool         // add a cluster explaining the colors and styles in use
+	NoWeight       bool         // draw all the edges with the same width, whatever their count
+	WeightBy       string       // metric driving the edge widths: count (the default, also when empty), bytes or packets; the latter two also appear in edge labels
+
+	// DirectionCounts appends to edge labels the number of observations from the client
+	// side (fwd) and from the server side (rev), flagging the asymmetric edges
+	DirectionCounts bool
+
+	// PortRecords draws each process with listening ports as a table, with a cell per
+	// port that the edges towards it attach to, instead of listing them in the label
+	PortRecords bool
+
+	// EdgeLabelTemplate, when non-nil, produces the edge labels instead of edgeLabel,
+	// out of an edgeLabelData
+	EdgeLabelTemplate *template.Template
+
+	// ClusterLabelTemplate, when non-nil, produces the labels of the GroupByPod clusters
+	// instead of podClusterLabel, out of a clusterLabelData
+	ClusterLabelTemplate *template.Template
+
+	// ColorBySource fills each node with the color of the input(s) it was observed in;
+	// SourceNames lists the inputs, for the node tooltips
+	ColorBySource bool
+	SourceNames   []string
+
+	// ColorByName fills each node with a color derived from its process name, the same
+	// across runs; it cannot be combined with ColorBySource
+	ColorByName bool
+
+	// SizeByDegree enlarges the nodes after their number of edges, see nodeFontSize
+	SizeByDegree bool
+
+	// Styles sets the shape and color of the nodes after their process name
+	Styles NodeStyles
+
+	// NodeCIDRs maps IPs to cluster nodes: edges crossing node boundaries are highlighted
+	NodeCIDRs NodeCIDRs
+
+	// NodeMap, with GroupByPod, draws the pod clusters inside an outer cluster per cluster
+	// node, after the IP of the pod; unmapped pods go in the unscheduledCluster
+	NodeMap NodeCIDRs
+
+	// CollapseExternal merges the placeholder nodes drawn by ShowExternal into a single
+	// INTERNET node, or into one node per ExternalCIDRs bucket
+	CollapseExternal bool
+	ExternalCIDRs    NodeCIDRs
+
+	// ClusterClasses tags the GroupByPod clusters, and the nodes and edges inside them, with
+	// classes naming their cluster, carried over to the SVG output for the HTML viewer:
+	// "pod-<i>" for the i-th cluster, "in-pod-<i>" for its nodes, "from-pod-<i>" and
+	// "to-pod-<j>" for the edges
+	ClusterClasses bool
+
+	// Bidirectional draws the edges observed from both of their ends, i.e. from the client
+	// and from the server, with an arrowhead at both ends
+	Bidirectional bool
+
+	// EdgeAttrs attaches the metadata of each edge as custom attributes, for the tools
+	// processing the DOT output: dst_port, protocol and count, plus src_role and dst_role
+	// with ShowRoles
+	EdgeAttrs bool
+
+	// Header, when non-nil, is drawn as the label of the graph
+	Header *GraphHeader
+
+	// RankDir and Layout set the Graphviz "rankdir" and "layout" graph attributes;
+	// empty means the Graphviz defaults (top-down, dot)
+	RankDir string
+	Layout  string
+}
+
+var (
+	validRankDirs = []string{"TB", "LR", "BT", "RL"}
+	validLayouts  = []string{"dot", "neato", "fdp", "sfdp", "circo"}
+)
+
+// Validate checks the options that come from free-form flags
+func (opts RenderOptions) Validate() error {
+	if opts.RankDir != "" && !slices.Contains(validRankDirs, opts.RankDir) {
+		return fmt.Errorf("invalid rankdir %q; valid values: %s", opts.RankDir, strings.Join(validRankDirs, ", "))
+	}
+	if opts.Layout != "" && !slices.Contains(validLayouts, opts.Layout) {
+		return fmt.Errorf("invalid layout %q; valid values: %s", opts.Layout, strings.Join(validLayouts, ", "))
+	}
+	if opts.WeightBy != "" && !slices.Contains(validWeightMetrics, opts.WeightBy) {
+		return fmt.Errorf("invalid weight metric %q; valid values: %s", opts.WeightBy, strings.Join(validWeightMetrics, ", "))
+	}
+	if opts.MaxNameLen < 0 {
+		return fmt.Errorf("invalid max name length %d", opts.MaxNameLen)
+	}
+	return nil
+}
+
+// sourcePalette holds the fill colors used by ColorBySource (ColorBrewer "Set3")
+var sourcePalette = []string{
+	"#8dd3c7", "#ffffb3", "#bebada", "#fb8072", "#80b1d3", "#fdb462",
+	"#b3de69", "#fccde5", "#d9d9d9", "#bc80bd", "#ccebc5", "#ffed6f",
+}
+
+// colorBySource styles the node after the inputs it was observed in: nodes seen in
+// several inputs are drawn as wedges, one color per input. The returned tooltip line
+// lists the inputs.
+func colorBySource(node *dotNode, n *netflow.ProcessEndpoints, opts RenderOptions) string {
+	colors := make([]string, 0, len(n.Sources))
+	names := make([]string, 0, len(n.Sources))
+	for _, src := range n.Sources {
+		colors = append(colors, sourcePalette[src%len(sourcePalette)])
+		if src < len(opts.SourceNames) {
+			names = append(names, opts.SourceNames[src])
+		}
+	}
+	style := "filled"
+	if len(colors) > 1 {
+		style = "wedged"
+	}
+	node.Attr("style", style)
+	node.Attr("fillcolor", strings.Join(colors, ":"))
+	if len(names) == 0 {
+		return ""
+	}
+	return "observed in: " + strings.Join(names, ", ")
+}
+
+// truncateName shortens the name to at most maxLen characters, the last one being an
+// ellipsis; multibyte characters are never split. maxLen <= 0 means no limit.
+func truncateName(name string, maxLen int) (string, bool) {
+	if maxLen <= 0 || utf8.RuneCountInString(name) <= maxLen {
+		return name, false
+	}
+	runes := []rune(name)
+	return string(runes[:maxLen-1]) + "…", true
+}
+
+// nodeLabel returns the human-readable label of a process node. The label is quoted by
+// dotGraph, which escapes quotes and backslashes: names containing them, e.g. a
+// literal "\n", are rendered verbatim. Only the listening ports are shown, ephemeral
+// client ports would just clutter the label; the idle ones, without any connection, are
+// repeated on their own line. The synthetic nodes of grouped ranges show their CIDR.
+func nodeLabel(n *netflow.ProcessEndpoints, listening, idle []int, clients map[int]int, maxNameLen int) string {
+	name, _ := truncateName(n.ProcessName, maxNameLen)
+	pid := strconv.FormatInt(n.PID(), 10)
+	if len(n.Forks) > 1 {
+		pid += fmt.Sprintf(" (+%d forks)", len(n.Forks)-1)
+	}
+	var label string
+	if n.CIDR != "" {
+		label = fmt.Sprintf("%s\nCIDR=%s", name, n.CIDR)
+	} else if n.Pod != nil {
+		label = fmt.Sprintf("PID=%s\nName=%s\nPod=%s", pid, name, n.Pod)
+	} else {
+		label = fmt.Sprintf("PID=%s\nName=%s\nIP=%s", pid, name, n.LocalIP)
+	}
+	if len(listening) > 0 && clients != nil {
+		label += "\nListen=" + portsWithClients(listening, clients)
+	} else if len(listening) > 0 {
+		label += "\nListen=" + compressPorts(listening)
+	}
+	if len(idle) > 0 {
+		label += "\nIdle=" + compressPorts(idle)
+	}
+	return label
+}
+
+// portsWithClients formats the sorted ports with their number of clients, e.g.
+// "8080 (37 clients), 9090 (1 client)"
+func portsWithClients(ports []int, clients map[int]int) string {
+	ret := make([]string, 0, len(ports))
+	for _, port := range ports {
+		noun := "clients"
+		if clients[port] == 1 {
+			noun = "client"
+		}
+		ret = append(ret, fmt.Sprintf("%d (%d %s)", port, clients[port], noun))
+	}
+	return strings.Join(ret, ", ")
+}
+
+// nodeTooltip returns the lines of the tooltip of a process node, shown on hover in the
+// SVG output: the full metadata of the process, even when the label abbreviates or omits it
+func nodeTooltip(model *netflow.Model, n *netflow.ProcessEndpoints) []string {
+	var lines []string
+	if n.CIDR != "" {
+		lines = append(lines, "Group="+n.ProcessName, "CIDR="+n.CIDR)
+	} else {
+		lines = append(lines, "PID="+strconv.FormatInt(n.PID(), 10))
+		if len(n.Forks) > 1 {
+			lines = append(lines, "PIDs: "+joinPIDs(n.Forks))
+		}
+		lines = append(lines, "Command="+n.ProcessName, "IP="+n.LocalIP)
+		if n.ContainerID != "" {
+			lines = append(lines, "Container="+n.ContainerID)
+		}
+		if n.Pod != nil {
+			lines = append(lines, "Pod="+n.Pod.String())
+		}
+	}
+	ports := "Ports=" + compressPorts(slices.Sorted(slices.Values(n.LocalPorts)))
+	if listening := model.ListeningPorts(n); len(listening) > 0 {
+		ports += " (listening: " + compressPorts(listening) + ")"
+	}
+	return append(lines, ports)
+}
+
+// edgeTooltip returns the tooltip of an edge: the full endpoint pair, whatever the
+// label shows, the number of times the connection was observed and its traffic volume,
+// if reported by the tracer
+func edgeTooltip(sourceNode, destNode *netflow.ProcessEndpoints, edge netflow.Edge, info *netflow.EdgeInfo) string {
+	ret := fmt.Sprintf("%s:%d -> %s:%d\nCount=%d", sourceNode.LocalIP, edge.Source.Port, destNode.LocalIP, edge.Dest.Port, info.Count)
+	if info.Bytes > 0 || info.Packets > 0 {
+		ret += fmt.Sprintf("\nBytes=%d Packets=%d", info.Bytes, info.Packets)
+	}
+	return ret
+}
+
+// joinPIDs formats the PIDs as a comma-separated list
+func joinPIDs(pids []int64) string {
+	ret := make([]string, 0, len(pids))
+	for _, pid := range pids {
+		ret = append(ret, strconv.FormatInt(pid, 10))
+	}
+	return strings.Join(ret, ", ")
+}
+
+// compressPorts formats the sorted ports as a comma-separated list, where runs of
+// contiguous ports are collapsed to ranges, e.g. "8080-8082,9090"
+func compressPorts(ports []int) string {
+	var ret []string
+	for i := 0; i < len(ports); {
+		j := i
+		for j+1 < len(ports) && ports[j+1] == ports[j]+1 {
+			j++
+		}
+		if j == i {
+			ret = append(ret, strconv.Itoa(ports[i]))
+		} else {
+			ret = append(ret, fmt.Sprintf("%d-%d", ports[i], ports[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ret, ",")
+}
+
+// edgeLabel returns the human-readable label of an edge. Unless FullLabels is set, intra-pod
+// edges omit the IP, shared by both endpoints, and only show the ports.
+func edgeLabel(sourceNode, destNode *netflow.ProcessEndpoints, edge netflow.Edge, opts RenderOptions) string {
+	srcPort := opts.Services.PortLabel(edge.Source.Port)
+	dstPort := opts.dstPortLabel(edge.Dest.Port)
+	if sourceNode.LocalIP == destNode.LocalIP && !opts.FullLabels {
+		return fmt.Sprintf("%s->%s", srcPort, dstPort)
+	}
+	return fmt.Sprintf("%s:%s->%s:%s", sourceNode.LocalIP, srcPort, destNode.LocalIP, dstPort)
+}
+
+// dstPortLabel formats the destination port of an edge: its ServiceLabels name, if any,
+// else the port annotated after Services
+func (opts RenderOptions) dstPortLabel(port int) string {
+	if name, ok := opts.ServiceLabels[port]; ok {
+		return name
+	}
+	return opts.Services.PortLabel(port)
+}
+
+// maxPenWidth caps the width of the most observed edges
+const maxPenWidth = 5
+
+// edgeWidth returns the Graphviz penwidth of an edge observed count times, growing with
+// the logarithm of the count, or "" for the default width of edges observed once
+func edgeWidth(count int) string {
+	if count <= 1 {
+		return ""
+	}
+	width := min(1+math.Log(float64(count)), maxPenWidth)
+	return strconv.FormatFloat(math.Round(width*100)/100, 'f', -1, 64)
+}
+
+// unscheduledCluster is the label of the NodeMap cluster of the pods mapped to no node
+const unscheduledCluster = "unscheduled"
+
+// podClusterLabel returns the label of the cluster grouping all the processes of a pod,
+// or of a container when the pod is unknown
+func podClusterLabel(n *netflow.ProcessEndpoints) string {
+	if n.Pod != nil {
+		return n.Pod.String()
+	}
+	return n.Group()
+}
+
+// renderDOT converts the model into a DOT graph. Nodes and edges are created in the
+// stable order given by the model, so that the output is byte-identical across runs.
+func renderDOT(model *netflow.Model, opts RenderOptions) *dotGraph {
+	graph := newDOTGraph()
+	if opts.RankDir != "" {
+		graph.Attr("rankdir", opts.RankDir)
+	}
+	if opts.Layout != "" {
+		graph.Attr("layout", opts.Layout)
+	}
+	if opts.Header != nil {
+		graph.Attr("label", dotString(opts.Header.Label()))
+		graph.Attr("labelloc", "t")
+		graph.Attr("labeljust", "l")
+	}
+
+	degrees := model.Degrees()
+	idleListening := model.IdleListening()
+	used := newLegendUsage()
+	var clientCounts map[netflow.ProcessEndpoint]int
+	if opts.ClientCounts {
+		clientCounts = model.ClientCounts()
+	}
+	maxVolume := maxEdgeVolume(model, opts.WeightBy)
+	dotNodes := make(map[int64]*dotNode, len(model.Nodes))
+	clusters := make(map[int64]int) // PID -> 1-based index of its cluster, with ClusterClasses
+	clusterIndexes := make(map[string]int)
+	var clusterSizes map[string]int
+	if opts.GroupByPod && opts.ClusterLabelTemplate != nil {
+		clusterSizes = make(map[string]int)
+		for _, n := range model.Nodes {
+			clusterSizes[podClusterLabel(n)]++
+		}
+	}
+	for _, n := range model.SortedNodes() {
+		parent := graph
+		if opts.GroupByPod {
+			clusterLabel := podClusterLabel(n)
+			if opts.NodeMap != nil {
+				nodeName, ok := opts.NodeMap.NodeOf(n.LocalIP)
+				if !ok {
+					nodeName = unscheduledCluster
+				}
+				parent = graph.Cluster(dotString(nodeName))
+			}
+			parent = parent.Cluster(dotString(clusterLabel))
+			if opts.ClusterLabelTemplate != nil {
+				// the cluster keeps its identity, only its label changes
+				parent.Attr("label", dotString(templateClusterLabel(n, clusterSizes[clusterLabel], opts)))
+			}
+			if opts.ClusterClasses {
+				idx, ok := clusterIndexes[clusterLabel]
+				if !ok {
+					idx = len(clusterIndexes) + 1
+					clusterIndexes[clusterLabel] = idx
+					parent.Attr("class", fmt.Sprintf("pod-%d", idx))
+				}
+				clusters[n.ProcessID] = idx
+			}
+		}
+		var listening, idle []int
+		if !opts.NoPortsInLabel {
+			listening = model.ListeningPorts(n)
+		}
+		if n.Collapsed == 0 {
+			// the edges of collapsed nodes are gone, not their connections
+			for _, port := range listening {
+				if _, ok := idleListening[netflow.ProcessEndpoint{PID: n.ProcessID, Port: port}]; ok {
+					idle = append(idle, port)
+				}
+			}
+		}
+		var clients map[int]int
+		if opts.ClientCounts {
+			clients = make(map[int]int, len(listening))
+			for _, port := range listening {
+				clients[port] = clientCounts[netflow.ProcessEndpoint{PID: n.ProcessID, Port: port}]
+			}
+		}
+		portRecords := opts.PortRecords && len(listening) > 0
+		var label string
+		if portRecords {
+			label = nodeLabel(n, nil, nil, nil, opts.MaxNameLen)
+		} else {
+			label = nodeLabel(n, listening, idle, clients, opts.MaxNameLen)
+		}
+		if n.Collapsed > 0 {
+			label += fmt.Sprintf("\n(%d connections, collapsed)", n.Collapsed)
+		} else if !opts.NoDegree {
+			label += fmt.Sprintf("\nin=%d out=%d", degrees[n.ProcessID].In, degrees[n.ProcessID].Out)
+		}
+		// the ID is the identity of the process, not its label: processes with the same
+		// label must not collapse into a single node
+		node := parent.Node(processNodeID(n.ProcessID))
+		if portRecords {
+			node.HTMLLabel(portRecordLabel(dotString(label), listening, idle, clients)).Attr("shape", "plain")
+		} else {
+			node.Label(dotString(label))
+		}
+		dotNodes[n.ProcessID] = node
+		if idx, ok := clusters[n.ProcessID]; ok {
+			node.Attr("class", fmt.Sprintf("in-pod-%d", idx))
+		}
+		if n.CIDR != "" {
+			node.Attr("shape", "box3d")
+		}
+		if opts.SizeByDegree {
+			if size := nodeFontSize(degrees[n.ProcessID].In + degrees[n.ProcessID].Out); size != "" {
+				node.Attr("fontsize", size)
+			}
+		}
+		if style, ok := opts.Styles.Match(n.ProcessName); ok {
+			style.Apply(node)
+			used.styles[style.Pattern.String()] = struct{}{}
+		}
+
+		if opts.ColorByName {
+			colorByName(node, n.ProcessName)
+		}
+		tooltip := nodeTooltip(model, n)
+		if opts.ColorBySource {
+			if line := colorBySource(node, n, opts); line != "" {
+				tooltip = append(tooltip, line)
+			}
+			for _, src := range n.Sources {
+				used.sources[src] = struct{}{}
+			}
+		}
+		node.Attr("tooltip", dotString(strings.Join(tooltip, "\n")))
+	}
+
+	for _, edge := range model.SortedEdges() {
+		sourceNode := model.Nodes[edge.Source.PID]
+		destNode := model.Nodes[edge.Dest.PID]
+
+		label := edgeLabel(sourceNode, destNode, edge, opts)
+		if opts.EdgeLabelTemplate != nil {
+			label = templateEdgeLabel(model, sourceNode, destNode, edge, opts)
+		}
+		info := model.Edges[edge]
+		volume := edgeVolume(info, opts.WeightBy)
+		if volume >= 0 {
+			label += fmt.Sprintf(" (%s)", formatVolume(volume, opts.WeightBy))
+		}
+		if info.ViaSidecar {
+			label += " (via sidecar)"
+		}
+		if opts.DirectionCounts {
+			label += directionCountsLabel(info)
+		}
+		if opts.ShowRoles {
+			label += fmt.Sprintf(" [%s]", model.Role(edge))
+		}
+		graphEdge := dotNodes[edge.Source.PID].Edge(dotNodes[edge.Dest.PID], dotString(label))
+		if opts.PortRecords && !opts.NoPortsInLabel && model.Role(edge) == netflow.RoleClientServer {
+			graphEdge.Attr("headport", portCellID(edge.Dest.Port))
+		}
+		graphEdge.Attr("tooltip", dotString(edgeTooltip(sourceNode, destNode, edge, info)))
+		width := edgeWidth(info.Count)
+		if volume >= 0 {
+			width = volumeWidth(volume, maxVolume)
+		}
+		if width != "" && !opts.NoWeight {
+			graphEdge.Attr("penwidth", width)
+		}
+		if opts.Bidirectional && info.SeenBySource && info.SeenByDest {
+			graphEdge.Attr("dir", "both")
+		}
+		if opts.EdgeAttrs {
+			setEdgeAttrs(graphEdge, model, edge, info, opts)
+		}
+		if opts.ClusterClasses && opts.GroupByPod {
+			graphEdge.Attr("class", fmt.Sprintf("from-pod-%d to-pod-%d", clusters[edge.Source.PID], clusters[edge.Dest.PID]))
+		}
+		if opts.NodeCIDRs.IsCrossNode(sourceNode.LocalIP, destNode.LocalIP) {
+			// cross-node traffic has latency and cost implications
+			graphEdge.Bold().Attr("color", "red")
+			used.crossNode = true
+		}
+	}
+
+	if opts.ShowExternal && model.NumDangling() > 0 {
+		if opts.CollapseExternal {
+			renderCollapsedExternal(graph, model, dotNodes, opts)
+		} else {
+			renderExternal(graph, model, dotNodes)
+		}
+		used.external = true
+	}
+
+	if banner := truncationBanner(model.Stats); banner != "" {
+		graph.Node(banner).Attr("shape", "note").Attr("color", "red")
+	}
+
+	if opts.Legend {
+		renderLegend(graph, used, opts)
+	}
+
+	return graph
+}
+
+// setEdgeAttrs attaches the metadata of the edge as custom attributes, see
+// RenderOptions.EdgeAttrs
+func setEdgeAttrs(graphEdge *dotEdge, model *netflow.Model, edge netflow.Edge, info *netflow.EdgeInfo, opts RenderOptions) {
+	graphEdge.Attr("dst_port", strconv.Itoa(edge.Dest.Port))
+	graphEdge.Attr("protocol", "tcp")
+	graphEdge.Attr("count", strconv.Itoa(info.Count))
+	if opts.ShowRoles {
+		src, dst := model.Role(edge).Endpoints()
+		graphEdge.Attr("src_role", src).Attr("dst_role", dst)
+	}
+}
+
+// truncationBanner returns the label of the node signaling that -max-nodes / -max-edges
+// kicked in, or "" if the graph is complete
+func truncationBanner(stats netflow.Stats) string {
+	var parts []string
+	if stats.TruncatedNodes > 0 {
+		parts = append(parts, fmt.Sprintf("%d more nodes", stats.TruncatedNodes))
+	}
+	if stats.TruncatedEdges > 0 {
+		parts = append(parts, fmt.Sprintf("%d more edges", stats.TruncatedEdges))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("... truncated (%s)", strings.Join(parts, ", "))
+}
@@ -0,0 +1,748 @@
+package main
+
+import (
+	"maps"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"net_visualizer/netflow"
+)
+
+// sampleTrace is a small excerpt of a real tracer output, with two clients connecting to
+// each of two servers
+const sampleTrace = `Attaching 5 probes...
+Listening for TCPv4 traffic...
+10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat
+10.42.0.55:5672->10.42.0.54:5671|PID=722977 CMD=tcp-echo
+10.42.0.58:5673<-10.42.0.56:5674|PID=723715 CMD=ncat
+10.42.0.56:5674->10.42.0.58:5673|PID=723429 CMD=ncat
+10.42.0.54:5671<-10.42.0.53:5672|PID=723753 CMD=ncat
+10.42.0.53:5672->10.42.0.54:5671|PID=722977 CMD=tcp-echo
+10.42.0.58:5673<-10.42.0.57:5674|PID=723801 CMD=ncat
+10.42.0.57:5674->10.42.0.58:5673|PID=723429 CMD=ncat
+127.0.0.1:8080<-127.0.0.1:52114|PID=19190 CMD=coredns
+`
+
+func mustBuildModel(t *testing.T, trace string, opts netflow.ModelOptions) *netflow.Model {
+	t.Helper()
+	model, err := netflow.BuildModel(strings.NewReader(trace), opts)
+	if err != nil {
+		t.Fatalf("BuildModel returned unexpected error: %v", err)
+	}
+	return model
+}
+
+func TestRenderDOTIsDeterministic(t *testing.T) {
+	render := func() string {
+		model, err := netflow.BuildModel(strings.NewReader(sampleTrace), netflow.ModelOptions{})
+		if err != nil {
+			t.Fatalf("BuildModel returned unexpected error: %v", err)
+		}
+		return renderDOT(model, RenderOptions{}).String()
+	}
+
+	first := render()
+	for i := 0; i < 10; i++ {
+		if got := render(); got != first {
+			t.Fatalf("run %d produced different output:\n%s\nvs first run:\n%s", i, got, first)
+		}
+	}
+}
+
+func TestRenderDOTPodEnrichment(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	model.ResolvePods(netflow.StaticPodResolver{
+		"10.42.0.54": {Name: "tcp-echo-5d8f", Namespace: "default"},
+		"10.42.0.55": {Name: "traffic-sink-a", Namespace: "traffic"},
+	})
+
+	out := renderDOT(model, RenderOptions{GroupByPod: true, NoDegree: true}).String()
+
+	for _, want := range []string{
+		`label="PID=722977\nName=tcp-echo\nPod=default/tcp-echo-5d8f\nListen=5671"`,
+		`label="PID=723736\nName=ncat\nPod=traffic/traffic-sink-a"`,
+		`label="default/tcp-echo-5d8f"`,
+		`label="traffic/traffic-sink-a"`,
+		// unresolved IPs keep the raw IP, both in the node and in the cluster label
+		`label="PID=723753\nName=ncat\nIP=10.42.0.53"`,
+		`label="10.42.0.53"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, out)
+		}
+	}
+	if got := strings.Count(out, "subgraph cluster_"); got != 6 {
+		t.Errorf("got %d pod clusters, want 6:\n%s", got, out)
+	}
+}
+
+func TestRenderDOTNoGrouping(t *testing.T) {
+	out := renderDOT(mustBuildModel(t, sampleTrace, netflow.ModelOptions{}), RenderOptions{}).String()
+	if strings.Contains(out, "subgraph") {
+		t.Errorf("unexpected clusters without GroupByPod:\n%s", out)
+	}
+}
+
+func TestRenderDOTGroupByContainer(t *testing.T) {
+	// a pod with two containers, sharing its IP, and a client
+	trace := `10.42.0.1:80<-10.42.0.2:40000|PID=200 CONTAINER=ctr-client CMD=client
+10.42.0.2:40000->10.42.0.1:80|PID=100 CONTAINER=ctr-app CMD=app
+10.42.0.1:9090<-10.42.0.2:40001|PID=200 CONTAINER=ctr-client CMD=client
+10.42.0.2:40001->10.42.0.1:9090|PID=101 CONTAINER=ctr-sidecar CMD=sidecar
+`
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+	out := renderDOT(model, RenderOptions{GroupByPod: true}).String()
+	if n := strings.Count(out, "subgraph"); n != 3 {
+		t.Errorf("got %d clusters, want one per container:\n%s", n, out)
+	}
+	for _, want := range []string{`label="ctr-app"`, `label="ctr-sidecar"`, `\nContainer=ctr-sidecar\n`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, out)
+		}
+	}
+
+	// without the container token, the processes sharing the IP are grouped together
+	trace = regexp.MustCompile(` CONTAINER=\S+`).ReplaceAllString(trace, "")
+	out = renderDOT(mustBuildModel(t, trace, netflow.ModelOptions{}), RenderOptions{GroupByPod: true}).String()
+	if n := strings.Count(out, "subgraph"); n != 2 || !strings.Contains(out, `label="10.42.0.1"`) {
+		t.Errorf("want one cluster per IP:\n%s", out)
+	}
+}
+
+func TestRenderDOTGroupCIDR(t *testing.T) {
+	var groups netflow.CIDRGroups
+	groups.Set("rds=10.0.8.0/22")
+	trace := `10.0.8.10:5432<-10.42.0.1:40000|PID=100 CMD=api
+10.0.9.20:5432<-10.42.0.1:40001|PID=100 CMD=api
+`
+	model := mustBuildModel(t, trace, netflow.ModelOptions{GroupCIDRs: groups})
+	out := renderDOT(model, RenderOptions{NoDegree: true}).String()
+	if want := `[label="rds\nCIDR=10.0.8.0/22\nListen=5432",shape="box3d",tooltip="Group=rds\nCIDR=10.0.8.0/22\nPorts=5432 (listening: 5432)"]`; !strings.Contains(out, want) {
+		t.Errorf("DOT output does not contain %s:\n%s", want, out)
+	}
+}
+
+func TestRenderDOTDegrees(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+
+	out := renderDOT(model, RenderOptions{}).String()
+	for _, want := range []string{
+		`label="PID=722977\nName=tcp-echo\nIP=10.42.0.54\nListen=5671\nin=2 out=0"`,
+		`label="PID=723736\nName=ncat\nIP=10.42.0.55\nin=0 out=1"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, out)
+		}
+	}
+
+	out = renderDOT(model, RenderOptions{NoDegree: true}).String()
+	if strings.Contains(out, "in=") {
+		t.Errorf("degrees should be omitted with NoDegree:\n%s", out)
+	}
+}
+
+func TestEdgeLabel(t *testing.T) {
+	podA1 := &netflow.ProcessEndpoints{ProcessID: 1, LocalIP: "10.42.0.9"}
+	podA2 := &netflow.ProcessEndpoints{ProcessID: 2, LocalIP: "10.42.0.9"}
+	podB := &netflow.ProcessEndpoints{ProcessID: 3, LocalIP: "10.42.0.10"}
+	intraPod := netflow.Edge{Source: netflow.ProcessEndpoint{PID: 1, Port: 40000}, Dest: netflow.ProcessEndpoint{PID: 2, Port: 8080}}
+	crossPod := netflow.Edge{Source: netflow.ProcessEndpoint{PID: 1, Port: 40000}, Dest: netflow.ProcessEndpoint{PID: 3, Port: 8080}}
+
+	tests := []struct {
+		name     string
+		src, dst *netflow.ProcessEndpoints
+		edge     netflow.Edge
+		opts     RenderOptions
+		want     string
+	}{
+		{"intra-pod is compacted", podA1, podA2, intraPod, RenderOptions{}, "40000->8080"},
+		{"intra-pod with full labels", podA1, podA2, intraPod, RenderOptions{FullLabels: true}, "10.42.0.9:40000->10.42.0.9:8080"},
+		{"cross-pod keeps IPs", podA1, podB, crossPod, RenderOptions{}, "10.42.0.9:40000->10.42.0.10:8080"},
+		{"compact with services", podA1, podA2, intraPod, RenderOptions{Services: ServiceNames{8080: "http-alt"}}, "40000->8080 (http-alt)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := edgeLabel(tt.src, tt.dst, tt.edge, tt.opts); got != tt.want {
+				t.Errorf("edgeLabel = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderDOTSameIPDifferentPorts(t *testing.T) {
+	model := mustBuildModel(t, `10.42.0.9:8080<-10.42.0.9:40000|PID=200 CMD=sidecar
+10.42.0.9:40000->10.42.0.9:8080|PID=100 CMD=app
+10.42.0.9:8080<-10.42.0.9:40000|PID=200 CMD=sidecar
+`, netflow.ModelOptions{})
+	out := renderDOT(model, RenderOptions{}).String()
+	if !strings.Contains(out, `n200->n100[label="40000->8080"`) {
+		t.Errorf("the sidecar should be drawn connecting to the application, labeled with the ports only:\n%s", out)
+	}
+	if strings.Contains(out, "n100->n100") || strings.Contains(out, "n200->n200") {
+		t.Errorf("no self-loop expected:\n%s", out)
+	}
+}
+
+func TestRenderDOTColorBySource(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	model.Nodes[722977].Sources = []int{0, 1}
+
+	out := renderDOT(model, RenderOptions{ColorBySource: true, SourceNames: []string{"a.trace", "b.trace"}}).String()
+	for _, want := range []string{
+		`fillcolor="#8dd3c7:#ffffb3"`,
+		`style="wedged"`,
+		`\nobserved in: a.trace, b.trace"`,
+		`fillcolor="#8dd3c7",label="PID=723429`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderDOTTooltips(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	out := renderDOT(model, RenderOptions{MaxNameLen: 3, NoPortsInLabel: true}).String()
+	if n := strings.Count(out, "tooltip="); n != len(model.Nodes)+len(model.Edges) {
+		t.Errorf("got %d tooltips, want one per node and per edge:\n%s", n, out)
+	}
+	for _, want := range []string{
+		// the label abbreviates the name and omits the ports, not the tooltip
+		`label="PID=722977\nName=tc…\nIP=10.42.0.54\nin=2 out=0",tooltip="PID=722977\nCommand=tcp-echo\nIP=10.42.0.54\nPorts=5671 (listening: 5671)"`,
+		`tooltip="10.42.0.53:5672 -> 10.42.0.54:5671\nCount=2"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderDOTLayoutOptions(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+
+	out := renderDOT(model, RenderOptions{RankDir: "LR", Layout: "neato"}).String()
+	if !strings.Contains(out, `layout="neato";rankdir="LR";`) {
+		t.Errorf("graph attributes not set:\n%s", out)
+	}
+
+	out = renderDOT(model, RenderOptions{}).String()
+	if strings.Contains(out, "rankdir") || strings.Contains(out, "layout") {
+		t.Errorf("no layout attribute expected by default:\n%s", out)
+	}
+}
+
+func TestRenderOptionsValidate(t *testing.T) {
+	for _, opts := range []RenderOptions{{}, {RankDir: "BT"}, {Layout: "sfdp"}} {
+		if err := opts.Validate(); err != nil {
+			t.Errorf("Validate(%+v) returned unexpected error: %v", opts, err)
+		}
+	}
+	for _, opts := range []RenderOptions{{RankDir: "lr"}, {Layout: "twopi"}} {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Validate(%+v) expected an error", opts)
+		}
+	}
+}
+
+func TestEdgeRoles(t *testing.T) {
+	server := netflow.ProcessEndpoint{PID: 100, Port: 8080}
+	client := netflow.ProcessEndpoint{PID: 200, Port: 40000}
+	for _, tc := range []struct {
+		name  string
+		trace string
+		edge  netflow.Edge
+		want  netflow.EdgeRole
+	}{
+		{
+			// the edge is created by the Local2Remote line of the client
+			name: "accept then connect",
+			trace: `10.0.0.2:40000->10.0.0.1:8080|PID=100 CMD=server
+10.0.0.1:8080<-10.0.0.2:40000|PID=200 CMD=client
+`,
+			edge: netflow.Edge{Source: client, Dest: server},
+			want: netflow.RoleClientServer,
+		},
+		{
+			// the edge is created by the Remote2Local line of the server
+			name: "connect then accept",
+			trace: `10.0.0.1:8080<-10.0.0.2:40000|PID=200 CMD=client
+10.0.0.2:40000->10.0.0.1:8080|PID=100 CMD=server
+`,
+			edge: netflow.Edge{Source: client, Dest: server},
+			want: netflow.RoleClientServer,
+		},
+		{
+			// the server accepted on 8080 but later reported an outgoing connection from
+			// that port: the listener wins over the direction
+			name: "direction contradicted by the listener",
+			trace: `10.0.0.3:50000->10.0.0.1:8080|PID=100 CMD=server
+10.0.0.1:9999<-10.0.0.2:40000|PID=200 CMD=client
+10.0.0.2:40000<-10.0.0.1:8080|PID=100 CMD=server
+`,
+			edge: netflow.Edge{Source: client, Dest: server},
+			want: netflow.RoleClientServer,
+		},
+		{
+			// 8080 is only detected as listening once it accepts another connection,
+			// after the edge has been oriented
+			name: "listener detected afterwards",
+			trace: `10.0.0.1:8080<-10.0.0.2:40000|PID=200 CMD=client
+10.0.0.2:40000<-10.0.0.1:8080|PID=100 CMD=server
+10.0.0.3:50000->10.0.0.1:8080|PID=100 CMD=server
+`,
+			edge: netflow.Edge{Source: server, Dest: client},
+			want: netflow.RoleServerClient,
+		},
+		{
+			// both processes only reported outgoing connections
+			name: "no known listener",
+			trace: `10.0.0.1:8080<-10.0.0.2:40000|PID=200 CMD=client
+10.0.0.2:40000<-10.0.0.1:8080|PID=100 CMD=server
+`,
+			edge: netflow.Edge{Source: server, Dest: client},
+			want: netflow.RoleUnknown,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			model := mustBuildModel(t, tc.trace, netflow.ModelOptions{})
+			if _, ok := model.Edges[tc.edge]; !ok {
+				t.Fatalf("edge %+v not found in %+v", tc.edge, model.SortedEdges())
+			}
+			if got := model.Role(tc.edge); got != tc.want {
+				t.Errorf("Role = %v, want %v", got, tc.want)
+			}
+
+			out := renderDOT(model, RenderOptions{ShowRoles: true}).String()
+			if want := "[" + tc.want.String() + "]"; !strings.Contains(out, want) {
+				t.Errorf("DOT output does not contain %s:\n%s", want, out)
+			}
+		})
+	}
+}
+
+// TestRenderDOTArrowDirection checks that the arrow of a connection always points from
+// the client 10.0.0.2:40000 to the server 10.0.0.1:8080, however it is observed
+func TestRenderDOTArrowDirection(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		trace string
+	}{
+		{"Local2Remote then Remote2Local", `10.0.0.1:8080<-10.0.0.2:40000|PID=200 CMD=client
+10.0.0.2:40000->10.0.0.1:8080|PID=100 CMD=server
+`},
+		{"Remote2Local then Local2Remote", `10.0.0.2:40000->10.0.0.1:8080|PID=100 CMD=server
+10.0.0.1:8080<-10.0.0.2:40000|PID=200 CMD=client
+`},
+		// both sides claim to have initiated the connection, but 8080 is a known listener
+		{"both Local2Remote", `0.0.0.0:0<-10.0.0.1:8080|PID=100 STATE=LISTEN CMD=server
+10.0.0.1:8080<-10.0.0.2:40000|PID=200 CMD=client
+10.0.0.2:40000<-10.0.0.1:8080|PID=100 CMD=server
+`},
+		// both sides claim to have accepted the connection: the first observation wins
+		{"both Remote2Local", `10.0.0.1:8080 10.0.0.2:40000|PID=200 DIR=in CMD=client
+10.0.0.2:40000->10.0.0.1:8080|PID=100 CMD=server
+10.0.0.1:8080 10.0.0.2:40000|PID=200 DIR=in CMD=client
+`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			model := mustBuildModel(t, tc.trace, netflow.ModelOptions{})
+			if len(model.Edges) != 1 {
+				t.Fatalf("got edges %+v, want a single one", model.SortedEdges())
+			}
+			out := renderDOT(model, RenderOptions{}).String()
+			if !strings.Contains(out, `[label="10.0.0.2:40000->10.0.0.1:8080"`) {
+				t.Errorf("the arrow does not point from the client to the server:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestRenderDOTClientCounts(t *testing.T) {
+	// three distinct clients of 8080, two of them from the same process, and one of 9090
+	trace := `10.0.0.1:8080<-10.0.0.2:40000|PID=200 CMD=client
+10.0.0.2:40000->10.0.0.1:8080|PID=100 CMD=server
+10.0.0.1:8080<-10.0.0.2:40001|PID=200 CMD=client
+10.0.0.2:40001->10.0.0.1:8080|PID=100 CMD=server
+10.0.0.1:8080<-10.0.0.3:40000|PID=300 CMD=client
+10.0.0.3:40000->10.0.0.1:8080|PID=100 CMD=server
+10.0.0.1:9090<-10.0.0.3:40001|PID=300 CMD=client
+10.0.0.3:40001->10.0.0.1:9090|PID=100 CMD=server
+`
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+	want := map[netflow.ProcessEndpoint]int{{PID: 100, Port: 8080}: 3, {PID: 100, Port: 9090}: 1}
+	if got := model.ClientCounts(); !maps.Equal(got, want) {
+		t.Errorf("ClientCounts = %v, want %v", got, want)
+	}
+
+	out := renderDOT(model, RenderOptions{ClientCounts: true, NoDegree: true}).String()
+	if want := `label="PID=100\nName=server\nIP=10.0.0.1\nListen=8080 (3 clients), 9090 (1 client)"`; !strings.Contains(out, want) {
+		t.Errorf("DOT output does not contain %s:\n%s", want, out)
+	}
+	if out := renderDOT(model, RenderOptions{NoDegree: true}).String(); !strings.Contains(out, `\nListen=8080,9090"`) {
+		t.Errorf("the client counts should only be shown with ClientCounts:\n%s", out)
+	}
+}
+
+func TestRenderDOTNodeIDs(t *testing.T) {
+	// two ranges with the same name and CIDR, e.g. given twice on the command line by
+	// mistake, get the same label but must still be drawn as two nodes
+	client := &netflow.ProcessEndpoints{ProcessID: 100, ProcessName: "api", LocalIP: "10.42.0.1", LocalPorts: []int{40000, 40001}}
+	dbA := &netflow.ProcessEndpoints{ProcessID: -1, ProcessName: "db", LocalIP: "10.0.8.0/24", CIDR: "10.0.8.0/24", LocalPorts: []int{5432}}
+	dbB := &netflow.ProcessEndpoints{ProcessID: -2, ProcessName: "db", LocalIP: "10.0.8.0/24", CIDR: "10.0.8.0/24", LocalPorts: []int{5432}}
+	model := &netflow.Model{
+		Nodes: map[int64]*netflow.ProcessEndpoints{100: client, -1: dbA, -2: dbB},
+		Edges: map[netflow.Edge]*netflow.EdgeInfo{
+			{Source: netflow.ProcessEndpoint{PID: 100, Port: 40000}, Dest: netflow.ProcessEndpoint{PID: -1, Port: 5432}}: {Count: 1},
+			{Source: netflow.ProcessEndpoint{PID: 100, Port: 40001}, Dest: netflow.ProcessEndpoint{PID: -2, Port: 5432}}: {Count: 1},
+		},
+	}
+	out := renderDOT(model, RenderOptions{NoDegree: true}).String()
+	if n := strings.Count(out, `[label="db\nCIDR=10.0.8.0/24"`); n != 2 {
+		t.Errorf("got %d nodes labeled as the range, want 2:\n%s", n, out)
+	}
+	// the IDs derive from the PIDs, not from the order of the nodes; the negative ones
+	// are quoted
+	for _, want := range []string{`n100->"n-1"[`, `n100->"n-2"[`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, out)
+		}
+	}
+
+	// the IDs of the processes stay the same when another one shows up first
+	trace := "10.42.0.9:80<-10.42.0.1:40000|PID=1 CMD=early\n10.42.0.1:40000->10.42.0.9:80|PID=2 CMD=n1\n" + sampleTrace
+	model = mustBuildModel(t, trace, netflow.ModelOptions{})
+	model.Stats.TruncatedNodes = 1
+	out = renderDOT(model, RenderOptions{}).String()
+	for _, want := range []string{`n723736->n722977[`, `n723801->n723429[`, `n1->n2[`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, out)
+		}
+	}
+	// the labels are written as-is, even when they look like an ID; the other nodes, here
+	// the truncation banner, are identified by their quoted name
+	for _, want := range []string{`n2[label="PID=2\nName=n1\n`, `"... truncated (1 more nodes)"[color="red"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestTruncateName(t *testing.T) {
+	for _, tc := range []struct {
+		name, want string
+		maxLen     int
+		truncated  bool
+	}{
+		{"ncat", "ncat", 4, false},
+		{"tcp-echo", "tcp-…", 5, true},
+		{"tcp-echo", "tcp-echo", 0, false},
+		// multibyte characters count as one and are never split
+		{"日本語のプロセス", "日本…", 3, true},
+		{"日本語", "日本語", 3, false},
+	} {
+		got, truncated := truncateName(tc.name, tc.maxLen)
+		if got != tc.want || truncated != tc.truncated {
+			t.Errorf("truncateName(%q, %d) = %q, %v, want %q, %v", tc.name, tc.maxLen, got, truncated, tc.want, tc.truncated)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncateName(%q, %d) = %q is not valid UTF-8", tc.name, tc.maxLen, got)
+		}
+	}
+}
+
+func TestRenderDOTLongNames(t *testing.T) {
+	longName := strings.Repeat("x", 500)
+	trace := "10.0.0.2:40000->10.0.0.1:8080|PID=100 CMD=" + longName + "\n"
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+
+	out := renderDOT(model, RenderOptions{MaxNameLen: 120, NoDegree: true}).String()
+	if want := `label="PID=100\nName=` + strings.Repeat("x", 119) + `…\nIP=10.0.0.1\nListen=8080"`; !strings.Contains(out, want) {
+		t.Errorf("DOT output does not contain the truncated name:\n%s", out)
+	}
+	if want := `\nCommand=` + longName + `\n`; !strings.Contains(out, want) {
+		t.Errorf("DOT output does not contain the full name in the tooltip:\n%s", out)
+	}
+
+	// exports keep the full name
+	if nodes, _ := model.Export(); nodes[0].Name != longName {
+		t.Errorf("exported name = %q, want the full name", nodes[0].Name)
+	}
+}
+
+func TestRenderDOTEscapesProcessNames(t *testing.T) {
+	trace := `10.0.0.2:40000->10.0.0.1:8080|PID=100 CMD=sh -c "echo {a}" \n` + "   \n"
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+	out := renderDOT(model, RenderOptions{NoDegree: true}).String()
+	// quotes and backslashes are escaped, the trailing spaces are gone
+	if want := `label="PID=100\nName=sh -c \"echo {a}\" \\n\nIP=10.0.0.1\nListen=8080"`; !strings.Contains(out, want) {
+		t.Errorf("DOT output does not contain %s:\n%s", want, out)
+	}
+}
+
+func TestRenderDOTTruncationBanner(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	if out := renderDOT(model, RenderOptions{}).String(); strings.Contains(out, "truncated") {
+		t.Errorf("complete graphs must not have a banner:\n%s", out)
+	}
+
+	model = mustBuildModel(t, sampleTrace, netflow.ModelOptions{MaxNodes: 3})
+	out := renderDOT(model, RenderOptions{}).String()
+	if want := `[color="red",label="... truncated (3 more nodes)",shape="note"]`; !strings.Contains(out, want) {
+		t.Errorf("DOT output does not contain %s:\n%s", want, out)
+	}
+
+	if got := truncationBanner(netflow.Stats{TruncatedNodes: 5, TruncatedEdges: 12}); got != "... truncated (5 more nodes, 12 more edges)" {
+		t.Errorf("truncationBanner = %q", got)
+	}
+}
+
+func TestCompressPorts(t *testing.T) {
+	for _, tc := range []struct {
+		ports []int
+		want  string
+	}{
+		{[]int{8080, 8081, 8082}, "8080-8082"},
+		{[]int{53, 8080, 8081, 8082, 9090}, "53,8080-8082,9090"},
+		{[]int{80, 443}, "80,443"},
+		{[]int{6443}, "6443"},
+		{nil, ""},
+	} {
+		if got := compressPorts(tc.ports); got != tc.want {
+			t.Errorf("compressPorts(%v) = %q, want %q", tc.ports, got, tc.want)
+		}
+	}
+}
+
+func TestRenderDOTNoPortsInLabel(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	if out := renderDOT(model, RenderOptions{NoPortsInLabel: true}).String(); strings.Contains(out, "Listen=") {
+		t.Errorf("ports should be omitted with NoPortsInLabel:\n%s", out)
+	}
+}
+
+func TestRenderDOTIdleListeningPorts(t *testing.T) {
+	trace := sampleTrace + "0.0.0.0:0<-10.42.0.54:9090|PID=722977 STATE=LISTEN CMD=tcp-echo\n"
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+	out := renderDOT(model, RenderOptions{}).String()
+	if strings.Count(out, "Idle=") != 1 || !strings.Contains(out, `Listen=5671,9090\nIdle=9090`) {
+		t.Errorf("only the LISTEN-only port should be annotated as idle:\n%s", out)
+	}
+}
+
+func TestRenderDOTShowExternal(t *testing.T) {
+	trace := sampleTrace + `10.42.0.60:443<-10.42.0.55:5680|PID=723736 CMD=ncat
+10.42.0.61:40000->10.42.0.58:5673|PID=723429 CMD=ncat
+`
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+	if out := renderDOT(model, RenderOptions{}).String(); strings.Contains(out, "external") {
+		t.Errorf("external nodes should only be drawn with ShowExternal:\n%s", out)
+	}
+
+	out := renderDOT(model, RenderOptions{ShowExternal: true}).String()
+	for _, want := range []string{
+		`[label="10.42.0.60:443\n(external)",shape="box",style="dashed"]`,
+		`[label="10.42.0.61\n(external)",shape="box",style="dashed"]`,
+		`[label="10.42.0.55:5680->10.42.0.60:443",style="dashed"]`,
+		`[label="10.42.0.61:40000->10.42.0.58:5673",style="dashed"]`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderDOTCollapseExternal(t *testing.T) {
+	trace := sampleTrace + `1.1.1.1:443<-10.42.0.55:5680|PID=723736 CMD=ncat
+8.8.8.8:443<-10.42.0.55:5681|PID=723736 CMD=ncat
+52.1.2.3:443<-10.42.0.55:5682|PID=723736 CMD=ncat
+203.0.113.7:40000->10.42.0.58:5673|PID=723429 CMD=ncat
+`
+	model := mustBuildModel(t, trace, netflow.ModelOptions{})
+	out := renderDOT(model, RenderOptions{ShowExternal: true, CollapseExternal: true}).String()
+	if n := strings.Count(out, `(external)",shape="box"`); n != 1 {
+		t.Errorf("got %d external nodes, want a single one:\n%s", n, out)
+	}
+	for _, want := range []string{
+		`[label="INTERNET\n(external)",shape="box",style="dashed"]`,
+		`[label="3 connections",penwidth="2.1",style="dashed"]`,
+		`[label="1 connection",style="dashed"]`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, out)
+		}
+	}
+
+	var cidrs NodeCIDRs
+	if err := cidrs.Set("aws=52.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+	out = renderDOT(model, RenderOptions{ShowExternal: true, CollapseExternal: true, ExternalCIDRs: cidrs}).String()
+	for _, want := range []string{
+		`[label="aws\n(external)",shape="box",style="dashed"]`,
+		`[label="2 connections",penwidth="1.69",style="dashed"]`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestReportDangling(t *testing.T) {
+	var buf strings.Builder
+	reportDangling(&buf, mustBuildModel(t, sampleTrace, netflow.ModelOptions{}))
+	if buf.Len() != 0 {
+		t.Errorf("unexpected report for a complete trace:\n%s", buf.String())
+	}
+
+	model := mustBuildModel(t, sampleTrace+"10.42.0.60:443<-10.42.0.55:5680|PID=723736 CMD=ncat\n", netflow.ModelOptions{})
+	reportDangling(&buf, model)
+	if want := "  PID=723736 (ncat) 10.42.0.55:5680 -> 10.42.0.60:443\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("report does not contain %q:\n%s", want, buf.String())
+	}
+}
+
+func TestRenderDOTCollapsedNode(t *testing.T) {
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{}).CollapseChatty(1)
+	out := renderDOT(model, RenderOptions{}).String()
+	if want := `label="PID=722977\nName=tcp-echo\nIP=10.42.0.54\nListen=5671\n(2 connections, collapsed)"`; !strings.Contains(out, want) {
+		t.Errorf("DOT output does not contain %s:\n%s", want, out)
+	}
+}
+
+func TestRenderDOTMergedForks(t *testing.T) {
+	trace := `10.42.0.1:80<-10.42.0.10:40000|PID=200 CMD=client
+10.42.0.10:40000->10.42.0.1:80|PID=101 CMD=nginx
+10.42.0.1:80<-10.42.0.11:40000|PID=201 CMD=client
+10.42.0.11:40000->10.42.0.1:80|PID=100 CMD=nginx
+`
+	model := mustBuildModel(t, trace, netflow.ModelOptions{}).MergeForks()
+	out := renderDOT(model, RenderOptions{NoDegree: true}).String()
+	if want := `[label="PID=100 (+1 forks)\nName=nginx\nIP=10.42.0.1\nListen=80",tooltip="PID=100\nPIDs: 100, 101\nCommand=nginx\n`; !strings.Contains(out, want) {
+		t.Errorf("DOT output does not contain %s:\n%s", want, out)
+	}
+}
+
+func TestEdgeWidth(t *testing.T) {
+	for _, tc := range []struct {
+		count int
+		want  string
+	}{
+		{0, ""},
+		{1, ""},
+		{2, "1.69"},
+		{10, "3.3"},
+		{1000000, "5"},
+	} {
+		if got := edgeWidth(tc.count); got != tc.want {
+			t.Errorf("edgeWidth(%d) = %q, want %q", tc.count, got, tc.want)
+		}
+	}
+}
+
+func TestRenderDOTEdgeWeight(t *testing.T) {
+	// in the sample trace, the connections of the second client of each server are
+	// observed twice: on both sides, the server being already known
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	out := renderDOT(model, RenderOptions{}).String()
+	if got := strings.Count(out, "penwidth"); got != 2 {
+		t.Errorf("got %d weighted edges, want 2:\n%s", got, out)
+	}
+	if want := `[label="10.42.0.53:5672->10.42.0.54:5671",penwidth="1.69",tooltip=`; !strings.Contains(out, want) {
+		t.Errorf("DOT output does not contain %s:\n%s", want, out)
+	}
+	if out := renderDOT(model, RenderOptions{NoWeight: true}).String(); strings.Contains(out, "penwidth") {
+		t.Errorf("edges should not be weighted with NoWeight:\n%s", out)
+	}
+}
+
+func TestRenderDOTWeightBy(t *testing.T) {
+	server := &netflow.ProcessEndpoints{ProcessID: 100, ProcessName: "server", LocalIP: "10.0.0.1", LocalPorts: []int{8080}}
+	client := &netflow.ProcessEndpoints{ProcessID: 200, ProcessName: "client", LocalIP: "10.0.0.2", LocalPorts: []int{40000, 40001}}
+	model := &netflow.Model{
+		Nodes: map[int64]*netflow.ProcessEndpoints{100: server, 200: client},
+		Edges: map[netflow.Edge]*netflow.EdgeInfo{
+			{Source: netflow.ProcessEndpoint{PID: 200, Port: 40000}, Dest: netflow.ProcessEndpoint{PID: 100, Port: 8080}}: {Count: 1, Bytes: 1 << 20, Packets: 1},
+			{Source: netflow.ProcessEndpoint{PID: 200, Port: 40001}, Dest: netflow.ProcessEndpoint{PID: 100, Port: 8080}}: {Count: 3, Bytes: 1536, Packets: 10},
+		},
+	}
+	for _, tc := range []struct {
+		weightBy string
+		want     []string
+	}{
+		{"", []string{
+			`[label="10.0.0.2:40000->10.0.0.1:8080",tooltip="10.0.0.2:40000 -> 10.0.0.1:8080\nCount=1\nBytes=1048576 Packets=1"]`,
+			`[label="10.0.0.2:40001->10.0.0.1:8080",penwidth="2.1",tooltip=`,
+		}},
+		{"bytes", []string{
+			// the busiest edge gets the largest width, however many times it was observed
+			`[label="10.0.0.2:40000->10.0.0.1:8080 (1.0 MiB)",penwidth="5",tooltip=`,
+			`[label="10.0.0.2:40001->10.0.0.1:8080 (1.5 KiB)",penwidth="3.12",tooltip=`,
+		}},
+		{"packets", []string{
+			`[label="10.0.0.2:40000->10.0.0.1:8080 (1 packet)",tooltip=`,
+			`[label="10.0.0.2:40001->10.0.0.1:8080 (10 packets)",penwidth="5",tooltip=`,
+		}},
+	} {
+		out := renderDOT(model, RenderOptions{WeightBy: tc.weightBy, NoDegree: true}).String()
+		for _, want := range tc.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("-weight-by %q: DOT output does not contain %s:\n%s", tc.weightBy, want, out)
+			}
+		}
+	}
+
+	if err := (RenderOptions{WeightBy: "latency"}).Validate(); err == nil {
+		t.Error("Validate accepted an invalid weight metric")
+	}
+}
+
+func TestRenderDOTBidirectional(t *testing.T) {
+	server := &netflow.ProcessEndpoints{ProcessID: 100, ProcessName: "server", LocalIP: "10.0.0.1", LocalPorts: []int{8080}}
+	client := &netflow.ProcessEndpoints{ProcessID: 200, ProcessName: "client", LocalIP: "10.0.0.2", LocalPorts: []int{40000, 40001}}
+	model := &netflow.Model{
+		Nodes: map[int64]*netflow.ProcessEndpoints{100: server, 200: client},
+		Edges: map[netflow.Edge]*netflow.EdgeInfo{
+			// observed from both ends, then only from the client
+			{Source: netflow.ProcessEndpoint{PID: 200, Port: 40000}, Dest: netflow.ProcessEndpoint{PID: 100, Port: 8080}}: {Count: 2, SeenBySource: true, SeenByDest: true},
+			{Source: netflow.ProcessEndpoint{PID: 200, Port: 40001}, Dest: netflow.ProcessEndpoint{PID: 100, Port: 8080}}: {Count: 1, SeenBySource: true},
+		},
+	}
+	out := renderDOT(model, RenderOptions{Bidirectional: true, NoDegree: true}).String()
+	if want := `[dir="both",label="10.0.0.2:40000->10.0.0.1:8080"`; !strings.Contains(out, want) {
+		t.Errorf("DOT output does not contain %s:\n%s", want, out)
+	}
+	if n := strings.Count(out, "dir="); n != 1 {
+		t.Errorf("got %d edges with a dir attribute, want only the one observed from both ends:\n%s", n, out)
+	}
+	if out := renderDOT(model, RenderOptions{}).String(); strings.Contains(out, "dir=") {
+		t.Errorf("the edges must be single-headed by default:\n%s", out)
+	}
+}
+
+func TestRenderDOTEdgeAttrs(t *testing.T) {
+	server := &netflow.ProcessEndpoints{ProcessID: 100, ProcessName: "server", LocalIP: "10.0.0.1", LocalPorts: []int{8080}}
+	client := &netflow.ProcessEndpoints{ProcessID: 200, ProcessName: "client", LocalIP: "10.0.0.2", LocalPorts: []int{40000}}
+	edge := netflow.Edge{Source: netflow.ProcessEndpoint{PID: 200, Port: 40000}, Dest: netflow.ProcessEndpoint{PID: 100, Port: 8080}}
+	model := &netflow.Model{
+		Nodes:     map[int64]*netflow.ProcessEndpoints{100: server, 200: client},
+		Edges:     map[netflow.Edge]*netflow.EdgeInfo{edge: {Count: 3}},
+		Listening: map[netflow.ProcessEndpoint]struct{}{edge.Dest: {}},
+	}
+
+	out := renderDOT(model, RenderOptions{EdgeAttrs: true}).String()
+	if want := `n200->n100[count="3",dst_port="8080",label=`; !strings.Contains(out, want) {
+		t.Errorf("DOT output does not contain %s:\n%s", want, out)
+	}
+	if !strings.Contains(out, `protocol="tcp"`) || strings.Contains(out, "src_role") {
+		t.Errorf("want the protocol, and the roles only with ShowRoles:\n%s", out)
+	}
+	out = renderDOT(model, RenderOptions{EdgeAttrs: true, ShowRoles: true}).String()
+	if want := `dst_role="server"`; !strings.Contains(out, want) || !strings.Contains(out, `src_role="client"`) {
+		t.Errorf("DOT output does not contain the roles of the endpoints:\n%s", out)
+	}
+	if out := renderDOT(model, RenderOptions{}).String(); strings.Contains(out, "dst_port") {
+		t.Errorf("the attributes must only be attached with EdgeAttrs:\n%s", out)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ServiceNames maps TCP port numbers to human-readable service names
+type ServiceNames map[int]string
+
+// systemServicesFile is the standard location of the services(5) database
+const systemServicesFile = "/etc/services"
+
+// builtinServices lists the ports that are common in Kubernetes clusters but that are
+// typically missing from /etc/services
+var builtinServices = ServiceNames{
+	53:    "dns",
+	443:   "https",
+	2379:  "etcd-client",
+	2380:  "etcd-peer",
+	3306:  "mysql",
+	5432:  "postgresql",
+	6379:  "redis",
+	6443:  "kube-apiserver",
+	8181:  "coredns-ready",
+	9090:  "prometheus",
+	9092:  "kafka",
+	9100:  "node-exporter",
+	9153:  "coredns-metrics",
+	10250: "kubelet",
+	10256: "kube-proxy-health",
+	10257: "kube-controller-manager",
+	10259: "kube-scheduler",
+	15001: "envoy-outbound",
+	15006: "envoy-inbound",
+	27017: "mongodb",
+}
+
+// wellKnownServicesData is the table of the IANA well-known ports of -label-services, in
+// services(5) format
+//
+//go:embed wellknown.services
+var wellKnownServicesData string
+
+// wellKnownServices returns the embedded table of the IANA well-known ports, which does not
+// depend on the system nor on the cluster, unlike the tables of loadServices
+func wellKnownServices() ServiceNames {
+	ret, err := parseServices(strings.NewReader(wellKnownServicesData))
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded wellknown.services: %v", err))
+	}
+	return ret
+}
+
+// parseServices reads TCP port mappings in services(5) format, i.e. lines like
+//
+//	kube-apiserver   6443/tcp   # optional comment
+//
+// Non-TCP entries are ignored; when a port is listed more than once the first entry wins.
+func parseServices(r io.Reader) (ServiceNames, error) {
+	ret := make(ServiceNames)
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected '<name> <port>/<protocol>', got %q", lineNum, line)
+		}
+
+		portStr, proto, found := strings.Cut(fields[1], "/")
+		if !found {
+			return nil, fmt.Errorf("line %d: missing protocol in %q", lineNum, fields[1])
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port < 0 || port > 65535 {
+			return nil, fmt.Errorf("line %d: invalid port %q", lineNum, portStr)
+		}
+		if proto != "tcp" {
+			continue
+		}
+		if _, exists := ret[port]; !exists {
+			ret[port] = fields[0]
+		}
+	}
+	return ret, scanner.Err()
+}
+
+// loadServices builds the port->name table used to annotate edges. Sources are applied in
+// increasing order of priority: /etc/services, the builtin table, the user-provided file.
+// A missing or malformed /etc/services is not fatal, while any problem with the user-provided
+// file is reported as an error.
+func loadServices(userFile string) (ServiceNames, error) {
+	ret := make(ServiceNames)
+
+	if f, err := os.Open(systemServicesFile); err == nil {
+		system, err := parseServices(f)
+		f.Close()
+		if err != nil {
+			warnf("ignoring %s: %v", systemServicesFile, err)
+		}
+		for port, name := range system {
+			ret[port] = name
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		warnf("ignoring %s: %v", systemServicesFile, err)
+	}
+
+	for port, name := range builtinServices {
+		ret[port] = name
+	}
+
+	if userFile != "" {
+		f, err := os.Open(userFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		user, err := parseServices(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", userFile, err)
+		}
+		for port, name := range user {
+			ret[port] = name
+		}
+	}
+
+	return ret, nil
+}
+
+// PortLabel formats the given port, appending its service name when known
+func (s ServiceNames) PortLabel(port int) string {
+	if name, ok := s[port]; ok {
+		return fmt.Sprintf("%d (%s)", port, name)
+	}
+	return strconv.Itoa(port)
+}
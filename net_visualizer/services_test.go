@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestParseServices(t *testing.T) {
+	input := `# comment line
+http		80/tcp		www		# WorldWideWeb HTTP
+domain		53/udp
+kube-api	6443/tcp
+alt-name	80/tcp
+
+`
+	got, err := parseServices(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseServices returned unexpected error: %v", err)
+	}
+	want := ServiceNames{80: "http", 6443: "kube-api"}
+	if len(got) != len(want) {
+		t.Fatalf("parseServices = %v, want %v", got, want)
+	}
+	for port, name := range want {
+		if got[port] != name {
+			t.Errorf("port %d: got %q, want %q", port, got[port], name)
+		}
+	}
+}
+
+func TestParseServicesErrors(t *testing.T) {
+	for _, input := range []string{
+		"lonely-name\n",
+		"noproto 80\n",
+		"badport http/tcp\n",
+		"outofrange 70000/tcp\n",
+	} {
+		if _, err := parseServices(strings.NewReader(input)); err == nil {
+			t.Errorf("parseServices(%q) expected an error", input)
+		}
+	}
+}
+
+func TestLoadServicesUserFileOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services")
+	if err := os.WriteFile(path, []byte("my-apiserver 6443/tcp\nmy-app 31337/tcp\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	services, err := loadServices(path)
+	if err != nil {
+		t.Fatalf("loadServices returned unexpected error: %v", err)
+	}
+	if got := services.PortLabel(6443); got != "6443 (my-apiserver)" {
+		t.Errorf("PortLabel(6443) = %q, user file should override the builtin table", got)
+	}
+	if got := services.PortLabel(31337); got != "31337 (my-app)" {
+		t.Errorf("PortLabel(31337) = %q", got)
+	}
+	if got := services.PortLabel(10250); got != "10250 (kubelet)" {
+		t.Errorf("PortLabel(10250) = %q, builtin entry should be present", got)
+	}
+	if got := services.PortLabel(54321); got != "54321" {
+		t.Errorf("PortLabel(54321) = %q, unknown ports should render unchanged", got)
+	}
+
+	if _, err := loadServices(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("loadServices with a missing user file expected an error")
+	}
+}
+
+func TestPortLabelDisabled(t *testing.T) {
+	var services ServiceNames
+	if got := services.PortLabel(6443); got != "6443" {
+		t.Errorf("nil ServiceNames PortLabel(6443) = %q, want plain port", got)
+	}
+}
+
+func TestLabelServices(t *testing.T) {
+	server := &netflow.ProcessEndpoints{ProcessID: 100, ProcessName: "db", LocalIP: "10.0.0.1"}
+	client := &netflow.ProcessEndpoints{ProcessID: 200, ProcessName: "api", LocalIP: "10.0.0.2"}
+	opts := RenderOptions{ServiceLabels: wellKnownServices()}
+	for _, tc := range []struct {
+		srcPort, dstPort int
+		want             string
+	}{
+		{40000, 5432, "10.0.0.2:40000->10.0.0.1:postgres"},
+		{40001, 443, "10.0.0.2:40001->10.0.0.1:https"},
+		// unknown ports keep their numeric form, and source ports are never renamed
+		{40002, 31337, "10.0.0.2:40002->10.0.0.1:31337"},
+		{443, 8080, "10.0.0.2:443->10.0.0.1:http-alt"},
+	} {
+		edge := netflow.Edge{Source: netflow.ProcessEndpoint{PID: 200, Port: tc.srcPort}, Dest: netflow.ProcessEndpoint{PID: 100, Port: tc.dstPort}}
+		if got := edgeLabel(client, server, edge, opts); got != tc.want {
+			t.Errorf("edgeLabel(%d->%d) = %q, want %q", tc.srcPort, tc.dstPort, got, tc.want)
+		}
+	}
+}
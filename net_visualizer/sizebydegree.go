@@ -0,0 +1,25 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+// defaultNodeFontSize is the Graphviz default fontsize, that of the nodes with at most
+// one edge under SizeByDegree
+const defaultNodeFontSize = 14
+
+// maxNodeFontSize caps the fontsize of the most connected nodes, so that a single hub
+// does not dwarf the rest of the graph
+const maxNodeFontSize = 32
+
+// nodeFontSize returns the Graphviz fontsize of a node with degree edges, growing with the
+// logarithm of the degree like the width of the edges, or "" for the default size of the
+// nodes with at most one edge. The node grows with its label.
+func nodeFontSize(degree int) string {
+	if degree <= 1 {
+		return ""
+	}
+	size := min(defaultNodeFontSize+4*math.Log(float64(degree)), maxNodeFontSize)
+	return strconv.FormatFloat(math.Round(size*10)/10, 'f', -1, 64)
+}
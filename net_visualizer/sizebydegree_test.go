@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestNodeFontSize(t *testing.T) {
+	for _, tc := range []struct {
+		degree int
+		want   string
+	}{
+		{0, ""},
+		{1, ""},
+		{2, "16.8"},
+		{10, "23.2"},
+		{1000000, "32"},
+	} {
+		if got := nodeFontSize(tc.degree); got != tc.want {
+			t.Errorf("nodeFontSize(%d) = %q, want %q", tc.degree, got, tc.want)
+		}
+	}
+}
+
+func TestRenderDOTSizeByDegree(t *testing.T) {
+	// a hub with 10 clients, besides the servers of sampleTrace with 2 clients each
+	var trace strings.Builder
+	trace.WriteString(sampleTrace)
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&trace, "10.42.1.1:80<-10.42.2.%d:40000|PID=%d CMD=client\n", i, 100+i)
+		fmt.Fprintf(&trace, "10.42.2.%d:40000->10.42.1.1:80|PID=99 CMD=hub\n", i)
+	}
+	model := mustBuildModel(t, trace.String(), netflow.ModelOptions{})
+
+	if out := renderDOT(model, RenderOptions{}).String(); strings.Contains(out, "fontsize") {
+		t.Errorf("unexpected node sizes without SizeByDegree:\n%s", out)
+	}
+	out := renderDOT(model, RenderOptions{SizeByDegree: true}).String()
+	for _, want := range []string{
+		`[fontsize="23.2",label="PID=99\nName=hub`,
+		`[fontsize="16.8",label="PID=722977\nName=tcp-echo`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, out)
+		}
+	}
+	// the clients, with a single edge, keep the default size
+	if got := strings.Count(out, "fontsize="); got != 3 {
+		t.Errorf("got %d sized nodes, want 3:\n%s", got, out)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"net_visualizer/netflow"
+)
+
+// renderedFormats lists the output formats that Graphviz lays out: the images, rendered
+// right away, and the DOT source, rendered later by the user
+var renderedFormats = []string{"dot", "svg", "png", "html"}
+
+// imageFormats lists the output formats that launch Graphviz
+var imageFormats = []string{"svg", "png", "html"}
+
+// sizeGuard protects from graphs too large for Graphviz, that may hang or exhaust the
+// memory of the renderer: above Nodes processes or Edges edges (0 = no limit) a warning
+// recommends ways of shrinking the graph, and the image formats are refused unless Force
+type sizeGuard struct {
+	Nodes int
+	Edges int
+	Force bool
+}
+
+// check warns on w about a model too large to be rendered in the format, before writing
+// it; the returned error refuses to launch Graphviz on it
+func (g sizeGuard) check(w io.Writer, model *netflow.Model, format string) error {
+	if !slices.Contains(renderedFormats, format) {
+		return nil
+	}
+	nodes, edges := len(model.Nodes), len(model.Edges)
+	if (g.Nodes == 0 || nodes <= g.Nodes) && (g.Edges == 0 || edges <= g.Edges) {
+		return nil
+	}
+	fmt.Fprintf(w, "WARNING: the graph has %d nodes and %d edges, beyond -warn-nodes %d or -warn-edges %d: Graphviz may take forever or run out of memory to lay it out. "+
+		"Shrink it with filters (e.g. -exclude-process, -dst-ports, -min-connections-per-node), -focus or -suppress-chatty, or use -layout sfdp\n", nodes, edges, g.Nodes, g.Edges)
+	if slices.Contains(imageFormats, format) && !g.Force {
+		return fmt.Errorf("refusing to render a graph of %d nodes and %d edges as %s: use -force to render it anyway, or -format dot", nodes, edges, format)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+func TestSizeGuard(t *testing.T) {
+	// 6 nodes and 4 edges
+	model := mustBuildModel(t, sampleTrace, netflow.ModelOptions{})
+	for _, tc := range []struct {
+		name    string
+		guard   sizeGuard
+		format  string
+		warn    bool
+		wantErr bool
+	}{
+		{"below the thresholds", sizeGuard{Nodes: 6, Edges: 4}, "svg", false, false},
+		{"no limits", sizeGuard{}, "svg", false, false},
+		{"too many nodes for dot", sizeGuard{Nodes: 5}, "dot", true, false},
+		{"too many edges for svg", sizeGuard{Edges: 3}, "svg", true, true},
+		{"forced png", sizeGuard{Nodes: 5, Force: true}, "png", true, false},
+		{"not laid out by Graphviz", sizeGuard{Nodes: 1, Edges: 1}, "json", false, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := tc.guard.check(&buf, model, tc.format)
+			if warned := strings.Contains(buf.String(), "WARNING: the graph has 6 nodes and 4 edges"); warned != tc.warn {
+				t.Errorf("warning = %q, want one: %v", buf.String(), tc.warn)
+			}
+			if (err != nil) != tc.wantErr {
+				t.Errorf("check returned %v, want an error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
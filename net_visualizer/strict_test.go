@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestStrictExit runs main in a child process, as -strict exits the program
+func TestStrictExit(t *testing.T) {
+	if runMainChild() {
+		return
+	}
+
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.trace")
+	bad := filepath.Join(dir, "bad.trace")
+	if err := os.WriteFile(good, []byte(sampleTrace), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bad, []byte(sampleTrace+"10.42.0.54:5671<-10.42.0.55|PID=723736 CMD=ncat\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run := func(args ...string) (int, string) {
+		return runMain(t, "TestStrictExit", args...)
+	}
+
+	if code, out := run("-strict", "-o", filepath.Join(dir, "good.dot"), good); code != 0 {
+		t.Errorf("-strict on a valid trace exited with %d:\n%s", code, out)
+	}
+	output := filepath.Join(dir, "bad.dot")
+	code, out := run("-strict", "-o", output, bad)
+	wantLine := strings.Count(sampleTrace, "\n") + 1
+	if code != exitError {
+		t.Errorf("-strict on an invalid line exited with %d, want %d:\n%s", code, exitError, out)
+	}
+	if want := bad + ":" + strconv.Itoa(wantLine) + `: cannot parse "10.42.0.54:5671<-10.42.0.55|PID=723736 CMD=ncat"`; !strings.Contains(out, want) {
+		t.Errorf("the error does not report %s:\n%s", want, out)
+	}
+	if _, err := os.Stat(output); err == nil {
+		t.Error("no graph must be written when -strict fails")
+	}
+	// the lines of a -tail are numbered from the start of the input
+	for _, args := range [][]string{{"-strict", "-tail", "2", "-o", output}, {"-validate", "-tail", "2"}} {
+		_, out := run(append(args, bad)...)
+		if want := bad + ":" + strconv.Itoa(wantLine) + ": "; !strings.Contains(out, want) {
+			t.Errorf("%v does not report %s:\n%s", args, want, out)
+		}
+	}
+	errorsFile := filepath.Join(dir, "errors.txt")
+	if code, out := run("-tail", "2", "-errors-file", errorsFile, "-o", output, bad); code != 0 {
+		t.Errorf("-errors-file with -tail exited with %d:\n%s", code, out)
+	}
+	if report, _ := os.ReadFile(errorsFile); !strings.HasPrefix(string(report), bad+":"+strconv.Itoa(wantLine)+": ") {
+		t.Errorf("-errors-file with -tail does not report the line %d of %s:\n%s", wantLine, bad, report)
+	}
+	// without -strict, the invalid line is skipped
+	if code, out := run("-o", output, bad); code != 0 {
+		t.Errorf("the invalid line should only be skipped without -strict, exit status %d:\n%s", code, out)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+)
+
+// tailChunkSize is the size of the blocks read backwards from the end of seekable inputs
+const tailChunkSize = 64 * 1024
+
+// tailInput returns a reader of the last n lines of an input, given its raw reader and
+// the decoded one returned by decompress, and the function counting the lines before
+// them. Plain seekable files are read backwards from their end, the lines before the tail
+// being counted only when asked for; the other inputs, e.g. pipes or compressed files,
+// are consumed in full, keeping only their last n lines.
+func tailInput(raw, decoded io.Reader, codec string, n int) (io.Reader, func() (int, error), error) {
+	if f, ok := raw.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok && codec == "" {
+		if size, err := f.Seek(0, io.SeekEnd); err == nil {
+			return tailSeekable(f, size, n)
+		}
+	}
+	return tailStream(decoded, n)
+}
+
+// tailSeekable returns a reader of the last n lines of the size bytes of r, and the
+// function counting the lines before them
+func tailSeekable(r io.ReaderAt, size int64, n int) (io.Reader, func() (int, error), error) {
+	buf := make([]byte, tailChunkSize)
+	newlines := 0
+	for pos := size; pos > 0; {
+		chunk := min(pos, tailChunkSize)
+		pos -= chunk
+		if _, err := r.ReadAt(buf[:chunk], pos); err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+		for i := chunk - 1; i >= 0; i-- {
+			// the newline terminating the last line does not start a line
+			if buf[i] != '\n' || pos+i == size-1 {
+				continue
+			}
+			if newlines++; newlines == n {
+				start := pos + i + 1
+				skipped := sync.OnceValues(func() (int, error) { return countLines(io.NewSectionReader(r, 0, start)) })
+				return io.NewSectionReader(r, start, size-start), skipped, nil
+			}
+		}
+	}
+	return io.NewSectionReader(r, 0, size), noLinesSkipped, nil
+}
+
+// countLines returns the number of newlines read from r
+func countLines(r io.Reader) (int, error) {
+	buf := make([]byte, tailChunkSize)
+	count := 0
+	for {
+		n, err := r.Read(buf)
+		count += bytes.Count(buf[:n], []byte{'\n'})
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+}
+
+func noLinesSkipped() (int, error) { return 0, nil }
+
+// tailStream consumes r, returning a reader of its last n lines, kept in a ring buffer,
+// and the function counting the lines before them
+func tailStream(r io.Reader, n int) (io.Reader, func() (int, error), error) {
+	ring := make([]string, 0, min(n, 1024))
+	next := 0
+	skipped := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if len(ring) < n {
+			ring = append(ring, scanner.Text())
+			continue
+		}
+		ring[next] = scanner.Text()
+		next = (next + 1) % n
+		skipped++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	var buf bytes.Buffer
+	for _, lines := range [][]string{ring[next:], ring[:next]} {
+		for _, line := range lines {
+			buf.WriteString(line)
+			buf.WriteByte('\n')
+		}
+	}
+	return &buf, func() (int, error) { return skipped, nil }, nil
+}
+
+// lineOffsets holds the function counting the lines skipped by -tail at the start of
+// each input, nil for the inputs read in full
+type lineOffsets []func() (int, error)
+
+// lineNum returns the number, from the start of the input, of the lineNum-th line read
+// from the source-th input. Should the skipped lines fail to be counted, the lines of
+// that input are numbered from the start of the tail, with a warning.
+func (o lineOffsets) lineNum(source, lineNum int) int {
+	if source >= len(o) || o[source] == nil {
+		return lineNum
+	}
+	skipped, err := o[source]()
+	if err != nil {
+		warnf("counting the lines before the -tail: %v; the lines of the input are numbered from the start of the tail", err)
+		o[source] = nil
+		return lineNum
+	}
+	return skipped + lineNum
+}
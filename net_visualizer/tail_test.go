@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"net_visualizer/netflow"
+)
+
+// streamOnly hides the io.Seeker of a reader, as for pipes
+type streamOnly struct{ io.Reader }
+
+func TestTailInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		n       int
+		want    string
+		skipped int
+	}{
+		{"last lines", "a\nb\nc\nd\n", 2, "c\nd\n", 2},
+		{"unterminated last line", "a\nb\nc", 2, "b\nc", 1},
+		{"fewer lines than n", "a\nb\n", 5, "a\nb\n", 0},
+		{"blank lines count", "a\n\nb\n", 2, "\nb\n", 1},
+		{"empty input", "", 3, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, skipped, err := tailInput(strings.NewReader(tt.input), nil, "", tt.n)
+			if err != nil {
+				t.Fatalf("tailInput returned unexpected error: %v", err)
+			}
+			if got, _ := io.ReadAll(r); string(got) != tt.want {
+				t.Errorf("seekable tail = %q, want %q", got, tt.want)
+			}
+			if got, err := skipped(); got != tt.skipped || err != nil {
+				t.Errorf("seekable tail skipped %d lines (err %v), want %d", got, err, tt.skipped)
+			}
+
+			in := streamOnly{strings.NewReader(tt.input)}
+			r, skipped, err = tailInput(in, in, "", tt.n)
+			if err != nil {
+				t.Fatalf("tailInput returned unexpected error: %v", err)
+			}
+			// the streamed lines are always newline-terminated
+			want := tt.want
+			if want != "" && !strings.HasSuffix(want, "\n") {
+				want += "\n"
+			}
+			if got, _ := io.ReadAll(r); string(got) != want {
+				t.Errorf("streamed tail = %q, want %q", got, want)
+			}
+			if got, err := skipped(); got != tt.skipped || err != nil {
+				t.Errorf("streamed tail skipped %d lines (err %v), want %d", got, err, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestTailInputAcrossChunks(t *testing.T) {
+	var input bytes.Buffer
+	for i := 0; input.Len() < 3*tailChunkSize; i++ {
+		fmt.Fprintf(&input, "line %d\n", i)
+	}
+	lines := strings.SplitAfter(input.String(), "\n")
+	lines = lines[:len(lines)-1]
+	n := len(lines) - 10
+
+	r, skipped, err := tailInput(bytes.NewReader(input.Bytes()), nil, "", n)
+	if err != nil {
+		t.Fatalf("tailInput returned unexpected error: %v", err)
+	}
+	if got, _ := io.ReadAll(r); string(got) != strings.Join(lines[10:], "") {
+		t.Errorf("tail does not start at line 10, got the first lines %q", strings.SplitN(string(got), "\n", 2)[0])
+	}
+	if got, err := skipped(); got != 10 || err != nil {
+		t.Errorf("the tail skipped %d lines (err %v), want 10", got, err)
+	}
+}
+
+func TestTailInputModel(t *testing.T) {
+	// the last 3 lines: a single connection, and the loopback one dropped anyway
+	in := streamOnly{strings.NewReader(sampleTrace)}
+	r, _, err := tailInput(in, in, "", 3)
+	if err != nil {
+		t.Fatalf("tailInput returned unexpected error: %v", err)
+	}
+	model, err := netflow.BuildModel(r, netflow.ModelOptions{})
+	if err != nil {
+		t.Fatalf("BuildModel returned unexpected error: %v", err)
+	}
+	if model.Stats.LinesRead != 3 {
+		t.Errorf("LinesRead = %d, want 3", model.Stats.LinesRead)
+	}
+	if len(model.Nodes) != 2 || len(model.Edges) != 1 {
+		t.Errorf("got %d nodes and %d edges, want 2 and 1", len(model.Nodes), len(model.Edges))
+	}
+	for e := range model.Edges {
+		if e.Source.PID != 723801 || e.Dest.PID != 723429 {
+			t.Errorf("got edge %+v, want the one from 723801 to 723429", e)
+		}
+	}
+}
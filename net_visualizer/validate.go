@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"net_visualizer/netflow"
+)
+
+// maxReportedFailures caps the failing lines printed by -validate
+const maxReportedFailures = 10
+
+// ValidationFailure describes an input line that -validate rejected
+type ValidationFailure struct {
+	Source  int // index of the input the line comes from
+	LineNum int // 1-based line number within that input
+	Line    string
+	Reason  string
+}
+
+// ValidationResult summarizes the outcome of -validate
+type ValidationResult struct {
+	Lines    int                 // lines checked, excluding blank and banner lines
+	Failed   int                 // lines that failed
+	Failures []ValidationFailure // the first maxReportedFailures failures
+}
+
+// validateLine checks the fields of a parsed line for values the tracer can never emit;
+// the endpoints are IPs or, for some tracer builds, hostnames
+func validateLine(line netflow.InputLine) error {
+	for _, host := range []string{line.RemoteIP, line.LocalIP} {
+		if net.ParseIP(host) == nil && !validHostname(host) {
+			return fmt.Errorf("invalid IP address or hostname %q", host)
+		}
+	}
+	if line.ProcessID <= 0 {
+		return fmt.Errorf("invalid PID %d", line.ProcessID)
+	}
+	return nil
+}
+
+// validHostname tells whether s is a syntactically valid hostname, as of RFC 1123: dot
+// separated labels of letters, digits and hyphens, not starting or ending with a hyphen
+func validHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if c != '-' && !('0' <= c && c <= '9') && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validateInput checks that every line of the inputs can be parsed and carries sensible
+// values, without building any graph. Blank lines and tracer banners are skipped.
+func validateInput(sources []io.Reader, parser *netflow.LineParser) (ValidationResult, error) {
+	var res ValidationResult
+	for src, r := range sources {
+		scanner := bufio.NewScanner(r)
+		lineNum := 0
+		for scanner.Scan() {
+			lineNum++
+			line := scanner.Text()
+			if netflow.IsBannerLine(line) {
+				continue
+			}
+			res.Lines++
+
+			reason := ""
+			if parsed, err := parser.Parse(line); err != nil {
+				reason = "does not match the expected format"
+				var parseErr *netflow.ParseError
+				if errors.As(err, &parseErr) {
+					reason = parseErr.Reason.String()
+				}
+			} else if err := validateLine(parsed); err != nil {
+				reason = err.Error()
+			}
+			if reason == "" {
+				continue
+			}
+			res.Failed++
+			if len(res.Failures) < maxReportedFailures {
+				res.Failures = append(res.Failures, ValidationFailure{Source: src, LineNum: lineNum, Line: line, Reason: reason})
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return res, fmt.Errorf("error reading input after %d lines: %w", lineNum, err)
+		}
+	}
+	return res, nil
+}
+
+// Print writes the validation summary, including the first failing lines
+func (res ValidationResult) Print(w io.Writer, sourceNames []string) {
+	fmt.Fprintf(w, "Validated %d lines: %d failed\n", res.Lines, res.Failed)
+	for _, f := range res.Failures {
+		fmt.Fprintf(w, "  %s:%d: %s: %q\n", sourceNames[f.Source], f.LineNum, f.Reason, f.Line)
+	}
+	if res.Failed > len(res.Failures) {
+		fmt.Fprintf(w, "  ... and %d more\n", res.Failed-len(res.Failures))
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestValidateInput(t *testing.T) {
+	res, err := validateInput([]io.Reader{strings.NewReader(sampleTrace + "\nExiting TCPv4 traffic monitor.\n")}, nil)
+	if err != nil {
+		t.Fatalf("validateInput returned unexpected error: %v", err)
+	}
+	if res.Lines != 9 || res.Failed != 0 {
+		t.Errorf("got %d lines, %d failed; want 9 lines, 0 failed (banners and blank lines skipped)", res.Lines, res.Failed)
+	}
+
+	bad := `10.42.0.54:5671<-10.42.0.55:5672|PID=723736 CMD=ncat
+garbage
+10.42.0.54:70000<-10.42.0.55:5672|PID=723736 CMD=ncat
+not_a_host:5671<-10.42.0.55:5672|PID=723736 CMD=ncat
+10.42.0.54:5671<-10.42.0.55:5672|PID=0 CMD=ncat
+`
+	res, err = validateInput([]io.Reader{strings.NewReader(sampleTrace), strings.NewReader(bad)}, nil)
+	if err != nil {
+		t.Fatalf("validateInput returned unexpected error: %v", err)
+	}
+	if res.Failed != 4 {
+		t.Fatalf("Failed = %d, want 4", res.Failed)
+	}
+	wantLines := []int{2, 3, 4, 5}
+	for i, f := range res.Failures {
+		if f.Source != 1 || f.LineNum != wantLines[i] {
+			t.Errorf("failure %d at source %d line %d, want source 1 line %d", i, f.Source, f.LineNum, wantLines[i])
+		}
+	}
+
+	var out bytes.Buffer
+	res.Print(&out, []string{"good.trace", "bad.trace"})
+	for _, want := range []string{
+		"Validated 14 lines: 4 failed",
+		`bad.trace:2: does not match the expected format: "garbage"`,
+		"bad.trace:3: port or PID out of range",
+		`bad.trace:4: invalid IP address or hostname "not_a_host"`,
+		"bad.trace:5: invalid PID 0",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("summary does not contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestValidateInputHostnames(t *testing.T) {
+	// some tracer builds report hostnames, kept as-is by the normal runs
+	input := `db.internal:5432<-10.0.0.2:5000|PID=7 CMD=a
+10.0.0.2:5000->db-1.internal.:5432|PID=8 CMD=b
+-db.internal:5432<-10.0.0.2:5000|PID=7 CMD=a
+db..internal:5432<-10.0.0.2:5000|PID=7 CMD=a
+`
+	res, err := validateInput([]io.Reader{strings.NewReader(input)}, nil)
+	if err != nil {
+		t.Fatalf("validateInput returned unexpected error: %v", err)
+	}
+	if res.Lines != 4 || res.Failed != 2 {
+		t.Fatalf("got %d lines, %d failed; want 4 lines, 2 failed", res.Lines, res.Failed)
+	}
+	for i, f := range res.Failures {
+		if f.LineNum != i+3 {
+			t.Errorf("failure %d at line %d, want line %d: %s", i, f.LineNum, i+3, f.Reason)
+		}
+	}
+}
+
+func TestValidateInputCapsReportedFailures(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < maxReportedFailures+5; i++ {
+		fmt.Fprintf(&input, "garbage %d\n", i)
+	}
+	res, err := validateInput([]io.Reader{strings.NewReader(input.String())}, nil)
+	if err != nil {
+		t.Fatalf("validateInput returned unexpected error: %v", err)
+	}
+	if res.Failed != maxReportedFailures+5 || len(res.Failures) != maxReportedFailures {
+		t.Errorf("got %d failed and %d reported, want %d and %d", res.Failed, len(res.Failures), maxReportedFailures+5, maxReportedFailures)
+	}
+
+	var out bytes.Buffer
+	res.Print(&out, []string{"stdin"})
+	if !strings.Contains(out.String(), "... and 5 more") {
+		t.Errorf("summary does not mention the unreported failures:\n%s", out.String())
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"net_visualizer/netflow"
+)
+
+// consumeAsync feeds the sources to the builder in the background; the returned channel
+// yields the first read error, or nil, once the sources are exhausted
+func consumeAsync(b *netflow.Builder, sources []io.Reader) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- b.ConsumeAll(sources)
+	}()
+	return done
+}
+
+// interruptContext returns a context cancelled on SIGINT or SIGTERM, so that the graph
+// collected so far can still be written out. Only the first signal is caught: a second
+// Ctrl-C terminates the program as usual, should the final write get stuck.
+func interruptContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// consumeSources feeds the sources to the builder until they are exhausted, returning the
+// first read error, or until ctx is done, returning ctx.Err(). In the latter case the
+// sources keep being consumed in the background: the model must be accessed through
+// b.WithModel.
+func consumeSources(ctx context.Context, b *netflow.Builder, sources []io.Reader) error {
+	select {
+	case err := <-consumeAsync(b, sources):
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runWatch consumes the sources in the background and calls emit with the model built so
+// far every interval, so that the output follows a live tracer. A last emit happens once
+// the input is exhausted or ctx is cancelled (e.g. on SIGINT); an input error is
+// returned only after that last emit.
+func runWatch(ctx context.Context, b *netflow.Builder, sources []io.Reader, interval time.Duration, emit func(*netflow.Model) error) error {
+	done := consumeAsync(b, sources)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := b.WithModel(emit); err != nil {
+				return err
+			}
+		case inputErr := <-done:
+			if err := b.WithModel(emit); err != nil {
+				return err
+			}
+			if inputErr != nil {
+				return fmt.Errorf("%w: the topology is incomplete", inputErr)
+			}
+			return nil
+		case <-ctx.Done():
+			return b.WithModel(emit)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"net_visualizer/netflow"
+)
+
+// failingReader yields its data and then fails, like a broken pipe would
+type failingReader struct {
+	data io.Reader
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		return n, r.err
+	}
+	return n, err
+}
+
+func TestRunWatch(t *testing.T) {
+	pr, pw := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	emitted := make(chan int, 100)
+	emit := func(model *netflow.Model) error {
+		emitted <- len(model.Edges)
+		return nil
+	}
+	result := make(chan error, 1)
+	go func() {
+		result <- runWatch(ctx, netflow.NewBuilder(netflow.ModelOptions{}), []io.Reader{pr}, 10*time.Millisecond, emit)
+	}()
+
+	// the input is still open: the graph must be re-emitted as the edges show up
+	if _, err := io.WriteString(pw, sampleTrace); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.After(5 * time.Second)
+	for edges := 0; edges != 4; {
+		select {
+		case edges = <-emitted:
+		case <-deadline:
+			t.Fatalf("periodic emit never reported the 4 edges of the sample trace")
+		}
+	}
+
+	// on interrupt one last graph is flushed
+	cancel()
+	if err := <-result; err != nil {
+		t.Fatalf("runWatch returned unexpected error: %v", err)
+	}
+	var last int
+	for len(emitted) > 0 {
+		last = <-emitted
+	}
+	if last != 4 {
+		t.Errorf("final emit reported %d edges, want 4", last)
+	}
+	pw.Close()
+}
+
+func TestRunWatchEndOfInput(t *testing.T) {
+	var emits, edges int
+	emit := func(model *netflow.Model) error {
+		emits++
+		edges = len(model.Edges)
+		return nil
+	}
+	err := runWatch(context.Background(), netflow.NewBuilder(netflow.ModelOptions{}), []io.Reader{strings.NewReader(sampleTrace)}, time.Hour, emit)
+	if err != nil {
+		t.Fatalf("runWatch returned unexpected error: %v", err)
+	}
+	if emits != 1 || edges != 4 {
+		t.Errorf("got %d emits with %d edges, want a single final emit with 4 edges", emits, edges)
+	}
+}
+
+func TestRunWatchReadError(t *testing.T) {
+	readErr := errors.New("broken pipe")
+	var edges int
+	emit := func(model *netflow.Model) error {
+		edges = len(model.Edges)
+		return nil
+	}
+	r := &failingReader{data: strings.NewReader(sampleTrace), err: readErr}
+	err := runWatch(context.Background(), netflow.NewBuilder(netflow.ModelOptions{}), []io.Reader{r}, time.Hour, emit)
+	if !errors.Is(err, readErr) {
+		t.Fatalf("runWatch error = %v, want %v", err, readErr)
+	}
+	if edges != 4 {
+		t.Errorf("the partial graph must still be emitted, got %d edges, want 4", edges)
+	}
+}
+
+func TestConsumeSourcesDeadline(t *testing.T) {
+	// the input stays open, as a live tracer's would
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go io.WriteString(pw, sampleTrace)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	b := netflow.NewBuilder(netflow.ModelOptions{})
+	start := time.Now()
+	if err := consumeSources(ctx, b, []io.Reader{pr}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("consumeSources returned %v, want the deadline error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("consumeSources returned after %s", elapsed)
+	}
+	b.WithModel(func(model *netflow.Model) error {
+		if len(model.Edges) != 4 {
+			t.Errorf("got %d edges, want the 4 edges read before the deadline", len(model.Edges))
+		}
+		return nil
+	})
+
+	// without deadline, exhausted sources end the consumption
+	b = netflow.NewBuilder(netflow.ModelOptions{})
+	if err := consumeSources(context.Background(), b, []io.Reader{strings.NewReader(sampleTrace)}); err != nil {
+		t.Fatalf("consumeSources returned unexpected error: %v", err)
+	}
+}
+
+func TestConsumeSourcesInterrupt(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	ctx, stop := interruptContext(context.Background())
+	defer stop()
+	b := netflow.NewBuilder(netflow.ModelOptions{})
+
+	// the signal arrives mid-stream, once the trace is built but with the input still open
+	go func() {
+		io.WriteString(pw, sampleTrace)
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			var edges int
+			b.WithModel(func(model *netflow.Model) error {
+				edges = len(model.Edges)
+				return nil
+			})
+			if edges == 4 {
+				break
+			}
+		}
+		syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	}()
+
+	if err := consumeSources(ctx, b, []io.Reader{pr}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("consumeSources returned %v, want the cancellation error", err)
+	}
+	b.WithModel(func(model *netflow.Model) error {
+		if len(model.Edges) != 4 {
+			t.Errorf("got %d edges, want the 4 edges read before the interruption", len(model.Edges))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"net_visualizer/netflow"
+)
+
+// the metrics that -weight-by can draw the edges after
+const (
+	weightByCount   = "count"
+	weightByBytes   = "bytes"
+	weightByPackets = "packets"
+)
+
+var validWeightMetrics = []string{weightByCount, weightByBytes, weightByPackets}
+
+// edgeVolume returns the traffic volume of an edge in the WeightBy metric, or -1 when
+// the edges are weighted by their number of observations
+func edgeVolume(info *netflow.EdgeInfo, weightBy string) int64 {
+	switch weightBy {
+	case weightByBytes:
+		return info.Bytes
+	case weightByPackets:
+		return info.Packets
+	default:
+		return -1
+	}
+}
+
+// maxEdgeVolume returns the largest traffic volume of the edges of the model
+func maxEdgeVolume(model *netflow.Model, weightBy string) int64 {
+	var ret int64
+	for _, info := range model.Edges {
+		ret = max(ret, edgeVolume(info, weightBy))
+	}
+	return ret
+}
+
+// volumeWidth returns the Graphviz penwidth of an edge carrying volume, growing with its
+// logarithm up to maxPenWidth for the busiest edge of the graph, or "" for the default
+// width. Unlike the counts, volumes span many orders of magnitude: they are scaled
+// against the largest one rather than capped.
+func volumeWidth(volume, maxVolume int64) string {
+	if volume <= 1 || maxVolume <= 1 {
+		return ""
+	}
+	width := 1 + (maxPenWidth-1)*math.Log(float64(volume))/math.Log(float64(maxVolume))
+	return strconv.FormatFloat(math.Round(width*100)/100, 'f', -1, 64)
+}
+
+// formatVolume returns the human-readable traffic volume shown in edge labels, e.g.
+// "1.5 KiB" or "42 packets"
+func formatVolume(volume int64, weightBy string) string {
+	if weightBy == weightByPackets {
+		if volume == 1 {
+			return "1 packet"
+		}
+		return fmt.Sprintf("%d packets", volume)
+	}
+	if volume < 1024 {
+		return fmt.Sprintf("%d B", volume)
+	}
+	v := float64(volume)
+	unit := 0
+	for v >= 1024 && unit < len(byteUnits)-1 {
+		v /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", v, byteUnits[unit])
+}
+
+var byteUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}